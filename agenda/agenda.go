@@ -0,0 +1,33 @@
+/**
+ * file: agenda/agenda.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains what the renderings of the
+ * agendas of council meetings have in common.
+ */
+
+package agenda
+
+import (
+	"fmt"
+	"strings"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// Filename returns the name of the file of the agenda of the
+// meeting, without its extension.
+func Filename(m *models.Meeting) string {
+	return fmt.Sprintf("meeting-%d-%s", m.ID, m.StartsAt.UTC().Format("2006-01-02"))
+}
+
+// scopeName returns the course or faculty the meeting is about.
+func scopeName(m *models.Meeting) string {
+	return fmt.Sprintf("%s %s", m.Scope, strings.ToUpper(m.Code))
+}
+
+// oneLine joins the lines of the text with spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,99 @@
+/**
+ * file: agenda/agenda_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package agenda
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMeeting returns a meeting of the LINFO faculty council, with
+// two items on its agenda, the first one decided.
+func newTestMeeting() *models.Meeting {
+	start := time.Date(2025, 3, 12, 14, 0, 0, 0, time.UTC)
+	decided := start.Add(time.Hour)
+	return &models.Meeting{
+		ID:        7,
+		Body:      "LINFO faculty council",
+		Scope:     models.StatsScopeFaculty,
+		Code:      "linfo",
+		StartsAt:  start,
+		EndsAt:    start.Add(2 * time.Hour),
+		Location:  "Room A.10, Réaumur building",
+		UpdatedAt: start.AddDate(0, 0, -1),
+		Items: []*models.MeetingItem{
+			{
+				FeedbackID: 1,
+				Position:   1,
+				Notes:      "Ask for *two* more sessions",
+				Outcome:    models.FeedbackStatusResolved,
+				Response:   "Two sessions were added.",
+				DecidedAt:  &decided,
+				Feedback:   &models.Feedback{ID: 1, Course: "LINFO1101", Feedback: "The exercise sessions are\nfar too short.", Upvotes: 12, Downvotes: 2, Status: models.FeedbackStatusResolved},
+			},
+			{
+				FeedbackID: 2,
+				Position:   2,
+				Feedback:   &models.Feedback{ID: 2, Course: "LINFO1252", Feedback: "The slides are published <too late>, and [linked](here).", Upvotes: 3, Status: models.FeedbackStatusNew},
+			},
+		},
+	}
+}
+
+// TestWriteMarkdown tests that agendas list their items in order,
+// with user text escaped.
+func TestWriteMarkdown(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, WriteMarkdown(&b, newTestMeeting()))
+	md := b.String()
+
+	assert.True(t, strings.HasPrefix(md, "# LINFO faculty council, 2025-03-12\n"), "the title should be the body and the day")
+	assert.Contains(t, md, "- **When:** 2025-03-12 14:00 to 2025-03-12 16:00 UTC")
+	assert.Contains(t, md, "- **About:** faculty LINFO")
+	assert.Contains(t, md, "1. **LINFO1101** (+10, resolved): The exercise sessions are far too short.")
+	assert.Contains(t, md, "*Notes:* Ask for \\*two\\* more sessions")
+	assert.Contains(t, md, "*Outcome:* resolved. Two sessions were added.")
+	assert.Contains(t, md, `2. **LINFO1252** (+3, new): The slides are published \<too late\>, and \[linked\](here).`)
+	assert.Less(t, strings.Index(md, "LINFO1101"), strings.Index(md, "LINFO1252"), "the items should be in order")
+}
+
+// TestWriteICS tests that meetings are written as RFC 5545 events,
+// with escaped text and folded lines.
+func TestWriteICS(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, WriteICS(&b, newTestMeeting()))
+	ics := b.String()
+
+	require.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"), "the calendar should be terminated")
+	lines := strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n")
+	for _, l := range lines {
+		assert.LessOrEqual(t, len(l), 75, "the line %q should be folded", l)
+		assert.True(t, utf8.ValidString(l), "the line %q should not split characters", l)
+		assert.NotContains(t, l, "\n", "lines should end with CRLF")
+	}
+
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	assert.Contains(t, unfolded, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n")
+	assert.Contains(t, unfolded, "\r\nUID:meeting-7@delegit\r\n")
+	assert.Contains(t, unfolded, "\r\nDTSTAMP:20250311T140000Z\r\n")
+	assert.Contains(t, unfolded, "\r\nDTSTART:20250312T140000Z\r\nDTEND:20250312T160000Z\r\n")
+	assert.Contains(t, unfolded, "\r\nLOCATION:Room A.10\\, Réaumur building\r\n")
+	assert.Contains(t, unfolded, `\n\n1. LINFO1101 (+10): The exercise sessions are far too short.\n   Outcome: resolved. Two sessions were added.`)
+	assert.NotContains(t, unfolded, "Ask for", "the notes should be left out")
+
+	// Calendars hold one event per meeting.
+	b.Reset()
+	require.NoError(t, WriteICS(&b, newTestMeeting(), newTestMeeting()))
+	assert.Equal(t, 2, strings.Count(b.String(), "BEGIN:VEVENT"))
+}
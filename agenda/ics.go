@@ -0,0 +1,122 @@
+/**
+ * file: agenda/ics.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file renders meetings as iCalendar events, as
+ * specified by RFC 5545, so that representatives can
+ * add them to their calendars.
+ */
+
+package agenda
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+const (
+	// icsLineLength is the maximum length of the lines of an
+	// iCalendar object, in octets, without the line break.
+	icsLineLength int = 75
+
+	icsTimeFormat string = "20060102T150405Z"
+)
+
+// icsEscaper escapes the characters of TEXT values.
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// icsWriter writes the content lines of an iCalendar object.
+type icsWriter struct {
+	b *bufio.Writer
+}
+
+// line writes the content line, folded at icsLineLength octets
+// without splitting characters.
+func (w icsWriter) line(name, value string) {
+	l := name + ":" + value
+	limit := icsLineLength
+	for len(l) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(l[cut]) {
+			cut--
+		}
+
+		w.b.WriteString(l[:cut])
+		w.b.WriteString("\r\n ")
+		l = l[cut:]
+		// The leading space counts towards the length of the
+		// continuation lines.
+		limit = icsLineLength - 1
+	}
+	w.b.WriteString(l)
+	w.b.WriteString("\r\n")
+}
+
+// text writes the content line of the TEXT value.
+func (w icsWriter) text(name, value string) {
+	w.line(name, icsEscaper.Replace(value))
+}
+
+// time writes the content line of the UTC date-time value.
+func (w icsWriter) time(name string, t time.Time) {
+	w.line(name, t.UTC().Format(icsTimeFormat))
+}
+
+// description returns the description of the event of the meeting:
+// the feedback on its agenda, and the outcomes recorded. The notes of
+// the representatives are left out, as events are shared beyond
+// them.
+func description(m *models.Meeting) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Agenda of the meeting on the %s.\n", scopeName(m))
+	for i, item := range m.Items {
+		f := item.Feedback
+		if f == nil {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n%d. %s (%+d): %s", i+1, f.Course, f.Score(), oneLine(f.Feedback))
+		if item.Outcome != "" {
+			fmt.Fprintf(&b, "\n   Outcome: %s. %s", item.Outcome, oneLine(item.Response))
+		}
+	}
+	return b.String()
+}
+
+// WriteICS writes the meetings as the events of an iCalendar object.
+// Events are identified by the ID of their meeting, and stamped with
+// its last update, so that calendars refresh them as their agenda
+// changes.
+func WriteICS(w io.Writer, meetings ...*models.Meeting) error {
+	ics := icsWriter{b: bufio.NewWriter(w)}
+
+	ics.line("BEGIN", "VCALENDAR")
+	ics.line("VERSION", "2.0")
+	ics.line("PRODID", "-//delegit//meetings//EN")
+	ics.line("CALSCALE", "GREGORIAN")
+	ics.line("METHOD", "PUBLISH")
+	for _, m := range meetings {
+		ics.line("BEGIN", "VEVENT")
+		ics.line("UID", fmt.Sprintf("meeting-%d@delegit", m.ID))
+		ics.time("DTSTAMP", m.UpdatedAt)
+		ics.time("DTSTART", m.StartsAt)
+		ics.time("DTEND", m.EndsAt)
+		ics.text("SUMMARY", m.Body)
+		if m.Location != "" {
+			ics.text("LOCATION", m.Location)
+		}
+		ics.text("DESCRIPTION", description(m))
+		ics.line("STATUS", "CONFIRMED")
+		ics.line("END", "VEVENT")
+	}
+	ics.line("END", "VCALENDAR")
+
+	return ics.b.Flush()
+}
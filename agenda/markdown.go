@@ -0,0 +1,71 @@
+/**
+ * file: agenda/markdown.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file renders the agenda of a meeting as a
+ * Markdown document, along with the notes of the
+ * representatives and the outcomes recorded.
+ */
+
+package agenda
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// markdownEscaper escapes the characters of user text that Markdown
+// would interpret. User text never starts a line, so that the
+// characters only interpreted there are left as they are.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "~", `\~`, "[", `\[`,
+	"]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+// markdownText escapes the text as a single Markdown paragraph.
+func markdownText(s string) string {
+	return markdownEscaper.Replace(oneLine(s))
+}
+
+// WriteMarkdown writes the agenda of the meeting as a Markdown
+// document. User text is escaped, so that it is rendered as it was
+// written.
+func WriteMarkdown(w io.Writer, m *models.Meeting) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "# %s, %s\n\n", markdownText(m.Body), m.StartsAt.UTC().Format("2006-01-02"))
+	fmt.Fprintf(b, "- **When:** %s to %s UTC\n", m.StartsAt.UTC().Format("2006-01-02 15:04"), m.EndsAt.UTC().Format("2006-01-02 15:04"))
+	if m.Location != "" {
+		fmt.Fprintf(b, "- **Where:** %s\n", markdownText(m.Location))
+	}
+	fmt.Fprintf(b, "- **About:** %s\n", markdownText(scopeName(m)))
+	if m.HeldAt != nil {
+		fmt.Fprintf(b, "- **Held:** %s UTC\n", m.HeldAt.UTC().Format("2006-01-02 15:04"))
+	}
+
+	b.WriteString("\n## Agenda\n")
+	if len(m.Items) == 0 {
+		b.WriteString("\nThere is no feedback on the agenda.\n")
+	}
+	for i, item := range m.Items {
+		f := item.Feedback
+		if f == nil {
+			continue
+		}
+
+		fmt.Fprintf(b, "\n%d. **%s** (%+d, %s): %s\n", i+1, markdownText(f.Course), f.Score(), f.Status, markdownText(f.Feedback))
+		if item.Notes != "" {
+			fmt.Fprintf(b, "\n   *Notes:* %s\n", markdownText(item.Notes))
+		}
+		if item.Outcome != "" {
+			fmt.Fprintf(b, "\n   *Outcome:* %s. %s\n", item.Outcome, markdownText(item.Response))
+		}
+	}
+
+	return b.Flush()
+}
@@ -0,0 +1,78 @@
+/**
+ * file: database/alert.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the alert database logic for
+ * the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (db *Database) AddAlertRule(r *models.AlertRule) (*models.AlertRule, error) {
+	if res := db.db.Create(r); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return r, nil
+}
+
+func (db *Database) GetAlertRules() (r []*models.AlertRule, err error) {
+	err = db.db.Order("id").Find(&r).Error
+	return
+}
+
+// DeleteAlertRule deletes the rule along with the alerts it fired.
+func (db *Database) DeleteAlertRule(id uint) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Where("rule_id = ?", id).Delete(&models.Alert{}); r.Error != nil {
+			return r.Error
+		}
+
+		r := tx.Delete(&models.AlertRule{}, id)
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// AddAlert records the alert, unless its rule already fired on the
+// feedback. It returns true if the alert was recorded.
+func (db *Database) AddAlert(a *models.Alert) (bool, error) {
+	r := db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if r.Error != nil {
+		return false, r.Error
+	}
+
+	return r.RowsAffected == 1, nil
+}
+
+// GetAlerts returns at most limit alerts, latest first.
+func (db *Database) GetAlerts(limit int) (a []*models.Alert, err error) {
+	err = db.db.Order("id DESC").Limit(limit).Find(&a).Error
+	return
+}
+
+// GetNetScoreSince returns the upvotes minus the downvotes cast on
+// the feedback since the given time. Quarantined votes are not
+// counted.
+func (db *Database) GetNetScoreSince(feedbackID uint, since time.Time) (score int64, err error) {
+	err = db.db.Model(&models.Vote{}).
+		Select("COALESCE(SUM(CASE WHEN kind = ? THEN delta ELSE -delta END), 0)", models.VoteKindUpvote).
+		Where("feedback_id = ? AND created_at >= ?", feedbackID, since).
+		Where("quarantined = ?", false).
+		Scan(&score).Error
+	return
+}
@@ -0,0 +1,62 @@
+/**
+ * file: database/alert_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the alert persistence.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetNetScoreSince tests that the net score only counts the
+// votes cast within the window, outside of quarantine.
+func TestGetNetScoreSince(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	since := time.Now().Add(-48 * time.Hour)
+	mock.
+		ExpectQuery("^SELECT COALESCE\\(SUM\\(CASE WHEN kind = .* THEN delta ELSE -delta END\\), 0\\) FROM [`\"']votes[`\"'] WHERE \\(feedback_id = .* AND created_at >= .*\\) AND quarantined = .*$").
+		WithArgs(models.VoteKindUpvote, 42, since, false).
+		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(-3))
+
+	score, err := db.GetNetScoreSince(42, since)
+	assert.NoError(t, err, "getting the net score should not return an error")
+	assert.Equal(t, int64(-3), score)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestAddAlert tests that a rule fires only once per feedback.
+func TestAddAlert(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	for _, fired := range []bool{true, false} {
+		rows := sqlmock.NewRows([]string{"id"})
+		if fired {
+			rows.AddRow(1)
+		}
+
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^INSERT INTO [`\"']alerts[`\"'] .* ON CONFLICT DO NOTHING RETURNING [`\"']id[`\"']$").
+			WithArgs(3, 42, 0.9, sqlmock.AnyArg()).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		added, err := db.AddAlert(&models.Alert{RuleID: 3, FeedbackID: 42, Value: 0.9})
+		assert.NoError(t, err, "adding the alert should not return an error")
+		assert.Equal(t, fired, added, "only the first alert should be added")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,105 @@
+/**
+ * file: database/change.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the change feed database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"encoding/json"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+)
+
+// recordChange records a change of the given kind on the feedback,
+// as it is after the change, in the transaction of the change.
+// Tombstones clear the snapshots of the earlier changes of the
+// feedback, so that deleted content is no longer served.
+func recordChange(tx *gorm.DB, kind models.ChangeKind, f *models.Feedback) error {
+	c := &models.Change{FeedbackID: f.ID, Kind: kind, Version: f.Version}
+	if c.Tombstone() {
+		r := tx.Model(&models.Change{}).
+			Where("feedback_id = ?", f.ID).
+			Update("snapshot", nil)
+		if r.Error != nil {
+			return r.Error
+		}
+	} else {
+		snapshot, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		c.Snapshot = snapshot
+	}
+
+	return tx.Create(c).Error
+}
+
+// Sequence numbers are allocated when inserting, but become visible
+// when committing: on PostgreSQL, a change may be committed after one
+// with a greater sequence number, which readers would then skip.
+// Changes are thus fed in the order of the transactions which made
+// them, recorded in xact_id, and only once all earlier transactions
+// are over, that is below the oldest transaction still running.
+// SQLite serializes writers, so changes are committed in order.
+const (
+	changeHorizon string = "xact_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint"
+	changeOrder   string = "xact_id, seq"
+)
+
+// GetChangesSince returns at most limit changes made after the
+// change with the given sequence number, in order.
+func (db *Database) GetChangesSince(since uint64, limit int) (c []*models.Change, err error) {
+	if db.kind != "pgsql" {
+		err = db.db.
+			Where("seq > ?", since).
+			Order("seq").
+			Limit(limit).
+			Find(&c).Error
+		return
+	}
+
+	q := db.db.Where(changeHorizon)
+	if since != 0 {
+		var xact []int64
+		r := db.db.Model(&models.Change{}).Where("seq = ?", since).Pluck("xact_id", &xact)
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if len(xact) == 1 {
+			q = q.Where("(xact_id, seq) > (?, ?)", xact[0], since)
+		} else {
+			q = q.Where("seq > ?", since)
+		}
+	}
+
+	err = q.Order(changeOrder).Limit(limit).Find(&c).Error
+	return
+}
+
+// GetLatestChangeSeq returns the sequence number of the latest
+// change fed to readers, or 0 if there are none.
+func (db *Database) GetLatestChangeSeq() (seq uint64, err error) {
+	if db.kind != "pgsql" {
+		err = db.db.Model(&models.Change{}).
+			Select("COALESCE(MAX(seq), 0)").
+			Scan(&seq).Error
+		return
+	}
+
+	var latest []uint64
+	err = db.db.Model(&models.Change{}).
+		Where(changeHorizon).
+		Order("xact_id DESC, seq DESC").
+		Limit(1).
+		Pluck("seq", &latest).Error
+	if err == nil && len(latest) == 1 {
+		seq = latest[0]
+	}
+	return
+}
@@ -0,0 +1,101 @@
+/**
+ * file: database/change_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the change feed.
+ */
+
+package database
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// expectChange expects a change of the given kind to be recorded on
+// the feedback identified by id, with the given version. No other
+// statement, such as a lock, is expected.
+func expectChange(mock sqlmock.Sqlmock, kind models.ChangeKind, id uint, version driver.Value) {
+	var snapshot driver.Value = sqlmock.AnyArg()
+	if kind == models.ChangeKindDelete || kind == models.ChangeKindPurge {
+		mock.
+			ExpectExec("^UPDATE [`\"']changes[`\"'] SET [`\"']snapshot[`\"']=.* WHERE feedback_id = .*$").
+			WithArgs(nil, id).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		snapshot = []byte(nil)
+	}
+
+	mock.
+		ExpectQuery("^INSERT INTO [`\"']changes[`\"'] .* RETURNING [`\"']seq[`\"']$").
+		WithArgs(id, kind, version, snapshot, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"seq"}).AddRow(1))
+}
+
+// TestRecordChangeWithoutLock is a unit test that tests that
+// recording a change does not lock the change feed, which would
+// serialize all writes.
+func TestRecordChangeWithoutLock(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	expectChange(mock, models.ChangeKindVote, 1, 3)
+	mock.ExpectCommit()
+
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		return recordChange(tx, models.ChangeKindVote, &models.Feedback{ID: 1, Version: 3})
+	})
+	assert.NoError(t, err, "recording a change should not return an error")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetChangesSince is a unit test that tests that changes are
+// returned in the order of their transactions, after the given
+// sequence number, once no earlier transaction is running.
+func TestGetChangesSince(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.
+		ExpectQuery("^SELECT [`\"']?xact_id[`\"']? FROM [`\"']changes[`\"'] WHERE seq = .*$").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"xact_id"}).AddRow(700))
+	rows := sqlmock.NewRows([]string{"seq", "feedback_id", "kind", "version", "snapshot"}).
+		AddRow(44, 1, models.ChangeKindVote, 3, []byte(`{"ID":1}`)).
+		AddRow(43, 2, models.ChangeKindDelete, 2, nil)
+	mock.
+		ExpectQuery("^SELECT .+ FROM [`\"']changes[`\"'] WHERE xact_id < pg_snapshot_xmin\\(pg_current_snapshot\\(\\)\\)::text::bigint AND \\(xact_id, seq\\) > \\(.*, .*\\) ORDER BY xact_id, seq LIMIT .*$").
+		WithArgs(700, 42, 2).
+		WillReturnRows(rows)
+
+	changes, err := db.GetChangesSince(42, 2)
+	assert.NoError(t, err, "getting changes should not return an error")
+	assert.Equal(t, []*models.Change{
+		{Seq: 44, FeedbackID: 1, Kind: models.ChangeKindVote, Version: 3, Snapshot: []byte(`{"ID":1}`)},
+		{Seq: 43, FeedbackID: 2, Kind: models.ChangeKindDelete, Version: 2},
+	}, changes, "changes should be returned in order")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetLatestChangeSeq is a unit test that tests that the latest
+// change is the last one fed to readers.
+func TestGetLatestChangeSeq(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.
+		ExpectQuery("^SELECT [`\"']?seq[`\"']? FROM [`\"']changes[`\"'] WHERE xact_id < pg_snapshot_xmin\\(pg_current_snapshot\\(\\)\\)::text::bigint ORDER BY xact_id DESC, seq DESC LIMIT .*$").
+		WillReturnRows(sqlmock.NewRows([]string{"seq"}).AddRow(43))
+
+	seq, err := db.GetLatestChangeSeq()
+	assert.NoError(t, err, "getting the latest change should not return an error")
+	assert.Equal(t, uint64(43), seq)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
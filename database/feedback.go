@@ -10,7 +10,17 @@
 package database
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVoteFloor error = errors.New("vote counter cannot go below zero")
 )
 
 func (db *Database) GetAllFeedback() (f []*models.Feedback, err error) {
@@ -18,6 +28,64 @@ func (db *Database) GetAllFeedback() (f []*models.Feedback, err error) {
 	return
 }
 
+// feedbackFilter restricts the feedback to the ones selected by the
+// filter.
+func feedbackFilter(f *models.FeedbackFilter) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if f.Course != "" {
+			tx = tx.Where("UPPER(course) = ?", strings.ToUpper(f.Course))
+		}
+		if f.Faculty != "" {
+			// Courses are the faculty followed by 4 digits.
+			tx = tx.Where("UPPER(course) LIKE ?", strings.ToUpper(f.Faculty)+"____")
+		}
+		if f.Status != "" {
+			tx = tx.Where("status = ?", f.Status)
+		}
+		if f.Tag != "" {
+			// Tags are stored as a JSON array, and cannot contain
+			// quotes nor wildcards.
+			tx = tx.Where("tags LIKE ?", `%"`+strings.ToLower(f.Tag)+`"%`)
+		}
+		if f.Since != nil {
+			tx = tx.Where("created_at >= ?", *f.Since)
+		}
+		if f.Until != nil {
+			tx = tx.Where("created_at < ?", *f.Until)
+		}
+		return tx
+	}
+}
+
+// GetFilteredFeedback returns the feedback selected by the filter, by
+// ID.
+func (db *Database) GetFilteredFeedback(filter *models.FeedbackFilter) (f []*models.Feedback, err error) {
+	err = db.db.Scopes(feedbackFilter(filter)).Order("id").Find(&f).Error
+	return
+}
+
+// EachFeedback calls fn on the feedback selected by the filter, by ID.
+// The feedback is read from a cursor rather than loaded at once, and
+// the iteration stops at the first error.
+func (db *Database) EachFeedback(filter *models.FeedbackFilter, fn func(*models.Feedback) error) error {
+	rows, err := db.db.Model(&models.Feedback{}).Scopes(feedbackFilter(filter)).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f := new(models.Feedback)
+		if err := db.db.ScanRows(rows, f); err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (db *Database) GetFeedback(id uint) (*models.Feedback, error) {
 	f := new(models.Feedback)
 	if r := db.db.First(&f, id); r.Error != nil {
@@ -27,87 +95,381 @@ func (db *Database) GetFeedback(id uint) (*models.Feedback, error) {
 	return f, nil
 }
 
+// AddFeedback stores the new feedback, and records its creation in
+// the change feed.
 func (db *Database) AddFeedback(feedback *models.Feedback) (*models.Feedback, error) {
-	if r := db.db.Create(feedback); r.Error != nil {
-		return nil, r.Error
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Create(feedback); r.Error != nil {
+			return r.Error
+		}
+
+		return recordChange(tx, models.ChangeKindCreate, feedback)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return feedback, nil
 }
 
-func (db *Database) UpdateFeedback(feedback *models.Feedback) (*models.Feedback, error) {
-	if r := db.db.Save(feedback); r.Error != nil {
-		return nil, r.Error
+// GetFeedbackListVersion returns the current version of the list
+// of all feedback, deleted or not.
+func (db *Database) GetFeedbackListVersion() (*models.FeedbackListVersion, error) {
+	v := new(models.FeedbackListVersion)
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Model(&models.Feedback{})
+
+		r := tx.Session(&gorm.Session{}).
+			Select("count(*) AS count, coalesce(max(id), 0) AS max_id, coalesce(sum(version), 0) AS versions").
+			Scan(v)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		// The timestamps are selected as is rather than aggregated,
+		// so that they keep their type on all database kinds.
+		var updated, deleted []time.Time
+		r = tx.Session(&gorm.Session{}).
+			Where("updated_at IS NOT NULL").
+			Order("updated_at DESC").
+			Limit(1).
+			Pluck("updated_at", &updated)
+		if r.Error != nil {
+			return r.Error
+		}
+		r = tx.Session(&gorm.Session{}).
+			Where("deleted_at IS NOT NULL").
+			Order("deleted_at DESC").
+			Limit(1).
+			Pluck("deleted_at", &deleted)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		for _, t := range append(updated, deleted...) {
+			if t.After(v.ModifiedAt) {
+				v.ModifiedAt = t
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return feedback, nil
+	return v, nil
 }
 
-func (db *Database) DeleteFeedback(feedback *models.Feedback) error {
-	if r := db.db.
-		Where("course = ?", feedback.Course).
-		Where("feedback = ?", feedback.Feedback).
-		Where("upvotes = ?", feedback.Upvotes).
-		Where("downvotes = ?", feedback.Downvotes).
-		Delete(feedback); r.Error != nil {
-		return r.Error
+// UpdateFeedback updates the content of the feedback identified by
+// the ID of the given feedback, and bumps its version. The vote
+// counters are left untouched.
+// The precondition is called with the current feedback, or nil if
+// it does not exist, while the feedback is locked. If it returns an
+// error, nothing is updated and the error is returned.
+func (db *Database) UpdateFeedback(feedback *models.Feedback, precondition func(*models.Feedback) error) (*models.Feedback, error) {
+	var f []*models.Feedback
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		current, err := lockFeedback(tx, feedback.ID, precondition)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		r := tx.Model(&f).
+			Clauses(clause.Returning{}).
+			Where("id = ?", feedback.ID).
+			UpdateColumns(map[string]any{
+				"course":     feedback.Course,
+				"feedback":   feedback.Feedback,
+				"tags":       feedback.Tags,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+			})
+		if r.Error != nil {
+			return r.Error
+		}
+		if len(f) != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return recordChange(tx, models.ChangeKindUpdate, f[0])
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	return f[0], nil
 }
 
-// updateFeedbackAppreciation is a helper function to update the feedback
-// appreciation counters. It is used to increment and decrement the upvotes
-// and downvotes of a feedback.
-// It takes the id of the feedback to update and a mutator function that
-// takes a pointer to the feedback and modifies it in place.
-func (db *Database) updateFeedbackAppreciation(id uint, mutator func(*models.Feedback)) (*models.Feedback, error) {
-	f := new(models.Feedback)
-	tx := db.db.Begin()
-	defer tx.Rollback()
+// UpdateFeedbackStatus changes the status of the feedback identified
+// by id, and bumps its version. The pending status requests on the
+// feedback are settled if its status changes.
+// The precondition is handled as in UpdateFeedback.
+func (db *Database) UpdateFeedbackStatus(id uint, status models.FeedbackStatus, precondition func(*models.Feedback) error) (*models.Feedback, error) {
+	var f []*models.Feedback
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		current, err := lockFeedback(tx, id, precondition)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return gorm.ErrRecordNotFound
+		}
 
-	if r := tx.First(&f, id); r.Error != nil {
-		return nil, r.Error
+		r := tx.Model(&f).
+			Clauses(clause.Returning{}).
+			Where("id = ?", id).
+			UpdateColumns(map[string]any{
+				"status":     status,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+			})
+		if r.Error != nil {
+			return r.Error
+		}
+		if len(f) != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		if current.Status != status {
+			if err := settleStatusRequests(tx, id); err != nil {
+				return err
+			}
+		}
+
+		return recordChange(tx, models.ChangeKindStatus, f[0])
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	mutator(f)
+	return f[0], nil
+}
 
-	if r := tx.Save(f); r.Error != nil {
-		return nil, r.Error
+// RespondFeedback changes the status of the feedback identified by
+// id, along with its public response, and bumps its version. The
+// pending status requests are settled as in UpdateFeedbackStatus.
+// The precondition is handled as in UpdateFeedback.
+func (db *Database) RespondFeedback(id uint, status models.FeedbackStatus, response string, precondition func(*models.Feedback) error) (*models.Feedback, error) {
+	var f []*models.Feedback
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		current, err := lockFeedback(tx, id, precondition)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		now := time.Now()
+		r := tx.Model(&f).
+			Clauses(clause.Returning{}).
+			Where("id = ?", id).
+			UpdateColumns(map[string]any{
+				"status":       status,
+				"response":     response,
+				"responded_at": now,
+				"version":      gorm.Expr("version + 1"),
+				"updated_at":   now,
+			})
+		if r.Error != nil {
+			return r.Error
+		}
+		if len(f) != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		if current.Status != status {
+			if err := settleStatusRequests(tx, id); err != nil {
+				return err
+			}
+		}
+
+		return recordChange(tx, models.ChangeKindStatus, f[0])
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	tx.Commit()
+	return f[0], nil
+}
+
+// DeleteFeedback soft deletes the feedback identified by id,
+// keeping it as a tombstone along with the reason of the deletion.
+// The precondition is called with the current feedback, or nil if
+// it does not exist, while the feedback is locked. If it returns an
+// error, nothing is deleted and the error is returned.
+// It returns true if the feedback was deleted, false if there was
+// no feedback to delete.
+func (db *Database) DeleteFeedback(id uint, reason string, precondition func(*models.Feedback) error) (bool, error) {
+	deleted := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		f, err := lockFeedback(tx, id, precondition)
+		if err != nil || f == nil {
+			return err
+		}
+
+		r := tx.Model(&models.Feedback{}).
+			Where("id = ?", id).
+			UpdateColumns(map[string]any{
+				"deleted_at":    time.Now(),
+				"delete_reason": reason,
+				"version":       gorm.Expr("version + 1"),
+			})
+		if r.Error != nil || r.RowsAffected != 1 {
+			return r.Error
+		}
+
+		deleted = true
+		tombstone := *f
+		tombstone.Version++
+		return recordChange(tx, models.ChangeKindDelete, &tombstone)
+	})
 
-	return f, tx.Error
+	return deleted, err
 }
 
-func (db *Database) IncrementFeedbackUpvotes(id uint) (*models.Feedback, error) {
-	mutator := func(f *models.Feedback) {
-		f.Upvotes++
+// PurgeFeedback permanently removes the feedback identified by id,
+// along with its votes, whether it was soft deleted or not.
+// The precondition is handled as in DeleteFeedback.
+// It returns true if the feedback was removed, false if there was
+// no feedback to remove.
+func (db *Database) PurgeFeedback(id uint, precondition func(*models.Feedback) error) (bool, error) {
+	purged := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		f, err := lockFeedback(tx.Unscoped(), id, precondition)
+		if err != nil || f == nil {
+			return err
+		}
+
+		if r := tx.Where("feedback_id = ?", id).Delete(&models.Vote{}); r.Error != nil {
+			return r.Error
+		}
+
+		r := tx.Unscoped().Delete(&models.Feedback{}, id)
+		if r.Error != nil || r.RowsAffected != 1 {
+			return r.Error
+		}
+
+		purged = true
+		return recordChange(tx, models.ChangeKindPurge, f)
+	})
+
+	return purged, err
+}
+
+// lockFeedback fetches and locks the feedback identified by id for
+// the rest of the transaction, and checks the precondition on it.
+// It returns nil if the feedback does not exist.
+func lockFeedback(tx *gorm.DB, id uint, precondition func(*models.Feedback) error) (*models.Feedback, error) {
+	f := new(models.Feedback)
+	r := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&f, id)
+	if errors.Is(r.Error, gorm.ErrRecordNotFound) {
+		f = nil
+	} else if r.Error != nil {
+		return nil, r.Error
 	}
 
-	return db.updateFeedbackAppreciation(id, mutator)
+	if precondition != nil {
+		if err := precondition(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
 }
 
-func (db *Database) DecrementFeedbackUpvotes(id uint) (*models.Feedback, error) {
-	mutator := func(f *models.Feedback) {
-		f.Upvotes--
+// updateFeedbackAppreciation atomically adds delta to the counter
+// of the given kind, on the feedback identified by id, and returns
+// the updated feedback.
+// The update is done in a single statement, so that concurrent votes
+// are neither lost nor serialized on a read-modify-write cycle. The
+// counter is never brought below 0, in which case ErrVoteFloor is
+// returned and the feedback is left untouched.
+// The vote is recorded in the change feed, so tx should be a
+// transaction.
+func updateFeedbackAppreciation(tx *gorm.DB, id uint, kind models.VoteKind, delta int) (*models.Feedback, error) {
+	column := counterColumn(kind)
+
+	var f []*models.Feedback
+	r := tx.Model(&f).
+		Clauses(clause.Returning{}).
+		Where("id = ?", id).
+		Where(column+" + ? >= 0", delta).
+		UpdateColumns(map[string]any{
+			column:       gorm.Expr(column+" + ?", delta),
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		})
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if r.RowsAffected == 1 && len(f) == 1 {
+		return f[0], recordChange(tx, models.ChangeKindVote, f[0])
 	}
 
-	return db.updateFeedbackAppreciation(id, mutator)
+	// Nothing was updated, either because the feedback does not
+	// exist, or because the counter would underflow.
+	var n int64
+	if r := tx.Model(&models.Feedback{}).Where("id = ?", id).Count(&n); r.Error != nil {
+		return nil, r.Error
+	}
+	if n == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return nil, ErrVoteFloor
 }
 
-func (db *Database) IncrementFeedbackDownvotes(id uint) (*models.Feedback, error) {
-	mutator := func(f *models.Feedback) {
-		f.Downvotes++
+// applyAppreciation runs updateFeedbackAppreciation in its own
+// transaction.
+func (db *Database) applyAppreciation(id uint, kind models.VoteKind, delta int) (*models.Feedback, error) {
+	var f *models.Feedback
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		f, err = updateFeedbackAppreciation(tx, id, kind, delta)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return db.updateFeedbackAppreciation(id, mutator)
+	return f, nil
+}
+
+func (db *Database) IncrementFeedbackUpvotes(id uint) (*models.Feedback, error) {
+	return db.applyAppreciation(id, models.VoteKindUpvote, 1)
+}
+
+func (db *Database) DecrementFeedbackUpvotes(id uint) (*models.Feedback, error) {
+	return db.applyAppreciation(id, models.VoteKindUpvote, -1)
+}
+
+func (db *Database) IncrementFeedbackDownvotes(id uint) (*models.Feedback, error) {
+	return db.applyAppreciation(id, models.VoteKindDownvote, 1)
 }
 
 func (db *Database) DecrementFeedbackDownvotes(id uint) (*models.Feedback, error) {
-	mutator := func(f *models.Feedback) {
-		f.Downvotes--
+	return db.applyAppreciation(id, models.VoteKindDownvote, -1)
+}
+
+// CastVote atomically applies the vote to the counters of its
+// feedback and records the vote event, in a single transaction.
+// It returns the updated feedback.
+func (db *Database) CastVote(vote *models.Vote) (*models.Feedback, error) {
+	var f *models.Feedback
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		f, err = updateFeedbackAppreciation(tx, vote.FeedbackID, vote.Kind, vote.Delta)
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(vote).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return db.updateFeedbackAppreciation(id, mutator)
+	return f, nil
 }
@@ -17,9 +17,12 @@
 package database
 
 import (
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,6 +35,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// maxGeneratedVotes is the upper bound of generated vote counters,
+// well beyond the participation of a single course.
+const maxGeneratedVotes uint64 = 1 << 40
+
+var feedbackColumns = []string{"id", "course", "feedback", "upvotes", "downvotes", "status", "version"}
+
 func createMockDatabase(t *testing.T) (*Database, func(), sqlmock.Sqlmock, *sqlmock.Rows) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err, "could not create database mock")
@@ -42,7 +51,7 @@ func createMockDatabase(t *testing.T) (*Database, func(), sqlmock.Sqlmock, *sqlm
 	db, err := NewDatabaseFromDialector(dialect, &gorm.Config{})
 	require.NoError(t, err, "could not create database from dialect")
 
-	schema := sqlmock.NewRows([]string{"id", "course", "feedback", "upvotes", "downvotes"})
+	schema := sqlmock.NewRows(feedbackColumns)
 
 	closer := func() {
 		mockDB.Close()
@@ -51,18 +60,29 @@ func createMockDatabase(t *testing.T) (*Database, func(), sqlmock.Sqlmock, *sqlm
 	return db, closer, mock, schema
 }
 
+// appreciationArgs returns the arguments of the statement updating
+// the counter of the given kind, in the order gorm binds them.
+func appreciationArgs(kind models.VoteKind, delta int, id uint) []driver.Value {
+	if kind == models.VoteKindDownvote {
+		return []driver.Value{delta, sqlmock.AnyArg(), id, delta}
+	}
+	return []driver.Value{sqlmock.AnyArg(), delta, id, delta}
+}
+
 func feedbackToCSV(f ...*models.Feedback) (s string) {
 	fs := []string{}
 	for _, v := range f {
 		fs = append(
 			fs,
 			fmt.Sprintf(
-				"%d,%s,%s,%d,%d",
+				"%d,%s,%s,%d,%d,%s,%d",
 				v.ID,
 				v.Course,
 				v.Feedback,
 				v.Upvotes,
 				v.Downvotes,
+				v.Status,
+				v.Version,
 			),
 		)
 	}
@@ -97,8 +117,10 @@ func generateFeedback(n uint, seed int64, mutator func(*models.Feedback, faker.F
 		newFeedback.ID = fkr.UInt()
 		newFeedback.Course = fkr.RandomStringWithLength(10)
 		newFeedback.Feedback = fkr.Lorem().Paragraph(3)
-		newFeedback.Upvotes = fkr.UIntBetween(0, 2000)
-		newFeedback.Downvotes = fkr.UIntBetween(0, 2000)
+		newFeedback.Upvotes = fkr.UInt64Between(0, maxGeneratedVotes)
+		newFeedback.Downvotes = fkr.UInt64Between(0, maxGeneratedVotes)
+		newFeedback.Status = models.FeedbackStatusNew
+		newFeedback.Version = fkr.UInt64Between(1, 0xffff)
 		mutator(newFeedback, fkr)
 		f = append(f, newFeedback)
 	}
@@ -230,8 +252,9 @@ func TestAddFeedback(t *testing.T) {
 		mock.ExpectBegin()
 		mock.
 			ExpectQuery("^INSERT INTO [`\"']feedbacks[`\"'] .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
+			WithArgs(f.Course, f.Feedback, "[]", f.Upvotes, f.Downvotes, f.Status, f.Version, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "", "", nil, f.ID).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(f.ID))
+		expectChange(mock, models.ChangeKindCreate, f.ID, f.Version)
 		mock.ExpectCommit()
 
 		actual, err := db.AddFeedback(f)
@@ -257,7 +280,7 @@ func TestAddFeedbackError(t *testing.T) {
 		mock.ExpectBegin()
 		mock.
 			ExpectQuery("^INSERT INTO [`\"']feedbacks[`\"'] .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
+			WithArgs(f.Course, f.Feedback, "[]", f.Upvotes, f.Downvotes, f.Status, f.Version, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "", "", nil, f.ID).
 			WillReturnError(gorm.ErrDuplicatedKey)
 		mock.ExpectRollback()
 
@@ -271,31 +294,43 @@ func TestAddFeedbackError(t *testing.T) {
 // TestUpdateFeedback is a unit test that tests the return
 // value and the stored data in the database using randomly
 // created feedback.
-// UpdateFeedback is expected to set the database values to the
-// updated feedback passed, matching is based on ID, and return
-// the updated data.
+// UpdateFeedback is expected to check the precondition on the
+// current feedback, update its content, matching is based on ID,
+// bump its version and return the updated data.
 func TestUpdateFeedback(t *testing.T) {
-	db, closer, mock, schema := createMockDatabase(t)
+	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
 
 	expectedFeedback, seed := generateFeedback(10, 0, nil)
 	t.Logf("seed: %x\n", seed)
 
 	for _, f := range expectedFeedback {
-		expect := schema
-		expect.FromCSVString(feedbackToCSV(f))
+		current := *f
+		current.Course = "LINFO1101"
+		f.Version++
 
 		mock.ExpectBegin()
 		mock.
-			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnResult(sqlmock.NewResult(int64(f.ID), 1))
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(&current)))
+		mock.
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET [`\"']course[`\"']=.*,[`\"']feedback[`\"']=.*,[`\"']updated_at[`\"']=.*,[`\"']version[`\"']=version \\+ 1 WHERE id = .* RETURNING .*$").
+			WithArgs(f.Course, f.Feedback, "[]", sqlmock.AnyArg(), f.ID).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f)))
+		expectChange(mock, models.ChangeKindUpdate, f.ID, f.Version)
 		mock.ExpectCommit()
 
-		actual, err := db.UpdateFeedback(f)
+		var checked *models.Feedback
+		actual, err := db.UpdateFeedback(f, func(c *models.Feedback) error {
+			checked = c
+			return nil
+		})
 		assert.NoError(t, err, "update valid feedback should not return an error")
 		assert.Equal(t, f, actual, "returned feedback should be the same as the updated one")
+		assert.Equal(t, &current, checked, "the precondition should be checked on the current feedback")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestUpdateFeedbackUnknown is a unit test that tests the return
@@ -313,26 +348,123 @@ func TestUpdateFeedbackUnknown(t *testing.T) {
 	for _, f := range expectedFeedback {
 		mock.ExpectBegin()
 		mock.
-			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnError(gorm.ErrRecordNotFound)
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns))
 		mock.ExpectRollback()
 
-		actual, err := db.UpdateFeedback(f)
+		actual, err := db.UpdateFeedback(f, nil)
 		assert.Error(t, err, "non existent feedback should return no such feedback error")
 		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "non existent feedback should return no such feedback error")
 		assert.Nil(t, actual, "non existent feedback should not return any feedback")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateFeedbackPreconditionFailed is a unit test that tests
+// that feedback is not updated when the precondition fails.
+func TestUpdateFeedbackPreconditionFailed(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	expectedFeedback, seed := generateFeedback(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	for _, f := range expectedFeedback {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f)))
+		mock.ExpectRollback()
+
+		actual, err := db.UpdateFeedback(f, func(*models.Feedback) error {
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError, "the precondition error should be returned")
+		assert.Nil(t, actual, "no feedback should be returned")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateFeedbackStatus is a unit test that tests that the
+// status of feedback is changed after checking the precondition,
+// and that its version is bumped.
+func TestUpdateFeedbackStatus(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	expectedFeedback, seed := generateFeedback(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	for _, f := range expectedFeedback {
+		current := *f
+		f.Status = models.FeedbackStatusAcknowledged
+		f.Version++
+
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(&current)))
+		mock.
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET [`\"']status[`\"']=.*,[`\"']updated_at[`\"']=.*,[`\"']version[`\"']=version \\+ 1 WHERE id = .* RETURNING .*$").
+			WithArgs(f.Status, sqlmock.AnyArg(), f.ID).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f)))
+		if current.Status != f.Status {
+			mock.
+				ExpectExec("^DELETE FROM [`\"']status_requests[`\"'] WHERE feedback_id = .*$").
+				WithArgs(f.ID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		expectChange(mock, models.ChangeKindStatus, f.ID, f.Version)
+		mock.ExpectCommit()
+
+		var checked *models.Feedback
+		actual, err := db.UpdateFeedbackStatus(f.ID, f.Status, func(c *models.Feedback) error {
+			checked = c
+			return nil
+		})
+		assert.NoError(t, err, "changing the status of feedback should not return an error")
+		assert.Equal(t, f, actual, "the updated feedback should be returned")
+		assert.Equal(t, &current, checked, "the precondition should be checked on the current feedback")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetFeedbackListVersion is a unit test that tests that the
+// version of the list of feedback accounts for all feedback,
+// including tombstones, and for the last deletion.
+func TestGetFeedbackListVersion(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	updated := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	deleted := updated.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^SELECT count\\(\\*\\) AS count, .* FROM [`\"']feedbacks[`\"']$").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max_id", "versions"}).AddRow(3, 42, 17))
+	mock.
+		ExpectQuery("^SELECT [`\"']?updated_at[`\"']? FROM [`\"']feedbacks[`\"'] WHERE updated_at IS NOT NULL ORDER BY updated_at DESC LIMIT .*$").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(updated))
+	mock.
+		ExpectQuery("^SELECT [`\"']?deleted_at[`\"']? FROM [`\"']feedbacks[`\"'] WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC LIMIT .*$").
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deleted))
+	mock.ExpectCommit()
+
+	v, err := db.GetFeedbackListVersion()
+	assert.NoError(t, err, "getting the list version should not return an error")
+	assert.Equal(t, &models.FeedbackListVersion{Count: 3, MaxID: 42, Versions: 17, ModifiedAt: deleted}, v, "the version should account for the last deletion")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-// TestDeleteFeedback is a unit test that tests the deletion
-// or voiding of data in the database. A void feedback is a feedback
-// with a 0 ID. A deleted feedback is completely removed from the
-// database. Feedback is generated randomly,
-// DeleteFeedback is expected to delete the provided feedback if
-// it exists, or return an error if it doesn't. Matching is done
-// using ALL attributes, so that mismatching versions of a feedback
-// are considered.
+// TestDeleteFeedback is a unit test that tests the soft deletion
+// of feedback in the database. Feedback is matched by ID only, and
+// kept as a tombstone along with the reason of the deletion.
+// DeleteFeedback is expected to check the precondition on the
+// current feedback, and report the feedback as deleted.
 func TestDeleteFeedback(t *testing.T) {
 	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
@@ -343,24 +475,32 @@ func TestDeleteFeedback(t *testing.T) {
 	for _, f := range expectedFeedback {
 		mock.ExpectBegin()
 		mock.
-			ExpectExec("^DELETE FROM [`\"']feedbacks[`\"] .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.* AND [`\"']feedbacks[`\"']\\.[`\"']deleted_at[`\"'] IS NULL .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f)))
+		mock.
+			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET [`\"']delete_reason[`\"']=.*,[`\"']deleted_at[`\"']=.* WHERE id = .*$").
+			WithArgs("duplicate", sqlmock.AnyArg(), f.ID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectChange(mock, models.ChangeKindDelete, f.ID, f.Version+1)
 		mock.ExpectCommit()
 
-		err := db.DeleteFeedback(f)
+		var checked *models.Feedback
+		deleted, err := db.DeleteFeedback(f.ID, "duplicate", func(current *models.Feedback) error {
+			checked = current
+			return nil
+		})
 		assert.NoError(t, err, "deleting an existing feedback should not be a problem")
+		assert.True(t, deleted, "the feedback should be reported as deleted")
+		assert.Equal(t, f, checked, "the precondition should be checked on the current feedback")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestDeleteFeedbackUnknown is a unit test that tests the deletion
-// or voiding of data in the database. A void feedback is a feedback
-// with a 0 ID. A deleted feedback is completely removed from the
-// database. Feedback is generated randomly,
-// DeleteFeedback is expected to delete the provided feedback if
-// it exists, or return an error if it doesn't. Matching is done
-// using ALL attributes, so that mismatching versions of a feedback
-// are considered.
+// of unknown or already deleted feedback.
+// DeleteFeedback is expected to check the precondition on a nil
+// feedback, and report that nothing was deleted.
 func TestDeleteFeedbackUnknown(t *testing.T) {
 	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
@@ -371,232 +511,461 @@ func TestDeleteFeedbackUnknown(t *testing.T) {
 	for _, f := range expectedFeedback {
 		mock.ExpectBegin()
 		mock.
-			ExpectExec("^DELETE FROM [`\"']feedbacks[`\"] .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnError(gorm.ErrRecordNotFound)
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns))
+		mock.ExpectCommit()
+
+		checked := f
+		deleted, err := db.DeleteFeedback(f.ID, "", func(current *models.Feedback) error {
+			checked = current
+			return nil
+		})
+		assert.NoError(t, err, "deleting unknown feedback should not return an error")
+		assert.False(t, deleted, "unknown feedback should not be reported as deleted")
+		assert.Nil(t, checked, "the precondition should be checked on no feedback")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteFeedbackPreconditionFailed is a unit test that tests
+// that feedback is not deleted when the precondition fails.
+func TestDeleteFeedbackPreconditionFailed(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	expectedFeedback, seed := generateFeedback(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	for _, f := range expectedFeedback {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f)))
 		mock.ExpectRollback()
 
-		err := db.DeleteFeedback(f)
-		assert.Error(t, err, "deleting unknown feedback should return an error")
-		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "deleting unknown feedback should return not found error")
+		deleted, err := db.DeleteFeedback(f.ID, "", func(current *models.Feedback) error {
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError, "the precondition error should be returned")
+		assert.False(t, deleted, "the feedback should not be deleted")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPurgeFeedback is a unit test that tests the permanent removal
+// of feedback, deleted or not, along with its votes.
+func TestPurgeFeedback(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	expectedFeedback, seed := generateFeedback(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	for _, f := range expectedFeedback {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=\\W*\\$1 ORDER BY .* FOR UPDATE$").
+			WithArgs(f.ID, 1).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f)))
+		mock.
+			ExpectExec("^DELETE FROM [`\"']votes[`\"'] WHERE feedback_id = .*$").
+			WithArgs(f.ID).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.
+			ExpectExec("^DELETE FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"'] = .*$").
+			WithArgs(f.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectChange(mock, models.ChangeKindPurge, f.ID, f.Version)
+		mock.ExpectCommit()
+
+		purged, err := db.PurgeFeedback(f.ID, nil)
+		assert.NoError(t, err, "purging an existing feedback should not be a problem")
+		assert.True(t, purged, "the feedback should be reported as purged")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPurgeFeedbackUnknown is a unit test that tests that purging
+// unknown feedback does not remove anything.
+func TestPurgeFeedbackUnknown(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE .* FOR UPDATE$").
+		WithArgs(42, 1).
+		WillReturnRows(sqlmock.NewRows(feedbackColumns))
+	mock.ExpectCommit()
+
+	purged, err := db.PurgeFeedback(42, nil)
+	assert.NoError(t, err, "purging unknown feedback should not return an error")
+	assert.False(t, purged, "unknown feedback should not be reported as purged")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestIncrementFeedbackUpvotes tests the correct incrementing
 // of feedback upvotes.
 // A feedback should be atomically updated to include the
-// upvote. Upvotes may not exceed 2000 or go below 0.
+// vote. Upvotes may not go below 0.
 func TestIncrementFeedbackUpvotes(t *testing.T) {
-	db, closer, mock, schema := createMockDatabase(t)
+	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
 
 	expectedFeedback, seed := generateFeedback(10, 0, func(f *models.Feedback, fkr faker.Faker) {
-		f.Upvotes = fkr.UIntBetween(0, 1999)
+		f.Upvotes = fkr.UInt64Between(0, maxGeneratedVotes)
 	})
-
 	t.Logf("seed: %x\n", seed)
 
 	for _, f := range expectedFeedback {
-		expectSQL := schema
-		expectSQL.FromCSVString(feedbackToCSV(f))
 		f.Upvotes += 1
+		expectSQL := sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f))
 
 		mock.ExpectBegin()
 		mock.
-			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.*$").
-			WithArgs(f.ID, 1).
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']upvotes[`\"']=upvotes \\+ .* WHERE id = .* AND upvotes \\+ .* >= 0 .*RETURNING .*$").
+			WithArgs(appreciationArgs(models.VoteKindUpvote, 1, f.ID)...).
 			WillReturnRows(expectSQL)
-		mock.
-			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnResult(sqlmock.NewResult(int64(f.ID), 1))
+		expectChange(mock, models.ChangeKindVote, f.ID, f.Version)
 		mock.ExpectCommit()
 
 		actual, err := db.IncrementFeedbackUpvotes(f.ID)
 		assert.NoError(t, err, "update valid feedback should not return an error")
 		assert.Equal(t, f, actual, "returned feedback should be the same as the updated one")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestDecrementFeedbackUpvotes tests the correct decrementing
 // of feedback upvotes.
 // A feedback should be atomically updated to include the
-// upvote. Upvotes may not exceed 2000 or go below 0.
+// vote. Upvotes may not go below 0.
 func TestDecrementFeedbackUpvotes(t *testing.T) {
-	db, closer, mock, schema := createMockDatabase(t)
+	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
 
 	expectedFeedback, seed := generateFeedback(10, 0, func(f *models.Feedback, fkr faker.Faker) {
-		f.Upvotes = fkr.UIntBetween(1, 2000)
+		f.Upvotes = fkr.UInt64Between(1, maxGeneratedVotes)
 	})
 	t.Logf("seed: %x\n", seed)
 
 	for _, f := range expectedFeedback {
-		expectSQL := schema
-		expectSQL.FromCSVString(feedbackToCSV(f))
 		f.Upvotes -= 1
+		expectSQL := sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f))
 
 		mock.ExpectBegin()
 		mock.
-			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.*$").
-			WithArgs(f.ID, 1).
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']upvotes[`\"']=upvotes \\+ .* WHERE id = .* AND upvotes \\+ .* >= 0 .*RETURNING .*$").
+			WithArgs(appreciationArgs(models.VoteKindUpvote, -1, f.ID)...).
 			WillReturnRows(expectSQL)
-		mock.
-			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnResult(sqlmock.NewResult(int64(f.ID), 1))
+		expectChange(mock, models.ChangeKindVote, f.ID, f.Version)
 		mock.ExpectCommit()
 
 		actual, err := db.DecrementFeedbackUpvotes(f.ID)
 		assert.NoError(t, err, "update valid feedback should not return an error")
 		assert.Equal(t, f, actual, "returned feedback should be the same as the updated one")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestIncrementFeedbackDownvotes tests the correct incrementing
-// of feedback upvotes.
+// of feedback downvotes.
 // A feedback should be atomically updated to include the
-// upvote. Upvotes may not exceed 2000 or go below 0.
+// vote. Downvotes may not go below 0.
 func TestIncrementFeedbackDownvotes(t *testing.T) {
-	db, closer, mock, schema := createMockDatabase(t)
+	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
 
 	expectedFeedback, seed := generateFeedback(10, 0, func(f *models.Feedback, fkr faker.Faker) {
-		f.Downvotes = fkr.UIntBetween(0, 1999)
+		f.Downvotes = fkr.UInt64Between(0, maxGeneratedVotes)
 	})
-
 	t.Logf("seed: %x\n", seed)
 
 	for _, f := range expectedFeedback {
-		expectSQL := schema
-		expectSQL.FromCSVString(feedbackToCSV(f))
 		f.Downvotes += 1
+		expectSQL := sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f))
 
 		mock.ExpectBegin()
 		mock.
-			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.*$").
-			WithArgs(f.ID, 1).
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']downvotes[`\"']=downvotes \\+ .* WHERE id = .* AND downvotes \\+ .* >= 0 .*RETURNING .*$").
+			WithArgs(appreciationArgs(models.VoteKindDownvote, 1, f.ID)...).
 			WillReturnRows(expectSQL)
-		mock.
-			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnResult(sqlmock.NewResult(int64(f.ID), 1))
+		expectChange(mock, models.ChangeKindVote, f.ID, f.Version)
 		mock.ExpectCommit()
 
 		actual, err := db.IncrementFeedbackDownvotes(f.ID)
 		assert.NoError(t, err, "update valid feedback should not return an error")
 		assert.Equal(t, f, actual, "returned feedback should be the same as the updated one")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestDecrementFeedbackDownvotes tests the correct decrementing
-// of feedback Downvotes.
+// of feedback downvotes.
 // A feedback should be atomically updated to include the
-// upvote. Downvotes may not exceed 2000 or go below 0.
+// vote. Downvotes may not go below 0.
 func TestDecrementFeedbackDownvotes(t *testing.T) {
-	db, closer, mock, schema := createMockDatabase(t)
+	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
 
 	expectedFeedback, seed := generateFeedback(10, 0, func(f *models.Feedback, fkr faker.Faker) {
-		f.Downvotes = fkr.UIntBetween(1, 2000)
+		f.Downvotes = fkr.UInt64Between(1, maxGeneratedVotes)
 	})
 	t.Logf("seed: %x\n", seed)
 
 	for _, f := range expectedFeedback {
-		expectSQL := schema
-		expectSQL.FromCSVString(feedbackToCSV(f))
 		f.Downvotes -= 1
+		expectSQL := sqlmock.NewRows(feedbackColumns).FromCSVString(feedbackToCSV(f))
 
 		mock.ExpectBegin()
 		mock.
-			ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.*$").
-			WithArgs(f.ID, 1).
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']downvotes[`\"']=downvotes \\+ .* WHERE id = .* AND downvotes \\+ .* >= 0 .*RETURNING .*$").
+			WithArgs(appreciationArgs(models.VoteKindDownvote, -1, f.ID)...).
 			WillReturnRows(expectSQL)
-		mock.
-			ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-			WithArgs(f.Course, f.Feedback, f.Upvotes, f.Downvotes, f.ID).
-			WillReturnResult(sqlmock.NewResult(int64(f.ID), 1))
+		expectChange(mock, models.ChangeKindVote, f.ID, f.Version)
 		mock.ExpectCommit()
 
 		actual, err := db.DecrementFeedbackDownvotes(f.ID)
 		assert.NoError(t, err, "update valid feedback should not return an error")
 		assert.Equal(t, f, actual, "returned feedback should be the same as the updated one")
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-// TestUpdateFeedbackAppreciationTransactionStartError tests
-// the error handling of the transaction start in the
-// updateFeedbackAppreciation function.
-func TestUpdateFeedbackAppreciationTransactionStartError(t *testing.T) {
-	db, closer, mock, _ := createMockDatabase(t)
+// TestUpdateFeedbackAppreciationUnknown tests that votes on
+// unknown feedback return a not found error.
+func TestUpdateFeedbackAppreciationUnknown(t *testing.T) {
+	db, closer, mock, schema := createMockDatabase(t)
 	defer closer()
 
-	mock.ExpectBegin().WillReturnError(assert.AnError)
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .* RETURNING .*$").
+		WithArgs(appreciationArgs(models.VoteKindUpvote, 1, 42)...).
+		WillReturnRows(schema)
+	mock.
+		ExpectQuery("^SELECT count\\(\\*\\) FROM [`\"']feedbacks[`\"'] WHERE id = .*$").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
 
-	mutator := func(f *models.Feedback) {}
+	f, err := db.IncrementFeedbackUpvotes(42)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "unknown feedback should return not found error")
+	assert.Nil(t, f, "no feedback should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	f, err := db.updateFeedbackAppreciation(0, mutator)
-	assert.Error(t, err, "an error should be returned")
-	assert.ErrorIs(t, err, assert.AnError, "begin error should be returned")
+// TestUpdateFeedbackAppreciationFloor tests that counters at 0
+// cannot be decremented, instead of underflowing.
+func TestUpdateFeedbackAppreciationFloor(t *testing.T) {
+	db, closer, mock, schema := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']downvotes[`\"'].* RETURNING .*$").
+		WithArgs(appreciationArgs(models.VoteKindDownvote, -1, 42)...).
+		WillReturnRows(schema)
+	mock.
+		ExpectQuery("^SELECT count\\(\\*\\) FROM [`\"']feedbacks[`\"'] WHERE id = .*$").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	f, err := db.DecrementFeedbackDownvotes(42)
+	assert.ErrorIs(t, err, ErrVoteFloor, "decrementing a counter at 0 should return the floor error")
 	assert.Nil(t, f, "no feedback should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-// TestUpdateFeedbackAppreciationFirstError tests the error
-// handling of the first query in the updateFeedbackAppreciation
-// function.
-func TestUpdateFeedbackAppreciationFirstError(t *testing.T) {
+// TestUpdateFeedbackAppreciationError tests the error handling
+// of the update query in updateFeedbackAppreciation.
+func TestUpdateFeedbackAppreciationError(t *testing.T) {
 	db, closer, mock, _ := createMockDatabase(t)
 	defer closer()
 
 	mock.ExpectBegin()
 	mock.
-		ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.*$").
-		WithArgs(0, 1).
+		ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .* RETURNING .*$").
+		WithArgs(appreciationArgs(models.VoteKindDownvote, 1, 42)...).
 		WillReturnError(assert.AnError)
 	mock.ExpectRollback()
 
-	mutator := func(f *models.Feedback) {}
-
-	f, err := db.updateFeedbackAppreciation(0, mutator)
-	assert.Error(t, err, "an error should be returned")
-	assert.ErrorIs(t, err, assert.AnError, "first error should be returned")
+	f, err := db.IncrementFeedbackDownvotes(42)
+	assert.ErrorIs(t, err, assert.AnError, "update error should be returned")
 	assert.Nil(t, f, "no feedback should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-// TestUpdateFeedbackAppreciationSaveError tests the error
-// handling of the save query in the updateFeedbackAppreciation
-// function.
-func TestUpdateFeedbackAppreciationSaveError(t *testing.T) {
+// TestCastVote tests that casting a vote updates the counter and
+// records the vote event in the same transaction.
+func TestCastVote(t *testing.T) {
 	db, closer, mock, schema := createMockDatabase(t)
 	defer closer()
 
-	expectFeedback, seed := generateFeedback(1, 0, nil)
+	expectedFeedback, seed := generateFeedback(1, 0, nil)
 	t.Logf("seed: %x\n", seed)
+	f := expectedFeedback[0]
+	f.Upvotes++
 
-	expectSQL := schema
-	expectSQL.FromCSVString(feedbackToCSV(expectFeedback...))
+	votes, _ := generateVotes(1, seed, func(v *models.Vote, fkr faker.Faker) {
+		v.FeedbackID = f.ID
+	})
+	v := votes[0]
 
 	mock.ExpectBegin()
 	mock.
-		ExpectQuery("^SELECT .+ FROM [`\"']feedbacks[`\"'] WHERE [`\"']feedbacks[`\"']\\.[`\"']id[`\"']\\W*=.*$").
-		WithArgs(expectFeedback[0].ID, 1).
-		WillReturnRows(expectSQL)
+		ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']upvotes[`\"'].* RETURNING .*$").
+		WithArgs(appreciationArgs(models.VoteKindUpvote, 1, f.ID)...).
+		WillReturnRows(schema.FromCSVString(feedbackToCSV(f)))
+	expectChange(mock, models.ChangeKindVote, f.ID, f.Version)
 	mock.
-		ExpectExec("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .*$").
-		WithArgs(
-			expectFeedback[0].Course,
-			expectFeedback[0].Feedback,
-			expectFeedback[0].Upvotes,
-			expectFeedback[0].Downvotes,
-			expectFeedback[0].ID,
-		).
-		WillReturnError(assert.AnError)
+		ExpectQuery("^INSERT INTO [`\"']votes[`\"'] .*$").
+		WithArgs(v.FeedbackID, v.Kind, v.Delta, v.Token, v.TokenFamily, v.Address, v.CreatedAt, v.Flag, v.Quarantined, v.Reviewed, v.SessionID, v.SessionRound, v.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(v.ID))
+	mock.ExpectCommit()
+
+	actual, err := db.CastVote(v)
+	assert.NoError(t, err, "casting a vote should not return an error")
+	assert.Equal(t, f, actual, "the updated feedback should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCastVoteFloor tests that a retraction on a counter at 0 is
+// rolled back and not recorded.
+func TestCastVoteFloor(t *testing.T) {
+	db, closer, mock, schema := createMockDatabase(t)
+	defer closer()
+
+	v := &models.Vote{FeedbackID: 42, Kind: models.VoteKindUpvote, Delta: -1}
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .* RETURNING .*$").
+		WithArgs(appreciationArgs(models.VoteKindUpvote, -1, 42)...).
+		WillReturnRows(schema)
+	mock.
+		ExpectQuery("^SELECT count\\(\\*\\) FROM [`\"']feedbacks[`\"'] WHERE id = .*$").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectRollback()
 
-	mutator := func(f *models.Feedback) {}
+	actual, err := db.CastVote(v)
+	assert.ErrorIs(t, err, ErrVoteFloor, "retracting a vote at 0 should return the floor error")
+	assert.Nil(t, actual, "no feedback should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	f, err := db.updateFeedbackAppreciation(expectFeedback[0].ID, mutator)
-	assert.Error(t, err, "an error should be returned")
-	assert.ErrorIs(t, err, assert.AnError, "save error should be returned")
-	assert.Nil(t, f, "no feedback should be returned")
+// TestCastVoteConcurrent hammers one feedback with votes from many
+// goroutines on a real database, and checks that no vote is lost.
+func TestCastVoteConcurrent(t *testing.T) {
+	db, err := NewDatabase("sqlite", t.TempDir()+"/test.db?_busy_timeout=10000&_journal_mode=WAL")
+	require.NoError(t, err, "could not create database")
+	_, err = db.MigrateUp()
+	require.NoError(t, err, "could not migrate database")
+
+	f, err := db.AddFeedback(&models.Feedback{Course: "LINFO1101", Feedback: "The exam overlaps with another exam of the same year."})
+	require.NoError(t, err, "could not add feedback")
+
+	const workers, votes = 20, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*votes)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < votes; j++ {
+				// Every worker upvotes, every second worker also
+				// downvotes, and every fourth retracts its downvotes.
+				if _, err := db.CastVote(&models.Vote{FeedbackID: f.ID, Kind: models.VoteKindUpvote, Delta: 1}); err != nil {
+					errs <- err
+				}
+				if i%2 == 1 {
+					if _, err := db.CastVote(&models.Vote{FeedbackID: f.ID, Kind: models.VoteKindDownvote, Delta: 1}); err != nil {
+						errs <- err
+					}
+				}
+				if i%4 == 3 {
+					if _, err := db.CastVote(&models.Vote{FeedbackID: f.ID, Kind: models.VoteKindDownvote, Delta: -1}); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err, "concurrent votes should not fail")
+	}
+
+	actual, err := db.GetFeedback(f.ID)
+	require.NoError(t, err, "could not fetch feedback")
+	assert.Equal(t, uint64(workers*votes), actual.Upvotes, "no upvote should be lost")
+	assert.Equal(t, uint64(workers/4*votes), actual.Downvotes, "no downvote should be lost")
+
+	var n int64
+	require.NoError(t, db.db.Model(&models.Vote{}).Count(&n).Error)
+	assert.Equal(t, int64(workers*votes+workers/2*votes+workers/4*votes), n, "every vote should be recorded")
+
+	_, err = db.CastVote(&models.Vote{FeedbackID: f.ID, Kind: models.VoteKindDownvote, Delta: -1})
+	for i := 0; i < workers/4*votes && err == nil; i++ {
+		_, err = db.CastVote(&models.Vote{FeedbackID: f.ID, Kind: models.VoteKindDownvote, Delta: -1})
+	}
+	assert.ErrorIs(t, err, ErrVoteFloor, "downvotes should not underflow")
+}
+
+// TestEachFeedback tests that the feedback selected by the filter
+// is iterated on by ID, and that the iteration stops on errors.
+func TestEachFeedback(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	for i, course := range []string{"LINFO1101", "LEPL1102", "linfo1102", "LINFO1101"} {
+		f := &models.Feedback{Course: course, Feedback: "The exercise sessions are far too short for us."}
+		if i%2 == 1 {
+			f.Tags = models.Tags{"exam", "schedule-conflict"}
+		}
+		_, err := db.AddFeedback(f)
+		require.NoError(t, err)
+	}
+	_, err = db.UpdateFeedbackStatus(4, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err)
+
+	ids := func(filter *models.FeedbackFilter) []uint {
+		var ids []uint
+		err := db.EachFeedback(filter, func(f *models.Feedback) error {
+			ids = append(ids, f.ID)
+			return nil
+		})
+		require.NoError(t, err, "iterating on the feedback should not fail")
+		return ids
+	}
+	assert.Equal(t, []uint{1, 2, 3, 4}, ids(&models.FeedbackFilter{}))
+	assert.Equal(t, []uint{1, 3, 4}, ids(&models.FeedbackFilter{Faculty: "linfo"}))
+	assert.Equal(t, []uint{4}, ids(&models.FeedbackFilter{Course: "linfo1101", Status: models.FeedbackStatusAcknowledged}))
+	assert.Equal(t, []uint{2, 4}, ids(&models.FeedbackFilter{Tag: "schedule-conflict"}))
+	assert.Empty(t, ids(&models.FeedbackFilter{Tag: "schedule"}), "tags should match whole")
+
+	future := time.Now().Add(time.Hour)
+	assert.Empty(t, ids(&models.FeedbackFilter{Since: &future}))
+
+	fb, err := db.GetFilteredFeedback(&models.FeedbackFilter{Course: "LEPL1102"})
+	require.NoError(t, err)
+	require.Len(t, fb, 1)
+	assert.Equal(t, uint(2), fb[0].ID)
+	assert.Equal(t, models.Tags{"exam", "schedule-conflict"}, fb[0].Tags, "the tags should be stored")
+
+	stop := errors.New("stop")
+	calls := 0
+	err = db.EachFeedback(&models.FeedbackFilter{}, func(*models.Feedback) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls, "the iteration should stop at the first error")
 }
@@ -0,0 +1,157 @@
+/**
+ * file: database/follow.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the follows of anonymous voters
+ * database logic for the data persistance plane:
+ * the follows, the inboxes of the voters, and their
+ * Web Push subscriptions.
+ */
+
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPushEndpointTaken error = errors.New("push endpoint subscribed by another voter")
+)
+
+// AddFollow adds the follow, unless the voter already has the same
+// one. It returns the follow of the voter, and whether it was added.
+func (db *Database) AddFollow(f *models.Follow) (*models.Follow, bool, error) {
+	added := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(f)
+		if r.Error != nil {
+			return r.Error
+		}
+		if added = r.RowsAffected != 0; added {
+			return nil
+		}
+
+		return tx.
+			Where("token_hash = ? AND feedback_id = ? AND course = ? AND faculty = ? AND tag = ?", f.TokenHash, f.FeedbackID, f.Course, f.Faculty, f.Tag).
+			First(f).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return f, added, nil
+}
+
+// GetFollows returns the follows of the voter.
+func (db *Database) GetFollows(tokenHash []byte) ([]*models.Follow, error) {
+	var f []*models.Follow
+	if r := db.db.Where("token_hash = ?", tokenHash).Order("id").Find(&f); r.Error != nil {
+		return nil, r.Error
+	}
+	return f, nil
+}
+
+// DeleteFollow deletes the follow of the voter. It returns
+// gorm.ErrRecordNotFound if the voter has no such follow.
+func (db *Database) DeleteFollow(tokenHash []byte, id uint) error {
+	r := db.db.Where("id = ? AND token_hash = ?", id, tokenHash).Delete(&models.Follow{})
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetFollowsOf returns the follows covering the feedback: on the
+// feedback itself, its course, its faculty, or one of its tags.
+// Courses and faculties are followed in uppercase, whatever the case
+// the feedback was filed with.
+func (db *Database) GetFollowsOf(f *models.Feedback) ([]*models.Follow, error) {
+	var follows []*models.Follow
+	tx := db.db.Where("feedback_id = ? OR course = ? OR faculty = ?", f.ID, strings.ToUpper(f.Course), f.Faculty())
+	if len(f.Tags) != 0 {
+		tx = tx.Or("tag IN ?", []string(f.Tags))
+	}
+	r := tx.
+		Order("id").
+		Find(&follows)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return follows, nil
+}
+
+// AddFollowNotification adds the notification to the inbox of the
+// voter. It returns false if the voter was already notified of the
+// event.
+func (db *Database) AddFollowNotification(n *models.FollowNotification) (bool, error) {
+	r := db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(n)
+	return r.RowsAffected != 0, r.Error
+}
+
+// GetFollowNotifications returns at most limit notifications of the
+// voter added after the notification since, oldest first.
+func (db *Database) GetFollowNotifications(tokenHash []byte, since uint, limit int) ([]*models.FollowNotification, error) {
+	var n []*models.FollowNotification
+	r := db.db.
+		Where("token_hash = ? AND id > ?", tokenHash, since).
+		Order("id").
+		Limit(limit).
+		Find(&n)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return n, nil
+}
+
+// AddPushSubscription adds the push subscription of the voter. A
+// browser subscribing again replaces its previous subscription. It
+// returns ErrPushEndpointTaken if the endpoint is subscribed by
+// another voter.
+func (db *Database) AddPushSubscription(s *models.PushSubscription) error {
+	r := db.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"p256dh", "auth"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "push_subscriptions.token_hash = excluded.token_hash"},
+		}},
+	}).Create(s)
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return ErrPushEndpointTaken
+	}
+	return nil
+}
+
+// GetPushSubscriptions returns the push subscriptions of the voter.
+func (db *Database) GetPushSubscriptions(tokenHash []byte) ([]*models.PushSubscription, error) {
+	var s []*models.PushSubscription
+	if r := db.db.Where("token_hash = ?", tokenHash).Order("id").Find(&s); r.Error != nil {
+		return nil, r.Error
+	}
+	return s, nil
+}
+
+// DeletePushSubscription deletes the push subscription of the voter
+// with the given endpoint. It returns gorm.ErrRecordNotFound if the
+// voter has no such subscription.
+func (db *Database) DeletePushSubscription(tokenHash []byte, endpoint string) error {
+	r := db.db.Where("token_hash = ? AND endpoint = ?", tokenHash, endpoint).Delete(&models.PushSubscription{})
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/**
+ * file: database/follow_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the follow persistence.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestAddFollowExisting tests that following the same target twice
+// returns the existing follow.
+func TestAddFollowExisting(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	hash := []byte("hash")
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^INSERT INTO [`\"']follows[`\"'] .* ON CONFLICT DO NOTHING RETURNING [`\"']id[`\"']$").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.
+		ExpectQuery("^SELECT \\* FROM [`\"']follows[`\"'] WHERE token_hash = .* AND feedback_id = .* AND course = .* AND faculty = .* AND tag = .*$").
+		WithArgs(hash, 0, "LINFO1101", "", "", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "course"}).AddRow(3, "LINFO1101"))
+	mock.ExpectCommit()
+
+	f, added, err := db.AddFollow(&models.Follow{TokenHash: hash, Course: "LINFO1101"})
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, uint(3), f.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteFollowOther tests that voters cannot delete the follows
+// of others.
+func TestDeleteFollowOther(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("^DELETE FROM [`\"']follows[`\"'] WHERE id = .* AND token_hash = .*$").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, db.DeleteFollow([]byte("hash"), 3), gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
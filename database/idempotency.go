@@ -0,0 +1,85 @@
+/**
+ * file: database/idempotency.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the idempotency key database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClaimIdempotencyKey records the key as pending, unless it was
+// already used on the same scope by the same caller and has not
+// expired yet.
+// It returns true if the key was claimed, in which case the request
+// should be processed. Otherwise, the existing key is returned.
+func (db *Database) ClaimIdempotencyKey(key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	existing := new(models.IdempotencyKey)
+	claimed := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.
+			Where("key = ? AND scope = ? AND caller = ?", key.Key, key.Scope, key.Caller).
+			Where("expires_at <= ?", key.CreatedAt).
+			Delete(&models.IdempotencyKey{})
+		if r.Error != nil {
+			return r.Error
+		}
+
+		r = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 1 {
+			claimed = true
+			return nil
+		}
+
+		return tx.Where("key = ? AND scope = ? AND caller = ?", key.Key, key.Scope, key.Caller).First(existing).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if claimed {
+		return key, true, nil
+	}
+
+	return existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request made
+// with the pending key.
+func (db *Database) CompleteIdempotencyKey(key *models.IdempotencyKey) error {
+	return db.db.Model(&models.IdempotencyKey{}).
+		Where("key = ? AND scope = ? AND caller = ?", key.Key, key.Scope, key.Caller).
+		Updates(map[string]any{
+			"status":        key.Status,
+			"content_type":  key.ContentType,
+			"etag":          key.ETag,
+			"last_modified": key.LastModified,
+			"body":          key.Body,
+		}).Error
+}
+
+// ReleaseIdempotencyKey forgets the pending key, so that the
+// request can be retried.
+func (db *Database) ReleaseIdempotencyKey(key *models.IdempotencyKey) error {
+	return db.db.
+		Where("key = ? AND scope = ? AND caller = ?", key.Key, key.Scope, key.Caller).
+		Delete(&models.IdempotencyKey{}).Error
+}
+
+// DeleteExpiredIdempotencyKeys removes all keys expired at the
+// given time. It returns the number of keys removed.
+func (db *Database) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	r := db.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return r.RowsAffected, r.Error
+}
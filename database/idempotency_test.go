@@ -0,0 +1,108 @@
+/**
+ * file: database/idempotency_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the idempotency keys persistence.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idempotencyKey(key string, now time.Time) *models.IdempotencyKey {
+	return &models.IdempotencyKey{
+		Key:         key,
+		Scope:       "POST /feedback/",
+		Caller:      "caller",
+		RequestHash: "hash",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+}
+
+// TestClaimIdempotencyKey tests that a key can only be claimed
+// once, and that later claims get the stored response.
+func TestClaimIdempotencyKey(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err, "migrating should not fail")
+
+	now := time.Now()
+	k, claimed, err := db.ClaimIdempotencyKey(idempotencyKey("key", now))
+	require.NoError(t, err, "claiming a new key should not fail")
+	assert.True(t, claimed, "a new key should be claimed")
+	assert.True(t, k.Pending(), "a claimed key should be pending")
+
+	other := idempotencyKey("key", now)
+	other.Scope = "PATCH /feedback/1/upvote"
+	_, claimed, err = db.ClaimIdempotencyKey(other)
+	require.NoError(t, err, "claiming a key on another scope should not fail")
+	assert.True(t, claimed, "keys should be scoped to the endpoint")
+
+	other = idempotencyKey("key", now)
+	other.Caller = "other"
+	_, claimed, err = db.ClaimIdempotencyKey(other)
+	require.NoError(t, err, "claiming a key by another caller should not fail")
+	assert.True(t, claimed, "keys should be scoped to the caller")
+
+	k.Status = 200
+	k.ContentType = "application/json"
+	k.ETag = `"1"`
+	k.Body = []byte(`{"ID":1}`)
+	require.NoError(t, db.CompleteIdempotencyKey(k), "completing a key should not fail")
+
+	existing, claimed, err := db.ClaimIdempotencyKey(idempotencyKey("key", now.Add(time.Minute)))
+	require.NoError(t, err, "claiming a used key should not fail")
+	assert.False(t, claimed, "a used key should not be claimed again")
+	assert.Equal(t, 200, existing.Status, "the stored response should be returned")
+	assert.Equal(t, []byte(`{"ID":1}`), existing.Body, "the stored response should be returned")
+	assert.Equal(t, `"1"`, existing.ETag, "the stored response should be returned")
+
+	_, claimed, err = db.ClaimIdempotencyKey(idempotencyKey("key", now.Add(2*time.Hour)))
+	require.NoError(t, err, "claiming an expired key should not fail")
+	assert.True(t, claimed, "an expired key should be claimed again")
+}
+
+// TestReleaseIdempotencyKey tests that released keys can be
+// claimed again.
+func TestReleaseIdempotencyKey(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err, "migrating should not fail")
+
+	now := time.Now()
+	k, _, err := db.ClaimIdempotencyKey(idempotencyKey("key", now))
+	require.NoError(t, err, "claiming a new key should not fail")
+	require.NoError(t, db.ReleaseIdempotencyKey(k), "releasing a key should not fail")
+
+	_, claimed, err := db.ClaimIdempotencyKey(idempotencyKey("key", now))
+	require.NoError(t, err, "claiming a released key should not fail")
+	assert.True(t, claimed, "a released key should be claimed again")
+}
+
+// TestDeleteExpiredIdempotencyKeys tests that only expired keys
+// are removed.
+func TestDeleteExpiredIdempotencyKeys(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err, "migrating should not fail")
+
+	now := time.Now()
+	for i, key := range []string{"a", "b", "c"} {
+		_, _, err := db.ClaimIdempotencyKey(idempotencyKey(key, now.Add(time.Duration(i)*time.Hour)))
+		require.NoError(t, err, "claiming a new key should not fail")
+	}
+
+	n, err := db.DeleteExpiredIdempotencyKeys(now.Add(2 * time.Hour))
+	require.NoError(t, err, "deleting expired keys should not fail")
+	assert.Equal(t, int64(2), n, "only expired keys should be removed")
+}
@@ -0,0 +1,73 @@
+/**
+ * file: database/import.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the imports of feedback from
+ * other sources for the data persistance plane.
+ * Imported rows are recorded by their hash, so that
+ * they are imported only once.
+ */
+
+package database
+
+import (
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+)
+
+// GetImportedRows returns the ID of the feedback imported from each
+// of the rows with the given hashes, if any.
+func (db *Database) GetImportedRows(hashes []string) (map[string]uint, error) {
+	return getImportedRows(db.db, hashes)
+}
+
+func getImportedRows(tx *gorm.DB, hashes []string) (map[string]uint, error) {
+	var rows []*models.ImportedRow
+	if r := tx.Where("hash IN ?", hashes).Find(&rows); r.Error != nil {
+		return nil, r.Error
+	}
+
+	imported := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		imported[row.Hash] = row.FeedbackID
+	}
+	return imported, nil
+}
+
+// ImportFeedback stores the feedback of the valid rows in a single
+// transaction, and records its creation in the change feed. Rows
+// which were already imported are marked as duplicates instead.
+func (db *Database) ImportFeedback(source string, results []*models.ImportResult) error {
+	hashes := make([]string, len(results))
+	for i, r := range results {
+		hashes[i] = r.Hash
+	}
+
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		imported, err := getImportedRows(tx, hashes)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			if id, ok := imported[result.Hash]; ok {
+				result.Status, result.Feedback.ID = models.ImportRowDuplicate, id
+				continue
+			}
+
+			if r := tx.Create(result.Feedback); r.Error != nil {
+				return r.Error
+			}
+			if err := recordChange(tx, models.ChangeKindCreate, result.Feedback); err != nil {
+				return err
+			}
+			row := &models.ImportedRow{Hash: result.Hash, Source: source, FeedbackID: result.Feedback.ID}
+			if r := tx.Create(row); r.Error != nil {
+				return r.Error
+			}
+			result.Status = models.ImportRowImported
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+/**
+ * file: database/import_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the imports of feedback.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImportFeedback tests that rows are imported once, along with
+// their creation in the change feed.
+func TestImportFeedback(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	rows := func() []*models.ImportResult {
+		return []*models.ImportResult{
+			{Hash: "a", Feedback: &models.Feedback{Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us."}},
+			{Hash: "b", Feedback: &models.Feedback{Course: "LINFO1102", Feedback: "The slides are not published before the lectures."}},
+		}
+	}
+
+	first := rows()
+	require.NoError(t, db.ImportFeedback("forms.csv", first), "importing should not fail")
+	for _, r := range first {
+		assert.Equal(t, models.ImportRowImported, r.Status)
+		assert.NotZero(t, r.Feedback.ID)
+	}
+
+	again := rows()
+	require.NoError(t, db.ImportFeedback("forms.csv", again))
+	for i, r := range again {
+		assert.Equal(t, models.ImportRowDuplicate, r.Status, "rows should be imported once")
+		assert.Equal(t, first[i].Feedback.ID, r.Feedback.ID)
+	}
+
+	imported, err := db.GetImportedRows([]string{"a", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint{"a": first[0].Feedback.ID}, imported)
+
+	changes, err := db.GetChangesSince(0, 10)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2, "the creation of the imported feedback should be recorded")
+}
@@ -16,7 +16,6 @@ package database
 import (
 	"errors"
 
-	"git.licolas.net/delegit/delegit/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -28,6 +27,10 @@ var (
 
 type Database struct {
 	db *gorm.DB
+
+	// kind is the kind of database, as passed to NewDatabase. It
+	// selects the dialect specific parts, like migrations.
+	kind string
 }
 
 func NewDatabase(kind, dsn string) (*Database, error) {
@@ -41,17 +44,17 @@ func NewDatabase(kind, dsn string) (*Database, error) {
 		return nil, ErrInvalidDatabaseKind
 	}
 
-	db, err := NewDatabaseFromDialector(dialect, &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-
-	err = db.AutoMigrate()
-	return db, err
+	return NewDatabaseFromDialector(dialect, &gorm.Config{})
 }
 
 func NewDatabaseFromDialector(dialect gorm.Dialector, config *gorm.Config) (*Database, error) {
 	db := new(Database)
+	switch dialect.Name() {
+	case "postgres":
+		db.kind = "pgsql"
+	default:
+		db.kind = dialect.Name()
+	}
 
 	var err error
 	db.db, err = gorm.Open(dialect, config)
@@ -62,16 +65,12 @@ func NewDatabaseFromDialector(dialect gorm.Dialector, config *gorm.Config) (*Dat
 	return db, err
 }
 
-func (db *Database) AutoMigrate() error {
-	t := []any{
-		models.Feedback{},
-	}
-
-	for _, v := range t {
-		if err := db.db.AutoMigrate(&v); err != nil {
-			return err
-		}
-	}
-
-	return nil
+// Transaction runs fn in a transaction, with a database bound to the
+// transaction. The transaction is committed if fn returns nil, and
+// rolled back otherwise. Transactions started on the bound database
+// are nested in the outer transaction, using save points.
+func (db *Database) Transaction(fn func(tx *Database) error) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Database{db: tx, kind: db.kind})
+	})
 }
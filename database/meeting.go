@@ -0,0 +1,203 @@
+/**
+ * file: database/meeting.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the council meeting database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddMeeting adds the meeting, with the given feedback on its
+// agenda, in order.
+func (db *Database) AddMeeting(meeting *models.Meeting, feedback []uint) (*models.Meeting, error) {
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Create(meeting); r.Error != nil {
+			return r.Error
+		}
+		if len(feedback) == 0 {
+			return nil
+		}
+
+		items := make([]*models.MeetingItem, len(feedback))
+		for i, id := range feedback {
+			items[i] = &models.MeetingItem{MeetingID: meeting.ID, FeedbackID: id, Position: i + 1}
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return meeting, nil
+}
+
+// GetMeetings returns the meetings starting from since, included,
+// until until, excluded, in chronological order. Nil bounds are not
+// applied.
+func (db *Database) GetMeetings(since, until *time.Time) (m []*models.Meeting, err error) {
+	tx := db.db.Order("starts_at").Order("id")
+	if since != nil {
+		tx = tx.Where("starts_at >= ?", *since)
+	}
+	if until != nil {
+		tx = tx.Where("starts_at < ?", *until)
+	}
+
+	err = tx.Find(&m).Error
+	return
+}
+
+func (db *Database) GetMeeting(id uint) (*models.Meeting, error) {
+	m := new(models.Meeting)
+	if r := db.db.First(&m, id); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return m, nil
+}
+
+// DeleteMeeting deletes the meeting and its agenda. It returns false
+// if the meeting does not exist.
+func (db *Database) DeleteMeeting(id uint) (bool, error) {
+	deleted := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Where("meeting_id = ?", id).Delete(&models.MeetingItem{}); r.Error != nil {
+			return r.Error
+		}
+
+		r := tx.Delete(&models.Meeting{}, id)
+		deleted = r.RowsAffected == 1
+		return r.Error
+	})
+
+	return deleted, err
+}
+
+// GetMeetingItems returns the agenda of the meeting, in order, along
+// with the feedback of each item. Deleted feedback is left out.
+func (db *Database) GetMeetingItems(id uint) ([]*models.MeetingItem, error) {
+	var items []*models.MeetingItem
+	r := db.db.
+		Joins("JOIN feedbacks ON feedbacks.id = meeting_items.feedback_id AND feedbacks.deleted_at IS NULL").
+		Where("meeting_items.meeting_id = ?", id).
+		Order("meeting_items.position").
+		Find(&items)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.FeedbackID
+	}
+	var f []*models.Feedback
+	if r := db.db.Where("id IN ?", ids).Find(&f); r.Error != nil {
+		return nil, r.Error
+	}
+
+	feedback := make(map[uint]*models.Feedback, len(f))
+	for _, f := range f {
+		feedback[f.ID] = f
+	}
+	for _, item := range items {
+		item.Feedback = feedback[item.FeedbackID]
+	}
+	return items, nil
+}
+
+// SetMeetingItem attaches the feedback to the agenda of the meeting,
+// after the items already attached, with the given notes. The notes
+// of feedback already attached are replaced.
+func (db *Database) SetMeetingItem(meetingID, feedbackID uint, notes string) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		var position int
+		r := tx.Model(&models.MeetingItem{}).
+			Select("COALESCE(MAX(position), 0)").
+			Where("meeting_id = ?", meetingID).
+			Scan(&position)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		item := &models.MeetingItem{MeetingID: meetingID, FeedbackID: feedbackID, Position: position + 1, Notes: notes}
+		r = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "meeting_id"}, {Name: "feedback_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"notes"}),
+		}).Create(item)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		return touchMeeting(tx, meetingID, time.Now())
+	})
+}
+
+// RemoveMeetingItem removes the feedback from the agenda of the
+// meeting. It returns false if the feedback was not attached.
+func (db *Database) RemoveMeetingItem(meetingID, feedbackID uint) (bool, error) {
+	removed := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Where("meeting_id = ? AND feedback_id = ?", meetingID, feedbackID).Delete(&models.MeetingItem{})
+		if r.Error != nil {
+			return r.Error
+		}
+
+		removed = r.RowsAffected == 1
+		if !removed {
+			return nil
+		}
+		return touchMeeting(tx, meetingID, time.Now())
+	})
+
+	return removed, err
+}
+
+// RecordMeetingOutcome records the outcome of the item of the
+// meeting, decided at the given time. The meeting is held from its
+// first outcome on. It returns gorm.ErrRecordNotFound if the
+// feedback is not on the agenda.
+func (db *Database) RecordMeetingOutcome(meetingID uint, o *models.MeetingOutcome, at time.Time) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Model(&models.MeetingItem{}).
+			Where("meeting_id = ? AND feedback_id = ?", meetingID, o.FeedbackID).
+			UpdateColumns(map[string]any{
+				"outcome":    o.Status,
+				"response":   o.Response,
+				"decided_at": at,
+			})
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		r = tx.Model(&models.Meeting{}).
+			Where("id = ?", meetingID).
+			UpdateColumns(map[string]any{
+				"held_at":    gorm.Expr("COALESCE(held_at, ?)", at),
+				"updated_at": at,
+			})
+		return r.Error
+	})
+}
+
+// touchMeeting bumps the update time of the meeting, so that the
+// calendar events of its agenda are refreshed.
+func touchMeeting(tx *gorm.DB, id uint, at time.Time) error {
+	r := tx.Model(&models.Meeting{}).Where("id = ?", id).UpdateColumn("updated_at", at)
+	return r.Error
+}
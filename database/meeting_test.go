@@ -0,0 +1,108 @@
+/**
+ * file: database/meeting_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the council meeting persistence.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMeetingItems tests that agendas keep their order, that notes
+// are replaced, and that deleted feedback is left out.
+func TestMeetingItems(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := db.AddFeedback(&models.Feedback{Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us."})
+		require.NoError(t, err)
+	}
+
+	start := time.Date(2025, 3, 12, 14, 0, 0, 0, time.UTC)
+	m, err := db.AddMeeting(&models.Meeting{Body: "LINFO faculty council", Scope: models.StatsScopeFaculty, Code: "LINFO", StartsAt: start, EndsAt: start.Add(time.Hour)}, []uint{2, 1})
+	require.NoError(t, err, "adding a meeting should not fail")
+
+	require.NoError(t, db.SetMeetingItem(m.ID, 3, "Raise it first"))
+	require.NoError(t, db.SetMeetingItem(m.ID, 1, "Ask for two more sessions"))
+
+	items, err := db.GetMeetingItems(m.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	for i, id := range []uint{2, 1, 3} {
+		assert.Equal(t, id, items[i].FeedbackID, "items should be in order")
+		assert.Equal(t, id, items[i].Feedback.ID, "items should hold their feedback")
+	}
+	assert.Equal(t, "Ask for two more sessions", items[1].Notes, "notes should be replaced")
+
+	_, err = db.DeleteFeedback(2, "spam", nil)
+	require.NoError(t, err)
+	removed, err := db.RemoveMeetingItem(m.ID, 3)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = db.RemoveMeetingItem(m.ID, 3)
+	require.NoError(t, err)
+	assert.False(t, removed, "removing an item twice should have no effect")
+
+	items, err = db.GetMeetingItems(m.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 1, "deleted feedback should be left out")
+	assert.Equal(t, uint(1), items[0].FeedbackID)
+
+	// Outcomes are only recorded on the agenda, and the meeting is
+	// held from the first one on.
+	decided := start.Add(30 * time.Minute)
+	require.NoError(t, db.RecordMeetingOutcome(m.ID, &models.MeetingOutcome{FeedbackID: 1, Status: models.FeedbackStatusResolved, Response: "Two sessions were added."}, decided))
+	assert.Error(t, db.RecordMeetingOutcome(m.ID, &models.MeetingOutcome{FeedbackID: 3, Status: models.FeedbackStatusResolved, Response: "Done."}, decided))
+	require.NoError(t, db.RecordMeetingOutcome(m.ID, &models.MeetingOutcome{FeedbackID: 1, Status: models.FeedbackStatusResolved, Response: "Two sessions were added."}, decided.Add(time.Hour)))
+
+	m, err = db.GetMeeting(m.ID)
+	require.NoError(t, err)
+	require.NotNil(t, m.HeldAt)
+	assert.True(t, decided.Equal(*m.HeldAt), "the meeting should be held at its first outcome")
+
+	deleted, err := db.DeleteMeeting(m.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	items, err = db.GetMeetingItems(m.ID)
+	require.NoError(t, err)
+	assert.Empty(t, items, "the agenda should be deleted along with the meeting")
+}
+
+// TestRespondFeedback tests that responses change the status of the
+// feedback, and are recorded in the change feed.
+func TestRespondFeedback(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	_, err = db.AddFeedback(&models.Feedback{Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us."})
+	require.NoError(t, err)
+
+	f, err := db.RespondFeedback(1, models.FeedbackStatusAcknowledged, "We will raise it with the teacher.", nil)
+	require.NoError(t, err, "responding should not fail")
+	assert.Equal(t, models.FeedbackStatusAcknowledged, f.Status)
+	assert.Equal(t, "We will raise it with the teacher.", f.Response)
+	assert.NotNil(t, f.RespondedAt)
+	assert.Equal(t, uint64(2), f.Version, "the version should be bumped")
+
+	changes, err := db.GetChangesSince(0, 10)
+	require.NoError(t, err)
+	last := changes[len(changes)-1]
+	assert.Equal(t, models.ChangeKindStatus, last.Kind)
+	assert.Contains(t, string(last.Snapshot), "We will raise it with the teacher.", "the response should be in the change feed")
+
+	_, err = db.RespondFeedback(42, models.FeedbackStatusAcknowledged, "Unknown.", nil)
+	assert.Error(t, err, "responding to unknown feedback should fail")
+}
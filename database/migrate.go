@@ -0,0 +1,376 @@
+/**
+ * file: database/migrate.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the versioned schema
+ * migrations of the data persistance plane.
+ */
+
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoMigration      error = errors.New("no migration to roll back")
+	ErrSchemaVersion    error = errors.New("unexpected schema version")
+	ErrInvalidMigration error = errors.New("invalid migration")
+)
+
+// Migrations are embedded per dialect, in the migrations/<kind>
+// directory. Each migration is made of a pair of scripts named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Statements within a script must end with a semicolon at the end
+// of a line.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// A Migration is one versioned step of the database schema.
+type Migration struct {
+	// Version orders the migrations. Versions start at 1, and have
+	// no gaps.
+	Version uint
+
+	// Name describes the migration, as found in the names of its
+	// scripts.
+	Name string
+
+	// Up and Down are the scripts applying the migration, and
+	// rolling it back.
+	Up   string
+	Down string
+}
+
+// The MigrationStatus structure describes whether a migration
+// was applied to the database, and when.
+type MigrationStatus struct {
+	Migration
+
+	// Applied is true if the migration was applied to the database,
+	// at AppliedAt. AppliedAt is zero otherwise.
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// schemaMigrationsTable creates the table recording the applied
+// migrations. It is portable across all database kinds.
+const schemaMigrationsTable string = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamp NOT NULL
+)`
+
+// schemaMigration is a migration applied to the database, as
+// recorded in the schema_migrations table.
+type schemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations returns all migrations available for the kind of the
+// database, ordered by version.
+func (db *Database) Migrations() ([]Migration, error) {
+	dir := path.Join("migrations", db.kind)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, e := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: unexpected file %q", ErrInvalidMigration, e.Name())
+		}
+
+		_version, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(_version, 10, 32)
+		if !ok || err != nil || version == 0 {
+			return nil, fmt.Errorf("%w: unexpected file %q", ErrInvalidMigration, e.Name())
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[m.Version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("%w: version %d has conflicting names", ErrInvalidMigration, version)
+		}
+
+		if direction == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != uint(i+1) {
+			return nil, fmt.Errorf("%w: version %d is missing", ErrInvalidMigration, i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// appliedMigrations returns the migrations recorded as applied,
+// ordered by version. A database without migrations table has
+// no migrations applied.
+func (db *Database) appliedMigrations() ([]schemaMigration, error) {
+	applied := []schemaMigration{}
+	if !db.db.Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+
+	err := db.db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// SchemaVersion returns the version of the last migration applied
+// to the database, 0 if none was.
+func (db *Database) SchemaVersion() (uint, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+
+	return applied[len(applied)-1].Version, nil
+}
+
+// LatestSchemaVersion returns the version of the last migration
+// available, which is the version expected by the application.
+func (db *Database) LatestSchemaVersion() (uint, error) {
+	migrations, err := db.Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// CheckSchemaVersion returns ErrSchemaVersion if the database is not
+// at the schema version expected by the application.
+func (db *Database) CheckSchemaVersion() error {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest, err := db.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if current != latest {
+		return fmt.Errorf("%w: database is at version %d, expected %d", ErrSchemaVersion, current, latest)
+	}
+
+	return nil
+}
+
+// MigrationStatus returns all available migrations, along with
+// whether they were applied to the database.
+func (db *Database) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := map[uint]time.Time{}
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		at, ok := appliedAt[m.Version]
+		status = append(status, MigrationStatus{Migration: m, Applied: ok, AppliedAt: at})
+	}
+
+	return status, nil
+}
+
+// MigrateUp applies all pending migrations in order, each in its
+// own transaction. It returns the migrations applied.
+func (db *Database) MigrateUp() ([]Migration, error) {
+	if err := db.db.Exec(schemaMigrationsTable).Error; err != nil {
+		return nil, err
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		err := db.runMigration(m.Up, func(tx *gorm.DB) error {
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown rolls back the last migration applied, in a single
+// transaction. It returns the migration rolled back, or
+// ErrNoMigration if no migration was applied.
+func (db *Database) MigrateDown() (*Migration, error) {
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current == 0 {
+		return nil, ErrNoMigration
+	}
+	if current > uint(len(migrations)) {
+		return nil, fmt.Errorf("%w: database is at version %d, which is unknown", ErrSchemaVersion, current)
+	}
+
+	m := migrations[current-1]
+	err = db.runMigration(m.Down, func(tx *gorm.DB) error {
+		return tx.Delete(&schemaMigration{Version: m.Version}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+	}
+
+	return &m, nil
+}
+
+// runMigration executes the migration script in a transaction, and
+// records it with record in the same transaction.
+// SQLite rebuilds tables to change their columns, and dropping a
+// table referenced with ON DELETE CASCADE would delete the rows
+// referencing it if foreign keys are enforced. Foreign keys cannot
+// be disabled within a transaction, so they are disabled on the
+// connection for the duration of the migration, and checked before
+// it is committed, as recommended for SQLite table rebuilds.
+func (db *Database) runMigration(script string, record func(tx *gorm.DB) error) error {
+	migrate := func(tx *gorm.DB) error {
+		if err := execScript(tx, script); err != nil {
+			return err
+		}
+
+		return record(tx)
+	}
+	if db.kind != "sqlite" {
+		return db.db.Transaction(migrate)
+	}
+
+	return db.db.Connection(func(conn *gorm.DB) error {
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		var enforced int
+		if err := conn.Raw("PRAGMA foreign_keys").Scan(&enforced).Error; err != nil {
+			return err
+		}
+		if enforced == 0 {
+			return conn.Transaction(migrate)
+		}
+
+		if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := migrate(tx); err != nil {
+				return err
+			}
+
+			rows, err := tx.Raw("PRAGMA foreign_key_check").Rows()
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			if rows.Next() {
+				return fmt.Errorf("%w: rows violate foreign keys", ErrInvalidMigration)
+			}
+			return rows.Err()
+		})
+	})
+}
+
+// execScript executes the statements of a migration script one by
+// one. Statements end with a semicolon at the end of a line.
+func execScript(tx *gorm.DB, script string) error {
+	for _, statement := range strings.Split(script, ";\n") {
+		if isBlankStatement(statement) {
+			continue
+		}
+
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isBlankStatement returns true if the statement is made of
+// whitespace and comments only.
+func isBlankStatement(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,178 @@
+/**
+ * file: database/migrate_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the versioned schema migrations.
+ */
+
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func createSQLiteDatabase(t *testing.T) *Database {
+	db, err := NewDatabaseFromDialector(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	require.NoError(t, err, "could not create database")
+	return db
+}
+
+// TestMigrationsDialectsInSync tests that every database kind
+// provides the same migrations, each with an up and down script.
+func TestMigrationsDialectsInSync(t *testing.T) {
+	sqliteMigrations, err := (&Database{kind: "sqlite"}).Migrations()
+	require.NoError(t, err, "sqlite migrations should be valid")
+	pgsqlMigrations, err := (&Database{kind: "pgsql"}).Migrations()
+	require.NoError(t, err, "pgsql migrations should be valid")
+
+	require.Equal(t, len(sqliteMigrations), len(pgsqlMigrations), "all kinds should have the same migrations")
+	for i := range sqliteMigrations {
+		assert.Equal(t, sqliteMigrations[i].Version, pgsqlMigrations[i].Version)
+		assert.Equal(t, sqliteMigrations[i].Name, pgsqlMigrations[i].Name)
+		for _, m := range []Migration{sqliteMigrations[i], pgsqlMigrations[i]} {
+			assert.NotEmpty(t, m.Up, "migration %d should have an up script", m.Version)
+			assert.NotEmpty(t, m.Down, "migration %d should have a down script", m.Version)
+		}
+	}
+}
+
+// TestMigrateUpDown tests that all migrations can be applied,
+// and rolled back one by one.
+func TestMigrateUpDown(t *testing.T) {
+	db := createSQLiteDatabase(t)
+
+	version, err := db.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), version, "a new database should not have any migration applied")
+	assert.ErrorIs(t, db.CheckSchemaVersion(), ErrSchemaVersion, "a new database should not pass the version check")
+
+	latest, err := db.LatestSchemaVersion()
+	require.NoError(t, err)
+
+	applied, err := db.MigrateUp()
+	require.NoError(t, err, "migrating up should not fail")
+	assert.Len(t, applied, int(latest), "all migrations should be applied")
+	assert.NoError(t, db.CheckSchemaVersion(), "a migrated database should pass the version check")
+
+	applied, err = db.MigrateUp()
+	require.NoError(t, err, "migrating up twice should not fail")
+	assert.Empty(t, applied, "no migration should be applied twice")
+
+	status, err := db.MigrationStatus()
+	require.NoError(t, err)
+	for _, s := range status {
+		assert.True(t, s.Applied, "migration %d should be applied", s.Version)
+	}
+
+	for v := latest; v > 0; v-- {
+		m, err := db.MigrateDown()
+		require.NoError(t, err, "migrating down should not fail")
+		assert.Equal(t, v, m.Version, "migrations should be rolled back in reverse order")
+
+		version, err := db.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, v-1, version)
+	}
+
+	_, err = db.MigrateDown()
+	assert.ErrorIs(t, err, ErrNoMigration, "there should be nothing left to roll back")
+	assert.False(t, db.db.Migrator().HasTable(&models.Feedback{}), "all tables should be dropped")
+
+	applied, err = db.MigrateUp()
+	require.NoError(t, err, "migrating up again should not fail")
+	assert.Len(t, applied, int(latest), "all migrations should be applied again")
+}
+
+// TestMigrateUpAdoptsExistingSchema tests that a database created
+// before versioned migrations is adopted, keeping the stored
+// feedback, and that its vote counters are widened so that they
+// can grow past the former ceiling.
+func TestMigrateUpAdoptsExistingSchema(t *testing.T) {
+	db := createSQLiteDatabase(t)
+
+	require.NoError(t, db.db.Exec("CREATE TABLE `feedbacks` (`id` integer PRIMARY KEY AUTOINCREMENT,`course` text NOT NULL,`feedback` text NOT NULL,`upvotes` integer DEFAULT 0,`downvotes` integer DEFAULT 0)").Error)
+	require.NoError(t, db.db.Exec("INSERT INTO `feedbacks` (`course`, `feedback`, `upvotes`, `downvotes`) VALUES ('LINFO1101', 'The exam overlaps with another exam of the same year.', 2000, 12)").Error)
+
+	_, err := db.MigrateUp()
+	require.NoError(t, err, "migrating an existing database should not fail")
+
+	columns, err := db.db.Migrator().ColumnTypes(&models.Feedback{})
+	require.NoError(t, err)
+	for _, c := range columns {
+		if c.Name() == "upvotes" || c.Name() == "downvotes" {
+			assert.Equal(t, "bigint", strings.ToLower(c.DatabaseTypeName()), "vote counters should be widened")
+		}
+	}
+
+	f, err := db.IncrementFeedbackUpvotes(1)
+	require.NoError(t, err, "votes past the former ceiling should be accepted")
+	assert.Equal(t, uint64(2001), f.Upvotes)
+	assert.Equal(t, uint64(12), f.Downvotes)
+	assert.Equal(t, "LINFO1101", f.Course, "stored feedback should be kept")
+}
+
+// TestMigrateUpEnforcedForeignKeys tests that rebuilding tables does
+// not cascade to the tables referencing them when foreign keys are
+// enforced.
+func TestMigrateUpEnforcedForeignKeys(t *testing.T) {
+	db, err := NewDatabaseFromDialector(sqlite.Open(t.TempDir()+"/test.db?_foreign_keys=1"), &gorm.Config{})
+	require.NoError(t, err, "could not create database")
+
+	// A single connection is used, so that the pragmas read are the
+	// ones of the connection used by the migrations.
+	sqlDB, err := db.db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	var enforced int
+	require.NoError(t, db.db.Raw("PRAGMA foreign_keys").Scan(&enforced).Error)
+	require.Equal(t, 1, enforced, "foreign keys should be enforced")
+
+	migrations, err := db.Migrations()
+	require.NoError(t, err)
+	require.NoError(t, db.db.Exec(schemaMigrationsTable).Error)
+	require.NoError(t, db.runMigration(migrations[0].Up, func(tx *gorm.DB) error {
+		return tx.Create(&schemaMigration{Version: 1, Name: migrations[0].Name, AppliedAt: time.Now()}).Error
+	}))
+	require.NoError(t, db.db.Exec("INSERT INTO feedbacks (course, feedback) VALUES ('LINFO1101', 'The exam overlaps with another exam of the same year.')").Error)
+	require.NoError(t, db.db.Exec("INSERT INTO votes (feedback_id, kind, delta, created_at) VALUES (1, 'upvote', 1, CURRENT_TIMESTAMP)").Error)
+
+	_, err = db.MigrateUp()
+	require.NoError(t, err, "migrating with foreign keys enforced should not fail")
+
+	var votes int64
+	require.NoError(t, db.db.Table("votes").Count(&votes).Error)
+	assert.Equal(t, int64(1), votes, "the votes should be kept when feedbacks is rebuilt")
+	require.NoError(t, db.db.Raw("PRAGMA foreign_keys").Scan(&enforced).Error)
+	assert.Equal(t, 1, enforced, "foreign keys should be enforced again")
+
+	for v, err := db.MigrateDown(); err == nil; v, err = db.MigrateDown() {
+		require.NotNil(t, v)
+	}
+	version, err := db.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), version, "all migrations should be rolled back")
+}
+
+// TestExecScript tests that scripts are split into statements,
+// skipping comments and blank statements.
+func TestExecScript(t *testing.T) {
+	db := createSQLiteDatabase(t)
+
+	script := "-- a comment; with a semicolon\nCREATE TABLE a (id integer);\n\nCREATE TABLE b (id integer);\n-- trailing comment\n"
+	require.NoError(t, execScript(db.db, script))
+	assert.True(t, db.db.Migrator().HasTable("a"))
+	assert.True(t, db.db.Migrator().HasTable("b"))
+
+	assert.NoError(t, execScript(db.db, "-- Nothing to do.\n"), "comment only scripts should do nothing")
+}
@@ -0,0 +1,27 @@
+-- Baseline schema, as created by the former automatic migration.
+-- Existing databases are adopted as they are.
+CREATE TABLE IF NOT EXISTS feedbacks (
+	id bigserial PRIMARY KEY,
+	course varchar(10) NOT NULL,
+	feedback text NOT NULL,
+	upvotes smallint DEFAULT 0,
+	downvotes smallint DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS votes (
+	id bigserial PRIMARY KEY,
+	feedback_id bigint NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	kind varchar(10) NOT NULL,
+	delta bigint NOT NULL,
+	token varchar(64),
+	address varchar(45),
+	created_at timestamptz NOT NULL,
+	flag varchar(20),
+	quarantined boolean NOT NULL DEFAULT false,
+	reviewed boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS idx_votes_feedback_id ON votes (feedback_id);
+CREATE INDEX IF NOT EXISTS idx_votes_token ON votes (token);
+CREATE INDEX IF NOT EXISTS idx_votes_address ON votes (address);
+CREATE INDEX IF NOT EXISTS idx_votes_created_at ON votes (created_at);
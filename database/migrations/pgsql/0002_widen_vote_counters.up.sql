@@ -0,0 +1,8 @@
+UPDATE feedbacks SET upvotes = 0 WHERE upvotes IS NULL;
+UPDATE feedbacks SET downvotes = 0 WHERE downvotes IS NULL;
+
+ALTER TABLE feedbacks
+	ALTER COLUMN upvotes TYPE bigint,
+	ALTER COLUMN upvotes SET NOT NULL,
+	ALTER COLUMN downvotes TYPE bigint,
+	ALTER COLUMN downvotes SET NOT NULL;
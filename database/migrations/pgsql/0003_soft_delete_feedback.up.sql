@@ -0,0 +1,5 @@
+ALTER TABLE feedbacks
+	ADD COLUMN deleted_at timestamptz,
+	ADD COLUMN delete_reason varchar(500);
+
+CREATE INDEX idx_feedbacks_deleted_at ON feedbacks (deleted_at);
@@ -0,0 +1,6 @@
+DROP INDEX idx_feedbacks_updated_at;
+
+ALTER TABLE feedbacks
+	DROP COLUMN updated_at,
+	DROP COLUMN created_at,
+	DROP COLUMN version;
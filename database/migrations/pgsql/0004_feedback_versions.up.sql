@@ -0,0 +1,12 @@
+-- Existing feedback is considered created and last changed when
+-- the migration is applied.
+ALTER TABLE feedbacks
+	ADD COLUMN version bigint NOT NULL DEFAULT 1,
+	ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+	ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+
+ALTER TABLE feedbacks
+	ALTER COLUMN created_at DROP DEFAULT,
+	ALTER COLUMN updated_at DROP DEFAULT;
+
+CREATE INDEX idx_feedbacks_updated_at ON feedbacks (updated_at);
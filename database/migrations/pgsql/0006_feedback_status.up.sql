@@ -0,0 +1,3 @@
+ALTER TABLE feedbacks ADD COLUMN status varchar(20) NOT NULL DEFAULT 'new';
+
+CREATE INDEX idx_feedbacks_status ON feedbacks (status);
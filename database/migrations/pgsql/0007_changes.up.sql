@@ -0,0 +1,10 @@
+CREATE TABLE changes (
+	seq bigserial PRIMARY KEY,
+	feedback_id bigint NOT NULL,
+	kind varchar(20) NOT NULL,
+	version bigint NOT NULL,
+	snapshot bytea,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_changes_feedback_id ON changes (feedback_id);
@@ -0,0 +1,22 @@
+CREATE TABLE sessions (
+	id bigserial PRIMARY KEY,
+	title varchar(200) NOT NULL,
+	status varchar(10) NOT NULL DEFAULT 'idle',
+	round bigint NOT NULL DEFAULT 0,
+	closes_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE TABLE session_items (
+	session_id bigint NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+	feedback_id bigint NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	position integer NOT NULL,
+	PRIMARY KEY (session_id, feedback_id)
+);
+
+ALTER TABLE votes ADD COLUMN session_id bigint;
+
+ALTER TABLE votes ADD COLUMN session_round bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_votes_session ON votes (session_id, session_round);
@@ -0,0 +1,36 @@
+CREATE TABLE webhooks (
+	id bigserial PRIMARY KEY,
+	url varchar(2000) NOT NULL,
+	events text,
+	course varchar(10),
+	secret varchar(255) NOT NULL,
+	cursor bigint NOT NULL DEFAULT 0,
+	created_at timestamptz
+);
+
+CREATE TABLE webhook_deliveries (
+	id bigserial PRIMARY KEY,
+	webhook_id bigint NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	seq bigint NOT NULL,
+	event varchar(20) NOT NULL,
+	payload bytea NOT NULL,
+	status varchar(10) NOT NULL DEFAULT 'pending',
+	attempts integer NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz NOT NULL,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
+
+CREATE TABLE webhook_attempts (
+	id bigserial PRIMARY KEY,
+	delivery_id bigint NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+	status_code integer NOT NULL,
+	error varchar(500),
+	duration bigint NOT NULL,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_webhook_attempts_delivery_id ON webhook_attempts (delivery_id);
@@ -0,0 +1,22 @@
+CREATE TABLE alert_rules (
+	id bigserial PRIMARY KEY,
+	name varchar(100) NOT NULL,
+	course varchar(10),
+	faculty varchar(6),
+	kind varchar(20) NOT NULL,
+	threshold double precision NOT NULL DEFAULT 0,
+	min_votes bigint NOT NULL DEFAULT 0,
+	vote_window varchar(20),
+	channels text,
+	created_at timestamptz
+);
+
+CREATE TABLE alerts (
+	id bigserial PRIMARY KEY,
+	rule_id bigint NOT NULL REFERENCES alert_rules (id) ON DELETE CASCADE,
+	feedback_id bigint NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	value double precision NOT NULL,
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_alerts_rule_feedback ON alerts (rule_id, feedback_id);
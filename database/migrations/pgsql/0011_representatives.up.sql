@@ -0,0 +1,14 @@
+CREATE TABLE representatives (
+	id bigserial PRIMARY KEY,
+	name varchar(100) NOT NULL,
+	email varchar(254) NOT NULL,
+	language varchar(5) NOT NULL DEFAULT 'en',
+	courses text,
+	faculties text,
+	digest_frequency varchar(10) NOT NULL DEFAULT 'weekly',
+	last_digest_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_representatives_email ON representatives (email);
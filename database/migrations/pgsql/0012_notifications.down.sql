@@ -0,0 +1,7 @@
+DROP TABLE notifications;
+
+DROP INDEX idx_representatives_token_hash;
+
+ALTER TABLE representatives DROP COLUMN token_hash;
+
+ALTER TABLE representatives DROP COLUMN inbox_events;
@@ -0,0 +1,21 @@
+ALTER TABLE representatives ADD COLUMN inbox_events text;
+
+ALTER TABLE representatives ADD COLUMN token_hash bytea;
+
+CREATE UNIQUE INDEX idx_representatives_token_hash ON representatives (token_hash);
+
+CREATE TABLE notifications (
+	id bigserial PRIMARY KEY,
+	representative_id bigint NOT NULL REFERENCES representatives (id) ON DELETE CASCADE,
+	kind varchar(20) NOT NULL,
+	ref bigint NOT NULL,
+	feedback_id bigint NOT NULL,
+	course varchar(10) NOT NULL,
+	detail varchar(200),
+	read_at timestamptz,
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_notifications_event ON notifications (representative_id, kind, ref);
+
+CREATE INDEX idx_notifications_created_at ON notifications (created_at);
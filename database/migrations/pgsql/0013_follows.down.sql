@@ -0,0 +1,5 @@
+DROP TABLE push_subscriptions;
+
+DROP TABLE follow_notifications;
+
+DROP TABLE follows;
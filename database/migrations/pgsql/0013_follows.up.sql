@@ -0,0 +1,44 @@
+CREATE TABLE follows (
+	id bigserial PRIMARY KEY,
+	token_hash bytea NOT NULL,
+	feedback_id bigint NOT NULL DEFAULT 0,
+	course varchar(10) NOT NULL DEFAULT '',
+	faculty varchar(6) NOT NULL DEFAULT '',
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_follows_target ON follows (token_hash, feedback_id, course, faculty);
+
+CREATE INDEX idx_follows_feedback_id ON follows (feedback_id);
+
+CREATE INDEX idx_follows_course ON follows (course);
+
+CREATE INDEX idx_follows_faculty ON follows (faculty);
+
+CREATE TABLE follow_notifications (
+	id bigserial PRIMARY KEY,
+	token_hash bytea NOT NULL,
+	kind varchar(20) NOT NULL,
+	ref bigint NOT NULL,
+	feedback_id bigint NOT NULL,
+	course varchar(10) NOT NULL,
+	detail varchar(200),
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_follow_notifications_event ON follow_notifications (token_hash, kind, ref);
+
+CREATE INDEX idx_follow_notifications_created_at ON follow_notifications (created_at);
+
+CREATE TABLE push_subscriptions (
+	id bigserial PRIMARY KEY,
+	token_hash bytea NOT NULL,
+	endpoint varchar(500) NOT NULL,
+	p256dh varchar(100) NOT NULL,
+	auth varchar(50) NOT NULL,
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_push_subscriptions_endpoint ON push_subscriptions (endpoint);
+
+CREATE INDEX idx_push_subscriptions_token_hash ON push_subscriptions (token_hash);
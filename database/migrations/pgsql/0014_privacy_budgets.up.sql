@@ -0,0 +1,11 @@
+CREATE TABLE privacy_budgets (
+	id bigserial PRIMARY KEY,
+	scope varchar(10) NOT NULL,
+	code varchar(10) NOT NULL,
+	term varchar(20) NOT NULL,
+	spent double precision NOT NULL DEFAULT 0,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_privacy_budgets_target ON privacy_budgets (scope, code, term);
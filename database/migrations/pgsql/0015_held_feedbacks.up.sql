@@ -0,0 +1,11 @@
+CREATE TABLE held_feedbacks (
+	id bigserial PRIMARY KEY,
+	course varchar(10) NOT NULL,
+	feedback text NOT NULL,
+	publish_at timestamptz NOT NULL,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_held_feedbacks_course ON held_feedbacks (course);
+
+CREATE INDEX idx_held_feedbacks_publish_at ON held_feedbacks (publish_at);
@@ -0,0 +1,8 @@
+CREATE TABLE imported_rows (
+	hash varchar(64) PRIMARY KEY,
+	source varchar(200) NOT NULL,
+	feedback_id bigint NOT NULL,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_imported_rows_feedback_id ON imported_rows (feedback_id);
@@ -0,0 +1,7 @@
+DROP TABLE meeting_items;
+
+DROP TABLE meetings;
+
+ALTER TABLE feedbacks DROP COLUMN responded_at;
+
+ALTER TABLE feedbacks DROP COLUMN response;
@@ -0,0 +1,30 @@
+ALTER TABLE feedbacks ADD COLUMN response text NOT NULL DEFAULT '';
+
+ALTER TABLE feedbacks ADD COLUMN responded_at timestamptz;
+
+CREATE TABLE meetings (
+	id bigserial PRIMARY KEY,
+	body varchar(200) NOT NULL,
+	scope varchar(10) NOT NULL,
+	code varchar(10) NOT NULL,
+	starts_at timestamptz NOT NULL,
+	ends_at timestamptz NOT NULL,
+	location varchar(200) NOT NULL DEFAULT '',
+	representative_id bigint REFERENCES representatives (id) ON DELETE SET NULL,
+	held_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE INDEX idx_meetings_starts_at ON meetings (starts_at);
+
+CREATE TABLE meeting_items (
+	meeting_id bigint NOT NULL REFERENCES meetings (id) ON DELETE CASCADE,
+	feedback_id bigint NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	position integer NOT NULL,
+	notes text NOT NULL DEFAULT '',
+	outcome varchar(20) NOT NULL DEFAULT '',
+	response text NOT NULL DEFAULT '',
+	decided_at timestamptz,
+	PRIMARY KEY (meeting_id, feedback_id)
+);
@@ -0,0 +1,15 @@
+ALTER TABLE votes ADD COLUMN token_family varchar(64);
+
+CREATE INDEX idx_votes_token_family ON votes (token_family);
+
+-- Voter tokens are now stored as keyed hashes, with a secret kept out
+-- of the database: the raw tokens of past votes cannot be hashed here
+-- and are erased, and the follows keyed by unkeyed hashes would never
+-- match their voters again.
+UPDATE votes SET token = NULL;
+
+DELETE FROM follow_notifications;
+
+DELETE FROM push_subscriptions;
+
+DELETE FROM follows;
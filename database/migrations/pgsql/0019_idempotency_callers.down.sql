@@ -0,0 +1,15 @@
+DROP TABLE idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+	key varchar(255) NOT NULL,
+	scope varchar(255) NOT NULL,
+	request_hash varchar(64) NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	content_type varchar(255),
+	body bytea,
+	created_at timestamptz,
+	expires_at timestamptz,
+	PRIMARY KEY (key, scope)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,21 @@
+-- The keys are scoped to their caller, which is part of the primary
+-- key. Pending and stored responses are dropped: they would be
+-- replayed to any caller.
+DROP TABLE idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+	key varchar(255) NOT NULL,
+	scope varchar(255) NOT NULL,
+	caller varchar(64) NOT NULL,
+	request_hash varchar(64) NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	content_type varchar(255),
+	etag varchar(255),
+	last_modified varchar(255),
+	body bytea,
+	created_at timestamptz,
+	expires_at timestamptz,
+	PRIMARY KEY (key, scope, caller)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
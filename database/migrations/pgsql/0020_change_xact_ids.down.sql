@@ -0,0 +1,3 @@
+DROP INDEX idx_changes_xact_id;
+
+ALTER TABLE changes DROP COLUMN xact_id;
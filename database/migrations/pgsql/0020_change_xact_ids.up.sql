@@ -0,0 +1,6 @@
+-- The transaction of each change orders the feed, without
+-- serializing the writers. The changes made so far share the
+-- transaction of the migration, and keep their order.
+ALTER TABLE changes ADD COLUMN xact_id bigint NOT NULL DEFAULT (pg_current_xact_id()::text::bigint);
+
+CREATE INDEX idx_changes_xact_id ON changes (xact_id, seq);
@@ -0,0 +1 @@
+ALTER TABLE alert_rules ADD COLUMN tag varchar(30);
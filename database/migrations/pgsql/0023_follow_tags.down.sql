@@ -0,0 +1,9 @@
+DELETE FROM follows WHERE tag <> '';
+
+DROP INDEX idx_follows_tag;
+
+DROP INDEX idx_follows_target;
+
+CREATE UNIQUE INDEX idx_follows_target ON follows (token_hash, feedback_id, course, faculty);
+
+ALTER TABLE follows DROP COLUMN tag;
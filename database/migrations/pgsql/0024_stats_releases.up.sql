@@ -0,0 +1,10 @@
+CREATE TABLE stats_releases (
+	id bigserial PRIMARY KEY,
+	scope varchar(10) NOT NULL,
+	code varchar(10) NOT NULL,
+	term varchar(20) NOT NULL,
+	stats text NOT NULL,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_stats_releases_target ON stats_releases (scope, code, term, created_at);
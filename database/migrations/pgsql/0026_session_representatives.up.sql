@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN representative_id bigint REFERENCES representatives (id) ON DELETE SET NULL;
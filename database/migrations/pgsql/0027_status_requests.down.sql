@@ -0,0 +1,3 @@
+DROP TABLE moderation_assignments;
+
+DROP TABLE status_requests;
@@ -0,0 +1,25 @@
+CREATE TABLE status_requests (
+	id bigserial PRIMARY KEY,
+	token_hash bytea NOT NULL,
+	feedback_id bigint NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	status varchar(20) NOT NULL,
+	reason varchar(500),
+	notified_at timestamptz,
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_status_requests_voter ON status_requests (token_hash, feedback_id, status);
+
+CREATE INDEX idx_status_requests_feedback_id ON status_requests (feedback_id);
+
+CREATE INDEX idx_status_requests_notified_at ON status_requests (notified_at);
+
+CREATE INDEX idx_status_requests_created_at ON status_requests (created_at);
+
+CREATE TABLE moderation_assignments (
+	feedback_id bigint PRIMARY KEY REFERENCES feedbacks (id) ON DELETE CASCADE,
+	representative_id bigint NOT NULL REFERENCES representatives (id) ON DELETE CASCADE,
+	created_at timestamptz
+);
+
+CREATE INDEX idx_moderation_assignments_representative_id ON moderation_assignments (representative_id);
@@ -0,0 +1 @@
+ALTER TABLE representatives DROP COLUMN all_courses;
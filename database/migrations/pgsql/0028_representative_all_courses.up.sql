@@ -0,0 +1 @@
+ALTER TABLE representatives ADD COLUMN all_courses boolean NOT NULL DEFAULT false;
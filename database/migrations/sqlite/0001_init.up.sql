@@ -0,0 +1,27 @@
+-- Baseline schema, as created by the former automatic migration.
+-- Existing databases are adopted as they are.
+CREATE TABLE IF NOT EXISTS feedbacks (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	course text NOT NULL,
+	feedback text NOT NULL,
+	upvotes integer DEFAULT 0,
+	downvotes integer DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS votes (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	feedback_id integer NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	kind text NOT NULL,
+	delta integer NOT NULL,
+	token text,
+	address text,
+	created_at datetime NOT NULL,
+	flag text,
+	quarantined numeric NOT NULL DEFAULT false,
+	reviewed numeric NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS idx_votes_feedback_id ON votes (feedback_id);
+CREATE INDEX IF NOT EXISTS idx_votes_token ON votes (token);
+CREATE INDEX IF NOT EXISTS idx_votes_address ON votes (address);
+CREATE INDEX IF NOT EXISTS idx_votes_created_at ON votes (created_at);
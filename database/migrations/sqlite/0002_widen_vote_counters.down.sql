@@ -0,0 +1,2 @@
+-- Vote counters are not narrowed back, as they may hold values
+-- beyond the former bounds.
@@ -0,0 +1,18 @@
+-- SQLite cannot change the type of a column, the table is rebuilt
+-- with 64-bit counters instead. Dropping feedbacks would cascade to
+-- the votes if foreign keys were enforced, so migrations are run
+-- with foreign keys disabled, and checked before they are committed.
+CREATE TABLE feedbacks_widened (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	course text NOT NULL,
+	feedback text NOT NULL,
+	upvotes bigint NOT NULL DEFAULT 0,
+	downvotes bigint NOT NULL DEFAULT 0
+);
+
+INSERT INTO feedbacks_widened (id, course, feedback, upvotes, downvotes)
+	SELECT id, course, feedback, COALESCE(upvotes, 0), COALESCE(downvotes, 0) FROM feedbacks;
+
+DROP TABLE feedbacks;
+
+ALTER TABLE feedbacks_widened RENAME TO feedbacks;
@@ -0,0 +1,9 @@
+-- Tombstones would come back to life without the deletion
+-- columns, they are purged instead.
+DELETE FROM votes WHERE feedback_id IN (SELECT id FROM feedbacks WHERE deleted_at IS NOT NULL);
+DELETE FROM feedbacks WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_feedbacks_deleted_at;
+
+ALTER TABLE feedbacks DROP COLUMN delete_reason;
+ALTER TABLE feedbacks DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE feedbacks ADD COLUMN deleted_at datetime;
+ALTER TABLE feedbacks ADD COLUMN delete_reason text;
+
+CREATE INDEX idx_feedbacks_deleted_at ON feedbacks (deleted_at);
@@ -0,0 +1,5 @@
+DROP INDEX idx_feedbacks_updated_at;
+
+ALTER TABLE feedbacks DROP COLUMN updated_at;
+ALTER TABLE feedbacks DROP COLUMN created_at;
+ALTER TABLE feedbacks DROP COLUMN version;
@@ -0,0 +1,9 @@
+ALTER TABLE feedbacks ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE feedbacks ADD COLUMN created_at datetime;
+ALTER TABLE feedbacks ADD COLUMN updated_at datetime;
+
+-- Existing feedback is considered created and last changed when
+-- the migration is applied.
+UPDATE feedbacks SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_feedbacks_updated_at ON feedbacks (updated_at);
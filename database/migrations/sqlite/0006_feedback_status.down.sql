@@ -0,0 +1,3 @@
+DROP INDEX idx_feedbacks_status;
+
+ALTER TABLE feedbacks DROP COLUMN status;
@@ -0,0 +1,10 @@
+CREATE TABLE changes (
+	seq integer PRIMARY KEY AUTOINCREMENT,
+	feedback_id integer NOT NULL,
+	kind text NOT NULL,
+	version integer NOT NULL,
+	snapshot blob,
+	created_at datetime
+);
+
+CREATE INDEX idx_changes_feedback_id ON changes (feedback_id);
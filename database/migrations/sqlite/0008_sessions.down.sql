@@ -0,0 +1,9 @@
+DROP INDEX idx_votes_session;
+
+ALTER TABLE votes DROP COLUMN session_round;
+
+ALTER TABLE votes DROP COLUMN session_id;
+
+DROP TABLE session_items;
+
+DROP TABLE sessions;
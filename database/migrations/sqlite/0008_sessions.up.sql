@@ -0,0 +1,22 @@
+CREATE TABLE sessions (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	title text NOT NULL,
+	status text NOT NULL DEFAULT 'idle',
+	round integer NOT NULL DEFAULT 0,
+	closes_at datetime,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE TABLE session_items (
+	session_id integer NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+	feedback_id integer NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	position integer NOT NULL,
+	PRIMARY KEY (session_id, feedback_id)
+);
+
+ALTER TABLE votes ADD COLUMN session_id integer;
+
+ALTER TABLE votes ADD COLUMN session_round integer NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_votes_session ON votes (session_id, session_round);
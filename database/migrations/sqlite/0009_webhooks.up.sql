@@ -0,0 +1,36 @@
+CREATE TABLE webhooks (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	url text NOT NULL,
+	events text,
+	course text,
+	secret text NOT NULL,
+	cursor integer NOT NULL DEFAULT 0,
+	created_at datetime
+);
+
+CREATE TABLE webhook_deliveries (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	webhook_id integer NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	seq integer NOT NULL,
+	event text NOT NULL,
+	payload blob NOT NULL,
+	status text NOT NULL DEFAULT 'pending',
+	attempts integer NOT NULL DEFAULT 0,
+	next_attempt_at datetime NOT NULL,
+	created_at datetime
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
+
+CREATE TABLE webhook_attempts (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	delivery_id integer NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+	status_code integer NOT NULL,
+	error text,
+	duration integer NOT NULL,
+	created_at datetime
+);
+
+CREATE INDEX idx_webhook_attempts_delivery_id ON webhook_attempts (delivery_id);
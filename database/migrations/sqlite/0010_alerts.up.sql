@@ -0,0 +1,22 @@
+CREATE TABLE alert_rules (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	course text,
+	faculty text,
+	kind text NOT NULL,
+	threshold real NOT NULL DEFAULT 0,
+	min_votes integer NOT NULL DEFAULT 0,
+	vote_window text,
+	channels text,
+	created_at datetime
+);
+
+CREATE TABLE alerts (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	rule_id integer NOT NULL REFERENCES alert_rules (id) ON DELETE CASCADE,
+	feedback_id integer NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	value real NOT NULL,
+	created_at datetime
+);
+
+CREATE UNIQUE INDEX idx_alerts_rule_feedback ON alerts (rule_id, feedback_id);
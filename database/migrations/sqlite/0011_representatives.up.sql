@@ -0,0 +1,14 @@
+CREATE TABLE representatives (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	email text NOT NULL,
+	language text NOT NULL DEFAULT 'en',
+	courses text,
+	faculties text,
+	digest_frequency text NOT NULL DEFAULT 'weekly',
+	last_digest_at datetime,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE UNIQUE INDEX idx_representatives_email ON representatives (email);
@@ -0,0 +1,21 @@
+ALTER TABLE representatives ADD COLUMN inbox_events text;
+
+ALTER TABLE representatives ADD COLUMN token_hash blob;
+
+CREATE UNIQUE INDEX idx_representatives_token_hash ON representatives (token_hash);
+
+CREATE TABLE notifications (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	representative_id integer NOT NULL REFERENCES representatives (id) ON DELETE CASCADE,
+	kind text NOT NULL,
+	ref integer NOT NULL,
+	feedback_id integer NOT NULL,
+	course text NOT NULL,
+	detail text,
+	read_at datetime,
+	created_at datetime
+);
+
+CREATE UNIQUE INDEX idx_notifications_event ON notifications (representative_id, kind, ref);
+
+CREATE INDEX idx_notifications_created_at ON notifications (created_at);
@@ -0,0 +1,44 @@
+CREATE TABLE follows (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	token_hash blob NOT NULL,
+	feedback_id integer NOT NULL DEFAULT 0,
+	course text NOT NULL DEFAULT '',
+	faculty text NOT NULL DEFAULT '',
+	created_at datetime
+);
+
+CREATE UNIQUE INDEX idx_follows_target ON follows (token_hash, feedback_id, course, faculty);
+
+CREATE INDEX idx_follows_feedback_id ON follows (feedback_id);
+
+CREATE INDEX idx_follows_course ON follows (course);
+
+CREATE INDEX idx_follows_faculty ON follows (faculty);
+
+CREATE TABLE follow_notifications (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	token_hash blob NOT NULL,
+	kind text NOT NULL,
+	ref integer NOT NULL,
+	feedback_id integer NOT NULL,
+	course text NOT NULL,
+	detail text,
+	created_at datetime
+);
+
+CREATE UNIQUE INDEX idx_follow_notifications_event ON follow_notifications (token_hash, kind, ref);
+
+CREATE INDEX idx_follow_notifications_created_at ON follow_notifications (created_at);
+
+CREATE TABLE push_subscriptions (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	token_hash blob NOT NULL,
+	endpoint text NOT NULL,
+	p256dh text NOT NULL,
+	auth text NOT NULL,
+	created_at datetime
+);
+
+CREATE UNIQUE INDEX idx_push_subscriptions_endpoint ON push_subscriptions (endpoint);
+
+CREATE INDEX idx_push_subscriptions_token_hash ON push_subscriptions (token_hash);
@@ -0,0 +1,11 @@
+CREATE TABLE privacy_budgets (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	scope text NOT NULL,
+	code text NOT NULL,
+	term text NOT NULL,
+	spent real NOT NULL DEFAULT 0,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE UNIQUE INDEX idx_privacy_budgets_target ON privacy_budgets (scope, code, term);
@@ -0,0 +1,11 @@
+CREATE TABLE held_feedbacks (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	course text NOT NULL,
+	feedback text NOT NULL,
+	publish_at datetime NOT NULL,
+	created_at datetime
+);
+
+CREATE INDEX idx_held_feedbacks_course ON held_feedbacks (course);
+
+CREATE INDEX idx_held_feedbacks_publish_at ON held_feedbacks (publish_at);
@@ -0,0 +1,8 @@
+CREATE TABLE imported_rows (
+	hash text PRIMARY KEY,
+	source text NOT NULL,
+	feedback_id integer NOT NULL,
+	created_at datetime
+);
+
+CREATE INDEX idx_imported_rows_feedback_id ON imported_rows (feedback_id);
@@ -0,0 +1,30 @@
+ALTER TABLE feedbacks ADD COLUMN response text NOT NULL DEFAULT '';
+
+ALTER TABLE feedbacks ADD COLUMN responded_at datetime;
+
+CREATE TABLE meetings (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	body text NOT NULL,
+	scope text NOT NULL,
+	code text NOT NULL,
+	starts_at datetime NOT NULL,
+	ends_at datetime NOT NULL,
+	location text NOT NULL DEFAULT '',
+	representative_id integer REFERENCES representatives (id) ON DELETE SET NULL,
+	held_at datetime,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE INDEX idx_meetings_starts_at ON meetings (starts_at);
+
+CREATE TABLE meeting_items (
+	meeting_id integer NOT NULL REFERENCES meetings (id) ON DELETE CASCADE,
+	feedback_id integer NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	position integer NOT NULL,
+	notes text NOT NULL DEFAULT '',
+	outcome text NOT NULL DEFAULT '',
+	response text NOT NULL DEFAULT '',
+	decided_at datetime,
+	PRIMARY KEY (meeting_id, feedback_id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX idx_votes_token_family;
+
+ALTER TABLE votes DROP COLUMN token_family;
@@ -0,0 +1,15 @@
+DROP TABLE idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+	key text NOT NULL,
+	scope text NOT NULL,
+	request_hash text NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	content_type text,
+	body blob,
+	created_at datetime,
+	expires_at datetime,
+	PRIMARY KEY (key, scope)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
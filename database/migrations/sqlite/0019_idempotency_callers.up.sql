@@ -0,0 +1,21 @@
+-- The keys are scoped to their caller, which is part of the primary
+-- key. Pending and stored responses are dropped: they would be
+-- replayed to any caller.
+DROP TABLE idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+	key text NOT NULL,
+	scope text NOT NULL,
+	caller text NOT NULL,
+	request_hash text NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	content_type text,
+	etag text,
+	last_modified text,
+	body blob,
+	created_at datetime,
+	expires_at datetime,
+	PRIMARY KEY (key, scope, caller)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,3 @@
+-- SQLite serializes writers, so changes are committed in the order
+-- of their sequence numbers: only PostgreSQL records their
+-- transaction.
@@ -0,0 +1,3 @@
+ALTER TABLE held_feedbacks DROP COLUMN tags;
+
+ALTER TABLE feedbacks DROP COLUMN tags;
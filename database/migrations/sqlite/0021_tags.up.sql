@@ -0,0 +1,3 @@
+ALTER TABLE feedbacks ADD COLUMN tags text NOT NULL DEFAULT '[]';
+
+ALTER TABLE held_feedbacks ADD COLUMN tags text NOT NULL DEFAULT '[]';
@@ -0,0 +1,7 @@
+ALTER TABLE follows ADD COLUMN tag text NOT NULL DEFAULT '';
+
+DROP INDEX idx_follows_target;
+
+CREATE UNIQUE INDEX idx_follows_target ON follows (token_hash, feedback_id, course, faculty, tag);
+
+CREATE INDEX idx_follows_tag ON follows (tag);
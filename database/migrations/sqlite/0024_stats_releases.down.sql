@@ -0,0 +1 @@
+DROP TABLE stats_releases;
@@ -0,0 +1,10 @@
+CREATE TABLE stats_releases (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	scope text NOT NULL,
+	code text NOT NULL,
+	term text NOT NULL,
+	stats text NOT NULL,
+	created_at datetime
+);
+
+CREATE INDEX idx_stats_releases_target ON stats_releases (scope, code, term, created_at);
@@ -0,0 +1 @@
+ALTER TABLE sessions DROP COLUMN presenter_token_hash;
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN presenter_token_hash blob;
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN representative_id integer REFERENCES representatives (id) ON DELETE SET NULL;
@@ -0,0 +1,25 @@
+CREATE TABLE status_requests (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	token_hash blob NOT NULL,
+	feedback_id integer NOT NULL REFERENCES feedbacks (id) ON DELETE CASCADE,
+	status text NOT NULL,
+	reason text,
+	notified_at datetime,
+	created_at datetime
+);
+
+CREATE UNIQUE INDEX idx_status_requests_voter ON status_requests (token_hash, feedback_id, status);
+
+CREATE INDEX idx_status_requests_feedback_id ON status_requests (feedback_id);
+
+CREATE INDEX idx_status_requests_notified_at ON status_requests (notified_at);
+
+CREATE INDEX idx_status_requests_created_at ON status_requests (created_at);
+
+CREATE TABLE moderation_assignments (
+	feedback_id integer PRIMARY KEY REFERENCES feedbacks (id) ON DELETE CASCADE,
+	representative_id integer NOT NULL REFERENCES representatives (id) ON DELETE CASCADE,
+	created_at datetime
+);
+
+CREATE INDEX idx_moderation_assignments_representative_id ON moderation_assignments (representative_id);
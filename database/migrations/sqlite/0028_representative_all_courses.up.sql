@@ -0,0 +1 @@
+ALTER TABLE representatives ADD COLUMN all_courses numeric NOT NULL DEFAULT false;
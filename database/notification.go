@@ -0,0 +1,79 @@
+/**
+ * file: database/notification.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the notification inbox database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddNotifications adds the notifications to the inboxes. Events
+// already notified to a representative are skipped.
+func (db *Database) AddNotifications(n []*models.Notification) error {
+	if len(n) == 0 {
+		return nil
+	}
+	return db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(n).Error
+}
+
+// GetInbox returns at most limit notifications of the representative,
+// latest first, along with the number of unread notifications.
+func (db *Database) GetInbox(representativeID uint, unread bool, limit int) (*models.Inbox, error) {
+	inbox := new(models.Inbox)
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("representative_id = ?", representativeID)
+		if unread {
+			q = q.Where("read_at IS NULL")
+		}
+		if r := q.Order("id DESC").Limit(limit).Find(&inbox.Notifications); r.Error != nil {
+			return r.Error
+		}
+
+		return tx.Model(&models.Notification{}).
+			Where("representative_id = ? AND read_at IS NULL", representativeID).
+			Count(&inbox.Unread).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inbox, nil
+}
+
+// MarkNotificationRead marks the notification of the representative
+// as read at the given time, unless it was already read. It returns
+// gorm.ErrRecordNotFound if the representative has no such
+// notification.
+func (db *Database) MarkNotificationRead(representativeID, id uint, at time.Time) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		n := new(models.Notification)
+		r := tx.Where("id = ? AND representative_id = ?", id, representativeID).First(n)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		return tx.Model(n).
+			Where("read_at IS NULL").
+			UpdateColumn("read_at", at).Error
+	})
+}
+
+// MarkAllNotificationsRead marks all unread notifications of the
+// representative as read at the given time, and returns how many
+// were marked.
+func (db *Database) MarkAllNotificationsRead(representativeID uint, at time.Time) (int64, error) {
+	r := db.db.Model(&models.Notification{}).
+		Where("representative_id = ? AND read_at IS NULL", representativeID).
+		UpdateColumn("read_at", at)
+	return r.RowsAffected, r.Error
+}
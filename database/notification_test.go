@@ -0,0 +1,47 @@
+/**
+ * file: database/notification_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the notification inbox persistence.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestMarkNotificationReadOther tests that representatives cannot
+// mark the notifications of others as read.
+func TestMarkNotificationReadOther(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^SELECT \\* FROM [`\"']notifications[`\"'] WHERE id = .* AND representative_id = .*$").
+		WithArgs(5, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	err := db.MarkNotificationRead(1, 5, time.Now())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestAddNotificationsEmpty tests that adding no notifications does
+// not reach the database.
+func TestAddNotificationsEmpty(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	assert.NoError(t, db.AddNotifications(nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,113 @@
+/**
+ * file: database/privacy.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the privacy budgets and the
+ * releases of the private statistics database logic
+ * for the data persistance plane.
+ */
+
+package database
+
+import (
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// budgetTolerance absorbs the rounding errors of the sums of the
+// privacy losses.
+const budgetTolerance float64 = 1e-9
+
+// SpendPrivacyBudget spends epsilon from the privacy budget of the
+// course or faculty for the term, unless it would exceed the limit.
+// It returns the budget remaining afterwards, and whether epsilon was
+// spent.
+func (db *Database) SpendPrivacyBudget(scope models.StatsScope, code, term string, epsilon, limit float64) (float64, bool, error) {
+	b := &models.PrivacyBudget{Scope: scope, Code: strings.ToUpper(code), Term: term}
+	spent := false
+
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(b); r.Error != nil {
+			return r.Error
+		}
+
+		target := func() *gorm.DB {
+			return tx.Model(&models.PrivacyBudget{}).Where("scope = ? AND code = ? AND term = ?", b.Scope, b.Code, b.Term)
+		}
+		r := target().
+			Where("spent + ? <= ?", epsilon, limit+budgetTolerance).
+			Update("spent", gorm.Expr("spent + ?", epsilon))
+		if r.Error != nil {
+			return r.Error
+		}
+		spent = r.RowsAffected != 0
+
+		return target().First(b).Error
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return max(limit-b.Spent, 0), spent, nil
+}
+
+// ReleaseStats records the release of the private statistics of a
+// course or faculty for a term, spending epsilon from its privacy
+// budget, unless it would exceed the limit. It returns whether the
+// statistics were released, and sets the budget remaining afterwards
+// on their privacy.
+func (db *Database) ReleaseStats(r *models.StatsRelease, epsilon, limit float64) (bool, error) {
+	r.Code = strings.ToUpper(r.Code)
+	released := false
+
+	err := db.Transaction(func(tx *Database) error {
+		remaining, spent, err := tx.SpendPrivacyBudget(r.Scope, r.Code, r.Term, epsilon, limit)
+		if err != nil || !spent {
+			return err
+		}
+
+		r.Stats.Privacy.RemainingBudget = remaining
+		released = true
+		return tx.db.Create(r).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return released, nil
+}
+
+// GetLatestStatsRelease returns the latest release of the statistics
+// of the course or faculty for the term. It returns
+// gorm.ErrRecordNotFound if they were never released.
+func (db *Database) GetLatestStatsRelease(scope models.StatsScope, code, term string) (*models.StatsRelease, error) {
+	r := &models.StatsRelease{}
+	err := db.db.
+		Where("scope = ? AND code = ? AND term = ?", scope, strings.ToUpper(code), term).
+		Order("created_at DESC, id DESC").
+		First(r).Error
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetStatsCourses returns the courses with feedback created between
+// since, included, and until, excluded.
+func (db *Database) GetStatsCourses(since, until time.Time) ([]string, error) {
+	var courses []string
+	r := db.db.Model(&models.Feedback{}).
+		Where("created_at >= ? AND created_at < ?", since, until).
+		Select("DISTINCT UPPER(course) AS course").
+		Order("course").
+		Pluck("course", &courses)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return courses, nil
+}
@@ -0,0 +1,104 @@
+/**
+ * file: database/privacy_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the privacy budgets and the releases of the
+ * private statistics.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestSpendPrivacyBudget tests that budgets are spent per course or
+// faculty and term, up to their limit.
+func TestSpendPrivacyBudget(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		remaining, spent, err := db.SpendPrivacyBudget(models.StatsScopeCourse, "linfo1101", "2024-2025-Q1", 0.1, 1)
+		require.NoError(t, err, "spending the budget should not fail")
+		assert.True(t, spent, "the budget should not be exhausted after %d queries", i)
+		assert.InDelta(t, 0.9-0.1*float64(i), remaining, 1e-9)
+	}
+
+	remaining, spent, err := db.SpendPrivacyBudget(models.StatsScopeCourse, "LINFO1101", "2024-2025-Q1", 0.1, 1)
+	require.NoError(t, err)
+	assert.False(t, spent, "the budget should be exhausted")
+	assert.InDelta(t, 0, remaining, 1e-9)
+
+	_, spent, err = db.SpendPrivacyBudget(models.StatsScopeCourse, "LINFO1101", "2024-2025-Q2", 0.1, 1)
+	require.NoError(t, err)
+	assert.True(t, spent, "terms should have their own budget")
+
+	_, spent, err = db.SpendPrivacyBudget(models.StatsScopeFaculty, "LINFO", "2024-2025-Q1", 0.1, 1)
+	require.NoError(t, err)
+	assert.True(t, spent, "faculties should have their own budget")
+}
+
+// TestReleaseStats tests that releases spend the privacy budget, are
+// refused once it is exhausted, and that the latest one is returned.
+func TestReleaseStats(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	_, err = db.GetLatestStatsRelease(models.StatsScopeCourse, "LINFO1101", "2024-2025-Q1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	start := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		r := &models.StatsRelease{
+			Scope:     models.StatsScopeCourse,
+			Code:      "linfo1101",
+			Term:      "2024-2025-Q1",
+			Stats:     &models.Stats{Feedback: int64(i), Privacy: &models.StatsPrivacy{Epsilon: 0.5}},
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+		}
+		released, err := db.ReleaseStats(r, 0.5, 1)
+		require.NoError(t, err, "releasing the statistics should not fail")
+		assert.Equal(t, i < 2, released, "releases should stop once the budget is exhausted")
+	}
+
+	r, err := db.GetLatestStatsRelease(models.StatsScopeCourse, "LINFO1101", "2024-2025-Q1")
+	require.NoError(t, err, "getting the latest release should not fail")
+	assert.Equal(t, int64(1), r.Stats.Feedback, "the latest release should be returned")
+	assert.InDelta(t, 0, r.Stats.Privacy.RemainingBudget, 1e-9)
+
+	_, err = db.GetLatestStatsRelease(models.StatsScopeCourse, "LINFO1101", "2024-2025-Q2")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "releases should be per term")
+}
+
+// TestGetStatsCourses tests that the courses with feedback during
+// the period are listed once.
+func TestGetStatsCourses(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	start := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+	for i, course := range []string{"LINFO1101", "linfo1101", "LEPL1102", "LSINF1000"} {
+		created := start.Add(time.Duration(i) * time.Hour)
+		if course == "LSINF1000" {
+			created = start.Add(-time.Hour)
+		}
+		f := &models.Feedback{Course: course, Feedback: "The exercise sessions are far too short for us.", CreatedAt: created}
+		require.NoError(t, db.db.Create(f).Error)
+	}
+
+	courses, err := db.GetStatsCourses(start, start.Add(24*time.Hour))
+	require.NoError(t, err, "getting the courses should not fail")
+	assert.Equal(t, []string{"LEPL1102", "LINFO1101"}, courses)
+}
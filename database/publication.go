@@ -0,0 +1,89 @@
+/**
+ * file: database/publication.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the held feedback database
+ * logic for the data persistance plane: feedback
+ * accepted but not published yet.
+ */
+
+package database
+
+import (
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+)
+
+// HoldFeedback stores the feedback until its publication.
+func (db *Database) HoldFeedback(h *models.HeldFeedback) error {
+	return db.db.Create(h).Error
+}
+
+// GetHeldFeedback returns the feedback held on the course, whatever
+// its case.
+func (db *Database) GetHeldFeedback(course string) ([]*models.HeldFeedback, error) {
+	var h []*models.HeldFeedback
+	if r := db.db.Where("UPPER(course) = ?", strings.ToUpper(course)).Order("id").Find(&h); r.Error != nil {
+		return nil, r.Error
+	}
+	return h, nil
+}
+
+// GetDueHeldCourses returns the courses, in upper case, on which at
+// least k feedback is held, or on which some held feedback is due
+// for publication at the given time.
+func (db *Database) GetDueHeldCourses(k int64, now time.Time) ([]string, error) {
+	var courses []string
+	r := db.db.Model(&models.HeldFeedback{}).
+		Select("UPPER(course) AS course").
+		Group("UPPER(course)").
+		Having("COUNT(*) >= ? OR MIN(publish_at) <= ?", k, now).
+		Order("course").
+		Pluck("course", &courses)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return courses, nil
+}
+
+// PublishHeldFeedback publishes the held feedback, in the given
+// order, and records their creation in the change feed. The creation
+// of the feedback is timestamped at the given time rather than now,
+// but its last update is not, so that conditional requests see it.
+// Feedback published concurrently is skipped. It returns the
+// published feedback by the ID of the held feedback.
+func (db *Database) PublishHeldFeedback(held []*models.HeldFeedback, at time.Time) (map[uint]*models.Feedback, error) {
+	published := make(map[uint]*models.Feedback, len(held))
+
+	tx := db.db.Session(&gorm.Session{NowFunc: func() time.Time { return at }})
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		for _, h := range held {
+			r := tx.Delete(&models.HeldFeedback{}, h.ID)
+			if r.Error != nil {
+				return r.Error
+			}
+			if r.RowsAffected == 0 {
+				continue
+			}
+
+			f := &models.Feedback{Course: h.Course, Feedback: h.Feedback, Tags: h.Tags, Status: models.FeedbackStatusNew, Version: 1, UpdatedAt: time.Now()}
+			if r := tx.Create(f); r.Error != nil {
+				return r.Error
+			}
+			if err := recordChange(tx, models.ChangeKindCreate, f); err != nil {
+				return err
+			}
+			published[h.ID] = f
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return published, nil
+}
@@ -0,0 +1,75 @@
+/**
+ * file: database/publication_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the held feedback.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPublishHeldFeedback tests that held feedback is published once,
+// in the given order, with the given creation time.
+func TestPublishHeldFeedback(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	now := time.Date(2024, 10, 9, 15, 30, 0, 0, time.UTC)
+	held := []*models.HeldFeedback{
+		{Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us.", PublishAt: now.Add(time.Hour)},
+		{Course: "linfo1101", Feedback: "The slides are published too late before lectures.", PublishAt: now.Add(2 * time.Hour)},
+		{Course: "LINFO1102", Feedback: "The project statement is ambiguous in many places.", PublishAt: now.Add(-time.Minute)},
+	}
+	for _, h := range held {
+		require.NoError(t, db.HoldFeedback(h), "holding feedback should not fail")
+	}
+
+	courses, err := db.GetDueHeldCourses(2, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LINFO1101", "LINFO1102"}, courses, "courses with enough or due feedback should be due")
+	courses, err = db.GetDueHeldCourses(3, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, courses)
+
+	onCourse, err := db.GetHeldFeedback("Linfo1101")
+	require.NoError(t, err)
+	require.Len(t, onCourse, 2, "held feedback should be matched whatever the case")
+
+	at := now.Truncate(time.Hour)
+	published, err := db.PublishHeldFeedback([]*models.HeldFeedback{onCourse[1], onCourse[0]}, at)
+	require.NoError(t, err, "publishing held feedback should not fail")
+	require.Len(t, published, 2)
+	assert.Equal(t, uint(1), published[onCourse[1].ID].ID, "feedback should be published in the given order")
+	assert.Equal(t, uint(2), published[onCourse[0].ID].ID)
+
+	f, err := db.GetFeedback(1)
+	require.NoError(t, err)
+	assert.Equal(t, held[1].Feedback, f.Feedback)
+	assert.True(t, f.CreatedAt.Equal(at), "the creation should be timestamped at the given time")
+	assert.True(t, f.UpdatedAt.After(at), "the last update should not be coarsened")
+
+	changes, err := db.GetChangesSince(0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, models.ChangeKindCreate, changes[0].Kind)
+	assert.True(t, changes[0].CreatedAt.Equal(at), "the change should be timestamped at the given time")
+
+	published, err = db.PublishHeldFeedback(onCourse, at)
+	require.NoError(t, err)
+	assert.Empty(t, published, "feedback should only be published once")
+
+	remaining, err := db.GetHeldFeedback("LINFO1101")
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
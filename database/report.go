@@ -0,0 +1,43 @@
+/**
+ * file: database/report.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the queries of the term reports
+ * for the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// GetScopedFeedback returns the feedback selected by the query, by
+// ID.
+func (db *Database) GetScopedFeedback(q *models.StatsQuery) ([]*models.Feedback, error) {
+	var f []*models.Feedback
+	if r := db.db.Scopes(statsScope(q)).Order("id").Find(&f); r.Error != nil {
+		return nil, r.Error
+	}
+	return f, nil
+}
+
+// GetStatusChanges returns the changes of status made between since,
+// included, and until, excluded, on the feedback selected by the
+// query, in order.
+func (db *Database) GetStatusChanges(q *models.StatsQuery, since, until time.Time) ([]*models.Change, error) {
+	var c []*models.Change
+	r := db.db.
+		Joins("JOIN feedbacks ON feedbacks.id = changes.feedback_id AND feedbacks.deleted_at IS NULL").
+		Scopes(statsScope(q)).
+		Where("changes.kind = ? AND changes.created_at >= ? AND changes.created_at < ?", models.ChangeKindStatus, since, until).
+		Order("changes.seq").
+		Find(&c)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return c, nil
+}
@@ -0,0 +1,54 @@
+/**
+ * file: database/report_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the queries of the term reports.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetStatusChanges tests that the changes of status are selected
+// on the feedback of the course, during the period.
+func TestGetStatusChanges(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	for _, course := range []string{"LINFO1101", "linfo1101", "LINFO1102"} {
+		_, err := db.AddFeedback(&models.Feedback{Course: course, Feedback: "The exercise sessions are far too short for us."})
+		require.NoError(t, err)
+	}
+	for id := uint(1); id <= 3; id++ {
+		_, err := db.UpdateFeedbackStatus(id, models.FeedbackStatusAcknowledged, nil)
+		require.NoError(t, err)
+	}
+
+	until := time.Now().Add(time.Minute)
+	q := &models.StatsQuery{Scope: models.StatsScopeCourse, Code: "LINFO1101", Until: &until}
+
+	feedback, err := db.GetScopedFeedback(q)
+	require.NoError(t, err, "getting the feedback should not fail")
+	assert.Len(t, feedback, 2, "the feedback should be matched whatever the case")
+
+	changes, err := db.GetStatusChanges(q, until.Add(-time.Hour), until)
+	require.NoError(t, err, "getting the changes should not fail")
+	require.Len(t, changes, 2, "only the changes of status on the course should be selected")
+	assert.Equal(t, uint(1), changes[0].FeedbackID)
+	assert.Equal(t, models.ChangeKindStatus, changes[0].Kind)
+	assert.NotEmpty(t, changes[0].Snapshot)
+
+	changes, err = db.GetStatusChanges(q, until, until.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, changes, "changes outside of the period should not be selected")
+}
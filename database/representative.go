@@ -0,0 +1,161 @@
+/**
+ * file: database/representative.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the representative database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"errors"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDuplicateEmail error = errors.New("email address already in use")
+)
+
+// AddRepresentative adds the representative. It returns
+// ErrDuplicateEmail if another representative has the same email
+// address.
+func (db *Database) AddRepresentative(r *models.Representative) (*models.Representative, error) {
+	res := db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(r)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrDuplicateEmail
+	}
+
+	return r, nil
+}
+
+func (db *Database) GetRepresentatives() (r []*models.Representative, err error) {
+	err = db.db.Order("id").Find(&r).Error
+	return
+}
+
+func (db *Database) GetRepresentative(id uint) (*models.Representative, error) {
+	r := new(models.Representative)
+	if res := db.db.First(r, id); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return r, nil
+}
+
+// UpdateRepresentative saves the settings of the representative.
+// It returns ErrDuplicateEmail if another representative has the
+// same email address.
+func (db *Database) UpdateRepresentative(r *models.Representative) (*models.Representative, error) {
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		res := tx.Model(&models.Representative{}).
+			Where("email = ? AND id <> ?", r.Email, r.ID).
+			Count(&taken)
+		if res.Error != nil {
+			return res.Error
+		}
+		if taken != 0 {
+			return ErrDuplicateEmail
+		}
+
+		res = tx.Model(r).
+			Select("name", "email", "language", "courses", "faculties", "all_courses", "digest_frequency", "inbox_events", "updated_at").
+			Updates(r)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.First(r, r.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetRepresentativeByToken returns the representative with the
+// given access token hash.
+func (db *Database) GetRepresentativeByToken(hash []byte) (*models.Representative, error) {
+	r := new(models.Representative)
+	if res := db.db.Where("token_hash = ?", hash).First(r); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return r, nil
+}
+
+// SetRepresentativeToken replaces the access token hash of the
+// representative.
+func (db *Database) SetRepresentativeToken(id uint, hash []byte) error {
+	r := db.db.Model(&models.Representative{}).
+		Where("id = ?", id).
+		UpdateColumn("token_hash", hash)
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateRepresentativePreferences saves the preferences of the
+// representative, and returns the updated representative.
+func (db *Database) UpdateRepresentativePreferences(id uint, p *models.RepresentativePreferences) (*models.Representative, error) {
+	r := &models.Representative{
+		ID:              id,
+		Language:        p.Language,
+		DigestFrequency: p.DigestFrequency,
+		InboxEvents:     p.InboxEvents,
+	}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(r).
+			Select("language", "digest_frequency", "inbox_events", "updated_at").
+			Updates(r)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.First(r, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (db *Database) DeleteRepresentative(id uint) error {
+	r := db.db.Delete(&models.Representative{}, id)
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetDigestSent records that a digest was sent to the representative
+// at the given time.
+func (db *Database) SetDigestSent(id uint, at time.Time) error {
+	return db.db.Model(&models.Representative{}).
+		Where("id = ?", id).
+		UpdateColumn("last_digest_at", at).Error
+}
@@ -0,0 +1,54 @@
+/**
+ * file: database/representative_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the representative persistence.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAddRepresentativeDuplicate tests that email addresses are
+// unique among representatives.
+func TestAddRepresentativeDuplicate(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^INSERT INTO [`\"']representatives[`\"'] .* ON CONFLICT DO NOTHING RETURNING [`\"']id[`\"']$").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	r, err := db.AddRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org"})
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.Nil(t, r)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateRepresentativeDuplicate tests that representatives
+// cannot take the email address of another.
+func TestUpdateRepresentativeDuplicate(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^SELECT count\\(\\*\\) FROM [`\"']representatives[`\"'] WHERE email = .* AND id <> .*$").
+		WithArgs("alex@example.org", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	_, err := db.UpdateRepresentative(&models.Representative{ID: 3, Name: "Alex", Email: "alex@example.org"})
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
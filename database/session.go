@@ -0,0 +1,144 @@
+/**
+ * file: database/session.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the live session database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (db *Database) AddSession(session *models.Session) (*models.Session, error) {
+	if r := db.db.Create(session); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return session, nil
+}
+
+func (db *Database) GetSession(id uint) (*models.Session, error) {
+	s := new(models.Session)
+	if r := db.db.First(&s, id); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return s, nil
+}
+
+// GetSessionItems returns the feedback attached to the session, in
+// order. Deleted feedback is left out.
+func (db *Database) GetSessionItems(id uint) (f []*models.Feedback, err error) {
+	err = db.db.
+		Joins("JOIN session_items ON session_items.feedback_id = feedbacks.id").
+		Where("session_items.session_id = ?", id).
+		Order("session_items.position").
+		Find(&f).Error
+	return
+}
+
+// AddSessionItem attaches the feedback to the session, after the
+// items already attached. It returns false if the feedback was
+// already attached.
+func (db *Database) AddSessionItem(sessionID, feedbackID uint) (bool, error) {
+	added := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var position int
+		r := tx.Model(&models.SessionItem{}).
+			Select("COALESCE(MAX(position), 0)").
+			Where("session_id = ?", sessionID).
+			Scan(&position)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		item := &models.SessionItem{SessionID: sessionID, FeedbackID: feedbackID, Position: position + 1}
+		r = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(item)
+		added = r.RowsAffected == 1
+		return r.Error
+	})
+
+	return added, err
+}
+
+// OpenSessionRound opens the next round of the session, until the
+// given time, and returns the updated session.
+func (db *Database) OpenSessionRound(id uint, closesAt time.Time) (*models.Session, error) {
+	return db.updateSession(id, map[string]any{
+		"status":     models.SessionStatusOpen,
+		"round":      gorm.Expr("round + 1"),
+		"closes_at":  closesAt,
+		"updated_at": time.Now(),
+	})
+}
+
+// CloseSessionRound closes the current round of the session at the
+// given time, and returns the updated session.
+func (db *Database) CloseSessionRound(id uint, now time.Time) (*models.Session, error) {
+	return db.updateSession(id, map[string]any{
+		"status":     models.SessionStatusIdle,
+		"closes_at":  now,
+		"updated_at": now,
+	})
+}
+
+func (db *Database) updateSession(id uint, columns map[string]any) (*models.Session, error) {
+	var s []*models.Session
+	r := db.db.Model(&s).
+		Clauses(clause.Returning{}).
+		Where("id = ?", id).
+		UpdateColumns(columns)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if len(s) != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return s[0], nil
+}
+
+// GetSessionTallies returns the votes cast in the given round of
+// the session, per feedback. Quarantined votes are not counted.
+func (db *Database) GetSessionTallies(id, round uint) ([]*models.SessionTally, error) {
+	var rows []struct {
+		FeedbackID uint
+		Kind       models.VoteKind
+		Votes      int64
+	}
+	err := db.db.Model(&models.Vote{}).
+		Select("feedback_id, kind, SUM(delta) AS votes").
+		Where("session_id = ? AND session_round = ?", id, round).
+		Where("quarantined = ?", false).
+		Group("feedback_id, kind").
+		Order("feedback_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tallies := []*models.SessionTally{}
+	for _, r := range rows {
+		if len(tallies) == 0 || tallies[len(tallies)-1].FeedbackID != r.FeedbackID {
+			tallies = append(tallies, &models.SessionTally{FeedbackID: r.FeedbackID})
+		}
+
+		t := tallies[len(tallies)-1]
+		switch r.Kind {
+		case models.VoteKindUpvote:
+			t.Upvotes = r.Votes
+		case models.VoteKindDownvote:
+			t.Downvotes = r.Votes
+		}
+	}
+
+	return tallies, nil
+}
@@ -0,0 +1,72 @@
+/**
+ * file: database/session_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the live session persistence.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetSessionTallies tests that the votes of a round are summed
+// per feedback and kind.
+func TestGetSessionTallies(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	rows := sqlmock.NewRows([]string{"feedback_id", "kind", "votes"}).
+		AddRow(1, models.VoteKindDownvote, 2).
+		AddRow(1, models.VoteKindUpvote, 5).
+		AddRow(3, models.VoteKindUpvote, 1)
+	mock.
+		ExpectQuery("^SELECT feedback_id, kind, SUM\\(delta\\) AS votes FROM [`\"']votes[`\"'] WHERE \\(session_id = .* AND session_round = .*\\) AND quarantined = .* GROUP BY feedback_id, kind ORDER BY feedback_id$").
+		WithArgs(7, 2, false).
+		WillReturnRows(rows)
+
+	tallies, err := db.GetSessionTallies(7, 2)
+	assert.NoError(t, err, "getting tallies should not return an error")
+	assert.Equal(t, []*models.SessionTally{
+		{FeedbackID: 1, Upvotes: 5, Downvotes: 2},
+		{FeedbackID: 3, Upvotes: 1},
+	}, tallies, "votes should be tallied per feedback")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestAddSessionItem tests that feedback is attached after the
+// items already attached.
+func TestAddSessionItem(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	for _, attached := range []bool{true, false} {
+		var affected int64
+		if attached {
+			affected = 1
+		}
+
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT COALESCE\\(MAX\\(position\\), 0\\) FROM [`\"']session_items[`\"'] WHERE session_id = .*$").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(3))
+		mock.
+			ExpectExec("^INSERT INTO [`\"']session_items[`\"'] .* ON CONFLICT DO NOTHING$").
+			WithArgs(7, 42, 4).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+		mock.ExpectCommit()
+
+		added, err := db.AddSessionItem(7, 42)
+		assert.NoError(t, err, "attaching feedback should not return an error")
+		assert.Equal(t, attached, added, "only new items should be reported as added")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
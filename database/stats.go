@@ -0,0 +1,204 @@
+/**
+ * file: database/stats.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the feedback statistics
+ * database logic for the data persistance plane.
+ * Statistics are aggregated by the database.
+ */
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+)
+
+// statsScope restricts the feedback to the ones the query is about.
+func statsScope(q *models.StatsQuery) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		code := strings.ToUpper(q.Code)
+		if q.Scope == models.StatsScopeFaculty {
+			// Courses are the faculty followed by 4 digits.
+			tx = tx.Where("UPPER(feedbacks.course) LIKE ?", code+"____")
+		} else {
+			tx = tx.Where("UPPER(feedbacks.course) = ?", code)
+		}
+
+		if q.Since != nil {
+			tx = tx.Where("feedbacks.created_at >= ?", *q.Since)
+		}
+		if q.Until != nil {
+			tx = tx.Where("feedbacks.created_at < ?", *q.Until)
+		}
+		return tx
+	}
+}
+
+// secondsBetween returns the SQL expression of the number of seconds
+// between the two timestamp expressions.
+func (db *Database) secondsBetween(from, to string) string {
+	if db.kind == "pgsql" {
+		return fmt.Sprintf("EXTRACT(EPOCH FROM %s - %s)", to, from)
+	}
+	return fmt.Sprintf("(julianday(%s) - julianday(%s)) * 86400", to, from)
+}
+
+// tagElements returns the SQL join of the tags of feedback, as the
+// value column of the tag table.
+func (db *Database) tagElements() string {
+	if db.kind == "pgsql" {
+		return "CROSS JOIN json_array_elements_text(feedbacks.tags::json) AS tag(value)"
+	}
+	return "CROSS JOIN json_each(feedbacks.tags) AS tag"
+}
+
+// scoreBucket returns the SQL expression of the index of the bucket
+// of the score of feedback, within models.ScoreBucketBounds.
+func scoreBucket() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, bound := range models.ScoreBucketBounds {
+		fmt.Fprintf(&b, " WHEN upvotes - downvotes < %d THEN %d", bound, i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(models.ScoreBucketBounds))
+	return b.String()
+}
+
+// GetStats returns the statistics of the feedback selected by the
+// query. All the tags of the feedback are counted, most common
+// first.
+func (db *Database) GetStats(q *models.StatsQuery) (*models.Stats, error) {
+	stats := &models.Stats{
+		Scope:    q.Scope,
+		Code:     strings.ToUpper(q.Code),
+		Since:    q.Since,
+		Until:    q.Until,
+		Statuses: make(map[models.FeedbackStatus]int64),
+		Tags:     []*models.TagCount{},
+	}
+
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		feedback := func() *gorm.DB {
+			return tx.Model(&models.Feedback{}).Scopes(statsScope(q))
+		}
+
+		var totals struct {
+			Feedback, Upvotes, Downvotes int64
+		}
+		r := feedback().
+			Select("COUNT(*) AS feedback, COALESCE(SUM(upvotes), 0) AS upvotes, COALESCE(SUM(downvotes), 0) AS downvotes").
+			Scan(&totals)
+		if r.Error != nil {
+			return r.Error
+		}
+		stats.Feedback, stats.Upvotes, stats.Downvotes = totals.Feedback, totals.Upvotes, totals.Downvotes
+
+		var statuses []struct {
+			Status models.FeedbackStatus
+			Count  int64
+		}
+		if r := feedback().Select("status, COUNT(*) AS count").Group("status").Scan(&statuses); r.Error != nil {
+			return r.Error
+		}
+		for _, s := range statuses {
+			stats.Statuses[s.Status] = s.Count
+		}
+
+		var buckets []struct {
+			Bucket int
+			Count  int64
+		}
+		r = feedback().
+			Select(scoreBucket() + " AS bucket, COUNT(*) AS count").
+			Group("bucket").
+			Scan(&buckets)
+		if r.Error != nil {
+			return r.Error
+		}
+		stats.Scores = newScoreBuckets()
+		for _, b := range buckets {
+			stats.Scores[b.Bucket].Feedback = b.Count
+		}
+
+		r = feedback().
+			Joins(db.tagElements()).
+			Select("tag.value AS tag, COUNT(*) AS count").
+			Group("tag.value").
+			Order("count DESC, tag").
+			Scan(&stats.Tags)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		// The response time of feedback is the time until the
+		// first change of its status.
+		responses := tx.Model(&models.Change{}).
+			Select("feedback_id, MIN(created_at) AS responded_at").
+			Where("kind = ?", models.ChangeKindStatus).
+			Group("feedback_id")
+		responded := func() *gorm.DB {
+			return feedback().Joins("JOIN (?) AS responses ON responses.feedback_id = feedbacks.id", responses)
+		}
+
+		if r := responded().Count(&stats.Responded); r.Error != nil {
+			return r.Error
+		}
+		if stats.Responded == 0 {
+			return nil
+		}
+
+		var middle []float64
+		r = responded().
+			Select(db.secondsBetween("feedbacks.created_at", "responses.responded_at")+" AS seconds").
+			Order("seconds").
+			Limit(int(2-stats.Responded%2)).
+			Offset(int((stats.Responded-1)/2)).
+			Pluck("seconds", &middle)
+		if r.Error != nil {
+			return r.Error
+		}
+
+		var median float64
+		for _, s := range middle {
+			median += s / float64(len(middle))
+		}
+		stats.MedianResponseSeconds = &median
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if votes := stats.Upvotes + stats.Downvotes; votes != 0 {
+		ratio := float64(stats.Upvotes) / float64(votes)
+		stats.UpvoteRatio = &ratio
+	}
+	stats.ComputedAt = time.Now()
+	return stats, nil
+}
+
+// newScoreBuckets returns the empty buckets of the score
+// distribution.
+func newScoreBuckets() []*models.ScoreBucket {
+	bounds := models.ScoreBucketBounds
+	buckets := make([]*models.ScoreBucket, len(bounds)+1)
+
+	buckets[0] = &models.ScoreBucket{Max: ptr(bounds[0] - 1)}
+	for i, bound := range bounds {
+		buckets[i+1] = &models.ScoreBucket{Min: ptr(bound)}
+		if i+1 < len(bounds) {
+			buckets[i+1].Max = ptr(bounds[i+1] - 1)
+		}
+	}
+	return buckets
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,124 @@
+/**
+ * file: database/stats_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the feedback statistics.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetStats tests that statistics are aggregated over the feedback
+// of the course or faculty, within the period, ignoring deleted
+// feedback.
+func TestGetStats(t *testing.T) {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	start := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+	feedback := []struct {
+		course             string
+		upvotes, downvotes uint64
+		status             models.FeedbackStatus
+		tags               models.Tags
+		respondAfter       time.Duration
+		deleted, before    bool
+	}{
+		{course: "LINFO1101", upvotes: 12, downvotes: 2, status: models.FeedbackStatusResolved, tags: models.Tags{"exam", "schedule-conflict"}, respondAfter: time.Hour},
+		{course: "linfo1101", upvotes: 0, downvotes: 3, status: models.FeedbackStatusAcknowledged, tags: models.Tags{"schedule-conflict"}, respondAfter: 3 * time.Hour},
+		{course: "LINFO1102", upvotes: 3, downvotes: 0, status: models.FeedbackStatusNew, tags: models.Tags{"slides"}},
+		{course: "LINFO1102", upvotes: 60, downvotes: 1, status: models.FeedbackStatusRejected, respondAfter: 2 * time.Hour},
+		{course: "LINFO1102", upvotes: 5, downvotes: 5, tags: models.Tags{"slides"}, deleted: true},
+		{course: "LINFO1102", upvotes: 5, downvotes: 5, before: true},
+		{course: "LINF1101", upvotes: 7},
+		{course: "LEPL1102", upvotes: 7},
+	}
+	for i, f := range feedback {
+		created := start.Add(time.Duration(i) * time.Minute)
+		if f.before {
+			created = start.Add(-time.Hour)
+		}
+		if f.status == "" {
+			f.status = models.FeedbackStatusNew
+		}
+
+		row := &models.Feedback{
+			Course:    f.course,
+			Feedback:  "The exercise sessions are far too short for us.",
+			Upvotes:   f.upvotes,
+			Downvotes: f.downvotes,
+			Status:    f.status,
+			Tags:      f.tags,
+			CreatedAt: created,
+		}
+		require.NoError(t, db.db.Create(row).Error)
+		if f.deleted {
+			require.NoError(t, db.db.Delete(row).Error)
+		}
+		if f.respondAfter != 0 {
+			for _, after := range []time.Duration{f.respondAfter, 2 * f.respondAfter} {
+				c := &models.Change{FeedbackID: row.ID, Kind: models.ChangeKindStatus, Version: 2, CreatedAt: created.Add(after)}
+				require.NoError(t, db.db.Create(c).Error)
+			}
+		}
+	}
+
+	stats, err := db.GetStats(&models.StatsQuery{Scope: models.StatsScopeFaculty, Code: "linfo", Since: &start})
+	require.NoError(t, err, "getting the statistics should not fail")
+	assert.Equal(t, "LINFO", stats.Code)
+	assert.Equal(t, int64(4), stats.Feedback, "only the feedback of the faculty, within the period, should be counted")
+	assert.Equal(t, int64(75), stats.Upvotes)
+	assert.Equal(t, int64(6), stats.Downvotes)
+	assert.InDelta(t, 75.0/81, *stats.UpvoteRatio, 1e-9)
+	assert.Equal(t, map[models.FeedbackStatus]int64{
+		models.FeedbackStatusNew:          1,
+		models.FeedbackStatusAcknowledged: 1,
+		models.FeedbackStatusResolved:     1,
+		models.FeedbackStatusRejected:     1,
+	}, stats.Statuses)
+
+	require.Len(t, stats.Scores, len(models.ScoreBucketBounds)+1)
+	distribution := make([]int64, len(stats.Scores))
+	for i, b := range stats.Scores {
+		distribution[i] = b.Feedback
+	}
+	assert.Equal(t, []int64{1, 0, 1, 0, 1, 0, 1}, distribution)
+	assert.Nil(t, stats.Scores[0].Min)
+	assert.Equal(t, int64(-1), *stats.Scores[0].Max)
+	assert.Equal(t, int64(50), *stats.Scores[6].Min)
+	assert.Nil(t, stats.Scores[6].Max)
+
+	assert.Equal(t, []*models.TagCount{
+		{Tag: "schedule-conflict", Count: 2},
+		{Tag: "exam", Count: 1},
+		{Tag: "slides", Count: 1},
+	}, stats.Tags, "the tags should be counted, most common first")
+
+	assert.Equal(t, int64(3), stats.Responded)
+	require.NotNil(t, stats.MedianResponseSeconds)
+	assert.InDelta(t, 2*time.Hour.Seconds(), *stats.MedianResponseSeconds, 1, "the median should be over the first responses")
+
+	stats, err = db.GetStats(&models.StatsQuery{Scope: models.StatsScopeCourse, Code: "LINFO1101"})
+	require.NoError(t, err, "getting the statistics should not fail")
+	assert.Equal(t, int64(2), stats.Feedback, "courses should match regardless of case")
+	require.NotNil(t, stats.MedianResponseSeconds)
+	assert.InDelta(t, 2*time.Hour.Seconds(), *stats.MedianResponseSeconds, 1, "the median of an even count should be the mean of the middle values")
+
+	stats, err = db.GetStats(&models.StatsQuery{Scope: models.StatsScopeCourse, Code: "LSINF1000"})
+	require.NoError(t, err, "getting the statistics of a course without feedback should not fail")
+	assert.Zero(t, stats.Feedback)
+	assert.Empty(t, stats.Tags)
+	assert.Nil(t, stats.UpvoteRatio)
+	assert.Nil(t, stats.MedianResponseSeconds)
+}
@@ -0,0 +1,78 @@
+/**
+ * file: database/status.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the status change requests
+ * database logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddStatusRequest adds the status request, unless the voter already
+// requested the same status for the feedback. It returns the request
+// of the voter, and whether it was added.
+func (db *Database) AddStatusRequest(s *models.StatusRequest) (*models.StatusRequest, bool, error) {
+	added := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(s)
+		if r.Error != nil {
+			return r.Error
+		}
+		if added = r.RowsAffected != 0; added {
+			return nil
+		}
+
+		return tx.
+			Where("token_hash = ? AND feedback_id = ? AND status = ?", s.TokenHash, s.FeedbackID, s.Status).
+			First(s).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return s, added, nil
+}
+
+// GetStatusRequests returns the pending status requests on the
+// feedback, oldest first.
+func (db *Database) GetStatusRequests(feedbackID uint) (s []*models.StatusRequest, err error) {
+	err = db.db.
+		Where("feedback_id = ?", feedbackID).
+		Order("id").
+		Find(&s).Error
+	return
+}
+
+// GetUnnotifiedStatusRequests returns at most limit pending status
+// requests the representatives were not notified of, oldest first.
+func (db *Database) GetUnnotifiedStatusRequests(limit int) (s []*models.StatusRequest, err error) {
+	err = db.db.
+		Where("notified_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&s).Error
+	return
+}
+
+// SetStatusRequestNotified records that the representatives were
+// notified of the status request at the given time.
+func (db *Database) SetStatusRequestNotified(id uint, at time.Time) error {
+	return db.db.Model(&models.StatusRequest{}).
+		Where("id = ?", id).
+		UpdateColumn("notified_at", at).Error
+}
+
+// settleStatusRequests removes the pending status requests on the
+// feedback, once its status changed.
+func settleStatusRequests(tx *gorm.DB, feedbackID uint) error {
+	return tx.Where("feedback_id = ?", feedbackID).Delete(&models.StatusRequest{}).Error
+}
@@ -0,0 +1,42 @@
+/**
+ * file: database/status_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the status request persistence.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAddStatusRequestExisting tests that requesting the same status
+// twice returns the existing request.
+func TestAddStatusRequestExisting(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	hash := []byte("hash")
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^INSERT INTO [`\"']status_requests[`\"'] .* ON CONFLICT DO NOTHING RETURNING [`\"']id[`\"']$").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.
+		ExpectQuery("^SELECT \\* FROM [`\"']status_requests[`\"'] WHERE token_hash = .* AND feedback_id = .* AND status = .*$").
+		WithArgs(hash, 1, models.FeedbackStatusAcknowledged, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "feedback_id", "status"}).AddRow(3, 1, "acknowledged"))
+	mock.ExpectCommit()
+
+	s, added, err := db.AddStatusRequest(&models.StatusRequest{TokenHash: hash, FeedbackID: 1, Status: models.FeedbackStatusAcknowledged})
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, uint(3), s.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
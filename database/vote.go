@@ -0,0 +1,340 @@
+/**
+ * file: database/vote.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the vote event database
+ * logic for the data persistance plane.
+ */
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVoteNotFlagged error = errors.New("vote is not awaiting review")
+)
+
+// counterColumn returns the feedback column holding the counter
+// a vote of the given kind applies to.
+func counterColumn(kind models.VoteKind) string {
+	if kind == models.VoteKindDownvote {
+		return "downvotes"
+	}
+	return "upvotes"
+}
+
+func (db *Database) AddVote(vote *models.Vote) (*models.Vote, error) {
+	if r := db.db.Create(vote); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return vote, nil
+}
+
+func (db *Database) GetVote(id uint) (*models.Vote, error) {
+	v := new(models.Vote)
+	if r := db.db.First(&v, id); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return v, nil
+}
+
+// GetVotesSince returns all votes cast at or after since, in the
+// order they were cast.
+func (db *Database) GetVotesSince(since time.Time) (v []*models.Vote, err error) {
+	err = db.db.
+		Where("created_at >= ?", since).
+		Order("created_at, id").
+		Find(&v).Error
+	return
+}
+
+// GetFlaggedVotes returns all votes currently quarantined and
+// awaiting moderator review, grouped by feedback.
+func (db *Database) GetFlaggedVotes() (v []*models.Vote, err error) {
+	err = db.db.
+		Where("quarantined = ?", true).
+		Where("reviewed = ?", false).
+		Order("feedback_id, created_at, id").
+		Find(&v).Error
+	return
+}
+
+// QuarantineVote flags the vote with the given reason and removes
+// it from the public score of its feedback. Votes that are already
+// quarantined or were reviewed are left untouched, in which case
+// false is returned.
+func (db *Database) QuarantineVote(vote *models.Vote, reason string) (bool, error) {
+	quarantined := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Model(&models.Vote{}).
+			Where("id = ?", vote.ID).
+			Where("quarantined = ?", false).
+			Where("reviewed = ?", false).
+			Updates(map[string]any{"flag": reason, "quarantined": true})
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 0 {
+			return nil
+		}
+
+		// A counter already at 0, or a deleted feedback, has nothing
+		// left to withdraw the vote from, the vote is quarantined
+		// nonetheless.
+		quarantined = true
+		_, err := updateFeedbackAppreciation(tx, vote.FeedbackID, vote.Kind, -vote.Delta)
+		if errors.Is(err, ErrVoteFloor) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if quarantined {
+		vote.Flag = reason
+		vote.Quarantined = true
+	}
+
+	return quarantined, nil
+}
+
+// ReviewVote records the moderator decision on a quarantined vote.
+// Approved votes are released from quarantine and counted again,
+// rejected votes stay excluded from the public score. The review of
+// the feedback is unassigned once no vote on it awaits review.
+func (db *Database) ReviewVote(id uint, approve bool) (*models.Vote, error) {
+	v := new(models.Vote)
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.First(&v, id); r.Error != nil {
+			return r.Error
+		}
+		if !v.Quarantined || v.Reviewed {
+			return ErrVoteNotFlagged
+		}
+
+		v.Reviewed = true
+		v.Quarantined = !approve
+		r := tx.Model(v).Updates(map[string]any{"reviewed": v.Reviewed, "quarantined": v.Quarantined})
+		if r.Error != nil {
+			return r.Error
+		}
+
+		// The assignment ends with the review of the last vote
+		// awaiting it on the feedback.
+		r = tx.Where("feedback_id = ?", v.FeedbackID).
+			Where("feedback_id NOT IN (?)", pendingVotes(tx)).
+			Delete(&models.ModerationAssignment{})
+		if r.Error != nil {
+			return r.Error
+		}
+
+		if !approve {
+			return nil
+		}
+
+		_, err := updateFeedbackAppreciation(tx, v.FeedbackID, v.Kind, v.Delta)
+		if errors.Is(err, ErrVoteFloor) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// pendingVotes returns the subquery of the IDs of the feedback with
+// votes awaiting moderator review.
+func pendingVotes(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&models.Vote{}).
+		Select("feedback_id").
+		Where("quarantined = ?", true).
+		Where("reviewed = ?", false)
+}
+
+// AssignModeration assigns the review of the votes on the feedback,
+// unless it is already assigned. It returns the assignment of the
+// feedback, and whether it was added.
+func (db *Database) AssignModeration(a *models.ModerationAssignment) (*models.ModerationAssignment, bool, error) {
+	added := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+		if r.Error != nil {
+			return r.Error
+		}
+		if added = r.RowsAffected != 0; added {
+			return nil
+		}
+
+		return tx.Where("feedback_id = ?", a.FeedbackID).First(a).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return a, added, nil
+}
+
+// GetModerationAssignment returns the assignment of the review of
+// the votes on the feedback.
+func (db *Database) GetModerationAssignment(feedbackID uint) (*models.ModerationAssignment, error) {
+	a := new(models.ModerationAssignment)
+	if r := db.db.Where("feedback_id = ?", feedbackID).First(a); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return a, nil
+}
+
+// GetModerationAssignments returns the assignments of the feedback
+// with votes awaiting moderator review.
+func (db *Database) GetModerationAssignments() (a []*models.ModerationAssignment, err error) {
+	err = db.db.
+		Where("feedback_id IN (?)", pendingVotes(db.db)).
+		Order("feedback_id").
+		Find(&a).Error
+	return
+}
+
+// GetModerationLoads returns the number of feedback with votes
+// awaiting moderator review assigned to each representative.
+// Representatives without any are left out.
+func (db *Database) GetModerationLoads() (map[uint]int, error) {
+	var rows []struct {
+		RepresentativeID uint
+		Count            int
+	}
+	err := db.db.Model(&models.ModerationAssignment{}).
+		Select("representative_id, COUNT(*) AS count").
+		Where("feedback_id IN (?)", pendingVotes(db.db)).
+		Group("representative_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	loads := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		loads[row.RepresentativeID] = row.Count
+	}
+	return loads, nil
+}
+
+// bucketIndex returns the SQL expression of the index of the bucket
+// of the given width, in seconds, holding the timestamp expression.
+// Buckets are counted from origin, in seconds since the epoch.
+func (db *Database) bucketIndex(expr string, origin, width int64) string {
+	if db.kind == "pgsql" {
+		return fmt.Sprintf("CAST(FLOOR((EXTRACT(EPOCH FROM %s) - %d) / %d) AS bigint)", expr, origin, width)
+	}
+	return fmt.Sprintf("(CAST(strftime('%%s', %s) AS INTEGER) - %d) / %d", expr, origin, width)
+}
+
+// GetVoteTimeline returns the votes cast on the feedback within the
+// period of the query, counted in buckets of its interval. Buckets
+// start at models.TimelineOrigin, and the first bucket holds Since.
+func (db *Database) GetVoteTimeline(q *models.TimelineQuery) (*models.Timeline, error) {
+	width := int64(q.Interval.Duration() / time.Second)
+	origin := models.TimelineOrigin.Unix()
+	since := q.Interval.Truncate(q.Since)
+
+	var rows []struct {
+		Bucket int64
+		Kind   models.VoteKind
+		Votes  int64
+	}
+	r := db.db.Model(&models.Vote{}).
+		Select(db.bucketIndex("created_at", origin, width)+" AS bucket, kind, SUM(delta) AS votes").
+		Where("feedback_id = ? AND quarantined = ?", q.FeedbackID, false).
+		Where("created_at >= ? AND created_at < ?", since, q.Until).
+		Group("bucket, kind").
+		Scan(&rows)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+
+	timeline := &models.Timeline{FeedbackID: q.FeedbackID, Interval: q.Interval}
+	for start := since; start.Before(q.Until); start = start.Add(q.Interval.Duration()) {
+		timeline.Buckets = append(timeline.Buckets, &models.TimelineBucket{Start: start})
+	}
+
+	first := (since.Unix() - origin) / width
+	for _, row := range rows {
+		i := row.Bucket - first
+		if i < 0 || i >= int64(len(timeline.Buckets)) {
+			continue
+		}
+
+		if row.Kind == models.VoteKindUpvote {
+			timeline.Buckets[i].Upvotes += row.Votes
+		} else {
+			timeline.Buckets[i].Downvotes += row.Votes
+		}
+	}
+
+	return timeline, nil
+}
+
+// GetVoteTrends returns the net votes cast on the feedback of the
+// course, within the baseline period from baselineStart to
+// recentStart, and within the recent window from recentStart on.
+// Feedback without votes since baselineStart is left out.
+func (db *Database) GetVoteTrends(course string, baselineStart, recentStart time.Time) ([]*models.Trend, error) {
+	net := fmt.Sprintf("CASE WHEN votes.kind = '%s' THEN votes.delta ELSE -votes.delta END", models.VoteKindUpvote)
+
+	var rows []struct {
+		FeedbackID       uint
+		Recent, Baseline int64
+	}
+	r := db.db.Model(&models.Vote{}).
+		Select(fmt.Sprintf("votes.feedback_id, COALESCE(SUM(CASE WHEN votes.created_at >= ? THEN %s END), 0) AS recent, COALESCE(SUM(CASE WHEN votes.created_at < ? THEN %s END), 0) AS baseline", net, net), recentStart, recentStart).
+		Joins("JOIN feedbacks ON feedbacks.id = votes.feedback_id AND feedbacks.deleted_at IS NULL").
+		Where("UPPER(feedbacks.course) = ?", strings.ToUpper(course)).
+		Where("votes.created_at >= ? AND votes.quarantined = ?", baselineStart, false).
+		Group("votes.feedback_id").
+		Order("votes.feedback_id").
+		Scan(&rows)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.FeedbackID
+	}
+	var feedback []*models.Feedback
+	if r := db.db.Where("id IN ?", ids).Find(&feedback); r.Error != nil {
+		return nil, r.Error
+	}
+	byID := make(map[uint]*models.Feedback, len(feedback))
+	for _, f := range feedback {
+		byID[f.ID] = f
+	}
+
+	trends := make([]*models.Trend, 0, len(rows))
+	for _, row := range rows {
+		if f, ok := byID[row.FeedbackID]; ok {
+			trends = append(trends, &models.Trend{Feedback: f, Recent: row.Recent, Baseline: row.Baseline})
+		}
+	}
+	return trends, nil
+}
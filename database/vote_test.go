@@ -0,0 +1,384 @@
+/**
+ * file: database/vote_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the vote events persistence.
+ */
+
+package database
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaswdr/faker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var voteColumns = []string{"id", "feedback_id", "kind", "delta", "token", "address", "created_at", "flag", "quarantined", "reviewed"}
+
+// generateVotes is a helper function for tests, generating
+// `n` votes using Faker. It takes a seed, which can be `0`,
+// in which case the seed is generated. The mutator function
+// allows to mutate the vote in place.
+//
+// The function returns the slice of votes and the actual
+// seed used.
+func generateVotes(n uint, seed int64, mutator func(*models.Vote, faker.Faker)) (v []*models.Vote, s int64) {
+	if seed == 0 {
+		s = time.Now().UnixMilli()
+	} else {
+		s = seed
+	}
+
+	if mutator == nil {
+		mutator = func(v *models.Vote, fkr faker.Faker) {}
+	}
+
+	fkr := faker.NewWithSeed(rand.NewSource(s))
+	var i uint
+	for i = 0; i < n; i++ {
+		newVote := &models.Vote{
+			ID:         fkr.UIntBetween(1, 0xffff),
+			FeedbackID: fkr.UIntBetween(1, 0xffff),
+			Kind:       models.VoteKindUpvote,
+			Delta:      1,
+			Token:      fkr.RandomStringWithLength(16),
+			Address:    fkr.Internet().Ipv4(),
+			CreatedAt:  time.Unix(fkr.Int64Between(0, time.Now().Unix()), 0).UTC(),
+		}
+		mutator(newVote, fkr)
+		v = append(v, newVote)
+	}
+
+	return
+}
+
+func voteRow(rows *sqlmock.Rows, v *models.Vote) *sqlmock.Rows {
+	return rows.AddRow(v.ID, v.FeedbackID, v.Kind, v.Delta, v.Token, v.Address, v.CreatedAt, v.Flag, v.Quarantined, v.Reviewed)
+}
+
+// TestAddVote tests that vote events are stored as given.
+func TestAddVote(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	for _, v := range votes {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^INSERT INTO [`\"']votes[`\"'] .*$").
+			WithArgs(v.FeedbackID, v.Kind, v.Delta, v.Token, v.TokenFamily, v.Address, v.CreatedAt, v.Flag, v.Quarantined, v.Reviewed, v.SessionID, v.SessionRound, v.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(v.ID))
+		mock.ExpectCommit()
+
+		actual, err := db.AddVote(v)
+		assert.NoError(t, err, "adding a vote should not return an error")
+		assert.Equal(t, v, actual, "the stored vote should be returned")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetVotesSince tests that votes are fetched from the given
+// time on.
+func TestGetVotesSince(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	rows := sqlmock.NewRows(voteColumns)
+	for _, v := range votes {
+		voteRow(rows, v)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	mock.
+		ExpectQuery("^SELECT .+ FROM [`\"']votes[`\"'] WHERE created_at >= .* ORDER BY created_at, id$").
+		WithArgs(since).
+		WillReturnRows(rows)
+
+	actual, err := db.GetVotesSince(since)
+	assert.NoError(t, err, "fetching votes should not return an error")
+	assert.Equal(t, votes, actual, "all votes should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestQuarantineVote tests that quarantining a vote removes it
+// from the counter of its feedback.
+func TestQuarantineVote(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(10, 0, func(v *models.Vote, fkr faker.Faker) {
+		if fkr.Bool() {
+			v.Kind = models.VoteKindDownvote
+		}
+	})
+	t.Logf("seed: %x\n", seed)
+
+	for _, v := range votes {
+		column := counterColumn(v.Kind)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("^UPDATE [`\"']votes[`\"'] SET .* WHERE id = .* AND quarantined = .* AND reviewed = .*$").
+			WithArgs("velocity", true, v.ID, false, false).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .*[`\"']" + column + "[`\"']=" + column + " \\+ .* WHERE id = .* AND " + column + " \\+ .* >= 0 .*RETURNING .*$").
+			WithArgs(appreciationArgs(v.Kind, -v.Delta, v.FeedbackID)...).
+			WillReturnRows(sqlmock.NewRows(feedbackColumns).AddRow(v.FeedbackID, "LINFO1101", "feedback", 0, 0, "new", 2))
+		expectChange(mock, models.ChangeKindVote, v.FeedbackID, 2)
+		mock.ExpectCommit()
+
+		ok, err := db.QuarantineVote(v, "velocity")
+		assert.NoError(t, err, "quarantining a vote should not return an error")
+		assert.True(t, ok, "the vote should be quarantined")
+		assert.True(t, v.Quarantined, "the vote should be marked as quarantined")
+		assert.Equal(t, "velocity", v.Flag, "the vote should carry the flag reason")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestQuarantineVoteFloor tests that votes are quarantined even
+// when the counter of their feedback is already at 0.
+func TestQuarantineVoteFloor(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(1, 0, nil)
+	t.Logf("seed: %x\n", seed)
+	v := votes[0]
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("^UPDATE [`\"']votes[`\"'] SET .* WHERE .*$").
+		WithArgs("velocity", true, v.ID, false, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .* RETURNING .*$").
+		WithArgs(appreciationArgs(v.Kind, -v.Delta, v.FeedbackID)...).
+		WillReturnRows(sqlmock.NewRows(feedbackColumns))
+	mock.
+		ExpectQuery("^SELECT count\\(\\*\\) FROM [`\"']feedbacks[`\"'] WHERE id = .*$").
+		WithArgs(v.FeedbackID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+
+	ok, err := db.QuarantineVote(v, "velocity")
+	assert.NoError(t, err, "quarantining a vote on a counter at 0 should not return an error")
+	assert.True(t, ok, "the vote should be quarantined")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestQuarantineVoteAlreadyQuarantined tests that votes already
+// quarantined or reviewed are not removed from the counters twice.
+func TestQuarantineVoteAlreadyQuarantined(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(10, 0, nil)
+	t.Logf("seed: %x\n", seed)
+
+	for _, v := range votes {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("^UPDATE [`\"']votes[`\"'] SET .* WHERE .*$").
+			WithArgs("address", true, v.ID, false, false).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		ok, err := db.QuarantineVote(v, "address")
+		assert.NoError(t, err, "quarantining a vote twice should not return an error")
+		assert.False(t, ok, "the vote should not be quarantined again")
+		assert.False(t, v.Quarantined, "the vote should be left untouched")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReviewVote tests that approved votes are counted again, and
+// rejected votes stay quarantined.
+func TestReviewVote(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(10, 0, func(v *models.Vote, fkr faker.Faker) {
+		v.Flag = "velocity"
+		v.Quarantined = true
+	})
+	t.Logf("seed: %x\n", seed)
+
+	for i, v := range votes {
+		approve := i%2 == 0
+
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT .+ FROM [`\"']votes[`\"'] WHERE [`\"']votes[`\"']\\.[`\"']id[`\"']\\W*=.*$").
+			WithArgs(v.ID, 1).
+			WillReturnRows(voteRow(sqlmock.NewRows(voteColumns), v))
+		mock.
+			ExpectExec("^UPDATE [`\"']votes[`\"'] SET .* WHERE .*$").
+			WithArgs(!approve, true, v.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectExec("^DELETE FROM [`\"']moderation_assignments[`\"'] WHERE feedback_id = .* AND feedback_id NOT IN \\(SELECT .*\\)$").
+			WithArgs(v.FeedbackID, true, false).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		if approve {
+			mock.
+				ExpectQuery("^UPDATE [`\"']feedbacks[`\"'] SET .* WHERE .* RETURNING .*$").
+				WithArgs(appreciationArgs(v.Kind, v.Delta, v.FeedbackID)...).
+				WillReturnRows(sqlmock.NewRows(feedbackColumns).AddRow(v.FeedbackID, "LINFO1101", "feedback", 1, 0, "new", 2))
+			expectChange(mock, models.ChangeKindVote, v.FeedbackID, 2)
+		}
+		mock.ExpectCommit()
+
+		actual, err := db.ReviewVote(v.ID, approve)
+		assert.NoError(t, err, "reviewing a quarantined vote should not return an error")
+		assert.True(t, actual.Reviewed, "the vote should be marked as reviewed")
+		assert.Equal(t, !approve, actual.Quarantined, "only rejected votes should stay quarantined")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReviewVoteNotFlagged tests that votes which are not awaiting
+// review cannot be reviewed.
+func TestReviewVoteNotFlagged(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	votes, seed := generateVotes(10, 0, func(v *models.Vote, fkr faker.Faker) {
+		v.Quarantined = fkr.Bool()
+		v.Reviewed = v.Quarantined
+	})
+	t.Logf("seed: %x\n", seed)
+
+	for _, v := range votes {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("^SELECT .+ FROM [`\"']votes[`\"'] WHERE .*$").
+			WithArgs(v.ID, 1).
+			WillReturnRows(voteRow(sqlmock.NewRows(voteColumns), v))
+		mock.ExpectRollback()
+
+		actual, err := db.ReviewVote(v.ID, true)
+		assert.ErrorIs(t, err, ErrVoteNotFlagged, "reviewing a vote not awaiting review should fail")
+		assert.Nil(t, actual, "no vote should be returned")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReviewVoteUnknown tests that unknown votes cannot be
+// reviewed.
+func TestReviewVoteUnknown(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("^SELECT .+ FROM [`\"']votes[`\"'] WHERE .*$").
+		WithArgs(42, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectRollback()
+
+	actual, err := db.ReviewVote(42, true)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "reviewing an unknown vote should fail")
+	assert.Nil(t, actual, "no vote should be returned")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// createVoteHistory is a helper function for tests, creating a
+// migrated SQLite database with a feedback on each course, and the
+// votes cast on the first one at the given offsets from now.
+// Negative offsets are upvotes, and positive ones downvotes.
+func createVoteHistory(t *testing.T, now time.Time, courses []string, votes map[uint][]time.Duration) *Database {
+	db := createSQLiteDatabase(t)
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+
+	for _, course := range courses {
+		require.NoError(t, db.db.Create(&models.Feedback{Course: course, Feedback: "The exercise sessions are far too short for us."}).Error)
+	}
+	for id, offsets := range votes {
+		for _, offset := range offsets {
+			v := &models.Vote{FeedbackID: id, Kind: models.VoteKindUpvote, Delta: 1, CreatedAt: now.Add(-offset.Abs())}
+			if offset > 0 {
+				v.Kind = models.VoteKindDownvote
+			}
+			_, err := db.AddVote(v)
+			require.NoError(t, err)
+		}
+	}
+	return db
+}
+
+// TestGetVoteTimeline tests that votes are counted in the buckets
+// they were cast in, leaving out quarantined votes.
+func TestGetVoteTimeline(t *testing.T) {
+	now := time.Date(2024, 10, 9, 15, 30, 0, 0, time.UTC)
+	db := createVoteHistory(t, now, []string{"LINFO1101"}, map[uint][]time.Duration{
+		1: {-time.Minute, -20 * time.Minute, 10 * time.Minute, -2 * time.Hour, -9 * 24 * time.Hour},
+	})
+	require.NoError(t, db.db.Create(&models.Vote{FeedbackID: 1, Kind: models.VoteKindUpvote, Delta: 1, CreatedAt: now, Quarantined: true}).Error)
+	require.NoError(t, db.db.Create(&models.Vote{FeedbackID: 1, Kind: models.VoteKindUpvote, Delta: -1, CreatedAt: now.Add(-2 * time.Hour)}).Error)
+
+	timeline, err := db.GetVoteTimeline(&models.TimelineQuery{
+		FeedbackID: 1,
+		Interval:   models.TimelineHour,
+		Since:      now.Add(-3 * time.Hour),
+		Until:      now.Add(time.Second),
+	})
+	require.NoError(t, err)
+	require.Len(t, timeline.Buckets, 4, "the first bucket should hold the start of the period")
+	assert.Equal(t, time.Date(2024, 10, 9, 12, 0, 0, 0, time.UTC), timeline.Buckets[0].Start)
+
+	counts := make([][2]int64, len(timeline.Buckets))
+	for i, b := range timeline.Buckets {
+		counts[i] = [2]int64{b.Upvotes, b.Downvotes}
+	}
+	assert.Equal(t, [][2]int64{{0, 0}, {0, 0}, {0, 0}, {2, 1}}, counts, "retracted votes should be subtracted")
+
+	timeline, err = db.GetVoteTimeline(&models.TimelineQuery{
+		FeedbackID: 1,
+		Interval:   models.TimelineWeek,
+		Since:      now.AddDate(0, 0, -14),
+		Until:      now,
+	})
+	require.NoError(t, err)
+	require.Len(t, timeline.Buckets, 3)
+	assert.Equal(t, time.Monday, timeline.Buckets[0].Start.Weekday(), "weeks should start on Mondays")
+	assert.Equal(t, int64(1), timeline.Buckets[1].Upvotes)
+	assert.Equal(t, int64(2), timeline.Buckets[2].Upvotes)
+}
+
+// TestGetVoteTrends tests that the votes on the feedback of the
+// course are split between the baseline and the recent window.
+func TestGetVoteTrends(t *testing.T) {
+	now := time.Now().UTC()
+	db := createVoteHistory(t, now, []string{"LINFO1101", "linfo1101", "LEPL1102"}, map[uint][]time.Duration{
+		1: {-time.Hour, -2 * time.Hour, 3 * time.Hour, -3 * 24 * time.Hour, -30 * 24 * time.Hour},
+		2: {-4 * 24 * time.Hour},
+		3: {-time.Hour},
+	})
+
+	trends, err := db.GetVoteTrends("LINFO1101", now.Add(-8*24*time.Hour), now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, trends, 2, "only the feedback of the course with votes should be returned")
+	assert.Equal(t, uint(1), trends[0].Feedback.ID)
+	assert.Equal(t, int64(1), trends[0].Recent, "downvotes should be subtracted")
+	assert.Equal(t, int64(1), trends[0].Baseline, "votes before the baseline should be left out")
+	assert.Equal(t, uint(2), trends[1].Feedback.ID)
+	assert.Equal(t, int64(0), trends[1].Recent)
+	assert.Equal(t, int64(1), trends[1].Baseline)
+}
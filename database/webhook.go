@@ -0,0 +1,186 @@
+/**
+ * file: database/webhook.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the webhook database logic for
+ * the data persistance plane.
+ */
+
+package database
+
+import (
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddWebhook adds the webhook. Only the changes made after it was
+// added are delivered to it.
+func (db *Database) AddWebhook(w *models.Webhook) (*models.Webhook, error) {
+	err := db.Transaction(func(tx *Database) error {
+		cursor, err := tx.GetLatestChangeSeq()
+		if err != nil {
+			return err
+		}
+		w.Cursor = cursor
+
+		return tx.db.Create(w).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (db *Database) GetWebhooks() (w []*models.Webhook, err error) {
+	err = db.db.Order("id").Find(&w).Error
+	return
+}
+
+func (db *Database) GetWebhook(id uint) (*models.Webhook, error) {
+	w := new(models.Webhook)
+	if r := db.db.First(w, id); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return w, nil
+}
+
+// DeleteWebhook deletes the webhook, along with its deliveries and
+// their log.
+func (db *Database) DeleteWebhook(id uint) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		deliveries := tx.Model(&models.WebhookDelivery{}).Select("id").Where("webhook_id = ?", id)
+		if r := tx.Where("delivery_id IN (?)", deliveries).Delete(&models.WebhookAttempt{}); r.Error != nil {
+			return r.Error
+		}
+		if r := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}); r.Error != nil {
+			return r.Error
+		}
+
+		r := tx.Delete(&models.Webhook{}, id)
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// EnqueueWebhookDeliveries queues the deliveries and moves the
+// cursor of the webhook to the given sequence number. It returns
+// false, and queues nothing, if the cursor of the webhook was moved
+// concurrently.
+func (db *Database) EnqueueWebhookDeliveries(w *models.Webhook, deliveries []*models.WebhookDelivery, cursor uint64) (bool, error) {
+	enqueued := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Model(&models.Webhook{}).
+			Where("id = ? AND cursor = ?", w.ID, w.Cursor).
+			UpdateColumn("cursor", cursor)
+		if r.Error != nil || r.RowsAffected == 0 {
+			return r.Error
+		}
+
+		if len(deliveries) > 0 {
+			if r := tx.Create(deliveries); r.Error != nil {
+				return r.Error
+			}
+		}
+
+		enqueued = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if enqueued {
+		w.Cursor = cursor
+	}
+	return enqueued, nil
+}
+
+// GetDueWebhookDeliveries returns at most limit pending deliveries
+// due at the given time, oldest first.
+func (db *Database) GetDueWebhookDeliveries(now time.Time, limit int) (d []*models.WebhookDelivery, err error) {
+	err = db.db.
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&d).Error
+	return
+}
+
+// ClaimWebhookDelivery postpones the due delivery until the given
+// time, so that it is not attempted concurrently. It returns false
+// if the delivery was already claimed or attempted.
+func (db *Database) ClaimWebhookDelivery(d *models.WebhookDelivery, now, until time.Time) (bool, error) {
+	r := db.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", d.ID, models.WebhookDeliveryPending, d.Attempts).
+		Where("next_attempt_at <= ?", now).
+		UpdateColumn("next_attempt_at", until)
+	if r.Error != nil {
+		return false, r.Error
+	}
+
+	return r.RowsAffected == 1, nil
+}
+
+// RecordWebhookAttempt logs the attempt at delivering and saves
+// the resulting state of the delivery.
+func (db *Database) RecordWebhookAttempt(d *models.WebhookDelivery, a *models.WebhookAttempt) error {
+	a.DeliveryID = d.ID
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Create(a); r.Error != nil {
+			return r.Error
+		}
+
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id = ?", d.ID).
+			UpdateColumns(map[string]any{
+				"status":          d.Status,
+				"attempts":        d.Attempts,
+				"next_attempt_at": d.NextAttemptAt,
+			}).Error
+	})
+}
+
+// GetWebhookDeliveries returns at most limit deliveries of the
+// webhook with their log, latest first.
+func (db *Database) GetWebhookDeliveries(webhookID uint, limit int) (d []*models.WebhookDelivery, err error) {
+	err = db.db.
+		Preload("Log", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Where("webhook_id = ?", webhookID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&d).Error
+	return
+}
+
+// RedeliverWebhookDelivery queues the delivery of the webhook
+// again, due at the given time. Its log is kept.
+func (db *Database) RedeliverWebhookDelivery(webhookID, id uint, now time.Time) (*models.WebhookDelivery, error) {
+	var d []*models.WebhookDelivery
+	r := db.db.Model(&d).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND webhook_id = ?", id, webhookID).
+		UpdateColumns(map[string]any{
+			"status":          models.WebhookDeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if len(d) != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return d[0], nil
+}
@@ -0,0 +1,79 @@
+/**
+ * file: database/webhook_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the webhook persistence.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEnqueueWebhookDeliveries tests that the cursor of the webhook
+// is only moved from the position it was read at.
+func TestEnqueueWebhookDeliveries(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	for _, moved := range []bool{false, true} {
+		var affected int64 = 1
+		if moved {
+			affected = 0
+		}
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("^UPDATE [`\"']webhooks[`\"'] SET [`\"']cursor[`\"']=.* WHERE id = .* AND cursor = .*$").
+			WithArgs(12, 7, 4).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+		mock.ExpectCommit()
+
+		w := &models.Webhook{ID: 7, Cursor: 4}
+		enqueued, err := db.EnqueueWebhookDeliveries(w, nil, 12)
+		assert.NoError(t, err, "enqueueing should not return an error")
+		assert.Equal(t, !moved, enqueued, "changes should not be enqueued if the cursor moved")
+		if moved {
+			assert.Equal(t, uint64(4), w.Cursor, "the cursor should be kept if it moved")
+		} else {
+			assert.Equal(t, uint64(12), w.Cursor, "the cursor should be moved")
+		}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestClaimWebhookDelivery tests that a delivery is claimed only if
+// it was not attempted since it was read.
+func TestClaimWebhookDelivery(t *testing.T) {
+	db, closer, mock, _ := createMockDatabase(t)
+	defer closer()
+
+	now := time.Now()
+	until := now.Add(time.Minute)
+	for _, claimed := range []bool{true, false} {
+		var affected int64
+		if claimed {
+			affected = 1
+		}
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("^UPDATE [`\"']webhook_deliveries[`\"'] SET [`\"']next_attempt_at[`\"']=.* WHERE \\(id = .* AND status = .* AND attempts = .*\\) AND next_attempt_at <= .*$").
+			WithArgs(until, 3, models.WebhookDeliveryPending, 2, now).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+		mock.ExpectCommit()
+
+		ok, err := db.ClaimWebhookDelivery(&models.WebhookDelivery{ID: 3, Attempts: 2}, now, until)
+		assert.NoError(t, err, "claiming should not return an error")
+		assert.Equal(t, claimed, ok)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,98 @@
+/**
+ * file: export/csv.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file writes the exports as CSV files, as
+ * opened by spreadsheets in the language of the
+ * export.
+ */
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// csvSeparators are the separators of the fields for the languages
+// whose spreadsheets do not expect commas.
+var csvSeparators = map[string]rune{
+	"fr": ';',
+}
+
+// csvTimeFormat is the format of the times, which spreadsheets
+// recognize as such.
+const csvTimeFormat string = "2006-01-02 15:04:05"
+
+type csvWriter struct {
+	w        io.Writer
+	csv      *csv.Writer
+	language string
+	started  bool
+}
+
+func newCSVWriter(w io.Writer, language string) *csvWriter {
+	c := csv.NewWriter(w)
+	if sep, ok := csvSeparators[language]; ok {
+		c.Comma = sep
+	}
+	return &csvWriter{w: w, csv: c, language: language}
+}
+
+// start writes the byte order mark, so that spreadsheets read the
+// file as UTF-8, and the headers.
+func (c *csvWriter) start() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+
+	if _, err := io.WriteString(c.w, "\ufeff"); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = header(c.language, column)
+	}
+	return c.csv.Write(record)
+}
+
+func (c *csvWriter) Write(f *models.Feedback) error {
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	return c.csv.Write([]string{
+		strconv.FormatUint(uint64(f.ID), 10),
+		csvText(f.Course),
+		csvText(f.Faculty()),
+		csvText(f.Feedback),
+		status(c.language, f.Status),
+		strconv.FormatUint(f.Upvotes, 10),
+		strconv.FormatUint(f.Downvotes, 10),
+		strconv.FormatInt(f.Score(), 10),
+		createdAt(f).Format(csvTimeFormat),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// csvText returns the text as a field which spreadsheets do not
+// evaluate as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,174 @@
+/**
+ * file: export/export.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the exports of feedback, for
+ * analysis in spreadsheets. Rows are written as the
+ * feedback is read, so that exports of any size can
+ * be streamed. The headers and the status of the
+ * spreadsheet formats are localized.
+ */
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// Format is the file format of an export.
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Valid returns whether the format is known.
+func (f Format) Valid() bool {
+	switch f {
+	case FormatCSV, FormatXLSX, FormatNDJSON:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentType returns the media type of the exports in the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// DefaultLanguage is the language used when none of the requested
+// ones is available.
+const DefaultLanguage string = "en"
+
+// columns are the columns of the exports, named after the fields of
+// the feedback.
+var columns = []string{"ID", "Course", "Faculty", "Feedback", "Status", "Upvotes", "Downvotes", "Score", "CreatedAt"}
+
+// headers are the localized headers of the columns, and names of the
+// sheet, for each language.
+var headers = map[string]map[string]string{
+	"en": {
+		"ID":        "ID",
+		"Course":    "Course",
+		"Faculty":   "Faculty",
+		"Feedback":  "Feedback",
+		"Status":    "Status",
+		"Upvotes":   "Upvotes",
+		"Downvotes": "Downvotes",
+		"Score":     "Score",
+		"CreatedAt": "Created at (UTC)",
+		"sheet":     "Feedback",
+	},
+	"fr": {
+		"ID":        "ID",
+		"Course":    "Cours",
+		"Faculty":   "Faculté",
+		"Feedback":  "Avis",
+		"Status":    "Statut",
+		"Upvotes":   "Votes pour",
+		"Downvotes": "Votes contre",
+		"Score":     "Score",
+		"CreatedAt": "Créé le (UTC)",
+		"sheet":     "Avis",
+	},
+}
+
+// statuses are the localized status of feedback, for each language.
+var statuses = map[string]map[models.FeedbackStatus]string{
+	"en": {
+		models.FeedbackStatusNew:          "New",
+		models.FeedbackStatusAcknowledged: "Acknowledged",
+		models.FeedbackStatusInProgress:   "In progress",
+		models.FeedbackStatusResolved:     "Resolved",
+		models.FeedbackStatusRejected:     "Rejected",
+	},
+	"fr": {
+		models.FeedbackStatusNew:          "Nouveau",
+		models.FeedbackStatusAcknowledged: "Pris en compte",
+		models.FeedbackStatusInProgress:   "En cours",
+		models.FeedbackStatusResolved:     "Résolu",
+		models.FeedbackStatusRejected:     "Rejeté",
+	},
+}
+
+// Language returns the first available language among the given
+// preferences, or the default language. Preferences are language
+// tags or Accept-Language headers, of which only the primary
+// language is considered, in order.
+func Language(preferences ...string) string {
+	for _, p := range preferences {
+		for _, tag := range strings.Split(p, ",") {
+			tag, _, _ = strings.Cut(tag, ";")
+			tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+			if _, ok := headers[strings.ToLower(tag)]; ok {
+				return strings.ToLower(tag)
+			}
+		}
+	}
+	return DefaultLanguage
+}
+
+// header returns the localized header of the column.
+func header(language, column string) string {
+	return headers[language][column]
+}
+
+// status returns the localized status.
+func status(language string, s models.FeedbackStatus) string {
+	if l, ok := statuses[language][s]; ok {
+		return l
+	}
+	return string(s)
+}
+
+// The Writer interface writes feedback as the rows of an export.
+// The export is complete once the writer is closed, which does not
+// close the underlying writer.
+type Writer interface {
+	Write(f *models.Feedback) error
+	Close() error
+}
+
+// NewWriter returns a writer of the export in the format, with its
+// headers in the language. Nothing is written until the first
+// feedback, or until the writer is closed.
+func NewWriter(format Format, w io.Writer, language string) (Writer, error) {
+	if _, ok := headers[language]; !ok {
+		language = DefaultLanguage
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, language), nil
+	case FormatXLSX:
+		return newXLSXWriter(w, language), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// createdAt returns the creation time of the feedback in UTC, to the
+// second.
+func createdAt(f *models.Feedback) time.Time {
+	return f.CreatedAt.UTC().Truncate(time.Second)
+}
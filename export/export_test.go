@@ -0,0 +1,146 @@
+/**
+ * file: export/export_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFeedback() []*models.Feedback {
+	created := time.Date(2024, 10, 1, 12, 30, 0, 0, time.UTC)
+	return []*models.Feedback{
+		{ID: 1, Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us.", Status: models.FeedbackStatusNew, Upvotes: 12, Downvotes: 2, CreatedAt: created},
+		{ID: 2, Course: "LEPL1102", Feedback: "=HYPERLINK(\"http://example.com\"), and <more> than 25 characters", Status: models.FeedbackStatusResolved, CreatedAt: created.Add(time.Hour)},
+	}
+}
+
+// export writes the test feedback in the format and language.
+func export(t *testing.T, format Format, language string, feedback []*models.Feedback) []byte {
+	var b bytes.Buffer
+	w, err := NewWriter(format, &b, language)
+	require.NoError(t, err)
+	for _, f := range feedback {
+		require.NoError(t, w.Write(f))
+	}
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+// TestLanguage tests that the first available language is selected.
+func TestLanguage(t *testing.T) {
+	assert.Equal(t, "fr", Language("", "fr-BE,fr;q=0.9,en;q=0.8"))
+	assert.Equal(t, "en", Language("EN", "fr"), "the first preference should win")
+	assert.Equal(t, "fr", Language("de", "nl-BE, fr"))
+	assert.Equal(t, DefaultLanguage, Language("", "de-DE"))
+}
+
+// TestNewWriter tests that unknown formats are refused.
+func TestNewWriter(t *testing.T) {
+	_, err := NewWriter("pdf", io.Discard, "en")
+	assert.Error(t, err)
+	assert.False(t, Format("pdf").Valid())
+	assert.True(t, FormatNDJSON.Valid())
+}
+
+// TestWriteCSV tests that CSV exports are localized, and do not let
+// spreadsheets evaluate formulas.
+func TestWriteCSV(t *testing.T) {
+	out := export(t, FormatCSV, "en", newTestFeedback())
+	require.True(t, bytes.HasPrefix(out, []byte("\ufeff")), "the export should start with a byte order mark")
+
+	records, err := csv.NewReader(bytes.NewReader(out[3:])).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"ID", "Course", "Faculty", "Feedback", "Status", "Upvotes", "Downvotes", "Score", "Created at (UTC)"}, records[0])
+	assert.Equal(t, []string{"1", "LINFO1101", "LINFO", "The exercise sessions are far too short for us.", "New", "12", "2", "10", "2024-10-01 12:30:00"}, records[1])
+	assert.True(t, strings.HasPrefix(records[2][3], "'="), "formulas should be escaped")
+
+	out = export(t, FormatCSV, "fr", newTestFeedback())
+	r := csv.NewReader(bytes.NewReader(out[3:]))
+	r.Comma = ';'
+	records, err = r.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "Avis", records[0][3])
+	assert.Equal(t, "Résolu", records[2][4])
+
+	out = export(t, FormatCSV, "en", nil)
+	assert.Equal(t, 1, bytes.Count(out, []byte("\n")), "empty exports should have their headers")
+}
+
+// TestWriteNDJSON tests that NDJSON exports have a line per feedback.
+func TestWriteNDJSON(t *testing.T) {
+	out := export(t, FormatNDJSON, "fr", newTestFeedback())
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "LINFO", record["Faculty"])
+	assert.Equal(t, "new", record["Status"], "the status should not be localized")
+	assert.Equal(t, float64(10), record["Score"])
+	assert.Equal(t, "2024-10-01T12:30:00Z", record["CreatedAt"])
+	assert.Contains(t, lines[1], "<more>")
+
+	assert.Empty(t, export(t, FormatNDJSON, "en", nil))
+}
+
+// TestWriteXLSX tests that XLSX exports are valid workbooks, with a
+// row per feedback.
+func TestWriteXLSX(t *testing.T) {
+	out := export(t, FormatXLSX, "fr", newTestFeedback())
+	z, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	require.NoError(t, err, "the workbook should be a zip archive")
+
+	parts := make(map[string][]byte)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		parts[f.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		require.Contains(t, parts, name)
+		require.NoError(t, xml.Unmarshal(parts[name], new(struct{})), "%s should be well-formed", name)
+	}
+	assert.Contains(t, string(parts["xl/workbook.xml"]), `name="Avis"`)
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R      string `xml:"r,attr"`
+				Style  int    `xml:"s,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	require.NoError(t, xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet))
+	require.Len(t, sheet.Rows, 3)
+	assert.Equal(t, "Cours", sheet.Rows[0].Cells[1].Inline)
+	assert.Equal(t, xlsxStyleHeader, sheet.Rows[0].Cells[1].Style)
+
+	row := sheet.Rows[2]
+	require.Len(t, row.Cells, len(columns))
+	assert.Equal(t, "D3", row.Cells[3].R)
+	assert.Equal(t, newTestFeedback()[1].Feedback, row.Cells[3].Inline, "text should not be evaluated")
+	assert.Equal(t, "Résolu", row.Cells[4].Inline)
+	assert.Equal(t, "45566.5625", row.Cells[8].Value, "times should be serial days")
+	assert.Equal(t, xlsxStyleTime, row.Cells[8].Style)
+}
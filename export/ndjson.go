@@ -0,0 +1,60 @@
+/**
+ * file: export/ndjson.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file writes the exports as newline-delimited
+ * JSON, one object per feedback. Its fields are the
+ * ones of the API, and are not localized.
+ */
+
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// The ndjsonRecord structure is a line of the export.
+type ndjsonRecord struct {
+	ID        uint                  `json:"ID"`
+	Course    string                `json:"Course"`
+	Faculty   string                `json:"Faculty"`
+	Feedback  string                `json:"Feedback"`
+	Status    models.FeedbackStatus `json:"Status"`
+	Upvotes   uint64                `json:"Upvotes"`
+	Downvotes uint64                `json:"Downvotes"`
+	Score     int64                 `json:"Score"`
+	CreatedAt time.Time             `json:"CreatedAt"`
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &ndjsonWriter{enc: enc}
+}
+
+func (n *ndjsonWriter) Write(f *models.Feedback) error {
+	return n.enc.Encode(&ndjsonRecord{
+		ID:        f.ID,
+		Course:    f.Course,
+		Faculty:   f.Faculty(),
+		Feedback:  f.Feedback,
+		Status:    f.Status,
+		Upvotes:   f.Upvotes,
+		Downvotes: f.Downvotes,
+		Score:     f.Score(),
+		CreatedAt: createdAt(f),
+	})
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
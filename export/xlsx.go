@@ -0,0 +1,184 @@
+/**
+ * file: export/xlsx.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file writes the exports as XLSX workbooks of
+ * a single sheet. The workbook is a zip archive whose
+ * sheet is written last, row by row, so that it can
+ * be streamed without being held in memory.
+ */
+
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+const xlsxHeader string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// xlsxParts are the parts of the workbook written before its sheet.
+// The workbook is formatted with the name of the sheet.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	// The styles are the default one, xlsxStyleHeader in bold, and
+	// xlsxStyleTime for times.
+	{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`</cellXfs>` +
+		`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+		`</styleSheet>`},
+}
+
+const (
+	xlsxStyleHeader int = 1
+	xlsxStyleTime   int = 2
+)
+
+// xlsxWidths are the widths of the columns, in characters.
+var xlsxWidths = []int{8, 12, 10, 80, 16, 10, 10, 8, 20}
+
+// xlsxEpoch is the origin of the serial times of spreadsheets.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+type xlsxWriter struct {
+	w        io.Writer
+	zip      *zip.Writer
+	sheet    io.Writer
+	language string
+	rows     int
+}
+
+func newXLSXWriter(w io.Writer, language string) *xlsxWriter {
+	return &xlsxWriter{w: w, language: language}
+}
+
+// start writes the parts of the workbook, and starts its sheet with
+// the headers, frozen above the rows.
+func (x *xlsxWriter) start() error {
+	if x.zip != nil {
+		return nil
+	}
+	x.zip = zip.NewWriter(x.w)
+
+	for _, p := range xlsxParts {
+		part, err := x.zip.Create(p.name)
+		if err != nil {
+			return err
+		}
+		content := p.content
+		if p.name == "xl/workbook.xml" {
+			content = fmt.Sprintf(content, header(x.language, "sheet"))
+		}
+		if _, err := io.WriteString(part, xlsxHeader+content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = sheet
+
+	fmt.Fprint(x.sheet, xlsxHeader+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	fmt.Fprint(x.sheet, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><cols>`)
+	for i, width := range xlsxWidths {
+		fmt.Fprintf(x.sheet, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+	}
+	fmt.Fprint(x.sheet, `</cols><sheetData>`)
+
+	x.startRow()
+	for i, column := range columns {
+		x.text(i, header(x.language, column), xlsxStyleHeader)
+	}
+	return x.endRow()
+}
+
+func (x *xlsxWriter) startRow() {
+	x.rows++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+}
+
+func (x *xlsxWriter) endRow() error {
+	_, err := io.WriteString(x.sheet, `</row>`)
+	return err
+}
+
+// ref returns the reference of the cell of the column in the
+// current row.
+func (x *xlsxWriter) ref(column int) string {
+	return string(rune('A'+column)) + strconv.Itoa(x.rows)
+}
+
+func (x *xlsxWriter) text(column int, s string, style int) {
+	fmt.Fprintf(x.sheet, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, x.ref(column), style)
+	xml.EscapeText(x.sheet, []byte(s))
+	io.WriteString(x.sheet, `</t></is></c>`)
+}
+
+func (x *xlsxWriter) number(column int, v string, style int) {
+	fmt.Fprintf(x.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, x.ref(column), style, v)
+}
+
+func (x *xlsxWriter) Write(f *models.Feedback) error {
+	if err := x.start(); err != nil {
+		return err
+	}
+
+	days := float64(createdAt(f).Sub(xlsxEpoch)) / float64(24*time.Hour)
+
+	x.startRow()
+	x.number(0, strconv.FormatUint(uint64(f.ID), 10), 0)
+	x.text(1, f.Course, 0)
+	x.text(2, f.Faculty(), 0)
+	x.text(3, f.Feedback, 0)
+	x.text(4, status(x.language, f.Status), 0)
+	x.number(5, strconv.FormatUint(f.Upvotes, 10), 0)
+	x.number(6, strconv.FormatUint(f.Downvotes, 10), 0)
+	x.number(7, strconv.FormatInt(f.Score(), 10), 0)
+	x.number(8, strconv.FormatFloat(days, 'f', -1, 64), xlsxStyleTime)
+	return x.endRow()
+}
+
+// Close ends the sheet, and writes the directory of the archive.
+func (x *xlsxWriter) Close() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
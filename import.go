@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+)
+
+// importFeedback runs the import subcommand, and returns the exit
+// code. The rows which are not imported are listed with the reason.
+//
+//	import [-mapping mapping.json] [-source name] [-dry-run] file.csv
+func importFeedback(db *database.Database, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	mappingFile := flags.String("mapping", "", "JSON file mapping the columns, those of Google Forms by default")
+	source := flags.String("source", "", "name of the source, the name of the file by default")
+	dryRun := flags.Bool("dry-run", false, "only report on the rows")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		usage()
+		return 2
+	}
+
+	mapping := models.DefaultImportMapping
+	if *mappingFile != "" {
+		b, err := os.ReadFile(*mappingFile)
+		if err == nil {
+			err = json.Unmarshal(b, &mapping)
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("unable to read the mapping")
+			return 1
+		}
+	}
+	if *source == "" {
+		*source = filepath.Base(flags.Arg(0))
+	}
+
+	if err := db.CheckSchemaVersion(); err != nil {
+		logger.Error().Err(err).Msg("run the migrations first")
+		return 1
+	}
+	logic.Setup(db)
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to open the file")
+		return 1
+	}
+	defer f.Close()
+
+	report, err := logic.ImportFeedback(f, *source, &mapping, *dryRun)
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to import the file")
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tSTATUS\tREASON")
+	for _, r := range report.Results {
+		switch {
+		case r.Status == models.ImportRowDuplicate:
+			fmt.Fprintf(w, "%d\t%s\timported before as feedback %d\n", r.Row, r.Status, r.Feedback.ID)
+		case r.Error != nil:
+			errs, ok := r.Error.(uxerrors.Errors)
+			if !ok {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", r.Row, r.Status, r.Error)
+				continue
+			}
+			for _, e := range errs.Errors {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", r.Row, r.Status, e.Summary)
+			}
+		}
+	}
+	w.Flush()
+
+	logger.Info().
+		Str("source", report.Source).
+		Bool("dry_run", report.DryRun).
+		Int("rows", report.Rows).
+		Int("valid", report.Valid).
+		Int("imported", report.Imported).
+		Int("duplicates", report.Duplicates).
+		Int("invalid", report.Invalid).
+		Msg("import done")
+	return 0
+}
@@ -0,0 +1,312 @@
+/**
+ * file: logic/alert.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the alert rules, which tell
+ * representatives when feedback gains traction.
+ * Rules are evaluated on the votes and new feedback
+ * in the change feed, and their alerts are routed to
+ * the registered notification channels.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+const alertListLimit int = 100
+
+// An AlertChannel routes the alerts to representatives. Channels are
+// called outside of requests, and handle their own failures.
+type AlertChannel interface {
+	SendAlert(a *models.Alert)
+}
+
+// The alertEngine structure evaluates the rules on the changes made
+// after its cursor, in order.
+type alertEngine struct {
+	// mu protects the rules and the channels. It is never held while
+	// reading the database.
+	mu         sync.Mutex
+	rules      []*models.AlertRule
+	loaded     bool
+	generation uint64
+	channels   map[string]AlertChannel
+
+	// evaluation serializes the evaluations, and protects the cursor.
+	evaluation sync.Mutex
+	cursor     uint64
+}
+
+var alerts = &alertEngine{channels: make(map[string]AlertChannel)}
+
+// RegisterAlertChannel makes the channel available to the rules
+// under the given name.
+func RegisterAlertChannel(name string, c AlertChannel) {
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+	alerts.channels[name] = c
+}
+
+// AlertChannels returns the names of the registered channels.
+func AlertChannels() []string {
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+
+	names := make([]string, 0, len(alerts.channels))
+	for name := range alerts.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setupAlerts starts evaluating the rules from the latest change.
+func setupAlerts() {
+	seq, _ := db.GetLatestChangeSeq()
+
+	alerts.evaluation.Lock()
+	defer alerts.evaluation.Unlock()
+	alerts.cursor = seq
+	alerts.invalidate()
+}
+
+// invalidate makes the next evaluation load the rules again.
+func (e *alertEngine) invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = nil
+	e.loaded = false
+	e.generation++
+}
+
+// snapshot returns the rules and the channels to evaluate the changes
+// with. The rules are read again if they changed, without holding the
+// lock.
+func (e *alertEngine) snapshot() ([]*models.AlertRule, map[string]AlertChannel, error) {
+	for {
+		e.mu.Lock()
+		if e.loaded {
+			channels := make(map[string]AlertChannel, len(e.channels))
+			for name, c := range e.channels {
+				channels[name] = c
+			}
+			rules := e.rules
+			e.mu.Unlock()
+			return rules, channels, nil
+		}
+		generation := e.generation
+		e.mu.Unlock()
+
+		rules, err := db.GetAlertRules()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e.mu.Lock()
+		if e.generation == generation {
+			e.rules, e.loaded = rules, true
+		}
+		e.mu.Unlock()
+	}
+}
+
+func alertRuleError(summary, detail string) error {
+	uxe := uxerrors.New(errors.New(summary))
+	uxe.Summary = summary
+	uxe.Detail = detail
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// CreateAlertRule validates and adds the rule. It fires on the
+// changes made from now on.
+func CreateAlertRule(r *models.AlertRule) (*models.AlertRule, error) {
+	r.ID = 0
+	r.Tag = strings.ToLower(strings.TrimSpace(r.Tag))
+	if err := validators.ValidateAlertRule(r); err != nil {
+		return nil, err
+	}
+
+	if r.Window != "" {
+		if d, err := time.ParseDuration(r.Window); err != nil || d <= 0 || r.Kind != models.AlertRuleNetScore {
+			return nil, alertRuleError("The window is invalid",
+				"The window should be a positive duration, such as 48h, and only applies to net score rules. Correct the window and try again.")
+		}
+	}
+	if r.Kind == models.AlertRuleUpvoteRatio && r.Threshold > 1 {
+		return nil, alertRuleError("The ratio is invalid",
+			"The threshold of an upvote ratio rule should be between 0 and 1, such as 0.8. Correct the threshold and try again.")
+	}
+
+	channels := AlertChannels()
+	for _, name := range r.Channels {
+		if !slices.Contains(channels, name) {
+			return nil, alertRuleError("The channel is unknown",
+				fmt.Sprintf("There is no notification channel named %q. Use one of the configured channels and try again.", name))
+		}
+	}
+
+	r, err := db.AddAlertRule(r)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	alerts.invalidate()
+	return r, nil
+}
+
+func GetAlertRules() ([]*models.AlertRule, error) {
+	r, err := db.GetAlertRules()
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return r, nil
+}
+
+// DeleteAlertRule deletes the rule and the alerts it fired.
+func DeleteAlertRule(id uint) error {
+	if err := db.DeleteAlertRule(id); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return handleDatabaseError(err)
+		}
+
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The alert rule was not found"
+		uxe.Detail = "The alert rule you requested does not exist. Check the identifier and try again."
+		return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+	}
+
+	alerts.invalidate()
+	return nil
+}
+
+// GetAlerts returns the latest alerts fired.
+func GetAlerts() ([]*models.Alert, error) {
+	a, err := db.GetAlerts(alertListLimit)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return a, nil
+}
+
+// evaluateAlerts evaluates the rules on the changes made since the
+// last evaluation, routes the alerts they fire, and notifies the
+// representatives and the followers of the changes needing their
+// attention. Changes that cannot be evaluated now are evaluated on
+// the next call.
+func evaluateAlerts() error {
+	alerts.evaluation.Lock()
+	defer alerts.evaluation.Unlock()
+
+	rules, channels, err := alerts.snapshot()
+	if err != nil {
+		return fmt.Errorf("loading the alert rules: %w", err)
+	}
+
+	for {
+		feed, err := GetChanges(alerts.cursor, maxChangeLimit)
+		if err != nil {
+			return fmt.Errorf("reading the changes after %d: %w", alerts.cursor, err)
+		}
+
+		for _, c := range feed.Changes {
+			if err := evaluateChange(rules, channels, c, time.Now()); err != nil {
+				return fmt.Errorf("evaluating the alert rules on change %d: %w", c.Seq, err)
+			}
+			if err := notifyChange(c); err != nil {
+				return fmt.Errorf("notifying the representatives of change %d: %w", c.Seq, err)
+			}
+			if err := notifyFollowers(c); err != nil {
+				return fmt.Errorf("notifying the followers of change %d: %w", c.Seq, err)
+			}
+			alerts.cursor = c.Seq
+		}
+
+		if !feed.More {
+			return nil
+		}
+	}
+}
+
+// evaluateChange evaluates the rules on the change, and routes the
+// alerts fired for the first time to the channels.
+func evaluateChange(rules []*models.AlertRule, channels map[string]AlertChannel, c *models.Change, now time.Time) error {
+	if c.Feedback == nil || (c.Kind != models.ChangeKindVote && c.Kind != models.ChangeKindCreate) {
+		return nil
+	}
+
+	for _, r := range rules {
+		if !r.Matches(c.Feedback) {
+			continue
+		}
+
+		value, fired, err := evaluateAlertRule(r, c, now)
+		if err != nil {
+			return err
+		}
+		if !fired {
+			continue
+		}
+
+		a := &models.Alert{RuleID: r.ID, FeedbackID: c.FeedbackID, Value: value}
+		added, err := db.AddAlert(a)
+		if err != nil {
+			return err
+		}
+		if !added {
+			continue
+		}
+
+		a.Rule, a.Feedback = r, c.Feedback
+		for _, name := range r.Channels {
+			if channel, ok := channels[name]; ok {
+				go channel.SendAlert(a)
+			}
+		}
+	}
+
+	return nil
+}
+
+// evaluateAlertRule returns the value of the rule on the feedback as
+// it is after the change, and whether the rule fires.
+func evaluateAlertRule(r *models.AlertRule, c *models.Change, now time.Time) (float64, bool, error) {
+	f := c.Feedback
+	switch r.Kind {
+	case models.AlertRuleCreated:
+		return 1, c.Kind == models.ChangeKindCreate, nil
+	case models.AlertRuleNetScore:
+		score := float64(f.Upvotes) - float64(f.Downvotes)
+		if window, err := time.ParseDuration(r.Window); err == nil {
+			s, err := db.GetNetScoreSince(f.ID, now.Add(-window))
+			if err != nil {
+				return 0, false, err
+			}
+			score = float64(s)
+		}
+		return score, c.Kind == models.ChangeKindVote && score >= r.Threshold, nil
+	case models.AlertRuleUpvoteRatio:
+		votes := f.Upvotes + f.Downvotes
+		if votes == 0 || votes < r.MinVotes {
+			return 0, false, nil
+		}
+		ratio := float64(f.Upvotes) / float64(votes)
+		return ratio, c.Kind == models.ChangeKindVote && ratio > r.Threshold, nil
+	default:
+		return 0, false, nil
+	}
+}
@@ -0,0 +1,171 @@
+/**
+ * file: logic/alert_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// alertRecorder is a channel forwarding the alerts it is sent.
+type alertRecorder chan *models.Alert
+
+func (r alertRecorder) SendAlert(a *models.Alert) {
+	r <- a
+}
+
+// receiveAlert returns the next alert sent to the recorder, or nil
+// if none is sent shortly.
+func (r alertRecorder) receiveAlert() *models.Alert {
+	select {
+	case a := <-r:
+		return a
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+// TestEvaluateAlerts tests that rules fire once per feedback, on
+// the feedback they apply to, and are routed to their channels.
+func TestEvaluateAlerts(t *testing.T) {
+	setupTestDatabase(t)
+	recorder := make(alertRecorder, 16)
+	RegisterAlertChannel("test", recorder)
+
+	rules := []*models.AlertRule{
+		{Name: "score", Faculty: "linfo", Kind: models.AlertRuleNetScore, Threshold: 2, Window: "48h", Channels: []string{"test"}},
+		{Name: "ratio", Kind: models.AlertRuleUpvoteRatio, Threshold: 0.6, MinVotes: 3, Channels: []string{"test"}},
+		{Name: "new", Course: "LINFO1101", Kind: models.AlertRuleCreated, Channels: []string{"test"}},
+	}
+	for _, r := range rules {
+		_, err := CreateAlertRule(r)
+		require.NoError(t, err, "creating the rule %s should not fail", r.Name)
+	}
+
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	processChanges(t)
+	a := recorder.receiveAlert()
+	require.NotNil(t, a, "new feedback should fire an alert")
+	assert.Equal(t, rules[2].ID, a.RuleID)
+	assert.Equal(t, "LINFO1101", a.Feedback.Course)
+
+	other := newTestFeedback()
+	other.Course = "LEPL1102"
+	_, err = AddFeedback(other)
+	require.NoError(t, err, "adding feedback should not fail")
+
+	source := models.VoteSource{Token: "token"}
+	for _, id := range []uint{1, 2} {
+		_, err = UpdateFeedbackUpvotes(id, 1, source)
+		require.NoError(t, err, "voting should not fail")
+	}
+	processChanges(t)
+	assert.Nil(t, recorder.receiveAlert(), "no rule should fire below its threshold")
+
+	for _, id := range []uint{1, 2} {
+		_, err = UpdateFeedbackUpvotes(id, 1, source)
+		require.NoError(t, err, "voting should not fail")
+	}
+	processChanges(t)
+	a = recorder.receiveAlert()
+	require.NotNil(t, a, "the net score rule should fire")
+	assert.Equal(t, rules[0].ID, a.RuleID)
+	assert.Equal(t, uint(1), a.FeedbackID, "the rule should only fire on its faculty")
+	assert.Equal(t, float64(2), a.Value)
+	assert.Nil(t, recorder.receiveAlert(), "the ratio rule should wait for enough votes")
+
+	_, err = UpdateFeedbackDownvotes(1, 1, source)
+	require.NoError(t, err, "voting should not fail")
+	processChanges(t)
+	a = recorder.receiveAlert()
+	require.NotNil(t, a, "the ratio rule should fire")
+	assert.Equal(t, rules[1].ID, a.RuleID)
+	assert.InDelta(t, 2.0/3, a.Value, 1e-9)
+
+	_, err = UpdateFeedbackUpvotes(1, 1, source)
+	require.NoError(t, err, "voting should not fail")
+	processChanges(t)
+	assert.Nil(t, recorder.receiveAlert(), "rules should fire once per feedback")
+
+	fired, err := GetAlerts()
+	require.NoError(t, err, "getting the alerts should not fail")
+	assert.Len(t, fired, 3)
+}
+
+// TestEvaluateUpvoteRatio tests that upvote ratio rules fire above
+// their threshold, and not at it.
+func TestEvaluateUpvoteRatio(t *testing.T) {
+	r := &models.AlertRule{Kind: models.AlertRuleUpvoteRatio, Threshold: 0.8, MinVotes: 5}
+
+	c := &models.Change{Kind: models.ChangeKindVote, Feedback: &models.Feedback{Upvotes: 4, Downvotes: 1}}
+	value, fired, err := evaluateAlertRule(r, c, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0.8, value)
+	assert.False(t, fired, "the rule should not fire at its threshold")
+
+	c.Feedback.Upvotes = 5
+	_, fired, err = evaluateAlertRule(r, c, time.Now())
+	require.NoError(t, err)
+	assert.True(t, fired, "the rule should fire above its threshold")
+}
+
+// TestEvaluateTaggedAlerts tests that rules with a tag only fire on
+// the feedback tagged with it.
+func TestEvaluateTaggedAlerts(t *testing.T) {
+	setupTestDatabase(t)
+	recorder := make(alertRecorder, 16)
+	RegisterAlertChannel("test", recorder)
+
+	r, err := CreateAlertRule(&models.AlertRule{Name: "conflicts", Tag: "Schedule-Conflict", Kind: models.AlertRuleCreated, Channels: []string{"test"}})
+	require.NoError(t, err, "creating the rule should not fail")
+	assert.Equal(t, "schedule-conflict", r.Tag, "the tag should be normalized")
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	processChanges(t)
+	assert.Nil(t, recorder.receiveAlert(), "the rule should not fire on untagged feedback")
+
+	tagged := newTestFeedback()
+	tagged.Tags = models.Tags{"exam", "schedule-conflict"}
+	f, err := AddFeedback(tagged)
+	require.NoError(t, err, "adding feedback should not fail")
+	processChanges(t)
+	a := recorder.receiveAlert()
+	require.NotNil(t, a, "the rule should fire on tagged feedback")
+	assert.Equal(t, r.ID, a.RuleID)
+	assert.Equal(t, f.ID, a.FeedbackID)
+
+	_, err = CreateAlertRule(&models.AlertRule{Name: "tag", Tag: "schedule conflict", Kind: models.AlertRuleCreated, Channels: []string{"test"}})
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+// TestCreateAlertRule tests that rules with an invalid window,
+// ratio or channel are refused.
+func TestCreateAlertRule(t *testing.T) {
+	setupTestDatabase(t)
+	RegisterAlertChannel("test", make(alertRecorder))
+
+	invalid := []*models.AlertRule{
+		{Name: "window", Kind: models.AlertRuleNetScore, Window: "two days", Channels: []string{"test"}},
+		{Name: "ratio window", Kind: models.AlertRuleUpvoteRatio, Window: "48h", Channels: []string{"test"}},
+		{Name: "ratio", Kind: models.AlertRuleUpvoteRatio, Threshold: 80, Channels: []string{"test"}},
+		{Name: "channel", Kind: models.AlertRuleCreated, Channels: []string{"carrier-pigeon"}},
+	}
+	for _, r := range invalid {
+		_, err := CreateAlertRule(r)
+		assertStatus(t, http.StatusBadRequest, err)
+	}
+
+	err := DeleteAlertRule(1)
+	assertStatus(t, http.StatusNotFound, err)
+}
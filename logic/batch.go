@@ -0,0 +1,160 @@
+/**
+ * file: logic/batch.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the batches of operations,
+ * submitted at once by offline clients and tooling.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+)
+
+const (
+	maxBatchOperations int = 100
+)
+
+var (
+	errBatchFailed error = errors.New("batch operation failed")
+)
+
+// RunBatch runs the operations of the batch in order, through the
+// same logic as their own endpoints. Votes are cast from the given
+// source, and status transitions are only allowed to admins.
+// Atomic batches are run in a single transaction, which is rolled
+// back if any operation fails. Other batches apply each operation
+// on its own.
+func RunBatch(batch *models.Batch, source models.VoteSource, admin bool) (*models.BatchOutcome, error) {
+	if n := len(batch.Operations); n == 0 || n > maxBatchOperations {
+		uxe := uxerrors.New(fmt.Errorf("batch of %d operations", n))
+		uxe.Summary = "The batch size is invalid"
+		uxe.Detail = fmt.Sprintf("A batch should contain at least 1 and at most %d operations. Split your batch and try again.", maxBatchOperations)
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	outcome := &models.BatchOutcome{Results: make([]*models.BatchResult, len(batch.Operations))}
+	if !batch.Atomic {
+		for i, op := range batch.Operations {
+			outcome.Results[i] = runBatchOperation(db, i, op, source, admin)
+		}
+		outcome.Committed = true
+		publishChanges()
+		return outcome, nil
+	}
+
+	err := db.Transaction(func(tx *database.Database) error {
+		failed := false
+		for i, op := range batch.Operations {
+			// Each operation runs in its own save point, so that the
+			// following operations are still checked after a failure.
+			var r *models.BatchResult
+			err := tx.Transaction(func(item *database.Database) error {
+				r = runBatchOperation(item, i, op, source, admin)
+				return r.Error
+			})
+			if err != nil && r.Error == nil {
+				r = batchFailure(i, handleDatabaseError(err))
+			}
+
+			outcome.Results[i] = r
+			failed = failed || r.Error != nil
+		}
+
+		if failed {
+			return errBatchFailed
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		outcome.Committed = true
+		publishChanges()
+	case errors.Is(err, errBatchFailed):
+		for i, r := range outcome.Results {
+			if r.Error != nil {
+				continue
+			}
+
+			uxe := uxerrors.New(errBatchFailed)
+			uxe.Summary = "The operation was not applied"
+			uxe.Detail = "The operation succeeded, but was rolled back because another operation of the batch failed. Fix the failed operations and submit the batch again."
+			outcome.Results[i] = batchFailure(i, uxerrors.NewErrors(http.StatusFailedDependency).Append(uxe))
+		}
+	default:
+		return nil, handleDatabaseError(err)
+	}
+
+	return outcome, nil
+}
+
+// runBatchOperation runs a single operation of a batch using the
+// given database, which may be bound to a transaction.
+func runBatchOperation(tx *database.Database, i int, op models.BatchOperation, source models.VoteSource, admin bool) *models.BatchResult {
+	var f *models.Feedback
+	var err error
+	status := http.StatusOK
+
+	switch op.Op {
+	case models.BatchOperationCreate:
+		if op.Feedback == nil {
+			uxe := uxerrors.New(fmt.Errorf("missing feedback"))
+			uxe.Summary = "The feedback to create is missing"
+			uxe.Detail = "A create operation needs the feedback to create, in the Feedback field. Add it and try again."
+			return batchFailure(i, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe))
+		}
+		f, err = addFeedback(tx, op.Feedback)
+		if err == nil && f.Held {
+			status = http.StatusAccepted
+		}
+	case models.BatchOperationUpvote:
+		status = http.StatusCreated
+		f, err = castVote(tx, op.ID, models.VoteKindUpvote, op.Votes, source)
+	case models.BatchOperationDownvote:
+		status = http.StatusCreated
+		f, err = castVote(tx, op.ID, models.VoteKindDownvote, op.Votes, source)
+	case models.BatchOperationStatus:
+		if !admin {
+			uxe := uxerrors.New(fmt.Errorf("status transition without administration token"))
+			uxe.Summary = "You are not allowed to change the status of feedback"
+			uxe.Detail = "Changing the status of feedback is restricted to administrators. Provide a valid administration token and try again."
+			return batchFailure(i, uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe))
+		}
+
+		etags := []string{}
+		if op.IfMatch != "" {
+			etags = append(etags, op.IfMatch)
+		}
+		f, err = transitionFeedbackStatus(tx, op.ID, op.Status, etags)
+	default:
+		uxe := uxerrors.New(fmt.Errorf("unknown operation %q", op.Op))
+		uxe.Summary = "The operation is unknown"
+		uxe.Detail = fmt.Sprintf("The operation %q does not exist. Use one of create, upvote, downvote or status and try again.", op.Op)
+		return batchFailure(i, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe))
+	}
+
+	if err != nil {
+		return batchFailure(i, err)
+	}
+
+	return &models.BatchResult{Index: i, Status: status, Feedback: f}
+}
+
+// batchFailure returns the result of a failed operation of a batch.
+func batchFailure(i int, err error) *models.BatchResult {
+	status := http.StatusInternalServerError
+	if es, ok := err.(uxerrors.Errors); ok {
+		status = es.Status
+	}
+
+	return &models.BatchResult{Index: i, Status: status, Error: err}
+}
@@ -0,0 +1,115 @@
+/**
+ * file: logic/batch_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestDatabase sets the logic up with a new, migrated sqlite
+// database.
+func setupTestDatabase(t *testing.T) *database.Database {
+	d, err := database.NewDatabase("sqlite", t.TempDir()+"/test.db")
+	require.NoError(t, err, "could not create database")
+	_, err = d.MigrateUp()
+	require.NoError(t, err, "could not migrate database")
+
+	Setup(d)
+
+	// Feedback is published at once, unless the test sets another
+	// publication policy.
+	SetPublicationPolicy(PublicationPolicy{K: 1})
+	t.Cleanup(func() { SetPublicationPolicy(DefaultPublicationPolicy) })
+	return d
+}
+
+// processChanges processes the changes committed so far, as the
+// server does outside of the requests.
+func processChanges(t *testing.T) {
+	t.Helper()
+	require.NoError(t, ProcessChanges(), "processing the changes should not fail")
+}
+
+func newTestFeedback() *models.Feedback {
+	return &models.Feedback{Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us."}
+}
+
+// TestRunBatchBestEffort tests that operations of non atomic
+// batches are applied on their own.
+func TestRunBatchBestEffort(t *testing.T) {
+	setupTestDatabase(t)
+
+	batch := &models.Batch{Operations: []models.BatchOperation{
+		{Op: models.BatchOperationCreate, Feedback: newTestFeedback()},
+		{Op: models.BatchOperationUpvote, ID: 1, Votes: 1},
+		{Op: models.BatchOperationUpvote, ID: 42, Votes: 1},
+		{Op: models.BatchOperationDownvote, ID: 1, Votes: 2},
+	}}
+
+	outcome, err := RunBatch(batch, models.VoteSource{Token: "token"}, false)
+	require.NoError(t, err, "running a batch should not fail")
+	assert.True(t, outcome.Committed, "best effort batches should be committed")
+
+	statuses := []int{}
+	for _, r := range outcome.Results {
+		statuses = append(statuses, r.Status)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusCreated, http.StatusNotFound, http.StatusBadRequest}, statuses)
+
+	f, err := GetFeedback(1)
+	require.NoError(t, err, "the created feedback should be stored")
+	assert.Equal(t, uint64(1), f.Upvotes, "the successful vote should be applied")
+}
+
+// TestRunBatchAtomic tests that no operation of atomic batches is
+// applied if any fails.
+func TestRunBatchAtomic(t *testing.T) {
+	setupTestDatabase(t)
+
+	batch := &models.Batch{Atomic: true, Operations: []models.BatchOperation{
+		{Op: models.BatchOperationCreate, Feedback: newTestFeedback()},
+		{Op: models.BatchOperationUpvote, ID: 1, Votes: 1},
+		{Op: models.BatchOperationStatus, ID: 1, Status: models.FeedbackStatusAcknowledged},
+	}}
+
+	outcome, err := RunBatch(batch, models.VoteSource{}, false)
+	require.NoError(t, err, "running a batch should not fail")
+	assert.False(t, outcome.Committed, "failed atomic batches should not be committed")
+	assert.Equal(t, http.StatusFailedDependency, outcome.Results[0].Status, "successful operations should be reported as rolled back")
+	assert.Equal(t, http.StatusFailedDependency, outcome.Results[1].Status, "successful operations should be reported as rolled back")
+	assert.Equal(t, http.StatusUnauthorized, outcome.Results[2].Status, "status transitions should be restricted to admins")
+
+	fs, err := GetAllFeedback()
+	require.NoError(t, err, "listing feedback should not fail")
+	assert.Empty(t, fs, "no feedback should be created")
+
+	outcome, err = RunBatch(batch, models.VoteSource{}, true)
+	require.NoError(t, err, "running a batch should not fail")
+	assert.True(t, outcome.Committed, "successful atomic batches should be committed")
+
+	f, err := GetFeedback(1)
+	require.NoError(t, err, "the created feedback should be stored")
+	assert.Equal(t, models.FeedbackStatusAcknowledged, f.Status, "all operations should be applied")
+	assert.Equal(t, uint64(1), f.Upvotes, "all operations should be applied")
+}
+
+// TestRunBatchSize tests that empty and oversized batches are
+// rejected.
+func TestRunBatchSize(t *testing.T) {
+	_, err := RunBatch(&models.Batch{}, models.VoteSource{}, false)
+	assert.Error(t, err, "empty batches should be rejected")
+
+	batch := &models.Batch{Operations: make([]models.BatchOperation, maxBatchOperations+1)}
+	_, err = RunBatch(batch, models.VoteSource{}, false)
+	assert.Error(t, err, "oversized batches should be rejected")
+}
@@ -0,0 +1,75 @@
+/**
+ * file: logic/change.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the change feed, used by clients
+ * to synchronize incrementally.
+ */
+
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+)
+
+const (
+	// DefaultChangeLimit is the number of changes returned when the
+	// client does not ask for a specific number.
+	DefaultChangeLimit int = 100
+
+	maxChangeLimit int = 1000
+)
+
+// GetChanges returns at most limit changes made after the given
+// cursor, in order. A cursor of 0 returns all changes from the
+// start. Clients should request the next page with the returned
+// cursor.
+func GetChanges(since uint64, limit int) (*models.ChangeFeed, error) {
+	if limit < 1 || limit > maxChangeLimit {
+		uxe := uxerrors.New(fmt.Errorf("change limit %d out of bounds", limit))
+		uxe.Summary = "The number of changes is invalid"
+		uxe.Detail = fmt.Sprintf("You can request at least 1 and at most %d changes at once. Correct the limit and try again.", maxChangeLimit)
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	// One more change is requested, to know whether there are more.
+	changes, err := db.GetChangesSince(since, limit+1)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	feed := &models.ChangeFeed{Changes: changes, Cursor: since}
+	if len(changes) > limit {
+		feed.Changes = changes[:limit]
+		feed.More = true
+	}
+
+	for _, c := range feed.Changes {
+		if err := decodeSnapshot(c); err != nil {
+			return nil, err
+		}
+		feed.Cursor = c.Seq
+	}
+
+	return feed, nil
+}
+
+// decodeSnapshot sets the feedback of the change from its snapshot.
+// Tombstones are left without feedback.
+func decodeSnapshot(c *models.Change) error {
+	if len(c.Snapshot) == 0 {
+		return nil
+	}
+
+	c.Feedback = new(models.Feedback)
+	if err := json.Unmarshal(c.Snapshot, c.Feedback); err != nil {
+		return uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/**
+ * file: logic/change_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetChanges tests that every change is recorded in order, and
+// that deleted feedback is only served as a tombstone.
+func TestGetChanges(t *testing.T) {
+	setupTestDatabase(t)
+
+	f, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	_, err = UpdateFeedbackUpvotes(f.ID, 1, models.VoteSource{Token: "token"})
+	require.NoError(t, err, "voting should not fail")
+	_, err = TransitionFeedbackStatus(f.ID, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err, "changing the status should not fail")
+
+	feed, err := GetChanges(0, 2)
+	require.NoError(t, err, "getting changes should not fail")
+	require.Len(t, feed.Changes, 2, "the limit should be respected")
+	assert.True(t, feed.More, "more changes should be available")
+	assert.Equal(t, models.ChangeKindCreate, feed.Changes[0].Kind)
+	assert.Equal(t, models.ChangeKindVote, feed.Changes[1].Kind)
+	assert.Equal(t, uint64(1), feed.Changes[1].Feedback.Upvotes, "the feedback should be returned as it was after the change")
+	assert.Equal(t, feed.Changes[1].Seq, feed.Cursor, "the cursor should be the last change")
+
+	_, err = DeleteFeedback(f.ID, "duplicate", nil)
+	require.NoError(t, err, "deleting feedback should not fail")
+
+	feed, err = GetChanges(feed.Cursor, DefaultChangeLimit)
+	require.NoError(t, err, "getting changes should not fail")
+	require.Len(t, feed.Changes, 2, "only the new changes should be returned")
+	assert.False(t, feed.More, "no more changes should be available")
+	assert.Equal(t, models.ChangeKindStatus, feed.Changes[0].Kind)
+	assert.Nil(t, feed.Changes[0].Feedback, "deleted content should no longer be served")
+	assert.Equal(t, models.ChangeKindDelete, feed.Changes[1].Kind)
+	assert.Equal(t, uint64(4), feed.Changes[1].Version, "the tombstone should carry the version of the deletion")
+
+	last := feed.Cursor
+	feed, err = GetChanges(last, DefaultChangeLimit)
+	require.NoError(t, err, "getting changes should not fail")
+	assert.Empty(t, feed.Changes, "no change should be returned")
+	assert.Equal(t, last, feed.Cursor, "the cursor should not move")
+
+	_, err = GetChanges(0, 0)
+	assert.Error(t, err, "an invalid limit should be rejected")
+}
@@ -0,0 +1,193 @@
+/**
+ * file: logic/events.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the in-process event bus, relaying
+ * the change feed to live subscribers. Requests only
+ * signal the changes they commit; the changes are
+ * relayed and evaluated outside of requests.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+const (
+	subscriptionBuffer int = 64
+)
+
+// The ChangeSubscription structure receives the changes published
+// after it subscribed, on C.
+// Subscribers that do not keep up are dropped, and C is closed.
+// They should resume from the change feed, using the sequence
+// number of the last change they received.
+type ChangeSubscription struct {
+	C <-chan *models.Change
+
+	c      chan *models.Change
+	course string
+	tag    string
+}
+
+// Close stops the subscription and closes C, if it was not closed
+// already.
+func (s *ChangeSubscription) Close() {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.unsubscribe(s)
+}
+
+// Matches returns true if the change should be sent to the
+// subscriber. Tombstones carry no feedback, and are sent to all
+// subscribers.
+func (s *ChangeSubscription) Matches(c *models.Change) bool {
+	return models.MatchesCourse(s.course, c) && models.MatchesTag(s.tag, c)
+}
+
+// The eventBus structure relays changes to the subscriptions, in
+// the order of the change feed.
+type eventBus struct {
+	mu sync.Mutex
+
+	// relay serializes the relays, so that the changes read from the
+	// database are delivered in order. It is held while reading the
+	// database, mu is not.
+	relay sync.Mutex
+
+	// cursor is the sequence number of the last change published.
+	// It is only maintained while there are subscribers.
+	cursor        uint64
+	subscriptions map[*ChangeSubscription]bool
+}
+
+var (
+	events = &eventBus{subscriptions: map[*ChangeSubscription]bool{}}
+
+	// changesPublished signals that changes were committed. A
+	// single signal is kept for any number of changes.
+	changesPublished = make(chan struct{}, 1)
+)
+
+// SubscribeChanges subscribes to the changes made from now on, on
+// the given course and to the feedback with the given tag. An empty
+// course or tag subscribes to the changes on all courses or tags.
+// The subscription must be closed once done.
+func SubscribeChanges(course, tag string) (*ChangeSubscription, error) {
+	return subscribeChanges(course, tag, subscriptionBuffer)
+}
+
+func subscribeChanges(course, tag string, buffer int) (*ChangeSubscription, error) {
+	seq, err := db.GetLatestChangeSeq()
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	if len(events.subscriptions) == 0 {
+		events.cursor = seq
+	}
+
+	c := make(chan *models.Change, buffer)
+	s := &ChangeSubscription{C: c, c: c, course: course, tag: tag}
+	events.subscriptions[s] = true
+	return s, nil
+}
+
+// unsubscribe removes the subscription. The bus must be locked.
+func (b *eventBus) unsubscribe(s *ChangeSubscription) {
+	if b.subscriptions[s] {
+		delete(b.subscriptions, s)
+		close(s.c)
+	}
+}
+
+// publishChanges signals that changes were committed, for them to be
+// processed outside of the request, and invalidates the cached
+// statistics. It never blocks.
+func publishChanges() {
+	stats.invalidate()
+	select {
+	case changesPublished <- struct{}{}:
+	default:
+	}
+}
+
+// ChangesPublished returns the channel signaling that changes were
+// committed since ProcessChanges was last called.
+func ChangesPublished() <-chan struct{} {
+	return changesPublished
+}
+
+// ProcessChanges relays the changes committed since the last call to
+// the subscriptions, evaluates the alert rules on them, and notifies
+// the representatives of the new status requests. It should
+// be called by a single goroutine, on the signals of
+// ChangesPublished and periodically. Changes that cannot be processed
+// now are processed on the next call.
+func ProcessChanges() error {
+	return errors.Join(relayChanges(), evaluateAlerts(), notifyStatusRequests())
+}
+
+// relayChanges relays the changes made since the last publication to
+// the subscriptions. Changes that cannot be read now are relayed on
+// the next call. The changes are read without locking the bus, and
+// read again if the cursor moved meanwhile.
+func relayChanges() error {
+	events.relay.Lock()
+	defer events.relay.Unlock()
+
+	for {
+		events.mu.Lock()
+		cursor, idle := events.cursor, len(events.subscriptions) == 0
+		events.mu.Unlock()
+		if idle {
+			return nil
+		}
+
+		feed, err := GetChanges(cursor, maxChangeLimit)
+		if err != nil {
+			return fmt.Errorf("reading the changes after %d: %w", cursor, err)
+		}
+		if !events.deliver(cursor, feed) {
+			return nil
+		}
+	}
+}
+
+// deliver sends the changes read after the cursor to the matching
+// subscriptions, and returns true if more changes are to be read.
+// The changes are dropped if the cursor moved since they were read.
+func (b *eventBus) deliver(cursor uint64, feed *models.ChangeFeed) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cursor != cursor {
+		return len(b.subscriptions) != 0
+	}
+
+	for _, c := range feed.Changes {
+		for s := range b.subscriptions {
+			if !s.Matches(c) {
+				continue
+			}
+
+			select {
+			case s.c <- c:
+			default:
+				b.unsubscribe(s)
+			}
+		}
+	}
+
+	b.cursor = feed.Cursor
+	return feed.More && len(b.subscriptions) != 0
+}
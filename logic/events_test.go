@@ -0,0 +1,123 @@
+/**
+ * file: logic/events_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribeChanges tests that subscribers receive the changes
+// made after they subscribed, on their course or tag only.
+func TestSubscribeChanges(t *testing.T) {
+	setupTestDatabase(t)
+
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+
+	all, err := SubscribeChanges("", "")
+	require.NoError(t, err, "subscribing should not fail")
+	defer all.Close()
+	course, err := SubscribeChanges("linfo1101", "")
+	require.NoError(t, err, "subscribing should not fail")
+	defer course.Close()
+	tag, err := SubscribeChanges("", "schedule-conflict")
+	require.NoError(t, err, "subscribing should not fail")
+	defer tag.Close()
+
+	other := newTestFeedback()
+	other.Course = "LINFO1002"
+	other.Tags = models.Tags{" Schedule-Conflict", "exam"}
+	_, err = AddFeedback(other)
+	require.NoError(t, err, "adding feedback should not fail")
+	f, err := UpdateFeedbackUpvotes(1, 1, models.VoteSource{Token: "token"})
+	require.NoError(t, err, "voting should not fail")
+
+	processChanges(t)
+	c := <-all.C
+	assert.Equal(t, models.ChangeKindCreate, c.Kind, "changes made before subscribing should not be received")
+	assert.Equal(t, "LINFO1002", c.Feedback.Course)
+	c = <-all.C
+	assert.Equal(t, models.ChangeKindVote, c.Kind)
+
+	c = <-course.C
+	assert.Equal(t, models.ChangeKindVote, c.Kind, "changes on other courses should not be received")
+	assert.Equal(t, f.Version, c.Feedback.Version, "the feedback should be received as it was after the change")
+	assert.Equal(t, f.Upvotes, c.Feedback.Upvotes, "the feedback should be received as it was after the change")
+	assert.Empty(t, course.C, "no more changes should be received")
+
+	c = <-tag.C
+	assert.Equal(t, models.ChangeKindCreate, c.Kind)
+	assert.Equal(t, models.Tags{"schedule-conflict", "exam"}, c.Feedback.Tags, "tags should be normalized")
+	assert.Empty(t, tag.C, "changes on feedback without the tag should not be received")
+}
+
+// TestSubscribeChangesLagged tests that subscribers that do not keep
+// up are dropped, without blocking the others.
+func TestSubscribeChangesLagged(t *testing.T) {
+	setupTestDatabase(t)
+
+	slow, err := subscribeChanges("", "", 1)
+	require.NoError(t, err, "subscribing should not fail")
+	defer slow.Close()
+	fast, err := SubscribeChanges("", "")
+	require.NoError(t, err, "subscribing should not fail")
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err, "adding feedback should not fail")
+	}
+	processChanges(t)
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	assert.Equal(t, 1, received, "the slow subscriber should be dropped once its buffer is full")
+	assert.Len(t, fast.C, 3, "other subscribers should receive all changes")
+}
+
+// TestRelayChangesUnlocked tests that the bus is not locked while the
+// changes are read, and that changes read before the cursor moved
+// are not delivered.
+func TestRelayChangesUnlocked(t *testing.T) {
+	setupTestDatabase(t)
+
+	s, err := SubscribeChanges("", "")
+	require.NoError(t, err, "subscribing should not fail")
+	defer s.Close()
+
+	events.relay.Lock()
+	subscribed := make(chan error)
+	go func() {
+		other, err := SubscribeChanges("", "")
+		if err == nil {
+			other.Close()
+		}
+		subscribed <- err
+	}()
+	require.NoError(t, <-subscribed, "subscribing should not wait for the relay")
+	events.relay.Unlock()
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	processChanges(t)
+	c := <-s.C
+
+	events.mu.Lock()
+	cursor := events.cursor
+	events.mu.Unlock()
+	assert.Equal(t, c.Seq, cursor)
+
+	stale := &models.ChangeFeed{Changes: []*models.Change{c}, Cursor: c.Seq}
+	events.deliver(cursor-1, stale)
+	assert.Empty(t, s.C, "changes read before the cursor moved should not be delivered")
+}
@@ -12,13 +12,20 @@ package logic
 import (
 	"fmt"
 	"net/http"
+	"time"
+	"unicode/utf8"
 
 	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/export"
 	"git.licolas.net/delegit/delegit/models"
 	"git.licolas.net/delegit/delegit/uxerrors"
 	"git.licolas.net/delegit/delegit/validators"
 )
 
+const (
+	maxDeleteReasonLength int = 500
+)
+
 var (
 	db *database.Database
 )
@@ -27,6 +34,12 @@ func sanitizeFeedback(f *models.Feedback) {
 	f.ID = 0
 	f.Upvotes = 0
 	f.Downvotes = 0
+	f.Version = 1
+	f.Status = models.FeedbackStatusNew
+	f.Held = false
+	f.Response = ""
+	f.RespondedAt = nil
+	f.Tags = f.Tags.Normalize()
 }
 
 func GetAllFeedback() ([]*models.Feedback, error) {
@@ -38,6 +51,34 @@ func GetAllFeedback() ([]*models.Feedback, error) {
 	return fs, nil
 }
 
+// GetFilteredFeedback returns the feedback selected by the filter.
+func GetFilteredFeedback(filter *models.FeedbackFilter) ([]*models.Feedback, error) {
+	if err := validators.ValidateFeedbackFilter(filter); err != nil {
+		return nil, err
+	}
+
+	fs, err := db.GetFilteredFeedback(filter)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return fs, nil
+}
+
+// ExportFeedback writes the feedback selected by the filter, as it is
+// read from the database. Nothing is written if the filter is
+// invalid.
+func ExportFeedback(filter *models.FeedbackFilter, w export.Writer) error {
+	if err := validators.ValidateFeedbackFilter(filter); err != nil {
+		return err
+	}
+
+	if err := db.EachFeedback(filter, w.Write); err != nil {
+		return handleDatabaseError(err)
+	}
+	return w.Close()
+}
+
 func GetFeedback(id uint) (*models.Feedback, error) {
 	f, err := db.GetFeedback(id)
 	if err != nil {
@@ -48,79 +89,187 @@ func GetFeedback(id uint) (*models.Feedback, error) {
 }
 
 func AddFeedback(f *models.Feedback) (*models.Feedback, error) {
+	r, err := addFeedback(db, f)
+	if err == nil {
+		publishChanges()
+	}
+
+	return r, err
+}
+
+// addFeedback adds the feedback using the given database, which may
+// be bound to a transaction. The feedback is held until it is
+// published, following the publication policy.
+func addFeedback(tx *database.Database, f *models.Feedback) (*models.Feedback, error) {
 	sanitizeFeedback(f)
 
 	if err := validators.ValidateFeedback(f); err != nil {
 		return nil, err
 	}
 
-	r, err := db.AddFeedback(f)
+	return holdFeedback(tx, f, time.Now())
+}
+
+// GetFeedbackListVersion returns the current version of the list
+// of feedback, used to answer conditional requests on the list.
+func GetFeedbackListVersion() (*models.FeedbackListVersion, error) {
+	v, err := db.GetFeedbackListVersion()
 	if err != nil {
 		return nil, handleDatabaseError(err)
 	}
 
-	return r, nil
+	return v, nil
 }
 
-func UpdateFeedback(f *models.Feedback) (*models.Feedback, error) {
+// UpdateFeedback updates the content of the feedback identified by
+// id. The update only happens if the feedback matches one of the
+// etags, if any are given.
+func UpdateFeedback(id uint, f *models.Feedback, etags []string) (*models.Feedback, error) {
+	f.ID = id
+	f.Tags = f.Tags.Normalize()
 	if err := validators.ValidateFeedback(f); err != nil {
 		return nil, err
 	}
 
-	r, err := db.UpdateFeedback(f)
+	r, err := db.UpdateFeedback(f, ifMatch(etags))
 	if err != nil {
 		return nil, handleDatabaseError(err)
 	}
 
+	publishChanges()
 	return r, nil
 }
 
-func DeleteFeedback(f *models.Feedback) (*models.Feedback, error) {
-	if err := validators.ValidateFeedback(f); err != nil {
-		return nil, err
+// ifMatch returns a precondition on the feedback, failing unless
+// the feedback matches one of the entity tags given in an If-Match
+// header. An empty list of entity tags always passes.
+func ifMatch(etags []string) func(*models.Feedback) error {
+	return func(f *models.Feedback) error {
+		if len(etags) == 0 {
+			return nil
+		}
+
+		if f != nil {
+			for _, etag := range etags {
+				if etag == "*" || etag == f.ETag() {
+					return nil
+				}
+			}
+		}
+
+		uxe := uxerrors.New(fmt.Errorf("precondition failed"))
+		uxe.Summary = "The feedback was changed in the meantime"
+		uxe.Detail = "The feedback does not match the version you based your request on. It was changed or removed since you last fetched it. Refresh the feedback and try again."
+		return uxerrors.NewErrors(http.StatusPreconditionFailed).Append(uxe)
 	}
+}
 
-	err := db.DeleteFeedback(f)
-	f.ID = 0
-	return f, handleDatabaseError(err)
-}
-
-func UpdateFeedbackUpvotes(id uint, votes int) (*models.Feedback, error) {
-	var feedback *models.Feedback
-	var err error
-	switch votes {
-	case 1:
-		feedback, err = db.IncrementFeedbackUpvotes(id)
-	case -1:
-		feedback, err = db.DecrementFeedbackUpvotes(id)
-	default:
-		uxe := uxerrors.New(fmt.Errorf("unknown increment"))
-		uxe.Summary = "The increment you are attempting to do is invalid"
-		uxe.Detail = fmt.Sprintf("You are trying to increment feedback upvotes by %d, but only 1 or -1 is allowed. Correct the values and try again.", votes)
+// DeleteFeedback soft deletes the feedback, keeping a tombstone
+// with the reason of the deletion. The deletion only happens if the
+// feedback matches one of the etags, if any are given.
+func DeleteFeedback(id uint, reason string, etags []string) (*models.FeedbackDeletion, error) {
+	if utf8.RuneCountInString(reason) > maxDeleteReasonLength {
+		uxe := uxerrors.New(fmt.Errorf("delete reason too long"))
+		uxe.Summary = "The reason is too long"
+		uxe.Detail = fmt.Sprintf("The reason of the deletion should be at most %d long. Shorten and try again.", maxDeleteReasonLength)
 		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
 	}
 
-	return feedback, handleDatabaseError(err)
+	deleted, err := db.DeleteFeedback(id, reason, ifMatch(etags))
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	publishChanges()
+
+	return &models.FeedbackDeletion{ID: id, Deleted: deleted}, nil
 }
 
-func UpdateFeedbackDownvotes(id uint, votes int) (*models.Feedback, error) {
-	var feedback *models.Feedback
-	var err error
-	switch votes {
-	case 1:
-		feedback, err = db.IncrementFeedbackDownvotes(id)
-	case -1:
-		feedback, err = db.DecrementFeedbackDownvotes(id)
-	default:
+// PurgeFeedback permanently removes the feedback, deleted or not,
+// along with its votes. The removal only happens if the feedback
+// matches one of the etags, if any are given.
+func PurgeFeedback(id uint, etags []string) (*models.FeedbackDeletion, error) {
+	purged, err := db.PurgeFeedback(id, ifMatch(etags))
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	publishChanges()
+
+	return &models.FeedbackDeletion{ID: id, Deleted: purged, Purged: purged}, nil
+}
+
+// castVote applies the vote of the given kind on the feedback, using
+// the given database, which may be bound to a transaction.
+func castVote(tx *database.Database, id uint, kind models.VoteKind, votes int, source models.VoteSource) (*models.Feedback, error) {
+	v, err := newVote(id, kind, votes, source)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyVote(tx, v)
+}
+
+// newVote returns the vote of the given kind on the feedback, from
+// the given source. Only votes of 1, or retractions of -1 are
+// allowed.
+func newVote(id uint, kind models.VoteKind, votes int, source models.VoteSource) (*models.Vote, error) {
+	if kind != models.VoteKindUpvote && kind != models.VoteKindDownvote {
+		uxe := uxerrors.New(fmt.Errorf("unknown vote kind %q", kind))
+		uxe.Summary = "The kind of vote is unknown"
+		uxe.Detail = fmt.Sprintf("The kind of vote %q does not exist. Use either upvote or downvote and try again.", kind)
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	if votes != 1 && votes != -1 {
 		uxe := uxerrors.New(fmt.Errorf("unknown increment"))
 		uxe.Summary = "The increment you are attempting to do is invalid"
-		uxe.Detail = fmt.Sprintf("You are trying to increment feedback downvotes by %d, but only 1 or -1 is allowed. Correct the values and try again.", votes)
+		uxe.Detail = fmt.Sprintf("You are trying to increment feedback %ss by %d, but only 1 or -1 is allowed. Correct the values and try again.", kind, votes)
 		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
 	}
 
-	return feedback, handleDatabaseError(err)
+	token, family := voteTokenHashes(source.Token)
+	return &models.Vote{
+		FeedbackID:  id,
+		Kind:        kind,
+		Delta:       votes,
+		Token:       token,
+		TokenFamily: family,
+		Address:     source.Address,
+	}, nil
+}
+
+// applyVote records the vote and applies it to its feedback, using
+// the given database, which may be bound to a transaction.
+func applyVote(tx *database.Database, v *models.Vote) (*models.Feedback, error) {
+	feedback, err := tx.CastVote(v)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return feedback, nil
+}
+
+func UpdateFeedbackUpvotes(id uint, votes int, source models.VoteSource) (*models.Feedback, error) {
+	f, err := castVote(db, id, models.VoteKindUpvote, votes, source)
+	if err == nil {
+		publishChanges()
+	}
+
+	return f, err
+}
+
+func UpdateFeedbackDownvotes(id uint, votes int, source models.VoteSource) (*models.Feedback, error) {
+	f, err := castVote(db, id, models.VoteKindDownvote, votes, source)
+	if err == nil {
+		publishChanges()
+	}
+
+	return f, err
 }
 
 func Setup(database *database.Database) {
 	db = database
+	setupAlerts()
+	stats = &statsCache{}
 }
@@ -0,0 +1,52 @@
+/**
+ * file: logic/feedback_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/export"
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExportFeedback tests that the feedback selected by the filter
+// is exported, and that nothing is written for invalid filters.
+func TestExportFeedback(t *testing.T) {
+	setupTestDatabase(t)
+
+	for _, course := range []string{"LINFO1101", "LEPL1102", "LINFO1102"} {
+		f := newTestFeedback()
+		f.Course = course
+		_, err := AddFeedback(f)
+		require.NoError(t, err)
+	}
+
+	var b bytes.Buffer
+	w, err := export.NewWriter(export.FormatNDJSON, &b, "en")
+	require.NoError(t, err)
+	require.NoError(t, ExportFeedback(&models.FeedbackFilter{Faculty: "linfo"}, w), "exporting should not fail")
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"Course":"LINFO1102"`)
+
+	fs, err := GetFilteredFeedback(&models.FeedbackFilter{Course: "lepl1102"})
+	require.NoError(t, err)
+	require.Len(t, fs, 1)
+	assert.Equal(t, uint(2), fs[0].ID)
+
+	b.Reset()
+	w, err = export.NewWriter(export.FormatCSV, &b, "en")
+	require.NoError(t, err)
+	err = ExportFeedback(&models.FeedbackFilter{Status: "closed"}, w)
+	assertStatus(t, http.StatusBadRequest, err)
+	assert.Zero(t, b.Len(), "nothing should be written for invalid filters")
+}
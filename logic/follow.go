@@ -0,0 +1,296 @@
+/**
+ * file: logic/follow.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the follows of the anonymous
+ * voters. Students follow feedback, courses or
+ * faculties with their voter token, and are notified
+ * of new feedback and changes of status, through Web
+ * Push or their inbox. Only a keyed hash of the token
+ * is stored, distinct from the one of the votes, so that
+ * follows are never linked to votes.
+ */
+
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+const (
+	followNotificationLimit int = 100
+	minVoterTokenLength     int = 16
+	maxVoterTokenLength     int = 64
+)
+
+var (
+	pusher      *notify.WebPush
+	pushFailure func(error)
+)
+
+// SetWebPush sets the sender of the push messages to the voters.
+// Failures to deliver them are reported to onError, if set. Nothing
+// is pushed until it is set.
+func SetWebPush(w *notify.WebPush, onError func(error)) {
+	pusher, pushFailure = w, onError
+}
+
+// voterHash returns the hash identifying the voter with the token.
+func voterHash(token string) ([]byte, error) {
+	if len(token) < minVoterTokenLength || len(token) > maxVoterTokenLength {
+		uxe := uxerrors.New(fmt.Errorf("missing or invalid voter token"))
+		uxe.Summary = "A voter token is required"
+		uxe.Detail = fmt.Sprintf("Following feedback requires your voter token, of %d to %d characters, in the X-Voter-Token header. Provide it and try again.", minVoterTokenLength, maxVoterTokenLength)
+		return nil, uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe)
+	}
+
+	return keyedVoterHash(voterHashFollow, token), nil
+}
+
+// followNotFound returns the error for an unknown follow, or err
+// itself for other errors.
+func followNotFound(err error) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return handleDatabaseError(err)
+	}
+
+	uxe := uxerrors.New(err)
+	uxe.Summary = "The follow was not found"
+	uxe.Detail = "You do not follow this. Check the identifier and try again."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// pushDisabled returns the error for push requests while push is
+// not configured.
+func pushDisabled() error {
+	uxe := uxerrors.New(fmt.Errorf("web push is not configured"))
+	uxe.Summary = "Push notifications are not available"
+	uxe.Detail = "This server does not send push notifications. Check your inbox for notifications instead."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// CreateFollow validates and adds the follow of the voter. It
+// returns the follow, and false if the voter already had it.
+func CreateFollow(token string, f *models.Follow) (*models.Follow, bool, error) {
+	hash, err := voterHash(token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.ID = 0
+	f.Course = strings.ToUpper(f.Course)
+	f.Faculty = strings.ToUpper(f.Faculty)
+	f.Tag = strings.ToLower(strings.TrimSpace(f.Tag))
+	if err := validators.ValidateFollow(f); err != nil {
+		return nil, false, err
+	}
+	if f.FeedbackID != 0 {
+		if _, err := db.GetFeedback(f.FeedbackID); err != nil {
+			return nil, false, handleDatabaseError(err)
+		}
+	}
+
+	f.TokenHash = hash
+	f, added, err := db.AddFollow(f)
+	if err != nil {
+		return nil, false, handleDatabaseError(err)
+	}
+	return f, added, nil
+}
+
+func GetFollows(token string) ([]*models.Follow, error) {
+	hash, err := voterHash(token)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := db.GetFollows(hash)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return f, nil
+}
+
+func DeleteFollow(token string, id uint) error {
+	hash, err := voterHash(token)
+	if err != nil {
+		return err
+	}
+
+	if err := db.DeleteFollow(hash, id); err != nil {
+		return followNotFound(err)
+	}
+	return nil
+}
+
+// GetFollowNotifications returns the notifications of the voter
+// after the notification since, oldest first. Clients pass the ID of
+// the last notification they got to get the next ones.
+func GetFollowNotifications(token string, since uint) ([]*models.FollowNotification, error) {
+	hash, err := voterHash(token)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := db.GetFollowNotifications(hash, since, followNotificationLimit)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return n, nil
+}
+
+// GetPushKey returns the public key browsers subscribe to push
+// messages with.
+func GetPushKey() (string, error) {
+	if pusher == nil {
+		return "", pushDisabled()
+	}
+	return pusher.PublicKey(), nil
+}
+
+// pushHostError returns the error for push endpoints outside of the
+// known push services.
+func pushHostError(endpoint string) error {
+	uxe := uxerrors.New(fmt.Errorf("push endpoint %q not on a known push service", endpoint))
+	uxe.Summary = "The push service is not supported"
+	uxe.Detail = "Push messages are only sent to the push services of the major browsers. Subscribe with a supported browser, or check your inbox for notifications instead."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// SubscribePush validates and adds the push subscription of the
+// browser of the voter. Its endpoint must be on one of the push
+// services messages are sent to.
+func SubscribePush(token string, s *models.PushSubscription) error {
+	hash, err := voterHash(token)
+	if err != nil {
+		return err
+	}
+	if pusher == nil {
+		return pushDisabled()
+	}
+
+	s.ID = 0
+	s.Keys.P256dh = strings.TrimRight(s.Keys.P256dh, "=")
+	s.Keys.Auth = strings.TrimRight(s.Keys.Auth, "=")
+	if err := validators.ValidatePushSubscription(s); err != nil {
+		return err
+	}
+	if !pusher.Allows(s.Endpoint) {
+		return pushHostError(s.Endpoint)
+	}
+
+	s.TokenHash = hash
+	return handleDatabaseError(db.AddPushSubscription(s))
+}
+
+// UnsubscribePush deletes the push subscription of the voter with
+// the given endpoint.
+func UnsubscribePush(token string, endpoint string) error {
+	hash, err := voterHash(token)
+	if err != nil {
+		return err
+	}
+
+	err = db.DeletePushSubscription(hash, endpoint)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The push subscription was not found"
+		uxe.Detail = "This browser is not subscribed to push messages. Subscribe it first."
+		return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+	}
+	return handleDatabaseError(err)
+}
+
+// notifyFollowers notifies the voters following the feedback of the
+// change, if it is of interest to them: new feedback, and changes of
+// status. Voters with push subscriptions are also pushed the
+// notification.
+func notifyFollowers(c *models.Change) error {
+	if c.Feedback == nil {
+		return nil
+	}
+
+	var kind models.NotificationKind
+	var detail string
+	switch c.Kind {
+	case models.ChangeKindCreate:
+		kind = models.NotificationFeedback
+	case models.ChangeKindStatus:
+		kind, detail = models.NotificationStatus, string(c.Feedback.Status)
+	default:
+		return nil
+	}
+
+	follows, err := db.GetFollowsOf(c.Feedback)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range follows {
+		n := &models.FollowNotification{
+			TokenHash:  f.TokenHash,
+			Kind:       kind,
+			Ref:        c.Seq,
+			FeedbackID: c.Feedback.ID,
+			Course:     c.Feedback.Course,
+			Detail:     detail,
+		}
+
+		added, err := db.AddFollowNotification(n)
+		if err != nil {
+			return err
+		}
+		if added && pusher != nil {
+			go pushNotification(pusher, pushFailure, n)
+		}
+	}
+
+	return nil
+}
+
+// pushNotification pushes the notification to the browsers of the
+// voter. Subscriptions the push service reports as gone, and those
+// outside of the push services, are deleted.
+func pushNotification(w *notify.WebPush, onError func(error), n *models.FollowNotification) {
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+
+	subscriptions, err := db.GetPushSubscriptions(n.TokenHash)
+	if err != nil {
+		report(err)
+		return
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		report(err)
+		return
+	}
+
+	for _, s := range subscriptions {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		err := w.Send(ctx, s, payload)
+		cancel()
+
+		if errors.Is(err, notify.ErrPushGone) || errors.Is(err, notify.ErrPushHost) {
+			err = db.DeletePushSubscription(s.TokenHash, s.Endpoint)
+		}
+		if err != nil {
+			report(err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/**
+ * file: logic/follow_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
+	"git.licolas.net/delegit/delegit/notify/pushtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testVoter      string = "0123456789abcdef-voter"
+	testOtherVoter string = "0123456789abcdef-other"
+)
+
+func setupTestPush(t *testing.T) (*pushtest.Server, chan error) {
+	service := pushtest.NewServer()
+	t.Cleanup(service.Close)
+
+	key, err := notify.GenerateVAPIDKey()
+	require.NoError(t, err)
+	w, err := notify.NewWebPush(key, "mailto:admin@example.org")
+	require.NoError(t, err)
+	w.Client = service.Client()
+	w.Hosts = []string{service.Host()}
+
+	failures := make(chan error, 16)
+	SetWebPush(w, func(err error) { failures <- err })
+	t.Cleanup(func() { SetWebPush(nil, nil) })
+	return service, failures
+}
+
+// TestCreateFollow tests that voters need a token and a single
+// target to follow, and that follows are per voter.
+func TestCreateFollow(t *testing.T) {
+	setupTestDatabase(t)
+
+	_, _, err := CreateFollow("short", &models.Follow{Course: "LINFO1101"})
+	assertStatus(t, http.StatusUnauthorized, err)
+	_, _, err = CreateFollow(testVoter, &models.Follow{Course: "LINFO1101", Faculty: "LINFO"})
+	assertStatus(t, http.StatusBadRequest, err)
+	_, _, err = CreateFollow(testVoter, &models.Follow{FeedbackID: 42})
+	assertStatus(t, http.StatusNotFound, err)
+
+	f, added, err := CreateFollow(testVoter, &models.Follow{Course: "linfo1101"})
+	require.NoError(t, err, "following a course should not fail")
+	assert.True(t, added)
+	assert.Equal(t, "LINFO1101", f.Course)
+
+	again, added, err := CreateFollow(testVoter, &models.Follow{Course: "LINFO1101"})
+	require.NoError(t, err, "following a course again should not fail")
+	assert.False(t, added, "the follow should not be added twice")
+	assert.Equal(t, f.ID, again.ID)
+
+	follows, err := GetFollows(testOtherVoter)
+	require.NoError(t, err)
+	assert.Empty(t, follows, "follows should be per voter")
+	assertStatus(t, http.StatusNotFound, DeleteFollow(testOtherVoter, f.ID))
+
+	require.NoError(t, DeleteFollow(testVoter, f.ID), "unfollowing should not fail")
+	follows, err = GetFollows(testVoter)
+	require.NoError(t, err)
+	assert.Empty(t, follows)
+}
+
+// TestNotifyFollowers tests that voters are notified once of new
+// feedback and changes of status on what they follow, in their inbox
+// and through push.
+func TestNotifyFollowers(t *testing.T) {
+	setupTestDatabase(t)
+	service, failures := setupTestPush(t)
+
+	_, _, err := CreateFollow(testVoter, &models.Follow{Faculty: "LINFO"})
+	require.NoError(t, err)
+	_, _, err = CreateFollow(testVoter, &models.Follow{Course: "LINFO1101"})
+	require.NoError(t, err)
+	browser := service.Subscribe()
+	require.NoError(t, SubscribePush(testVoter, browser), "subscribing to push should not fail")
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+	processChanges(t)
+	m := service.Receive(time.Second)
+	require.NotNil(t, m, "new feedback should be pushed")
+
+	var pushed models.FollowNotification
+	require.NoError(t, json.Unmarshal(m.Payload, &pushed))
+	assert.Equal(t, models.NotificationFeedback, pushed.Kind)
+	assert.Equal(t, uint(1), pushed.FeedbackID)
+	assert.Nil(t, service.Receive(100*time.Millisecond), "overlapping follows should notify once")
+
+	_, _, err = CreateFollow(testOtherVoter, &models.Follow{FeedbackID: 1})
+	require.NoError(t, err)
+	_, err = UpdateFeedbackUpvotes(1, 1, models.VoteSource{Token: testOtherVoter})
+	require.NoError(t, err)
+	_, err = TransitionFeedbackStatus(1, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err)
+
+	processChanges(t)
+	m = service.Receive(time.Second)
+	require.NotNil(t, m, "the change of status should be pushed")
+	require.NoError(t, json.Unmarshal(m.Payload, &pushed))
+	assert.Equal(t, models.NotificationStatus, pushed.Kind)
+	assert.Equal(t, string(models.FeedbackStatusAcknowledged), pushed.Detail)
+
+	n, err := GetFollowNotifications(testVoter, 0)
+	require.NoError(t, err)
+	require.Len(t, n, 2, "votes should not be notified")
+	n, err = GetFollowNotifications(testVoter, n[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, n, 1, "only the notifications after the cursor should be returned")
+
+	n, err = GetFollowNotifications(testOtherVoter, 0)
+	require.NoError(t, err)
+	require.Len(t, n, 1, "the follower of the feedback should be notified")
+	assert.Equal(t, models.NotificationStatus, n[0].Kind)
+
+	service.Revoke(browser.Endpoint)
+	_, err = TransitionFeedbackStatus(1, models.FeedbackStatusInProgress, nil)
+	require.NoError(t, err)
+	processChanges(t)
+	hash, _ := voterHash(testVoter)
+	require.Eventually(t, func() bool {
+		s, err := db.GetPushSubscriptions(hash)
+		return err == nil && len(s) == 0
+	}, time.Second, 10*time.Millisecond, "revoked subscriptions should be deleted")
+	select {
+	case err := <-failures:
+		t.Fatalf("pushing should not fail: %v", err)
+	default:
+	}
+}
+
+// TestNotifyTagFollowers tests that voters following a tag are
+// notified of the feedback tagged with it only.
+func TestNotifyTagFollowers(t *testing.T) {
+	setupTestDatabase(t)
+
+	f, _, err := CreateFollow(testVoter, &models.Follow{Tag: " Schedule-Conflict"})
+	require.NoError(t, err, "following a tag should not fail")
+	assert.Equal(t, "schedule-conflict", f.Tag)
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+	tagged := newTestFeedback()
+	tagged.Tags = models.Tags{"exam", "schedule-conflict"}
+	tagged, err = AddFeedback(tagged)
+	require.NoError(t, err)
+
+	processChanges(t)
+	n, err := GetFollowNotifications(testVoter, 0)
+	require.NoError(t, err)
+	require.Len(t, n, 1, "only the feedback with the tag should be notified")
+	assert.Equal(t, tagged.ID, n[0].FeedbackID)
+}
+
+// TestNotifyCourseFollowers tests that voters following a course are
+// notified of the feedback filed with the course in any case.
+func TestNotifyCourseFollowers(t *testing.T) {
+	setupTestDatabase(t)
+
+	f, _, err := CreateFollow(testVoter, &models.Follow{Course: "LINFO1101"})
+	require.NoError(t, err)
+	assert.Equal(t, "LINFO1101", f.Course)
+
+	lower := newTestFeedback()
+	lower.Course = "linfo1101"
+	lower, err = AddFeedback(lower)
+	require.NoError(t, err)
+
+	processChanges(t)
+	n, err := GetFollowNotifications(testVoter, 0)
+	require.NoError(t, err)
+	require.Len(t, n, 1, "feedback filed in lowercase should be notified")
+	assert.Equal(t, lower.ID, n[0].FeedbackID)
+}
+
+// TestSubscribePush tests that push subscriptions are validated,
+// restricted to the push services, and refused while push is not
+// configured.
+func TestSubscribePush(t *testing.T) {
+	setupTestDatabase(t)
+
+	_, err := GetPushKey()
+	assertStatus(t, http.StatusNotFound, err)
+	service, _ := setupTestPush(t)
+	key, err := GetPushKey()
+	require.NoError(t, err)
+	assert.NotEmpty(t, key)
+
+	browser := service.Subscribe()
+	browser.Keys.Auth += "=="
+	require.NoError(t, SubscribePush(testVoter, browser), "padded keys should be accepted")
+	require.NoError(t, SubscribePush(testVoter, browser), "the voter should be able to subscribe again")
+	assertStatus(t, http.StatusConflict, SubscribePush(testOtherVoter, browser))
+	hash, _ := voterHash(testVoter)
+	s, err := db.GetPushSubscriptions(hash)
+	require.NoError(t, err)
+	assert.Len(t, s, 1, "the subscription should stay with its voter")
+
+	plain := service.Subscribe()
+	plain.Endpoint = strings.Replace(plain.Endpoint, "https://", "http://", 1)
+	assertStatus(t, http.StatusBadRequest, SubscribePush(testVoter, plain))
+	internal := service.Subscribe()
+	internal.Endpoint = "https://169.254.169.254/latest/meta-data/"
+	assertStatus(t, http.StatusBadRequest, SubscribePush(testVoter, internal))
+
+	assertStatus(t, http.StatusNotFound, UnsubscribePush(testOtherVoter, browser.Endpoint))
+	require.NoError(t, UnsubscribePush(testVoter, browser.Endpoint))
+}
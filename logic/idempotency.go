@@ -0,0 +1,116 @@
+/**
+ * file: logic/idempotency.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the handling of idempotency keys,
+ * used to safely retry requests.
+ */
+
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+)
+
+const (
+	// DefaultIdempotencyWindow is how long responses are kept for
+	// replay, unless changed by SetIdempotencyWindow.
+	DefaultIdempotencyWindow time.Duration = 24 * time.Hour
+
+	maxIdempotencyKeyLength int = 255
+)
+
+var (
+	idempotencyWindow = DefaultIdempotencyWindow
+)
+
+// SetIdempotencyWindow sets how long responses to requests made
+// with an idempotency key are kept for replay.
+func SetIdempotencyWindow(window time.Duration) {
+	idempotencyWindow = window
+}
+
+// BeginIdempotentRequest claims the idempotency key for the request
+// with the given body, on the given scope, by the caller identified
+// by the given credentials. Only a keyed hash of the credentials is
+// stored.
+// If the key was already used for the same request, the stored key
+// is returned along with true, and its response should be replayed.
+// Otherwise, the claimed key is returned along with false, and must
+// be completed or released once the request is processed.
+func BeginIdempotentRequest(key, scope, caller string, body []byte) (*models.IdempotencyKey, bool, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		uxe := uxerrors.New(fmt.Errorf("idempotency key too long"))
+		uxe.Summary = "The idempotency key is too long"
+		uxe.Detail = fmt.Sprintf("The Idempotency-Key header should be at most %d long. Use a shorter key, such as a UUID, and try again.", maxIdempotencyKeyLength)
+		return nil, false, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	hash := sha256.Sum256(body)
+	now := time.Now()
+	claim := &models.IdempotencyKey{
+		Key:         key,
+		Scope:       scope,
+		Caller:      hex.EncodeToString(keyedVoterHash(voterHashIdempotency, caller)),
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(idempotencyWindow),
+	}
+
+	k, claimed, err := db.ClaimIdempotencyKey(claim)
+	if err != nil {
+		return nil, false, handleDatabaseError(err)
+	}
+	if claimed {
+		return k, false, nil
+	}
+
+	if k.RequestHash != claim.RequestHash {
+		uxe := uxerrors.New(fmt.Errorf("idempotency key reused with a different request"))
+		uxe.Summary = "The idempotency key was already used for another request"
+		uxe.Detail = "The Idempotency-Key header you sent was already used with a different request body. Use a new key for every new request and try again."
+		return nil, false, uxerrors.NewErrors(http.StatusUnprocessableEntity).Append(uxe)
+	}
+
+	if k.Pending() {
+		uxe := uxerrors.New(fmt.Errorf("idempotent request in progress"))
+		uxe.Summary = "Your request is still being processed"
+		uxe.Detail = "A request with the same idempotency key is still being processed. Wait a moment and try again."
+		return nil, false, uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	}
+
+	return k, true, nil
+}
+
+// CompleteIdempotentRequest stores the response to the request made
+// with the claimed key, along with its content type and validators,
+// for it to be replayed on retries.
+func CompleteIdempotentRequest(key *models.IdempotencyKey, status int, header http.Header, body []byte) error {
+	key.Status = status
+	key.ContentType = header.Get("Content-Type")
+	key.ETag = header.Get("ETag")
+	key.LastModified = header.Get("Last-Modified")
+	key.Body = body
+	return handleDatabaseError(db.CompleteIdempotencyKey(key))
+}
+
+// ReleaseIdempotentRequest forgets the claimed key without storing
+// a response, so that the request can be retried.
+func ReleaseIdempotentRequest(key *models.IdempotencyKey) error {
+	return handleDatabaseError(db.ReleaseIdempotencyKey(key))
+}
+
+// ExpireIdempotencyKeys removes the keys expired at the given time.
+// It returns the number of keys removed.
+func ExpireIdempotencyKeys(now time.Time) (int64, error) {
+	n, err := db.DeleteExpiredIdempotencyKeys(now)
+	return n, handleDatabaseError(err)
+}
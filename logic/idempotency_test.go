@@ -0,0 +1,100 @@
+/**
+ * file: logic/idempotency_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIdempotencyScope string = "POST /feedback/"
+
+// TestIdempotentRequestReplay tests that completed requests are
+// replayed, with their validators, to their caller only.
+func TestIdempotentRequestReplay(t *testing.T) {
+	setupTestDatabase(t)
+
+	claim, replay, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	require.NoError(t, err)
+	assert.False(t, replay, "a new key should not be replayed")
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("ETag", `"1"`)
+	header.Set("Last-Modified", "Sun, 18 Oct 2026 12:00:00 GMT")
+	require.NoError(t, CompleteIdempotentRequest(claim, http.StatusCreated, header, []byte(`{"ID":1}`)))
+
+	k, replay, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	require.NoError(t, err)
+	assert.True(t, replay, "a completed key should be replayed")
+	assert.Equal(t, http.StatusCreated, k.Status)
+	assert.Equal(t, "application/json", k.ContentType)
+	assert.Equal(t, `"1"`, k.ETag)
+	assert.Equal(t, "Sun, 18 Oct 2026 12:00:00 GMT", k.LastModified)
+	assert.Equal(t, []byte(`{"ID":1}`), k.Body)
+
+	k, replay, err = BeginIdempotentRequest("key", testIdempotencyScope, "other", []byte("body"))
+	require.NoError(t, err)
+	assert.False(t, replay, "the key of another caller should not be replayed")
+	assert.True(t, k.Pending())
+	assert.NotContains(t, k.Caller, "other", "the caller should be hashed")
+}
+
+// TestIdempotentRequestMismatch tests that keys reused with another
+// body are refused.
+func TestIdempotentRequestMismatch(t *testing.T) {
+	setupTestDatabase(t)
+
+	_, _, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	require.NoError(t, err)
+	_, _, err = BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("other"))
+	assertStatus(t, http.StatusUnprocessableEntity, err)
+
+	_, _, err = BeginIdempotentRequest(strings.Repeat("k", maxIdempotencyKeyLength+1), testIdempotencyScope, "caller", nil)
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+// TestIdempotentRequestInFlight tests that retries are refused while
+// the request is processed, and accepted once it is released.
+func TestIdempotentRequestInFlight(t *testing.T) {
+	setupTestDatabase(t)
+
+	claim, _, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	require.NoError(t, err)
+	_, _, err = BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	assertStatus(t, http.StatusConflict, err)
+
+	require.NoError(t, ReleaseIdempotentRequest(claim))
+	_, replay, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	require.NoError(t, err)
+	assert.False(t, replay, "a released key should be claimed again")
+}
+
+// TestIdempotentRequestExpiry tests that keys can be used for other
+// requests once expired.
+func TestIdempotentRequestExpiry(t *testing.T) {
+	setupTestDatabase(t)
+	SetIdempotencyWindow(-time.Minute)
+	t.Cleanup(func() { SetIdempotencyWindow(DefaultIdempotencyWindow) })
+
+	claim, _, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("body"))
+	require.NoError(t, err)
+	require.NoError(t, CompleteIdempotentRequest(claim, http.StatusOK, http.Header{}, nil))
+
+	_, replay, err := BeginIdempotentRequest("key", testIdempotencyScope, "caller", []byte("other"))
+	require.NoError(t, err, "an expired key should be used for another request")
+	assert.False(t, replay, "an expired key should not be replayed")
+
+	n, err := ExpireIdempotencyKeys(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "expired keys should be removed")
+}
@@ -0,0 +1,284 @@
+/**
+ * file: logic/import.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the imports of feedback from CSV
+ * files, such as the exports of Google Forms used to
+ * collect feedback before. Every row is validated as
+ * new feedback, and rows are imported only once.
+ */
+
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+)
+
+const (
+	// ImportChunkSize is the number of rows committed by each
+	// transaction of an import.
+	ImportChunkSize int = 500
+
+	// maxImportSourceLength is the maximum length of the name of the
+	// source of an import.
+	maxImportSourceLength int = 200
+)
+
+// importTimeFormats are the layouts of the submission times tried
+// when the mapping gives none.
+var importTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02 3:04:05 PM",
+}
+
+func importSourceError(source string) error {
+	uxe := uxerrors.New(fmt.Errorf("invalid import source %q", source))
+	uxe.Summary = "The source of the import is invalid"
+	uxe.Detail = fmt.Sprintf("The source names the imported file, such as the form it was exported from. It should be at most %d characters long, and not empty. Correct the source and try again.", maxImportSourceLength)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func importFileError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not read the imported file"
+	uxe.Detail = fmt.Sprintf("The file should be a CSV file, with the headers on its first line. Reading it failed with: %s. Check the file and its delimiter and try again.", err)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// The importColumns structure holds the indices of the mapped
+// columns, -1 for the columns which are not mapped.
+type importColumns struct {
+	course, feedback, createdAt int
+}
+
+// mapColumns returns the indices of the columns of the mapping in the
+// headers, or an error listing the missing ones.
+func mapColumns(headers []string, m *models.ImportMapping) (*importColumns, error) {
+	errs := uxerrors.NewErrors(http.StatusBadRequest)
+	index := func(column string) int {
+		if column == "" {
+			return -1
+		}
+		for i, h := range headers {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(column)) {
+				return i
+			}
+		}
+
+		uxe := uxerrors.New(fmt.Errorf("missing column %q", column))
+		uxe.Summary = fmt.Sprintf("The %s column is missing", column)
+		uxe.Detail = fmt.Sprintf("The mapping refers to the %q column, but the file has no such header. The headers are %q. Correct the mapping and try again.", column, headers)
+		errs = errs.Append(uxe)
+		return -1
+	}
+
+	c := &importColumns{course: index(m.Course), feedback: index(m.Feedback), createdAt: index(m.CreatedAt)}
+	if len(errs.Errors) != 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+// field returns the trimmed field of the record at the index, or an
+// empty string if the record is too short or the column not mapped.
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// parseImportTime parses the submission time of a row.
+func parseImportTime(s string, m *models.ImportMapping, loc *time.Location) (time.Time, error) {
+	formats := importTimeFormats
+	if m.TimeFormat != "" {
+		formats = []string{m.TimeFormat}
+	}
+
+	for _, layout := range formats {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	uxe := uxerrors.New(fmt.Errorf("unknown time format of %q", s))
+	uxe.Summary = "The submission time is invalid"
+	uxe.Detail = fmt.Sprintf("The submission time (%q) does not follow the time format of the mapping. Set the TimeFormat of the mapping, or correct the time, and try again.", s)
+	return time.Time{}, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// readImportRow reads the feedback of the row. The hash of the row
+// covers its course and all its fields, so that the same answers to
+// forms on other courses are told apart. Submission times are
+// coarsened as the ones of published feedback.
+func readImportRow(record []string, columns *importColumns, m *models.ImportMapping, loc *time.Location, now time.Time) *models.ImportResult {
+	f := &models.Feedback{Course: m.DefaultCourse, Feedback: field(record, columns.feedback)}
+	if course := field(record, columns.course); course != "" {
+		f.Course = course
+	}
+	sanitizeFeedback(f)
+
+	h := sha256.New()
+	io.WriteString(h, strings.ToUpper(f.Course))
+	for _, v := range record {
+		io.WriteString(h, "\x00"+v)
+	}
+	r := &models.ImportResult{Hash: hex.EncodeToString(h.Sum(nil)), Status: models.ImportRowInvalid, Feedback: f}
+
+	createdAt := now
+	if columns.createdAt >= 0 {
+		t, err := parseImportTime(field(record, columns.createdAt), m, loc)
+		if err != nil {
+			r.Error = err
+			return r
+		}
+		createdAt = t
+	}
+	f.CreatedAt = createdAt.UTC().Truncate(publicationGranularity)
+
+	if err := validators.ValidateFeedback(f); err != nil {
+		r.Error = err
+		return r
+	}
+	r.Status = models.ImportRowValid
+	return r
+}
+
+// ImportFeedback imports the feedback of the rows of the CSV file, as
+// mapped by the mapping, from the named source. Every row is
+// validated as new feedback, and rows imported before are skipped,
+// so that files can be imported again. Valid rows are committed in
+// chunks of ImportChunkSize rows, and published at once. A dry run
+// only reports on the rows.
+func ImportFeedback(r io.Reader, source string, m *models.ImportMapping, dryRun bool) (*models.ImportReport, error) {
+	if source == "" || len(source) > maxImportSourceLength {
+		return nil, importSourceError(source)
+	}
+	if err := validators.ValidateImportMapping(m); err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if m.TimeZone != "" {
+		loc, _ = time.LoadLocation(m.TimeZone)
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	if m.Delimiter != "" {
+		cr.Comma = rune(m.Delimiter[0])
+	}
+	headers, err := cr.Read()
+	if err != nil {
+		return nil, importFileError(err)
+	}
+	headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	columns, err := mapColumns(headers, m)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			publishChanges()
+		}
+	}()
+
+	var chunk []*models.ImportResult
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		defer func() { chunk = chunk[:0] }()
+
+		if !dryRun {
+			if err := db.ImportFeedback(source, chunk); err != nil {
+				return handleDatabaseError(err)
+			}
+			committed = true
+			return nil
+		}
+
+		hashes := make([]string, len(chunk))
+		for i, r := range chunk {
+			hashes[i] = r.Hash
+		}
+		imported, err := db.GetImportedRows(hashes)
+		if err != nil {
+			return handleDatabaseError(err)
+		}
+		for _, r := range chunk {
+			if id, ok := imported[r.Hash]; ok {
+				r.Status, r.Feedback.ID = models.ImportRowDuplicate, id
+			}
+		}
+		return nil
+	}
+
+	report := &models.ImportReport{Source: source, DryRun: dryRun, Results: []*models.ImportResult{}}
+	seen := make(map[string]bool)
+	now := time.Now()
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, importFileError(err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		r := readImportRow(record, columns, m, loc, now)
+		r.Row, _ = cr.FieldPos(0)
+		report.Results = append(report.Results, r)
+
+		switch {
+		case r.Status != models.ImportRowValid:
+		case seen[r.Hash]:
+			r.Status = models.ImportRowDuplicate
+		default:
+			seen[r.Hash] = true
+			chunk = append(chunk, r)
+			if len(chunk) == ImportChunkSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range report.Results {
+		report.Rows++
+		switch r.Status {
+		case models.ImportRowValid:
+			report.Valid++
+		case models.ImportRowImported:
+			report.Imported++
+		case models.ImportRowDuplicate:
+			report.Duplicates++
+		case models.ImportRowInvalid:
+			report.Invalid++
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,90 @@
+/**
+ * file: logic/import_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImport = "\ufeffTimestamp,Course,Feedback\n" +
+	"2023/10/12 14:34:56,LINFO1101,The exercise sessions are far too short for us.\n" +
+	"2023/10/12 15:02:11,LINFO,The exercise sessions are far too short for us.\n" +
+	"\n" +
+	"2023/10/13 09:12:00,linfo1102,\"The slides are not published before the lectures,\nso we cannot take notes.\"\n" +
+	"2023/10/13 10:00:00,LINFO1102,Too short.\n" +
+	"2023/10/12 14:34:56,LINFO1101,The exercise sessions are far too short for us.\n"
+
+// TestImportFeedback tests that the valid rows are imported once,
+// and that dry runs report on the rows without importing them.
+func TestImportFeedback(t *testing.T) {
+	setupTestDatabase(t)
+	m := models.DefaultImportMapping
+
+	report, err := ImportFeedback(strings.NewReader(testImport), "forms-2023.csv", &m, true)
+	require.NoError(t, err, "a dry run should not fail")
+	assert.Equal(t, 5, report.Rows, "blank rows should be skipped")
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Equal(t, 1, report.Duplicates, "rows repeated in the file should be duplicates")
+	assert.Equal(t, 3, report.Results[1].Row)
+	assert.Equal(t, 5, report.Results[2].Row, "rows should be numbered as lines of the file")
+	assertStatus(t, http.StatusBadRequest, report.Results[1].Error)
+	all, err := GetAllFeedback()
+	require.NoError(t, err)
+	assert.Empty(t, all, "a dry run should not import anything")
+
+	report, err = ImportFeedback(strings.NewReader(testImport), "forms-2023.csv", &m, false)
+	require.NoError(t, err, "importing should not fail")
+	assert.Equal(t, 2, report.Imported)
+	assert.Zero(t, report.Valid)
+	all, err = GetAllFeedback()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, time.Date(2023, 10, 12, 14, 0, 0, 0, time.UTC), all[0].CreatedAt.UTC(), "submission times should be coarsened")
+	assert.Equal(t, models.FeedbackStatusNew, all[1].Status)
+
+	report, err = ImportFeedback(strings.NewReader(testImport), "forms-2023-again.csv", &m, false)
+	require.NoError(t, err)
+	assert.Zero(t, report.Imported, "importing again should not import anything")
+	assert.Equal(t, 3, report.Duplicates)
+	assert.Equal(t, all[0].ID, report.Results[0].Feedback.ID, "duplicates should refer to the imported feedback")
+
+	_, err = ImportFeedback(strings.NewReader("When;Text\n"), "forms.csv", &models.ImportMapping{DefaultCourse: "LINFO1101", Feedback: "Comments", Delimiter: ";"}, true)
+	assertStatus(t, http.StatusBadRequest, err)
+	_, err = ImportFeedback(strings.NewReader(testImport), "", &m, true)
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+// TestImportFeedbackChunks tests that imports span several
+// transactions.
+func TestImportFeedbackChunks(t *testing.T) {
+	setupTestDatabase(t)
+
+	var b strings.Builder
+	b.WriteString("Comments\n")
+	rows := 2*ImportChunkSize + 1
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "The exercise sessions of week %d are far too short.\n", i)
+	}
+
+	m := &models.ImportMapping{DefaultCourse: "LINFO1101", Feedback: "Comments"}
+	report, err := ImportFeedback(strings.NewReader(b.String()), "forms.csv", m, false)
+	require.NoError(t, err)
+	assert.Equal(t, rows, report.Imported)
+
+	all, err := GetAllFeedback()
+	require.NoError(t, err)
+	assert.Len(t, all, rows)
+}
@@ -0,0 +1,252 @@
+/**
+ * file: logic/inbox.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the in-app inbox of the
+ * representatives. It is fed by the same events as
+ * the alerts, and the representatives choose which
+ * events land in their inbox.
+ */
+
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+const inboxLimit int = 100
+
+// newAccessToken returns a new access token, and its hash.
+func newAccessToken() (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	token := hex.EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+	return token, hash[:], nil
+}
+
+// AuthenticateRepresentative returns the representative with the
+// given access token.
+func AuthenticateRepresentative(token string) (*models.Representative, error) {
+	hash := sha256.Sum256([]byte(token))
+	r, err := db.GetRepresentativeByToken(hash[:])
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && token == "") {
+		uxe := uxerrors.New(fmt.Errorf("invalid representative token"))
+		uxe.Summary = "You are not allowed to access this resource"
+		uxe.Detail = "This resource is restricted to representatives. Provide your access token and try again."
+		return nil, uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe)
+	}
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return r, nil
+}
+
+// RotateRepresentativeToken issues a new access token to the
+// representative. The previous token stops working.
+func RotateRepresentativeToken(id uint) (*models.Representative, error) {
+	token, hash, err := newAccessToken()
+	if err != nil {
+		return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+
+	if err := db.SetRepresentativeToken(id, hash); err != nil {
+		return nil, representativeNotFound(err)
+	}
+
+	r, err := db.GetRepresentative(id)
+	if err != nil {
+		return nil, representativeNotFound(err)
+	}
+	r.Token = token
+	return r, nil
+}
+
+// UpdatePreferences saves the preferences of the representative.
+func UpdatePreferences(id uint, p *models.RepresentativePreferences) (*models.Representative, error) {
+	if err := validators.ValidatePreferences(p); err != nil {
+		return nil, err
+	}
+
+	r, err := db.UpdateRepresentativePreferences(id, p)
+	if err != nil {
+		return nil, representativeNotFound(err)
+	}
+	return r, nil
+}
+
+// GetInbox returns the latest notifications of the representative,
+// or only the unread ones.
+func GetInbox(id uint, unread bool) (*models.Inbox, error) {
+	inbox, err := db.GetInbox(id, unread, inboxLimit)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return inbox, nil
+}
+
+// MarkNotificationRead marks the notification of the representative
+// as read.
+func MarkNotificationRead(id, notification uint) error {
+	err := db.MarkNotificationRead(id, notification, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The notification was not found"
+		uxe.Detail = "The notification you requested is not in your inbox. Check the identifier and try again."
+		return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+	}
+	return handleDatabaseError(err)
+}
+
+// MarkAllNotificationsRead marks all notifications of the
+// representative as read, and returns how many were unread.
+func MarkAllNotificationsRead(id uint) (int64, error) {
+	n, err := db.MarkAllNotificationsRead(id, time.Now())
+	if err != nil {
+		return 0, handleDatabaseError(err)
+	}
+	return n, nil
+}
+
+// newNotification returns the notification of the event on the
+// feedback for the representative.
+func newNotification(r *models.Representative, kind models.NotificationKind, ref uint64, f *models.Feedback, detail string) *models.Notification {
+	return &models.Notification{
+		RepresentativeID: r.ID,
+		Kind:             kind,
+		Ref:              ref,
+		FeedbackID:       f.ID,
+		Course:           f.Course,
+		Detail:           truncate(detail, 200),
+	}
+}
+
+// notifyInbox adds a notification of the event on the feedback to
+// the inboxes of the representatives following it and wanting this
+// kind of notifications.
+func notifyInbox(kind models.NotificationKind, ref uint64, f *models.Feedback, detail string) error {
+	representatives, err := db.GetRepresentatives()
+	if err != nil {
+		return err
+	}
+
+	var notifications []*models.Notification
+	for _, r := range representatives {
+		if r.Follows(f) && r.Wants(kind) {
+			notifications = append(notifications, newNotification(r, kind, ref, f, detail))
+		}
+	}
+
+	return db.AddNotifications(notifications)
+}
+
+// notifyChange notifies the representatives of the change, if it
+// needs their attention: new feedback, and changes of status.
+func notifyChange(c *models.Change) error {
+	if c.Feedback == nil {
+		return nil
+	}
+
+	switch c.Kind {
+	case models.ChangeKindCreate:
+		return notifyInbox(models.NotificationFeedback, c.Seq, c.Feedback, "")
+	case models.ChangeKindStatus:
+		return notifyInbox(models.NotificationStatus, c.Seq, c.Feedback, string(c.Feedback.Status))
+	default:
+		return nil
+	}
+}
+
+// assignModeration returns the representative assigned to the review
+// of the votes on the feedback. If the review is not assigned yet, it
+// is assigned to the representative following the feedback with the
+// fewest reviews assigned, the earliest one on ties. It returns nil
+// if no representative follows the feedback, the review is then left
+// to the administrators.
+func assignModeration(f *models.Feedback) (*models.Representative, error) {
+	representatives, err := db.GetRepresentatives()
+	if err != nil {
+		return nil, err
+	}
+	byID := func(id uint) *models.Representative {
+		for _, r := range representatives {
+			if r.ID == id {
+				return r
+			}
+		}
+		return nil
+	}
+
+	a, err := db.GetModerationAssignment(f.ID)
+	if err == nil {
+		return byID(a.RepresentativeID), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	loads, err := db.GetModerationLoads()
+	if err != nil {
+		return nil, err
+	}
+
+	var assignee *models.Representative
+	for _, r := range representatives {
+		if r.Follows(f) && (assignee == nil || loads[r.ID] < loads[assignee.ID]) {
+			assignee = r
+		}
+	}
+	if assignee == nil {
+		return nil, nil
+	}
+
+	a, _, err = db.AssignModeration(&models.ModerationAssignment{FeedbackID: f.ID, RepresentativeID: assignee.ID})
+	if err != nil {
+		return nil, err
+	}
+	return byID(a.RepresentativeID), nil
+}
+
+// notifyModeration assigns the review of the votes on the feedback,
+// and notifies the assignee that votes await their review. Latest is
+// the ID of the latest vote awaiting review.
+func notifyModeration(f *models.Feedback, votes int, latest uint) error {
+	r, err := assignModeration(f)
+	if err != nil || r == nil || !r.Wants(models.NotificationModeration) {
+		return err
+	}
+
+	n := newNotification(r, models.NotificationModeration, uint64(latest), f, strconv.Itoa(votes))
+	return db.AddNotifications([]*models.Notification{n})
+}
+
+// The InboxAlertChannel structure routes the alerts to the inboxes
+// of the representatives following the feedback. Failures are
+// reported to OnError, if set.
+type InboxAlertChannel struct {
+	OnError func(error)
+}
+
+func (c InboxAlertChannel) SendAlert(a *models.Alert) {
+	err := notifyInbox(models.NotificationAlert, uint64(a.ID), a.Feedback, a.Rule.Name)
+	if err != nil && c.OnError != nil {
+		c.OnError(err)
+	}
+}
@@ -0,0 +1,127 @@
+/**
+ * file: logic/inbox_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRepresentativeToken tests that representatives authenticate
+// with their latest token only.
+func TestRepresentativeToken(t *testing.T) {
+	setupTestDatabase(t)
+
+	r, err := CreateRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org"})
+	require.NoError(t, err, "creating the representative should not fail")
+	require.NotEmpty(t, r.Token, "the token should be returned on creation")
+	assert.Empty(t, r.TokenHash, "the token hash should not be returned")
+
+	me, err := AuthenticateRepresentative(r.Token)
+	require.NoError(t, err, "the token should authenticate the representative")
+	assert.Equal(t, r.ID, me.ID)
+
+	for _, token := range []string{"", "nope"} {
+		_, err = AuthenticateRepresentative(token)
+		assertStatus(t, http.StatusUnauthorized, err)
+	}
+
+	rotated, err := RotateRepresentativeToken(r.ID)
+	require.NoError(t, err, "rotating the token should not fail")
+	assert.NotEqual(t, r.Token, rotated.Token)
+
+	_, err = AuthenticateRepresentative(r.Token)
+	assertStatus(t, http.StatusUnauthorized, err)
+	_, err = AuthenticateRepresentative(rotated.Token)
+	assert.NoError(t, err, "the new token should authenticate the representative")
+
+	_, err = RotateRepresentativeToken(42)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+// TestInbox tests that the events on followed feedback land in the
+// inboxes of the representatives wanting them, and can be marked as
+// read.
+func TestInbox(t *testing.T) {
+	setupTestDatabase(t)
+
+	all, err := CreateRepresentative(&models.Representative{Name: "All", Email: "all@example.org", Faculties: []string{"LINFO"}})
+	require.NoError(t, err, "creating the representative should not fail")
+	picky, err := CreateRepresentative(&models.Representative{Name: "Picky", Email: "picky@example.org", Courses: []string{"LINFO1101"}})
+	require.NoError(t, err, "creating the representative should not fail")
+	other, err := CreateRepresentative(&models.Representative{Name: "Other", Email: "other@example.org", Faculties: []string{"LEPL"}})
+	require.NoError(t, err, "creating the representative should not fail")
+	unscoped, err := CreateRepresentative(&models.Representative{Name: "Unscoped", Email: "unscoped@example.org"})
+	require.NoError(t, err, "creating the representative should not fail")
+	everything, err := CreateRepresentative(&models.Representative{Name: "Everything", Email: "everything@example.org", AllCourses: true})
+	require.NoError(t, err, "creating the representative should not fail")
+
+	_, err = UpdatePreferences(picky.ID, &models.RepresentativePreferences{
+		Language:        "en",
+		DigestFrequency: models.DigestFrequencyOff,
+		InboxEvents:     []models.NotificationKind{models.NotificationStatus, models.NotificationAlert},
+	})
+	require.NoError(t, err, "updating the preferences should not fail")
+	_, err = UpdatePreferences(picky.ID, &models.RepresentativePreferences{Language: "en", DigestFrequency: "hourly"})
+	assertStatus(t, http.StatusBadRequest, err)
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	_, err = TransitionFeedbackStatus(1, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err, "changing the status should not fail")
+
+	processChanges(t)
+	f, err := GetFeedback(1)
+	require.NoError(t, err)
+	InboxAlertChannel{}.SendAlert(&models.Alert{ID: 7, Rule: &models.AlertRule{Name: "hot"}, Feedback: f})
+
+	inbox, err := GetInbox(all.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.EqualValues(t, 3, inbox.Unread)
+	require.Len(t, inbox.Notifications, 3)
+	assert.Equal(t, models.NotificationAlert, inbox.Notifications[0].Kind, "the latest notification should come first")
+	assert.Equal(t, "hot", inbox.Notifications[0].Detail)
+	assert.Equal(t, models.NotificationStatus, inbox.Notifications[1].Kind)
+	assert.Equal(t, string(models.FeedbackStatusAcknowledged), inbox.Notifications[1].Detail)
+	assert.Equal(t, models.NotificationFeedback, inbox.Notifications[2].Kind)
+
+	inbox, err = GetInbox(other.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.Empty(t, inbox.Notifications, "only the followed feedback should land in the inbox")
+	inbox, err = GetInbox(unscoped.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.Empty(t, inbox.Notifications, "representatives following nothing should not be notified")
+	inbox, err = GetInbox(everything.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.Len(t, inbox.Notifications, 3, "representatives following all courses should be notified")
+
+	inbox, err = GetInbox(picky.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	require.Len(t, inbox.Notifications, 2, "only the wanted events should land in the inbox")
+
+	notification := inbox.Notifications[0].ID
+	err = MarkNotificationRead(all.ID, notification)
+	assertStatus(t, http.StatusNotFound, err)
+	require.NoError(t, MarkNotificationRead(picky.ID, notification), "marking the notification as read should not fail")
+
+	inbox, err = GetInbox(picky.ID, true)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.EqualValues(t, 1, inbox.Unread)
+	assert.Len(t, inbox.Notifications, 1, "only the unread notifications should be returned")
+
+	n, err := MarkAllNotificationsRead(all.ID)
+	require.NoError(t, err, "marking all notifications as read should not fail")
+	assert.EqualValues(t, 3, n)
+	inbox, err = GetInbox(all.ID, true)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.Zero(t, inbox.Unread)
+	assert.Empty(t, inbox.Notifications)
+}
@@ -12,11 +12,16 @@ package logic
 import (
 	"net/http"
 
+	"git.licolas.net/delegit/delegit/database"
 	"git.licolas.net/delegit/delegit/uxerrors"
 	"gorm.io/gorm"
 )
 
 func handleDatabaseError(err error) error {
+	if uxe, ok := err.(uxerrors.Errors); ok {
+		return uxe
+	}
+
 	switch err {
 	case nil:
 		return nil
@@ -25,6 +30,21 @@ func handleDatabaseError(err error) error {
 		uxe.Summary = "Feedback not found"
 		uxe.Detail = "The feedback you requested could not be found. Check the ID and try again."
 		return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+	case database.ErrVoteFloor:
+		uxe := uxerrors.New(err)
+		uxe.Summary = "There is no vote left to retract"
+		uxe.Detail = "You are trying to retract a vote, but the feedback has no votes left of that kind. Refresh the feedback and try again."
+		return uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	case database.ErrDuplicateEmail:
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The email address is already in use"
+		uxe.Detail = "Another representative already uses this email address. Use another address, or update the existing representative."
+		return uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	case database.ErrPushEndpointTaken:
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The push subscription belongs to another voter"
+		uxe.Detail = "This browser is already subscribed with another voter token. Unsubscribe it with that token first, or subscribe with a new browser subscription."
+		return uxerrors.NewErrors(http.StatusConflict).Append(uxe)
 	default:
 		return uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
 	}
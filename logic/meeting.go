@@ -0,0 +1,346 @@
+/**
+ * file: logic/meeting.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the council meetings, whose
+ * agenda is prepared by the representatives, and
+ * the outcomes recorded once they are held.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+// MeetingAgendaSize is the number of feedback put on the agenda of
+// new meetings, from the ranking of the open feedback in their scope.
+const MeetingAgendaSize int = 10
+
+func sanitizeMeeting(m *models.Meeting) {
+	m.ID = 0
+	m.RepresentativeID = nil
+	m.HeldAt = nil
+	m.Items = nil
+	m.StartsAt = m.StartsAt.UTC()
+	m.EndsAt = m.EndsAt.UTC()
+}
+
+// meetingNotFound returns the error for an unknown meeting, or err
+// itself for other errors.
+func meetingNotFound(err error) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return handleDatabaseError(err)
+	}
+
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Meeting not found"
+	uxe.Detail = "The meeting you requested could not be found. Check the ID and try again."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// notOnAgenda returns the error for feedback which is not on the
+// agenda of the meeting.
+func notOnAgenda(meetingID, feedbackID uint) error {
+	uxe := uxerrors.New(fmt.Errorf("feedback %d not on the agenda of meeting %d", feedbackID, meetingID))
+	uxe.Summary = "The feedback is not on the agenda"
+	uxe.Detail = fmt.Sprintf("The feedback %d is not on the agenda of this meeting. Refresh the meeting and try again.", feedbackID)
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// meetingForbidden returns the error for a representative managing
+// a meeting outside of the courses and faculties they follow.
+func meetingForbidden(r *models.Representative, m *models.Meeting) error {
+	uxe := uxerrors.New(fmt.Errorf("representative %d does not follow the %s %s of meeting %d", r.ID, m.Scope, m.Code, m.ID))
+	uxe.Summary = "You are not allowed to manage this meeting"
+	uxe.Detail = fmt.Sprintf("The meeting is about the %s %s, which you do not follow. Only the representatives of the %s, the representative who created the meeting and administrators can manage it.", m.Scope, m.Code, m.Scope)
+	return uxerrors.NewErrors(http.StatusForbidden).Append(uxe)
+}
+
+// canManageMeeting returns true if the representative may manage the
+// meeting: they created it, or follow its course or faculty. A nil
+// representative is an administrator, who may manage all meetings.
+func canManageMeeting(r *models.Representative, m *models.Meeting) bool {
+	if r == nil {
+		return true
+	}
+	if m.RepresentativeID != nil && *m.RepresentativeID == r.ID {
+		return true
+	}
+	return r.Represents(m.Scope, m.Code)
+}
+
+// managedMeeting returns the meeting identified by id, without its
+// agenda, if the representative may manage it.
+func managedMeeting(id uint, r *models.Representative) (*models.Meeting, error) {
+	m, err := db.GetMeeting(id)
+	if err != nil {
+		return nil, meetingNotFound(err)
+	}
+	if !canManageMeeting(r, m) {
+		return nil, meetingForbidden(r, m)
+	}
+
+	return m, nil
+}
+
+// CreateMeeting creates the meeting on behalf of the representative,
+// who must follow its course or faculty, or of an administrator if
+// the representative is nil. Its agenda is pre-populated with the
+// highest ranked feedback still open on its course or faculty.
+func CreateMeeting(m *models.Meeting, r *models.Representative) (*models.Meeting, error) {
+	sanitizeMeeting(m)
+	if r != nil {
+		m.RepresentativeID = &r.ID
+	}
+
+	if err := validators.ValidateMeeting(m); err != nil {
+		return nil, err
+	}
+	if r != nil && !r.Represents(m.Scope, m.Code) {
+		return nil, meetingForbidden(r, m)
+	}
+
+	feedback, err := db.GetScopedFeedback(&models.StatsQuery{Scope: m.Scope, Code: m.Code})
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	open := []*models.Feedback{}
+	for _, f := range feedback {
+		if f.Status != models.FeedbackStatusResolved && f.Status != models.FeedbackStatusRejected {
+			open = append(open, f)
+		}
+	}
+	notify.Rank(open)
+
+	agenda := make([]uint, min(len(open), MeetingAgendaSize))
+	for i := range agenda {
+		agenda[i] = open[i].ID
+	}
+
+	created, err := db.AddMeeting(m, agenda)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return getMeeting(created.ID)
+}
+
+// GetMeetings returns the meetings the representative may manage, or
+// all of them if the representative is nil, starting between since,
+// included, and until, excluded, in chronological order, along with
+// their agenda.
+func GetMeetings(since, until *time.Time, r *models.Representative) ([]*models.Meeting, error) {
+	all, err := db.GetMeetings(since, until)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	meetings := []*models.Meeting{}
+	for _, m := range all {
+		if !canManageMeeting(r, m) {
+			continue
+		}
+		if m.Items, err = db.GetMeetingItems(m.ID); err != nil {
+			return nil, handleDatabaseError(err)
+		}
+		meetings = append(meetings, m)
+	}
+
+	return meetings, nil
+}
+
+// GetMeeting returns the meeting identified by id, along with its
+// agenda, if the representative may manage it.
+func GetMeeting(id uint, r *models.Representative) (*models.Meeting, error) {
+	m, err := managedMeeting(id, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Items, err = db.GetMeetingItems(id); err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return m, nil
+}
+
+// getMeeting returns the meeting identified by id, along with its
+// agenda.
+func getMeeting(id uint) (*models.Meeting, error) {
+	m, err := db.GetMeeting(id)
+	if err != nil {
+		return nil, meetingNotFound(err)
+	}
+
+	if m.Items, err = db.GetMeetingItems(id); err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return m, nil
+}
+
+// DeleteMeeting deletes the meeting identified by id, if the
+// representative may manage it.
+func DeleteMeeting(id uint, r *models.Representative) error {
+	if _, err := managedMeeting(id, r); err != nil {
+		return err
+	}
+
+	deleted, err := db.DeleteMeeting(id)
+	if err != nil {
+		return handleDatabaseError(err)
+	}
+	if !deleted {
+		return meetingNotFound(gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
+
+// SetMeetingItem attaches the feedback to the agenda of the meeting,
+// with the given notes. The notes of feedback already on the agenda
+// are replaced. The feedback must be on the course or faculty of the
+// meeting, which the representative must be allowed to manage.
+func SetMeetingItem(meetingID, feedbackID uint, notes string, r *models.Representative) (*models.Meeting, error) {
+	if err := validators.ValidateMeetingItem(&models.MeetingItem{Notes: notes}); err != nil {
+		return nil, err
+	}
+
+	m, err := managedMeeting(meetingID, r)
+	if err != nil {
+		return nil, err
+	}
+	f, err := db.GetFeedback(feedbackID)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	if !m.Covers(f) {
+		uxe := uxerrors.New(fmt.Errorf("feedback %d on %s not in the scope of meeting %d", f.ID, f.Course, m.ID))
+		uxe.Summary = "The feedback is not about this meeting"
+		uxe.Detail = fmt.Sprintf("The feedback is on %s, but the meeting is about the %s %s. Choose feedback on the %s and try again.", f.Course, m.Scope, m.Code, m.Scope)
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	if err := db.SetMeetingItem(meetingID, feedbackID, notes); err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return getMeeting(meetingID)
+}
+
+// RemoveMeetingItem removes the feedback from the agenda of the
+// meeting, which the representative must be allowed to manage.
+func RemoveMeetingItem(meetingID, feedbackID uint, r *models.Representative) (*models.Meeting, error) {
+	if _, err := managedMeeting(meetingID, r); err != nil {
+		return nil, err
+	}
+
+	removed, err := db.RemoveMeetingItem(meetingID, feedbackID)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	if !removed {
+		return nil, notOnAgenda(meetingID, feedbackID)
+	}
+
+	return getMeeting(meetingID)
+}
+
+// RecordMeetingOutcomes records the outcomes of the items of the
+// meeting. The status of the feedback of each item is changed to its
+// outcome, through the statuses the workflow requires, and its public
+// response is set. Either all outcomes are recorded, or none are. The
+// representative must be allowed to manage the meeting.
+func RecordMeetingOutcomes(id uint, outcomes []*models.MeetingOutcome, r *models.Representative) (*models.Meeting, error) {
+	if len(outcomes) == 0 {
+		uxe := uxerrors.New(fmt.Errorf("no outcome"))
+		uxe.Summary = "There is no outcome to record"
+		uxe.Detail = "Give the outcome of at least one item of the agenda and try again."
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+	for _, o := range outcomes {
+		if err := validators.ValidateMeetingOutcome(o); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := GetMeeting(id, r)
+	if err != nil {
+		return nil, err
+	}
+
+	attached := make(map[uint]bool, len(m.Items))
+	for _, item := range m.Items {
+		attached[item.FeedbackID] = true
+	}
+	for _, o := range outcomes {
+		if !attached[o.FeedbackID] {
+			return nil, notOnAgenda(id, o.FeedbackID)
+		}
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *database.Database) error {
+		for _, o := range outcomes {
+			if err := respondFeedback(tx, o); err != nil {
+				return err
+			}
+			if err := tx.RecordMeetingOutcome(id, o, now); err != nil {
+				return handleDatabaseError(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	publishChanges()
+	return getMeeting(id)
+}
+
+// respondFeedback brings the feedback to the status of the outcome,
+// through the statuses the workflow requires, and sets its response.
+func respondFeedback(tx *database.Database, o *models.MeetingOutcome) error {
+	f, err := tx.GetFeedback(o.FeedbackID)
+	if err != nil {
+		return handleDatabaseError(err)
+	}
+
+	path := statusPath(f.Status, o.Status)
+	if path == nil {
+		return transitionError(f.Status, o.Status)
+	}
+
+	for _, status := range path[:max(len(path)-1, 0)] {
+		if _, err := transitionFeedbackStatus(tx, o.FeedbackID, status, nil); err != nil {
+			return err
+		}
+	}
+
+	precondition := func(f *models.Feedback) error {
+		if f != nil && f.Status != o.Status && !canTransition(f.Status, o.Status) {
+			return transitionError(f.Status, o.Status)
+		}
+		return nil
+	}
+	if _, err := tx.RespondFeedback(o.FeedbackID, o.Status, o.Response, precondition); err != nil {
+		return handleDatabaseError(err)
+	}
+
+	return nil
+}
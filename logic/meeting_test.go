@@ -0,0 +1,203 @@
+/**
+ * file: logic/meeting_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepresentative is the representative of the LINFO faculty
+// managing the test meetings.
+var testRepresentative = &models.Representative{ID: 7, Faculties: []string{"LINFO"}}
+
+func newTestMeeting() *models.Meeting {
+	start := time.Now().Add(24 * time.Hour)
+	return &models.Meeting{Body: "LINFO faculty council", Scope: models.StatsScopeFaculty, Code: "linfo", StartsAt: start, EndsAt: start.Add(2 * time.Hour)}
+}
+
+// TestStatusPath tests that outcomes go through the shortest path of
+// the workflow.
+func TestStatusPath(t *testing.T) {
+	assert.Equal(t, []models.FeedbackStatus{models.FeedbackStatusAcknowledged, models.FeedbackStatusResolved}, statusPath(models.FeedbackStatusNew, models.FeedbackStatusResolved))
+	assert.Equal(t, []models.FeedbackStatus{models.FeedbackStatusRejected}, statusPath(models.FeedbackStatusNew, models.FeedbackStatusRejected))
+	assert.Equal(t, []models.FeedbackStatus{models.FeedbackStatusAcknowledged, models.FeedbackStatusInProgress}, statusPath(models.FeedbackStatusResolved, models.FeedbackStatusInProgress))
+	assert.Empty(t, statusPath(models.FeedbackStatusResolved, models.FeedbackStatusResolved))
+	assert.NotNil(t, statusPath(models.FeedbackStatusResolved, models.FeedbackStatusResolved))
+	assert.Nil(t, statusPath(models.FeedbackStatusAcknowledged, models.FeedbackStatusNew), "new feedback should not be reached again")
+}
+
+// TestCreateMeeting tests that agendas are pre-populated with the
+// highest ranked open feedback of their scope.
+func TestCreateMeeting(t *testing.T) {
+	setupTestDatabase(t)
+
+	for _, course := range []string{"LINFO1101", "LINFO1102", "LINFO1103", "LEPL1101"} {
+		_, err := AddFeedback(&models.Feedback{Course: course, Feedback: "The exercise sessions are far too short for us."})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := UpdateFeedbackUpvotes(2, 1, models.VoteSource{})
+		require.NoError(t, err)
+	}
+	_, err := TransitionFeedbackStatus(3, models.FeedbackStatusRejected, nil)
+	require.NoError(t, err)
+
+	m, err := CreateMeeting(newTestMeeting(), testRepresentative)
+	require.NoError(t, err, "creating a meeting should not fail")
+	assert.Equal(t, uint(7), *m.RepresentativeID)
+	require.Len(t, m.Items, 2, "only the open feedback of the faculty should be on the agenda")
+	assert.Equal(t, uint(2), m.Items[0].FeedbackID, "the agenda should follow the ranking")
+	assert.Equal(t, uint(1), m.Items[1].FeedbackID)
+
+	invalid := newTestMeeting()
+	invalid.Code = "L1"
+	_, err = CreateMeeting(invalid, testRepresentative)
+	assertStatus(t, http.StatusBadRequest, err)
+
+	_, err = SetMeetingItem(m.ID, 4, "", testRepresentative)
+	assertStatus(t, http.StatusBadRequest, err)
+	m, err = SetMeetingItem(m.ID, 3, "Ask why it was rejected", testRepresentative)
+	require.NoError(t, err)
+	require.Len(t, m.Items, 3)
+	assert.Equal(t, "Ask why it was rejected", m.Items[2].Notes)
+
+	_, err = GetMeeting(42, testRepresentative)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = RemoveMeetingItem(m.ID, 4, testRepresentative)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+// TestRecordMeetingOutcomes tests that outcomes set the status and
+// response of the feedback, through the workflow.
+func TestRecordMeetingOutcomes(t *testing.T) {
+	d := setupTestDatabase(t)
+
+	for i := 0; i < 2; i++ {
+		_, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err)
+	}
+	_, err := AddFeedback(&models.Feedback{Course: "LEPL1101", Feedback: "The exercise sessions are far too short for us."})
+	require.NoError(t, err)
+
+	m, err := CreateMeeting(newTestMeeting(), testRepresentative)
+	require.NoError(t, err)
+
+	_, err = RecordMeetingOutcomes(m.ID, []*models.MeetingOutcome{{FeedbackID: 3, Status: models.FeedbackStatusResolved, Response: "Done."}}, testRepresentative)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = RecordMeetingOutcomes(m.ID, []*models.MeetingOutcome{{FeedbackID: 1, Status: models.FeedbackStatusResolved}}, testRepresentative)
+	assertStatus(t, http.StatusBadRequest, err)
+	_, err = RecordMeetingOutcomes(m.ID, nil, testRepresentative)
+	assertStatus(t, http.StatusBadRequest, err)
+
+	m, err = RecordMeetingOutcomes(m.ID, []*models.MeetingOutcome{
+		{FeedbackID: 1, Status: models.FeedbackStatusResolved, Response: "Two sessions were added."},
+		{FeedbackID: 2, Status: models.FeedbackStatusRejected, Response: "The teacher will not change the sessions."},
+	}, testRepresentative)
+	require.NoError(t, err, "recording outcomes should not fail")
+	assert.NotNil(t, m.HeldAt, "the meeting should be held")
+	assert.Equal(t, models.FeedbackStatusResolved, m.Items[0].Outcome)
+	assert.NotNil(t, m.Items[0].DecidedAt)
+
+	f, err := GetFeedback(1)
+	require.NoError(t, err)
+	assert.Equal(t, models.FeedbackStatusResolved, f.Status)
+	assert.Equal(t, "Two sessions were added.", f.Response)
+	f, err = GetFeedback(2)
+	require.NoError(t, err)
+	assert.Equal(t, models.FeedbackStatusRejected, f.Status)
+
+	changes, err := d.GetChangesSince(0, 20)
+	require.NoError(t, err)
+	var statuses []uint
+	for _, c := range changes {
+		if c.Kind == models.ChangeKindStatus {
+			statuses = append(statuses, c.FeedbackID)
+		}
+	}
+	assert.Equal(t, []uint{1, 1, 2}, statuses, "resolving new feedback should acknowledge it first")
+
+	// Responses can be corrected without changing the status.
+	m, err = RecordMeetingOutcomes(m.ID, []*models.MeetingOutcome{{FeedbackID: 1, Status: models.FeedbackStatusResolved, Response: "Three sessions were added."}}, testRepresentative)
+	require.NoError(t, err)
+	assert.Equal(t, "Three sessions were added.", m.Items[0].Feedback.Response)
+}
+
+// TestMeetingScope tests that representatives only manage the
+// meetings of the courses and faculties they follow, or which they
+// created, and that administrators manage all meetings.
+func TestMeetingScope(t *testing.T) {
+	setupTestDatabase(t)
+
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+
+	course := &models.Representative{ID: 8, Courses: []string{"LINFO1101"}}
+	other := &models.Representative{ID: 9, Faculties: []string{"LEPL"}}
+
+	_, err = CreateMeeting(newTestMeeting(), course)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = CreateMeeting(newTestMeeting(), other)
+	assertStatus(t, http.StatusForbidden, err)
+
+	m, err := CreateMeeting(newTestMeeting(), testRepresentative)
+	require.NoError(t, err)
+	courseMeeting := newTestMeeting()
+	courseMeeting.Scope, courseMeeting.Code = models.StatsScopeCourse, "linfo1101"
+	_, err = CreateMeeting(courseMeeting, course)
+	require.NoError(t, err, "representatives should create meetings on their courses")
+	_, err = CreateMeeting(newTestMeeting(), nil)
+	require.NoError(t, err, "administrators should create meetings on all faculties")
+
+	_, err = GetMeeting(m.ID, other)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = SetMeetingItem(m.ID, 1, "", other)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = RemoveMeetingItem(m.ID, 1, other)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = RecordMeetingOutcomes(m.ID, []*models.MeetingOutcome{{FeedbackID: 1, Status: models.FeedbackStatusResolved, Response: "Done."}}, other)
+	assertStatus(t, http.StatusForbidden, err)
+	assertStatus(t, http.StatusForbidden, DeleteMeeting(m.ID, course))
+
+	f, err := GetFeedback(1)
+	require.NoError(t, err)
+	assert.Equal(t, models.FeedbackStatusNew, f.Status, "refused outcomes should not change the feedback")
+
+	meetings, err := GetMeetings(nil, nil, course)
+	require.NoError(t, err)
+	require.Len(t, meetings, 1, "representatives should only list the meetings they manage")
+	assert.Equal(t, "linfo1101", meetings[0].Code)
+	meetings, err = GetMeetings(nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, meetings, 3, "administrators should list all meetings")
+
+	// Representatives following nothing represent nothing, unless
+	// the administrators let them follow all courses.
+	unscoped := &models.Representative{ID: 10}
+	_, err = CreateMeeting(newTestMeeting(), unscoped)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = GetMeeting(m.ID, unscoped)
+	assertStatus(t, http.StatusForbidden, err)
+	meetings, err = GetMeetings(nil, nil, unscoped)
+	require.NoError(t, err)
+	assert.Empty(t, meetings, "representatives following nothing should list no meeting")
+	all := &models.Representative{ID: 11, AllCourses: true}
+	_, err = GetMeeting(m.ID, all)
+	require.NoError(t, err, "representatives following all courses should manage all meetings")
+
+	// Owners keep managing their meetings once they stop following
+	// their scope.
+	former := &models.Representative{ID: 7, Faculties: []string{"LEPL"}}
+	_, err = GetMeeting(m.ID, former)
+	require.NoError(t, err)
+	require.NoError(t, DeleteMeeting(m.ID, nil))
+}
@@ -0,0 +1,394 @@
+/**
+ * file: logic/moderation.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the vote-manipulation detection
+ * and the moderation of quarantined votes.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"gorm.io/gorm"
+)
+
+// Reasons a vote can be flagged for by the analysis.
+const (
+	FlagVelocity    string = "velocity"
+	FlagAddress     string = "address"
+	FlagTokenFamily string = "token-family"
+	FlagReadingTime string = "reading-time"
+)
+
+// VoteAnalysisConfig holds the thresholds used by AnalyzeVotes
+// to detect vote manipulation.
+type VoteAnalysisConfig struct {
+	// BaselineWindow is how far back votes are considered. The
+	// votes outside of the SpikeWindow make up the baseline
+	// velocity of a feedback.
+	BaselineWindow time.Duration
+
+	// SpikeWindow is the recent period compared against the
+	// baseline velocity.
+	SpikeWindow time.Duration
+
+	// SpikeFactor is how many times faster than the baseline
+	// votes must come in during the SpikeWindow to be flagged.
+	SpikeFactor float64
+
+	// SpikeMinVotes is the minimum number of votes in the
+	// SpikeWindow before a spike is considered at all.
+	SpikeMinVotes int
+
+	// MaxVotesPerAddress is the number of votes a single address
+	// may cast on one feedback. Campus networks share addresses,
+	// so this should stay generous.
+	MaxVotesPerAddress int
+
+	// MaxVotesPerTokenFamily is the number of votes a family of
+	// voter tokens may cast on one feedback.
+	MaxVotesPerTokenFamily int
+
+	// TokenFamilyLength is the length of the voter token prefix
+	// shared by tokens of the same family. The family is hashed
+	// along with the token when the vote is cast.
+	TokenFamilyLength int
+
+	// ReadingSpeed is the number of words per second a voter is
+	// expected to read at most. Votes cast faster than the time
+	// needed to read the feedback are flagged.
+	ReadingSpeed float64
+}
+
+// DefaultVoteAnalysisConfig is the configuration used by
+// AnalyzeVotes, unless changed by SetVoteAnalysisConfig.
+var DefaultVoteAnalysisConfig = VoteAnalysisConfig{
+	BaselineWindow:         24 * time.Hour,
+	SpikeWindow:            10 * time.Minute,
+	SpikeFactor:            10,
+	SpikeMinVotes:          30,
+	MaxVotesPerAddress:     25,
+	MaxVotesPerTokenFamily: 5,
+	TokenFamilyLength:      8,
+	ReadingSpeed:           5,
+}
+
+var (
+	analysisConfig = DefaultVoteAnalysisConfig
+)
+
+// SetVoteAnalysisConfig replaces the configuration used by
+// AnalyzeVotes.
+func SetVoteAnalysisConfig(cfg VoteAnalysisConfig) {
+	analysisConfig = cfg
+}
+
+// AnalyzeVotes runs the vote-manipulation detection over the votes
+// cast within the baseline window before now. Flagged votes are
+// quarantined until a moderator reviews them. It returns the number
+// of newly quarantined votes.
+func AnalyzeVotes(now time.Time) (int, error) {
+	cfg := analysisConfig
+
+	votes, err := db.GetVotesSince(now.Add(-cfg.BaselineWindow))
+	if err != nil {
+		return 0, handleDatabaseError(err)
+	}
+
+	feedback := map[uint]*models.Feedback{}
+	for _, v := range votes {
+		if _, ok := feedback[v.FeedbackID]; ok {
+			continue
+		}
+
+		f, err := db.GetFeedback(v.FeedbackID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			feedback[v.FeedbackID] = nil
+		case err != nil:
+			return 0, handleDatabaseError(err)
+		default:
+			feedback[v.FeedbackID] = f
+		}
+	}
+
+	n := 0
+	defer publishChanges()
+	quarantined := map[uint][]*models.Vote{}
+	for _, flag := range detectVoteAnomalies(votes, feedback, cfg, now) {
+		ok, err := db.QuarantineVote(flag.vote, flag.reason)
+		if err != nil {
+			return n, handleDatabaseError(err)
+		}
+		if ok {
+			n++
+			quarantined[flag.vote.FeedbackID] = append(quarantined[flag.vote.FeedbackID], flag.vote)
+		}
+	}
+
+	for id, vs := range quarantined {
+		// Deleted feedback needs no attention.
+		if feedback[id] == nil {
+			continue
+		}
+
+		latest := uint(0)
+		for _, v := range vs {
+			latest = max(latest, v.ID)
+		}
+		if err := notifyModeration(feedback[id], len(vs), latest); err != nil {
+			return n, handleDatabaseError(err)
+		}
+	}
+
+	return n, nil
+}
+
+type voteFlag struct {
+	vote   *models.Vote
+	reason string
+}
+
+// detectVoteAnomalies returns the votes that look like vote
+// manipulation, along with the reason they were flagged. Votes
+// already quarantined or reviewed are never flagged. Each vote is
+// flagged at most once, for the first reason found.
+func detectVoteAnomalies(votes []*models.Vote, feedback map[uint]*models.Feedback, cfg VoteAnalysisConfig, now time.Time) []voteFlag {
+	flagged := map[uint]bool{}
+	flags := []voteFlag{}
+	flag := func(v *models.Vote, reason string) {
+		if v.Quarantined || v.Reviewed || flagged[v.ID] {
+			return
+		}
+		flagged[v.ID] = true
+		flags = append(flags, voteFlag{vote: v, reason: reason})
+	}
+
+	byFeedback := map[uint][]*models.Vote{}
+	for _, v := range votes {
+		byFeedback[v.FeedbackID] = append(byFeedback[v.FeedbackID], v)
+	}
+
+	ids := make([]uint, 0, len(byFeedback))
+	for id := range byFeedback {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		vs := byFeedback[id]
+
+		// Velocity spikes: votes in the spike window compared to
+		// the rate of votes in the rest of the baseline window.
+		spikeStart := now.Add(-cfg.SpikeWindow)
+		recent := []*models.Vote{}
+		for _, v := range vs {
+			if !v.CreatedAt.Before(spikeStart) {
+				recent = append(recent, v)
+			}
+		}
+		if len(recent) >= cfg.SpikeMinVotes {
+			baselineDuration := (cfg.BaselineWindow - cfg.SpikeWindow).Hours()
+			// At least one vote is assumed in the baseline, so that
+			// fresh feedback is not flagged for its first votes.
+			baselineRate := float64(max(len(vs)-len(recent), 1)) / baselineDuration
+			recentRate := float64(len(recent)) / cfg.SpikeWindow.Hours()
+			if recentRate > cfg.SpikeFactor*baselineRate {
+				for _, v := range recent {
+					flag(v, FlagVelocity)
+				}
+			}
+		}
+
+		// Many votes from one address or token family: the votes
+		// beyond the allowed amount are flagged.
+		byAddress := map[string]int{}
+		byFamily := map[string]int{}
+		for _, v := range vs {
+			if v.Address != "" {
+				byAddress[v.Address]++
+				if byAddress[v.Address] > cfg.MaxVotesPerAddress {
+					flag(v, FlagAddress)
+				}
+			}
+
+			if v.TokenFamily != "" {
+				byFamily[v.TokenFamily]++
+				if byFamily[v.TokenFamily] > cfg.MaxVotesPerTokenFamily {
+					flag(v, FlagTokenFamily)
+				}
+			}
+		}
+	}
+
+	// Votes faster than reading time: consecutive votes from one
+	// source on different feedback, cast quicker than the time
+	// needed to read the feedback voted on.
+	lastBySource := map[string]*models.Vote{}
+	for _, v := range votes {
+		source := v.Token
+		if source == "" {
+			source = v.Address
+		}
+		if source == "" {
+			continue
+		}
+
+		last, ok := lastBySource[source]
+		lastBySource[source] = v
+		if !ok || last.FeedbackID == v.FeedbackID {
+			continue
+		}
+
+		if v.CreatedAt.Sub(last.CreatedAt) < readingTime(feedback[v.FeedbackID], cfg.ReadingSpeed) {
+			flag(v, FlagReadingTime)
+		}
+	}
+
+	return flags
+}
+
+// tokenFamily returns the family of a voter token, that is its
+// prefix of the given length. Tokens shorter than the prefix have
+// no family.
+func tokenFamily(token string, length int) string {
+	if length <= 0 || len(token) < length {
+		return ""
+	}
+	return token[:length]
+}
+
+// readingTime returns the time needed to read the feedback at the
+// given speed, in words per second.
+func readingTime(f *models.Feedback, speed float64) time.Duration {
+	if f == nil || speed <= 0 {
+		return 0
+	}
+
+	words := len(strings.Fields(f.Feedback))
+	return time.Duration(float64(words) / speed * float64(time.Second))
+}
+
+// moderationForbidden returns the error for a representative
+// reviewing votes whose review is not assigned to them.
+func moderationForbidden(r *models.Representative, feedbackID uint) error {
+	uxe := uxerrors.New(fmt.Errorf("review of feedback %d not assigned to representative %d", feedbackID, r.ID))
+	uxe.Summary = "You are not allowed to access this resource"
+	uxe.Detail = "The review of the votes on this feedback is not assigned to you. Ask its assignee, or an administrator."
+	return uxerrors.NewErrors(http.StatusForbidden).Append(uxe)
+}
+
+// GetFlaggedFeedback returns the report of all feedback with votes
+// awaiting moderator review. Representatives only get the feedback
+// whose review is assigned to them, r is nil for administrators.
+func GetFlaggedFeedback(r *models.Representative) ([]*models.FlaggedFeedback, error) {
+	votes, err := db.GetFlaggedVotes()
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	assignments, err := db.GetModerationAssignments()
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	assignees := make(map[uint]uint, len(assignments))
+	for _, a := range assignments {
+		assignees[a.FeedbackID] = a.RepresentativeID
+	}
+
+	report := []*models.FlaggedFeedback{}
+	var current *models.FlaggedFeedback
+	for _, v := range votes {
+		assignee, assigned := assignees[v.FeedbackID]
+		if r != nil && (!assigned || assignee != r.ID) {
+			continue
+		}
+
+		if current == nil || current.Feedback.ID != v.FeedbackID {
+			f, err := db.GetFeedback(v.FeedbackID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Votes on deleted feedback no longer count towards
+				// any score, there is nothing left to review.
+				continue
+			}
+			if err != nil {
+				return nil, handleDatabaseError(err)
+			}
+
+			current = &models.FlaggedFeedback{Feedback: f, Votes: []*models.Vote{}}
+			if assigned {
+				current.AssigneeID = &assignee
+			}
+			report = append(report, current)
+		}
+
+		current.Votes = append(current.Votes, v)
+	}
+
+	return report, nil
+}
+
+// ReviewVote records the moderator decision on a quarantined vote.
+// Approved votes count towards the public score again.
+// Representatives only review the votes on the feedback whose review
+// is assigned to them, r is nil for administrators.
+func ReviewVote(id uint, approve bool, r *models.Representative) (*models.Vote, error) {
+	if r != nil {
+		if err := checkReviewAssignee(id, r); err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := db.ReviewVote(id, approve)
+	switch {
+	case err == nil:
+		publishChanges()
+		return v, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, voteNotFound(err)
+	case errors.Is(err, database.ErrVoteNotFlagged):
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The vote is not awaiting review"
+		uxe.Detail = "The vote you are reviewing is not quarantined, or was already reviewed. Refresh the report and try again."
+		return nil, uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	default:
+		return nil, handleDatabaseError(err)
+	}
+}
+
+// checkReviewAssignee returns an error unless the review of the votes
+// on the feedback of the vote is assigned to the representative.
+func checkReviewAssignee(id uint, r *models.Representative) error {
+	v, err := db.GetVote(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return voteNotFound(err)
+	}
+	if err != nil {
+		return handleDatabaseError(err)
+	}
+
+	a, err := db.GetModerationAssignment(v.FeedbackID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && a.RepresentativeID != r.ID) {
+		return moderationForbidden(r, v.FeedbackID)
+	}
+	return handleDatabaseError(err)
+}
+
+// voteNotFound returns the error for an unknown vote.
+func voteNotFound(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Vote not found"
+	uxe.Detail = "The vote you requested could not be found. Check the ID and try again."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
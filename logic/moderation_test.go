@@ -0,0 +1,180 @@
+/**
+ * file: logic/moderation_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func flaggedReasons(flags []voteFlag) map[uint]string {
+	m := map[uint]string{}
+	for _, f := range flags {
+		m[f.vote.ID] = f.reason
+	}
+	return m
+}
+
+// TestDetectVoteAnomaliesVelocity tests that a burst of votes on
+// a feedback with a quiet baseline is flagged.
+func TestDetectVoteAnomaliesVelocity(t *testing.T) {
+	now := time.Now()
+	cfg := DefaultVoteAnalysisConfig
+
+	votes := []*models.Vote{}
+	for i := 0; i < 5; i++ {
+		votes = append(votes, &models.Vote{ID: uint(len(votes) + 1), FeedbackID: 1, CreatedAt: now.Add(-time.Duration(i+2) * time.Hour)})
+	}
+	for i := 0; i < cfg.SpikeMinVotes; i++ {
+		votes = append(votes, &models.Vote{ID: uint(len(votes) + 1), FeedbackID: 1, CreatedAt: now.Add(-time.Duration(i) * time.Second)})
+	}
+
+	flags := flaggedReasons(detectVoteAnomalies(votes, nil, cfg, now))
+	assert.Len(t, flags, cfg.SpikeMinVotes, "only the burst should be flagged")
+	for _, v := range votes[5:] {
+		assert.Equal(t, FlagVelocity, flags[v.ID], "votes in the burst should be flagged for velocity")
+	}
+}
+
+// TestDetectVoteAnomaliesSources tests that votes beyond the
+// allowed amount per address and token family are flagged.
+func TestDetectVoteAnomaliesSources(t *testing.T) {
+	now := time.Now()
+	cfg := DefaultVoteAnalysisConfig
+	cfg.ReadingSpeed = 0
+
+	votes := []*models.Vote{}
+	for i := 0; i < cfg.MaxVotesPerTokenFamily+2; i++ {
+		votes = append(votes, &models.Vote{
+			ID:          uint(len(votes) + 1),
+			FeedbackID:  1,
+			Token:       fmt.Sprintf("family01-%d", i),
+			TokenFamily: "family01",
+			Address:     fmt.Sprintf("10.0.0.%d", i),
+			CreatedAt:   now.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+	for i := 0; i < cfg.MaxVotesPerAddress+1; i++ {
+		votes = append(votes, &models.Vote{
+			ID:         uint(len(votes) + 1),
+			FeedbackID: 2,
+			Address:    "10.0.1.1",
+			CreatedAt:  now.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+
+	flags := flaggedReasons(detectVoteAnomalies(votes, nil, cfg, now))
+	assert.Len(t, flags, 3, "only the votes beyond the limits should be flagged")
+	assert.Equal(t, FlagTokenFamily, flags[uint(cfg.MaxVotesPerTokenFamily+1)])
+	assert.Equal(t, FlagTokenFamily, flags[uint(cfg.MaxVotesPerTokenFamily+2)])
+	assert.Equal(t, FlagAddress, flags[uint(len(votes))])
+}
+
+// TestDetectVoteAnomaliesReadingTime tests that votes cast by one
+// voter faster than the time needed to read the feedback are
+// flagged, and that reviewed votes are never flagged again.
+func TestDetectVoteAnomaliesReadingTime(t *testing.T) {
+	now := time.Now()
+	cfg := DefaultVoteAnalysisConfig
+	feedback := map[uint]*models.Feedback{
+		1: {ID: 1, Feedback: "a short feedback of exactly ten words for testing purposes"},
+		2: {ID: 2, Feedback: "another short feedback of exactly ten words for testing purposes"},
+		3: {ID: 3, Feedback: "yet another feedback of exactly ten words for testing purposes"},
+	}
+
+	votes := []*models.Vote{
+		{ID: 1, FeedbackID: 1, Token: "voter", CreatedAt: now.Add(-time.Minute)},
+		{ID: 2, FeedbackID: 2, Token: "voter", CreatedAt: now.Add(-time.Minute + time.Second)},
+		{ID: 3, FeedbackID: 3, Token: "voter", CreatedAt: now},
+		{ID: 4, FeedbackID: 1, Token: "other", CreatedAt: now.Add(-time.Minute)},
+		{ID: 5, FeedbackID: 2, Token: "other", CreatedAt: now.Add(-time.Minute + time.Second), Reviewed: true},
+	}
+
+	flags := flaggedReasons(detectVoteAnomalies(votes, feedback, cfg, now))
+	assert.Equal(t, map[uint]string{2: FlagReadingTime}, flags, "only the vote cast without reading should be flagged")
+}
+
+// TestModerationAssignment tests that the review of the votes on a
+// feedback is assigned to a single representative following it, who
+// alone is notified and may review them, until all are reviewed.
+func TestModerationAssignment(t *testing.T) {
+	d := setupTestDatabase(t)
+
+	alex, err := CreateRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org", Faculties: []string{"LINFO"}})
+	require.NoError(t, err, "creating the representative should not fail")
+	sam, err := CreateRepresentative(&models.Representative{Name: "Sam", Email: "sam@example.org", Courses: []string{"LINFO1101"}})
+	require.NoError(t, err, "creating the representative should not fail")
+	lepl, err := CreateRepresentative(&models.Representative{Name: "Kim", Email: "kim@example.org", Faculties: []string{"LEPL"}})
+	require.NoError(t, err, "creating the representative should not fail")
+
+	feedback := []*models.Feedback{}
+	for range 2 {
+		f, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err, "adding feedback should not fail")
+		_, err = UpdateFeedbackUpvotes(f.ID, 1, models.VoteSource{})
+		require.NoError(t, err, "voting should not fail")
+		feedback = append(feedback, f)
+	}
+
+	votes, err := d.GetVotesSince(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, votes, 2)
+	for i, v := range votes {
+		_, err := d.QuarantineVote(v, FlagVelocity)
+		require.NoError(t, err, "quarantining the vote should not fail")
+		require.NoError(t, notifyModeration(feedback[i], 1, v.ID), "notifying the moderation should not fail")
+	}
+	require.NoError(t, notifyModeration(feedback[0], 1, votes[0].ID), "notifying the moderation again should not fail")
+
+	// The first review goes to the earliest representative, the
+	// second one to the representative with the fewest reviews.
+	for r, n := range map[*models.Representative]int{alex: 1, sam: 1, lepl: 0} {
+		inbox, err := GetInbox(r.ID, false)
+		require.NoError(t, err, "getting the inbox should not fail")
+		assert.Len(t, inbox.Notifications, n, "only the assignee should be notified, once, for %s", r.Name)
+	}
+
+	report, err := GetFlaggedFeedback(nil)
+	require.NoError(t, err, "getting the report should not fail")
+	require.Len(t, report, 2)
+	require.NotNil(t, report[0].AssigneeID)
+	assert.Equal(t, alex.ID, *report[0].AssigneeID)
+	require.NotNil(t, report[1].AssigneeID)
+	assert.Equal(t, sam.ID, *report[1].AssigneeID)
+
+	report, err = GetFlaggedFeedback(sam)
+	require.NoError(t, err, "getting the report should not fail")
+	require.Len(t, report, 1, "representatives should only get their assigned reviews")
+	assert.Equal(t, feedback[1].ID, report[0].Feedback.ID)
+	report, err = GetFlaggedFeedback(lepl)
+	require.NoError(t, err, "getting the report should not fail")
+	assert.Empty(t, report)
+
+	_, err = ReviewVote(votes[0].ID, true, sam)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = ReviewVote(votes[0].ID, true, lepl)
+	assertStatus(t, http.StatusForbidden, err)
+	_, err = ReviewVote(42, true, sam)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = ReviewVote(votes[0].ID, true, alex)
+	require.NoError(t, err, "the assignee should review the vote")
+	_, err = ReviewVote(votes[1].ID, false, nil)
+	require.NoError(t, err, "administrators should review any vote")
+
+	loads, err := d.GetModerationLoads()
+	require.NoError(t, err)
+	assert.Empty(t, loads, "reviewed feedback should no longer be assigned")
+	_, err = d.GetModerationAssignment(feedback[0].ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,131 @@
+/**
+ * file: logic/notify.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the notifications sent to the
+ * representatives: the periodic digests of the
+ * feedback on their courses, and the alerts routed
+ * to them by email.
+ */
+
+package logic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
+)
+
+const notifyTimeout time.Duration = time.Minute
+
+var (
+	notifier notify.Channel
+)
+
+// SetNotifyChannel sets the channel the digests and email alerts
+// are sent through. Nothing is sent until it is set.
+func SetNotifyChannel(c notify.Channel) {
+	notifier = c
+}
+
+func sendNotification(m *notify.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	return notifier.Send(ctx, m)
+}
+
+// SendDigests sends the digests due at the given time, and returns
+// the number of digests sent. Representatives with nothing new are
+// skipped until their next digest.
+func SendDigests(now time.Time) (int, error) {
+	if notifier == nil {
+		return 0, nil
+	}
+
+	representatives, err := db.GetRepresentatives()
+	if err != nil {
+		return 0, handleDatabaseError(err)
+	}
+
+	var feedback []*models.Feedback
+	var errs []error
+	sent := 0
+	for _, r := range representatives {
+		if !r.DigestDue(now) {
+			continue
+		}
+
+		if feedback == nil {
+			if feedback, err = db.GetAllFeedback(); err != nil {
+				return sent, handleDatabaseError(err)
+			}
+		}
+
+		since := now.Add(-r.DigestFrequency.Period())
+		if r.LastDigestAt != nil {
+			since = *r.LastDigestAt
+		}
+
+		d := notify.BuildDigest(r, feedback, since, now, notify.DigestLimit)
+		if !d.Empty() {
+			m, err := d.Message()
+			if err == nil {
+				err = sendNotification(m)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			sent++
+		}
+
+		if err := db.SetDigestSent(r.ID, now); err != nil {
+			return sent, handleDatabaseError(err)
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// The EmailAlertChannel structure routes the alerts by email to the
+// representatives following the feedback. Failures are reported to
+// OnError, if set.
+type EmailAlertChannel struct {
+	OnError func(error)
+}
+
+func (c EmailAlertChannel) SendAlert(a *models.Alert) {
+	if err := c.sendAlert(a); err != nil && c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+func (c EmailAlertChannel) sendAlert(a *models.Alert) error {
+	if notifier == nil {
+		return nil
+	}
+
+	representatives, err := db.GetRepresentatives()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, r := range representatives {
+		if !r.Follows(a.Feedback) {
+			continue
+		}
+
+		m, err := notify.AlertMessage(r, a)
+		if err == nil {
+			err = sendNotification(m)
+		}
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
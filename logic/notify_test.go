@@ -0,0 +1,117 @@
+/**
+ * file: logic/notify_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messageRecorder is a notification channel recording the messages
+// it is sent.
+type messageRecorder struct {
+	mu       sync.Mutex
+	messages []*notify.Message
+}
+
+func (r *messageRecorder) Send(_ context.Context, m *notify.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func (r *messageRecorder) received() []*notify.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*notify.Message(nil), r.messages...)
+}
+
+func setupTestNotifier(t *testing.T) *messageRecorder {
+	recorder := new(messageRecorder)
+	SetNotifyChannel(recorder)
+	t.Cleanup(func() { SetNotifyChannel(nil) })
+	return recorder
+}
+
+// TestSendDigests tests that digests are sent to the representatives
+// following feedback, at their frequency.
+func TestSendDigests(t *testing.T) {
+	setupTestDatabase(t)
+	recorder := setupTestNotifier(t)
+
+	reps := []*models.Representative{
+		{Name: "Daily", Email: "daily@example.org", Courses: []string{"LINFO1101"}, DigestFrequency: models.DigestFrequencyDaily},
+		{Name: "Weekly", Email: "weekly@example.org", Language: "fr", Faculties: []string{"LINFO"}},
+		{Name: "Off", Email: "off@example.org", DigestFrequency: models.DigestFrequencyOff},
+		{Name: "Other", Email: "other@example.org", Faculties: []string{"LEPL"}},
+	}
+	for _, r := range reps {
+		_, err := CreateRepresentative(r)
+		require.NoError(t, err, "creating the representative %s should not fail", r.Name)
+	}
+	_, err := CreateRepresentative(&models.Representative{Name: "Twin", Email: "daily@example.org"})
+	assertStatus(t, http.StatusConflict, err)
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+
+	now := time.Now().Add(time.Minute)
+	sent, err := SendDigests(now)
+	require.NoError(t, err, "sending the digests should not fail")
+	assert.Equal(t, 2, sent, "only the representatives following the feedback should get a digest")
+
+	messages := recorder.received()
+	require.Len(t, messages, 2)
+	assert.Equal(t, []string{"daily@example.org"}, messages[0].To)
+	assert.Equal(t, []string{"weekly@example.org"}, messages[1].To)
+	assert.Contains(t, messages[1].Subject, "Votre résumé", "the digest should be in the language of the representative")
+
+	sent, err = SendDigests(now.Add(time.Hour))
+	require.NoError(t, err, "sending the digests should not fail")
+	assert.Zero(t, sent, "digests should not be sent before their period")
+
+	sent, err = SendDigests(now.Add(25 * time.Hour))
+	require.NoError(t, err, "sending the digests should not fail")
+	assert.Equal(t, 1, sent, "daily digests should be sent the next day")
+	assert.NotContains(t, recorder.received()[2].Text, "New feedback", "feedback should only be new once")
+}
+
+// TestEmailAlertChannel tests that alerts are sent to the
+// representatives following the feedback.
+func TestEmailAlertChannel(t *testing.T) {
+	setupTestDatabase(t)
+	recorder := setupTestNotifier(t)
+
+	for _, r := range []*models.Representative{
+		{Name: "Follower", Email: "follower@example.org", Courses: []string{"LINFO1101"}},
+		{Name: "Other", Email: "other@example.org", Courses: []string{"LEPL1102"}},
+	} {
+		_, err := CreateRepresentative(r)
+		require.NoError(t, err, "creating the representative should not fail")
+	}
+
+	var failures []error
+	EmailAlertChannel{OnError: func(err error) { failures = append(failures, err) }}.SendAlert(&models.Alert{
+		Rule:     &models.AlertRule{Name: "new"},
+		Feedback: newTestFeedback(),
+	})
+
+	assert.Empty(t, failures)
+	messages := recorder.received()
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"follower@example.org"}, messages[0].To)
+	assert.Equal(t, "[LINFO1101] Alert: new", messages[0].Subject)
+}
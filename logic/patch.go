@@ -0,0 +1,580 @@
+/**
+ * file: logic/patch.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the partial updates of feedback,
+ * using JSON Merge Patch (RFC 7396) and JSON Patch
+ * (RFC 6902) documents.
+ */
+
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+)
+
+// PatchKind is the media type of a patch document.
+type PatchKind string
+
+const (
+	PatchKindMerge PatchKind = "application/merge-patch+json"
+	PatchKindJSON  PatchKind = "application/json-patch+json"
+)
+
+// mutableFeedbackFields are the fields of the feedback clients may
+// change with a patch. All other fields are maintained by the server.
+var mutableFeedbackFields = map[string]bool{
+	"Course":   true,
+	"Feedback": true,
+	"Tags":     true,
+}
+
+var (
+	errInvalidPatch error = errors.New("invalid patch")
+	errPatchTest    error = errors.New("patch test failed")
+)
+
+// The jsonPatchOperation structure is one operation of a JSON Patch
+// document.
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// PatchFeedback applies the patch document of the given kind to the
+// feedback identified by id. Only the mutable fields of the feedback
+// may be changed by the patch, and the patched feedback is validated
+// before being stored. The update only happens if the feedback
+// matches one of the etags, if any are given.
+func PatchFeedback(id uint, kind PatchKind, patch []byte, etags []string) (*models.Feedback, error) {
+	if kind != PatchKindMerge && kind != PatchKindJSON {
+		uxe := uxerrors.New(fmt.Errorf("unsupported patch media type %q", kind))
+		uxe.Summary = "The patch format is not supported"
+		uxe.Detail = fmt.Sprintf("Feedback can only be patched with %s or %s documents. Set the Content-Type of your request accordingly and try again.", PatchKindMerge, PatchKindJSON)
+		return nil, uxerrors.NewErrors(http.StatusUnsupportedMediaType).Append(uxe)
+	}
+
+	current, err := db.GetFeedback(id)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	patched, err := patchFeedback(current, kind, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	patched.Tags = patched.Tags.Normalize()
+	if err := validators.ValidateFeedback(patched); err != nil {
+		return nil, err
+	}
+
+	// The patch was applied on the feedback as read above. The update
+	// must not go through if the feedback changed in the meantime,
+	// even when the client accepts any version.
+	matches := ifMatch(etags)
+	precondition := func(f *models.Feedback) error {
+		if err := matches(f); err != nil {
+			return err
+		}
+		if f == nil || f.Version != current.Version {
+			return ifMatch([]string{current.ETag()})(f)
+		}
+		return nil
+	}
+
+	r, err := db.UpdateFeedback(patched, precondition)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	publishChanges()
+	return r, nil
+}
+
+// patchFeedback returns a copy of the feedback with the patch
+// applied. It fails if the patch changes any field that is not
+// mutable.
+func patchFeedback(f *models.Feedback, kind PatchKind, patch []byte) (*models.Feedback, error) {
+	original, err := feedbackDocument(f)
+	if err != nil {
+		return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+
+	var doc any
+	switch kind {
+	case PatchKindMerge:
+		var p any
+		if err = decodeJSON(patch, &p); err == nil {
+			doc = applyMergePatch(cloneDocument(original), p)
+		}
+	case PatchKindJSON:
+		var ops []jsonPatchOperation
+		if err = decodeJSON(patch, &ops); err == nil {
+			doc, err = applyJSONPatch(cloneDocument(original), ops)
+		}
+	}
+	if err != nil {
+		return nil, patchError(err)
+	}
+
+	patchedFields, ok := doc.(map[string]any)
+	if !ok {
+		return nil, patchError(fmt.Errorf("%w: the patched feedback is not an object", errInvalidPatch))
+	}
+
+	if errs := immutableFieldErrors(original, patchedFields); len(errs.Errors) != 0 {
+		return nil, errs
+	}
+
+	b, err := json.Marshal(patchedFields)
+	if err != nil {
+		return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+
+	patched := new(models.Feedback)
+	if err := json.Unmarshal(b, patched); err != nil {
+		return nil, patchError(fmt.Errorf("%w: %w", errInvalidPatch, err))
+	}
+
+	r := *f
+	r.Course = patched.Course
+	r.Feedback = patched.Feedback
+	r.Tags = patched.Tags
+	return &r, nil
+}
+
+// feedbackDocument returns the feedback as a JSON document, as it is
+// exposed to clients.
+func feedbackDocument(f *models.Feedback) (map[string]any, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]any{}
+	return doc, decodeJSON(b, &doc)
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as is so
+// that large vote counts are not rounded.
+func decodeJSON(b []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidPatch, err)
+	}
+	if d.More() {
+		return fmt.Errorf("%w: unexpected data after the patch", errInvalidPatch)
+	}
+
+	return nil
+}
+
+// immutableFieldErrors returns an error for each field that is not
+// mutable and differs between the original and the patched
+// document.
+func immutableFieldErrors(original, patched map[string]any) uxerrors.Errors {
+	fields := map[string]bool{}
+	for k := range original {
+		fields[k] = true
+	}
+	for k := range patched {
+		fields[k] = true
+	}
+
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	errs := uxerrors.NewErrors(http.StatusBadRequest)
+	for _, k := range names {
+		o, inOriginal := original[k]
+		p, inPatched := patched[k]
+		if mutableFeedbackFields[k] || (inOriginal == inPatched && jsonEqual(o, p)) {
+			continue
+		}
+
+		uxe := uxerrors.New(fmt.Errorf("field %q is not mutable", k))
+		uxe.Summary = fmt.Sprintf("The %s field cannot be changed", k)
+		uxe.Detail = fmt.Sprintf("The %s field is maintained by the server and cannot be changed. Remove it from your patch and try again.", k)
+		errs = errs.Append(uxe)
+	}
+
+	return errs
+}
+
+// patchError returns the user-facing error for a patch that could
+// not be applied.
+func patchError(err error) error {
+	uxe := uxerrors.New(err)
+	if errors.Is(err, errPatchTest) {
+		uxe.Summary = "The feedback does not match the test of your patch"
+		uxe.Detail = "A test operation of your patch failed, so no change was made. Refresh the feedback and try again."
+		return uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	}
+
+	uxe.Summary = "Could not apply your patch"
+	uxe.Detail = "The patch you gave could not be parsed or applied. This usually means that you did not respect the specification. Check your patch and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// cloneDocument returns a deep copy of a decoded JSON document.
+func cloneDocument(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = cloneDocument(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = cloneDocument(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// applyMergePatch applies a JSON Merge Patch to the document, as
+// specified by RFC 7396.
+func applyMergePatch(doc any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	target, ok := doc.(map[string]any)
+	if !ok {
+		target = map[string]any{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(target, k)
+		} else {
+			target[k] = applyMergePatch(target[k], v)
+		}
+	}
+
+	return target
+}
+
+// applyJSONPatch applies the operations of a JSON Patch to the
+// document, as specified by RFC 6902. The operations are applied in
+// order, and the patch fails as a whole if any operation fails.
+func applyJSONPatch(doc any, ops []jsonPatchOperation) (any, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyJSONPatchOperation(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func applyJSONPatchOperation(doc any, op jsonPatchOperation) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: missing path", errInvalidPatch)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := func() (any, error) {
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: missing value", errInvalidPatch)
+		}
+		var v any
+		return v, decodeJSON(*op.Value, &v)
+	}
+	from := func() ([]string, error) {
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: missing from", errInvalidPatch)
+		}
+		return parsePointer(*op.From)
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+	case "remove":
+		doc, _, err := pointerRemove(doc, path)
+		return doc, err
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = pointerRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+	case "move":
+		src, err := from()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(src) && reflect.DeepEqual(path[:len(src)], src) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", errInvalidPatch)
+		}
+		doc, v, err := pointerRemove(doc, src)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+	case "copy":
+		src, err := from()
+		if err != nil {
+			return nil, err
+		}
+		v, err := pointerGet(doc, src)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, cloneDocument(v))
+	case "test":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errPatchTest, err)
+		}
+		if !jsonEqual(actual, v) {
+			return nil, fmt.Errorf("%w: value at %q differs", errPatchTest, *op.Path)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", errInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped
+// reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: pointer %q does not start with a slash", errInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex returns the index referenced by the token in an array
+// of the given length. The "-" token references the end of the array
+// and is only allowed when end is true.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%w: invalid array index %q", errInvalidPatch, token)
+	}
+
+	limit := length - 1
+	if end {
+		limit = length
+	}
+	if i > limit {
+		return 0, fmt.Errorf("%w: array index %d out of bounds", errInvalidPatch, i)
+	}
+
+	return i, nil
+}
+
+// pointerGet returns the value referenced by the path.
+func pointerGet(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			e, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", errInvalidPatch, token)
+			}
+			doc = e
+		case []any:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%w: cannot reference %q in a scalar", errInvalidPatch, token)
+		}
+	}
+
+	return doc, nil
+}
+
+// pointerAdd adds the value at the path, and returns the document.
+// Members of objects are replaced, elements of arrays are inserted.
+func pointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		v[token] = value
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(token, len(v), true)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v[:i], append([]any{value}, v[i:]...)...)
+		return pointerSet(doc, path[:len(path)-1], v)
+	default:
+		return nil, fmt.Errorf("%w: cannot add %q to a scalar", errInvalidPatch, token)
+	}
+}
+
+// pointerRemove removes the value at the path, and returns the
+// document along with the removed value.
+func pointerRemove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := path[len(path)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		removed, ok := v[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: member %q does not exist", errInvalidPatch, token)
+		}
+		delete(v, token)
+		return doc, removed, nil
+	case []any:
+		i, err := arrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		removed := v[i]
+		v = append(v[:i:i], v[i+1:]...)
+		doc, err = pointerSet(doc, path[:len(path)-1], v)
+		return doc, removed, err
+	default:
+		return nil, nil, fmt.Errorf("%w: cannot remove %q from a scalar", errInvalidPatch, token)
+	}
+}
+
+// pointerSet replaces the value at the path, which must exist, and
+// returns the document. It is used to store arrays that were grown
+// or shrunk.
+func pointerSet(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		v[token] = value
+	case []any:
+		i, err := arrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = value
+	}
+
+	return doc, nil
+}
+
+// jsonEqual compares two decoded JSON values. Numbers are equal if
+// they have the same value, regardless of their representation.
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
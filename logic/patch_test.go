@@ -0,0 +1,161 @@
+/**
+ * file: logic/patch_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patchedFeedback() *models.Feedback {
+	return &models.Feedback{
+		ID:        42,
+		Course:    "LINFO1101",
+		Feedback:  "The exercises sessions are great.",
+		Tags:      models.Tags{},
+		Upvotes:   1 << 53,
+		Downvotes: 3,
+		Version:   7,
+	}
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	require.Error(t, err)
+	es, ok := err.(uxerrors.Errors)
+	require.True(t, ok, "the error should be a user-facing error")
+	assert.Equal(t, status, es.Status)
+}
+
+// TestPatchFeedbackMerge tests that merge patches change the
+// mutable fields only.
+func TestPatchFeedbackMerge(t *testing.T) {
+	f := patchedFeedback()
+
+	patched, err := patchFeedback(f, PatchKindMerge, []byte(`{"Course": "LEPL1402", "Upvotes": 9007199254740992}`))
+	require.NoError(t, err, "a merge patch on mutable fields should apply")
+
+	expected := patchedFeedback()
+	expected.Course = "LEPL1402"
+	assert.Equal(t, expected, patched)
+	assert.Equal(t, patchedFeedback(), f, "the original feedback should be left untouched")
+}
+
+// TestPatchFeedbackJSON tests that JSON patches are applied in
+// order, with their tests.
+func TestPatchFeedbackJSON(t *testing.T) {
+	f := patchedFeedback()
+
+	patch := `[
+		{"op": "test", "path": "/Course", "value": "LINFO1101"},
+		{"op": "replace", "path": "/Feedback", "value": "The exercises sessions are too short."},
+		{"op": "copy", "from": "/Course", "path": "/Copy"},
+		{"op": "remove", "path": "/Copy"}
+	]`
+	patched, err := patchFeedback(f, PatchKindJSON, []byte(patch))
+	require.NoError(t, err, "a JSON patch on mutable fields should apply")
+
+	expected := patchedFeedback()
+	expected.Feedback = "The exercises sessions are too short."
+	assert.Equal(t, expected, patched)
+}
+
+// TestPatchFeedbackTags tests that tags can be added and removed
+// with patches.
+func TestPatchFeedbackTags(t *testing.T) {
+	f := patchedFeedback()
+	f.Tags = models.Tags{"exam"}
+
+	patch := `[
+		{"op": "add", "path": "/Tags/-", "value": "schedule-conflict"},
+		{"op": "remove", "path": "/Tags/0"}
+	]`
+	patched, err := patchFeedback(f, PatchKindJSON, []byte(patch))
+	require.NoError(t, err, "a JSON patch on the tags should apply")
+	assert.Equal(t, models.Tags{"schedule-conflict"}, patched.Tags)
+}
+
+// TestPatchFeedbackImmutable tests that patches changing fields
+// maintained by the server are rejected.
+func TestPatchFeedbackImmutable(t *testing.T) {
+	f := patchedFeedback()
+
+	patches := map[PatchKind]string{
+		PatchKindMerge: `{"Upvotes": 0, "Version": null}`,
+		PatchKindJSON:  `[{"op": "add", "path": "/Extra", "value": true}]`,
+	}
+	for kind, patch := range patches {
+		_, err := patchFeedback(f, kind, []byte(patch))
+		assertStatus(t, http.StatusBadRequest, err)
+	}
+
+	_, err := patchFeedback(f, PatchKindMerge, []byte(`{"Upvotes": 0, "Version": null}`))
+	assert.Len(t, err.(uxerrors.Errors).Errors, 2, "each immutable field should be reported")
+}
+
+// TestPatchFeedbackTestFailed tests that failed test operations
+// are reported as a conflict.
+func TestPatchFeedbackTestFailed(t *testing.T) {
+	patch := `[{"op": "test", "path": "/Downvotes", "value": 4}, {"op": "replace", "path": "/Course", "value": "LEPL1402"}]`
+	_, err := patchFeedback(patchedFeedback(), PatchKindJSON, []byte(patch))
+	assertStatus(t, http.StatusConflict, err)
+}
+
+// TestPatchFeedbackInvalid tests that malformed patches are
+// rejected.
+func TestPatchFeedbackInvalid(t *testing.T) {
+	patches := map[string]PatchKind{
+		`{"Course": `:       PatchKindMerge,
+		`["LEPL1402"]`:      PatchKindMerge,
+		`{"op": "replace"}`: PatchKindJSON,
+		`[{"op": "replace", "path": "/Missing", "value": 1}]`:          PatchKindJSON,
+		`[{"op": "frobnicate", "path": "/Course"}]`:                    PatchKindJSON,
+		`[{"op": "replace", "path": "Course", "value": "LEPL1402"}]`:   PatchKindJSON,
+		`[{"op": "replace", "path": "/Course", "value": 1402}]`:        PatchKindJSON,
+		`[{"op": "add", "path": "/Course/0", "value": "LEPL1402"}]`:    PatchKindJSON,
+		`[{"op": "move", "from": "/Course", "path": "/Course/child"}]`: PatchKindJSON,
+	}
+	for patch, kind := range patches {
+		_, err := patchFeedback(patchedFeedback(), kind, []byte(patch))
+		assertStatus(t, http.StatusBadRequest, err)
+	}
+}
+
+// TestApplyMergePatch tests the merge of nested documents, as
+// specified by RFC 7396.
+func TestApplyMergePatch(t *testing.T) {
+	doc := map[string]any{"a": "b", "c": map[string]any{"d": "e", "f": "g"}}
+	patch := map[string]any{"a": "z", "c": map[string]any{"f": nil}}
+
+	assert.Equal(t, map[string]any{"a": "z", "c": map[string]any{"d": "e"}}, applyMergePatch(doc, patch))
+	assert.Equal(t, []any{"x"}, applyMergePatch(doc, []any{"x"}), "non object patches replace the document")
+}
+
+// TestApplyJSONPatchArrays tests the operations on arrays, as
+// specified by RFC 6902.
+func TestApplyJSONPatchArrays(t *testing.T) {
+	var doc any
+	require.NoError(t, decodeJSON([]byte(`{"a/b": ["x", "y"], "~": []}`), &doc))
+
+	var ops []jsonPatchOperation
+	require.NoError(t, decodeJSON([]byte(`[
+		{"op": "add", "path": "/a~1b/1", "value": "w"},
+		{"op": "add", "path": "/a~1b/-", "value": "z"},
+		{"op": "remove", "path": "/a~1b/0"},
+		{"op": "move", "from": "/a~1b/2", "path": "/~0/0"},
+		{"op": "replace", "path": "/a~1b/0", "value": "v"}
+	]`), &ops))
+
+	doc, err := applyJSONPatch(doc, ops)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a/b": []any{"v", "y"}, "~": []any{"z"}}, doc)
+}
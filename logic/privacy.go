@@ -0,0 +1,275 @@
+/**
+ * file: logic/privacy.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the privacy layer of the public
+ * statistics. Statistics are made differentially
+ * private with geometric noise, within a privacy
+ * budget per course or faculty and per term, and are
+ * suppressed if they cover too little feedback. They
+ * are released on a schedule, never on request, so
+ * that clients cannot spend the budget.
+ *
+ * The privacy unit is a single contribution: a
+ * feedback or a vote.
+ */
+
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+// statsReleases is the number of values released with statistics,
+// each getting an equal share of the privacy loss: the counts of
+// feedback, upvotes, downvotes and responded feedback, and the
+// histograms of the statuses, scores and tags.
+const statsReleases float64 = 7
+
+// tagSensitivity is the sensitivity of the histogram of the tags, as
+// a feedback carries up to 5 tags.
+const tagSensitivity float64 = 5
+
+// The PrivacyConfig structure configures the privacy layer of the
+// public statistics.
+type PrivacyConfig struct {
+	// Epsilon is the privacy loss of each release of statistics.
+	// Lower values add more noise.
+	Epsilon float64
+
+	// Budget is the total privacy loss allowed per course or
+	// faculty and per term.
+	Budget float64
+
+	// K is the number of feedback below which statistics are
+	// suppressed.
+	K int64
+
+	// Interval is the time between two releases of the statistics
+	// of a course or faculty during a term.
+	Interval time.Duration
+}
+
+// DefaultPrivacyConfig is the configuration of the privacy layer,
+// unless changed by SetPrivacyConfig.
+var DefaultPrivacyConfig = PrivacyConfig{Epsilon: 1, Budget: 20, K: 10, Interval: 7 * 24 * time.Hour}
+
+var privacy = DefaultPrivacyConfig
+
+// SetPrivacyConfig sets the configuration of the privacy layer of
+// the public statistics.
+func SetPrivacyConfig(c PrivacyConfig) {
+	privacy = c
+}
+
+// uniform returns a sample of the uniform distribution over (0, 1],
+// from a cryptographically secure source.
+func uniform() (float64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return float64(binary.BigEndian.Uint64(b[:])>>11+1) / (1 << 53), nil
+}
+
+// geometricNoise returns a sample of the two-sided geometric
+// distribution, the discrete counterpart of the Laplace distribution,
+// calibrated for the privacy loss of a value of the given
+// sensitivity. It is the difference of two geometric samples.
+func geometricNoise(epsilon, sensitivity float64) (int64, error) {
+	alpha := math.Exp(-epsilon / sensitivity)
+
+	var noise [2]int64
+	for i := range noise {
+		u, err := uniform()
+		if err != nil {
+			return 0, err
+		}
+		noise[i] = int64(math.Floor(math.Log(u) / math.Log(alpha)))
+	}
+	return noise[0] - noise[1], nil
+}
+
+// privatizeStats adds noise to the statistics, for the privacy loss
+// epsilon. Median response times cannot be released privately and
+// are removed. Statistics covering less than privacy.K feedback,
+// after noise, are suppressed, as are the tags of less than
+// privacy.K feedback, so that rare tags cannot be singled out.
+func privatizeStats(s *models.Stats, epsilon float64) error {
+	var err error
+	noisy := func(v *int64, sensitivity float64) {
+		if err != nil {
+			return
+		}
+		var noise int64
+		noise, err = geometricNoise(epsilon/statsReleases, sensitivity)
+		*v = max(*v+noise, 0)
+	}
+
+	noisy(&s.Feedback, 1)
+	noisy(&s.Upvotes, 1)
+	noisy(&s.Downvotes, 1)
+	noisy(&s.Responded, 1)
+	for status, count := range s.Statuses {
+		noisy(&count, 1)
+		s.Statuses[status] = count
+	}
+	// A vote may move feedback from a bucket to another.
+	for _, b := range s.Scores {
+		noisy(&b.Feedback, 2)
+	}
+	tags := make([]*models.TagCount, 0, len(s.Tags))
+	for _, t := range s.Tags {
+		noisy(&t.Count, tagSensitivity)
+		if t.Count >= privacy.K {
+			tags = append(tags, t)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.Tags = topTags(tags)
+
+	s.MedianResponseSeconds = nil
+	s.UpvoteRatio = nil
+	if votes := s.Upvotes + s.Downvotes; votes != 0 {
+		ratio := float64(s.Upvotes) / float64(votes)
+		s.UpvoteRatio = &ratio
+	}
+
+	s.Privacy = &models.StatsPrivacy{Epsilon: epsilon}
+	if s.Feedback < privacy.K {
+		s.Feedback, s.Upvotes, s.Downvotes, s.Responded = 0, 0, 0, 0
+		s.UpvoteRatio, s.Scores, s.Statuses, s.Tags = nil, nil, nil, nil
+		s.Privacy.Suppressed = true
+	}
+	return nil
+}
+
+// statsReleaseDue returns true if the statistics of the term should
+// be released again, given their last release. The statistics of the
+// current term are released every privacy.Interval, and those of a
+// past term once more after it ended.
+func statsReleaseDue(last *models.StatsRelease, until, now time.Time) bool {
+	switch {
+	case last == nil:
+		return true
+	case now.Before(until):
+		return now.Sub(last.CreatedAt) >= privacy.Interval
+	default:
+		return last.CreatedAt.Before(until)
+	}
+}
+
+// releaseStats makes the statistics of the course or faculty for the
+// term private, and releases them if the privacy budget allows it.
+func releaseStats(scope models.StatsScope, code, term string, since, until, now time.Time) (bool, error) {
+	last, err := db.GetLatestStatsRelease(scope, code, term)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if !statsReleaseDue(last, until, now) {
+		return false, nil
+	}
+
+	s, err := db.GetStats(&models.StatsQuery{Scope: scope, Code: code, Since: &since, Until: &until})
+	if err != nil {
+		return false, err
+	}
+	completeStats(s)
+	if err := privatizeStats(s, privacy.Epsilon); err != nil {
+		return false, err
+	}
+	s.Privacy.Term = term
+
+	r := &models.StatsRelease{Scope: scope, Code: code, Term: term, Stats: s, CreatedAt: now}
+	return db.ReleaseStats(r, privacy.Epsilon, privacy.Budget)
+}
+
+// ReleaseStats releases the private statistics of the courses and
+// faculties with feedback during the current term, and releases those
+// of the previous term a last time once it ended. It returns the
+// number of statistics released.
+func ReleaseStats(now time.Time) (int, error) {
+	current := models.TermOf(now)
+	start, _, err := models.TermBounds(current)
+	if err != nil {
+		return 0, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+
+	released := 0
+	for _, term := range []string{models.TermOf(start.Add(-time.Nanosecond)), current} {
+		since, until, err := models.TermBounds(term)
+		if err != nil {
+			return released, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+		}
+
+		courses, err := db.GetStatsCourses(since, until)
+		if err != nil {
+			return released, handleDatabaseError(err)
+		}
+
+		codes := map[models.StatsScope][]string{models.StatsScopeCourse: courses}
+		for _, course := range courses {
+			if faculty := models.Faculty(course); !slices.Contains(codes[models.StatsScopeFaculty], faculty) {
+				codes[models.StatsScopeFaculty] = append(codes[models.StatsScopeFaculty], faculty)
+			}
+		}
+
+		for scope, codes := range codes {
+			for _, code := range codes {
+				ok, err := releaseStats(scope, code, term, since, until, now)
+				if err != nil {
+					return released, handleDatabaseError(err)
+				}
+				if ok {
+					released++
+				}
+			}
+		}
+	}
+
+	return released, nil
+}
+
+// GetReleasedStats returns the private statistics of the course or
+// faculty for the academic term, the current one if empty, as last
+// released.
+func GetReleasedStats(scope models.StatsScope, code, term string) (*models.Stats, error) {
+	if term == "" {
+		term = models.TermOf(time.Now())
+	}
+	if _, _, err := models.TermBounds(term); err != nil {
+		return nil, termError(term, err)
+	}
+	if err := validators.ValidateStatsQuery(&models.StatsQuery{Scope: scope, Code: code}); err != nil {
+		return nil, err
+	}
+
+	r, err := db.GetLatestStatsRelease(scope, code, term)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, handleDatabaseError(err)
+		}
+
+		uxe := uxerrors.New(err)
+		uxe.Summary = "The statistics were not published yet"
+		uxe.Detail = fmt.Sprintf("The statistics of %s for %s are published periodically, and were not published yet. Try again later.", strings.ToUpper(code), term)
+		return nil, uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+	}
+	return r.Stats, nil
+}
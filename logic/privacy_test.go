@@ -0,0 +1,177 @@
+/**
+ * file: logic/privacy_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPrivacyConfig sets the privacy configuration for the duration of
+// the test.
+func setPrivacyConfig(t *testing.T, c PrivacyConfig) {
+	SetPrivacyConfig(c)
+	t.Cleanup(func() { SetPrivacyConfig(DefaultPrivacyConfig) })
+}
+
+// TestTermOf tests that terms run from September to January, and from
+// February to August.
+func TestTermOf(t *testing.T) {
+	terms := map[string]string{
+		"2024-09-01T00:00:00Z": "2024-2025-Q1",
+		"2024-12-24T12:00:00Z": "2024-2025-Q1",
+		"2025-01-31T23:59:59Z": "2024-2025-Q1",
+		"2025-02-01T00:00:00Z": "2024-2025-Q2",
+		"2025-08-31T23:59:59Z": "2024-2025-Q2",
+	}
+	for at, term := range terms {
+		parsed, err := time.Parse(time.RFC3339, at)
+		require.NoError(t, err)
+		assert.Equal(t, term, models.TermOf(parsed), "%s should be in %s", at, term)
+	}
+}
+
+// TestGeometricNoise tests that the noise is centered, with the
+// variance of the two-sided geometric distribution.
+func TestGeometricNoise(t *testing.T) {
+	const samples = 20000
+	epsilon, sensitivity := 0.5, 1.0
+
+	var sum, squares float64
+	for i := 0; i < samples; i++ {
+		noise, err := geometricNoise(epsilon, sensitivity)
+		require.NoError(t, err)
+		sum += float64(noise)
+		squares += float64(noise * noise)
+	}
+
+	alpha := math.Exp(-epsilon / sensitivity)
+	variance := 2 * alpha / ((1 - alpha) * (1 - alpha))
+	assert.InDelta(t, 0, sum/samples, 0.2, "the noise should be centered")
+	assert.InEpsilon(t, variance, squares/samples, 0.1, "the noise should have the expected variance")
+}
+
+// TestReleaseStats tests that public statistics are released on a
+// schedule, noisy and within the privacy budget, and suppressed below
+// the threshold.
+func TestReleaseStats(t *testing.T) {
+	setupTestDatabase(t)
+	setPrivacyConfig(t, PrivacyConfig{Epsilon: 1, Budget: 2, K: 0, Interval: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		_, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err)
+	}
+	now := time.Now()
+	term := models.TermOf(now)
+
+	_, err := GetReleasedStats(models.StatsScopeCourse, "LINFO1101", "")
+	assertStatus(t, http.StatusNotFound, err)
+
+	n, err := ReleaseStats(now)
+	require.NoError(t, err, "releasing the statistics should not fail")
+	assert.Equal(t, 2, n, "the statistics of the course and of its faculty should be released")
+
+	s, err := GetReleasedStats(models.StatsScopeCourse, "linfo1101", "")
+	require.NoError(t, err, "getting the released statistics should not fail")
+	require.NotNil(t, s.Privacy, "the statistics should be private")
+	assert.Equal(t, 1.0, s.Privacy.Epsilon)
+	assert.Equal(t, term, s.Privacy.Term)
+	assert.Equal(t, 1.0, s.Privacy.RemainingBudget)
+	assert.Nil(t, s.MedianResponseSeconds, "medians should not be released")
+	assert.Len(t, s.Statuses, len(statusTransitions), "all statuses should be released")
+
+	for i := 0; i < 5; i++ {
+		_, err := GetReleasedStats(models.StatsScopeCourse, "LINFO1101", term)
+		require.NoError(t, err)
+	}
+	n, err = ReleaseStats(now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, n, "statistics should not be released before the interval")
+
+	n, err = ReleaseStats(now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "statistics should be released again after the interval")
+	s, err = GetReleasedStats(models.StatsScopeCourse, "LINFO1101", term)
+	require.NoError(t, err)
+	assert.Zero(t, s.Privacy.RemainingBudget, "releases should spend the budget")
+
+	n, err = ReleaseStats(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n, "statistics should not be released once the budget is spent")
+	last, err := GetReleasedStats(models.StatsScopeCourse, "LINFO1101", term)
+	require.NoError(t, err, "statistics should still be served once the budget is spent")
+	assert.Equal(t, s, last, "the last release should be served once the budget is spent")
+
+	_, err = GetReleasedStats(models.StatsScopeCourse, "LINFO1101", "2024-Q1")
+	assertStatus(t, http.StatusBadRequest, err)
+	_, err = GetReleasedStats(models.StatsScopeCourse, "LINFO1101", "2019-2020-Q2")
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+// TestReleaseStatsSuppressed tests that statistics below the
+// threshold are released suppressed.
+func TestReleaseStatsSuppressed(t *testing.T) {
+	setupTestDatabase(t)
+	setPrivacyConfig(t, PrivacyConfig{Epsilon: 1, Budget: 2, K: 1000, Interval: time.Hour})
+
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+	_, err = ReleaseStats(time.Now())
+	require.NoError(t, err)
+
+	s, err := GetReleasedStats(models.StatsScopeFaculty, "LINFO", "")
+	require.NoError(t, err)
+	assert.True(t, s.Privacy.Suppressed, "statistics below the threshold should be suppressed")
+	assert.Zero(t, s.Feedback)
+	assert.Nil(t, s.Statuses)
+}
+
+// TestStatsReleaseDue tests that the statistics of the current term
+// are released every interval, and those of a past term once more
+// after it ended.
+func TestStatsReleaseDue(t *testing.T) {
+	setPrivacyConfig(t, PrivacyConfig{Epsilon: 1, Budget: 2, K: 0, Interval: time.Hour})
+	until := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+	last := &models.StatsRelease{CreatedAt: until.Add(-2 * time.Hour)}
+
+	assert.True(t, statsReleaseDue(nil, until, until.Add(-time.Hour)), "statistics never released should be due")
+	assert.False(t, statsReleaseDue(last, until, last.CreatedAt.Add(time.Minute)))
+	assert.True(t, statsReleaseDue(last, until, last.CreatedAt.Add(time.Hour)))
+	assert.True(t, statsReleaseDue(last, until, until.Add(time.Minute)), "past terms should be released once more")
+	assert.False(t, statsReleaseDue(&models.StatsRelease{CreatedAt: until}, until, until.Add(48*time.Hour)), "past terms should be released once")
+}
+
+// TestPrivatizeStatsTags tests that only the most common tags are
+// released, and that rare tags are suppressed.
+func TestPrivatizeStatsTags(t *testing.T) {
+	setPrivacyConfig(t, PrivacyConfig{Epsilon: 1, Budget: 20, K: 10})
+
+	s := &models.Stats{Feedback: 10000, Statuses: map[models.FeedbackStatus]int64{}}
+	for i := 0; i < maxStatsTags+2; i++ {
+		s.Tags = append(s.Tags, &models.TagCount{Tag: fmt.Sprintf("tag-%d", i), Count: int64(1000 * (i + 1))})
+	}
+	s.Tags = append(s.Tags, &models.TagCount{Tag: "rare", Count: 1})
+
+	require.NoError(t, privatizeStats(s, 1e6))
+	require.Len(t, s.Tags, maxStatsTags, "only the most common tags should be released")
+	assert.Equal(t, fmt.Sprintf("tag-%d", maxStatsTags+1), s.Tags[0].Tag, "the most common tag should be first")
+	for _, tag := range s.Tags {
+		assert.NotEqual(t, "rare", tag.Tag, "tags below the threshold should be suppressed")
+	}
+
+	rare := &models.Stats{Feedback: 10000, Tags: []*models.TagCount{{Tag: "rare", Count: 1}}}
+	require.NoError(t, privatizeStats(rare, 1e6))
+	assert.Empty(t, rare.Tags, "tags below the threshold should be suppressed")
+}
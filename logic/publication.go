@@ -0,0 +1,149 @@
+/**
+ * file: logic/publication.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the publication policy of new
+ * feedback. Feedback is held until enough of it is
+ * pending on its course, or until a randomized delay
+ * has passed, and is then published in shuffled
+ * batches with coarse timestamps, so that it cannot
+ * be attributed to the student who just wrote it.
+ */
+
+package logic
+
+import (
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"time"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+)
+
+// publicationGranularity is the precision of the timestamps of
+// published feedback.
+const publicationGranularity time.Duration = time.Hour
+
+// The PublicationPolicy structure configures when held feedback is
+// published.
+type PublicationPolicy struct {
+	// K is the number of feedback held on a course which triggers
+	// its publication. Feedback is published at once if K is 1.
+	K int64
+
+	// Held feedback is published after a delay picked uniformly
+	// between MinDelay and MaxDelay, even if too little feedback is
+	// held on its course.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultPublicationPolicy is the publication policy, unless changed
+// by SetPublicationPolicy.
+var DefaultPublicationPolicy = PublicationPolicy{K: 5, MinDelay: time.Hour, MaxDelay: 6 * time.Hour}
+
+var publication = DefaultPublicationPolicy
+
+// SetPublicationPolicy sets the publication policy of new feedback.
+func SetPublicationPolicy(p PublicationPolicy) {
+	publication = p
+}
+
+// randomInt returns a uniform sample of [0, n), from a
+// cryptographically secure source.
+func randomInt(n int64) (int64, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(n))
+	if err != nil {
+		return 0, err
+	}
+	return i.Int64(), nil
+}
+
+// holdFeedback holds the valid feedback using the given database,
+// which may be bound to a transaction, and publishes its course if
+// enough feedback is held on it. It returns the published feedback,
+// or the held one.
+func holdFeedback(tx *database.Database, f *models.Feedback, now time.Time) (*models.Feedback, error) {
+	delay := publication.MinDelay
+	if spread := publication.MaxDelay - publication.MinDelay; spread > 0 {
+		r, err := randomInt(int64(spread))
+		if err != nil {
+			return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+		}
+		delay += time.Duration(r)
+	}
+
+	h := &models.HeldFeedback{Course: f.Course, Feedback: f.Feedback, Tags: f.Tags, PublishAt: now.Add(delay)}
+	if err := tx.HoldFeedback(h); err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	held, err := tx.GetHeldFeedback(f.Course)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	if int64(len(held)) >= publication.K {
+		published, err := publishHeldFeedback(tx, held, now)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := published[h.ID]; ok {
+			return p, nil
+		}
+	}
+
+	f.Held = true
+	return f, nil
+}
+
+// publishHeldFeedback publishes the held feedback using the given
+// database, which may be bound to a transaction, in a random order
+// so that IDs do not reveal the order it was written in.
+func publishHeldFeedback(tx *database.Database, held []*models.HeldFeedback, now time.Time) (map[uint]*models.Feedback, error) {
+	for i := len(held) - 1; i > 0; i-- {
+		j, err := randomInt(int64(i + 1))
+		if err != nil {
+			return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+		}
+		held[i], held[j] = held[j], held[i]
+	}
+
+	published, err := tx.PublishHeldFeedback(held, now.Truncate(publicationGranularity))
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return published, nil
+}
+
+// PublishHeldFeedback publishes the feedback held on the courses due
+// for publication at the given time. It returns the number of
+// published feedback.
+func PublishHeldFeedback(now time.Time) (int, error) {
+	courses, err := db.GetDueHeldCourses(publication.K, now)
+	if err != nil {
+		return 0, handleDatabaseError(err)
+	}
+
+	n := 0
+	for _, course := range courses {
+		held, err := db.GetHeldFeedback(course)
+		if err != nil {
+			return n, handleDatabaseError(err)
+		}
+
+		published, err := publishHeldFeedback(db, held, now)
+		if err != nil {
+			return n, err
+		}
+		n += len(published)
+	}
+
+	if n > 0 {
+		publishChanges()
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+/**
+ * file: logic/publication_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPublicationPolicy tests that feedback is held until enough of it
+// is held on its course, or until its delay has passed.
+func TestPublicationPolicy(t *testing.T) {
+	setupTestDatabase(t)
+	SetPublicationPolicy(PublicationPolicy{K: 3, MinDelay: time.Hour, MaxDelay: 2 * time.Hour})
+
+	for i := 0; i < 2; i++ {
+		f, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err, "adding feedback should not fail")
+		assert.True(t, f.Held, "feedback should be held until enough is held on its course")
+		assert.Zero(t, f.ID, "held feedback should have no ID")
+	}
+	all, err := GetAllFeedback()
+	require.NoError(t, err)
+	assert.Empty(t, all, "held feedback should not be listed")
+
+	n, err := PublishHeldFeedback(time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, n, "feedback should not be published before its delay")
+
+	f, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+	assert.False(t, f.Held, "feedback should be published once enough is held on its course")
+	assert.NotZero(t, f.ID)
+	assert.Equal(t, f.CreatedAt, f.CreatedAt.Truncate(publicationGranularity), "the creation time should be coarsened")
+
+	all, err = GetAllFeedback()
+	require.NoError(t, err)
+	assert.Len(t, all, 3, "the whole batch should be published")
+
+	lone := &models.Feedback{Course: "LINFO1102", Feedback: "The project statement is ambiguous in many places."}
+	f, err = AddFeedback(lone)
+	require.NoError(t, err)
+	require.True(t, f.Held)
+
+	n, err = PublishHeldFeedback(time.Now().Add(30 * time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, n, "feedback should not be published before its delay")
+	n, err = PublishHeldFeedback(time.Now().Add(2*time.Hour + time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "feedback should be published once its delay has passed")
+
+	batch := &models.Batch{Operations: []models.BatchOperation{
+		{Op: models.BatchOperationCreate, Feedback: newTestFeedback()},
+	}}
+	outcome, err := RunBatch(batch, models.VoteSource{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, outcome.Results[0].Status, "created feedback should be held in batches too")
+}
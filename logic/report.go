@@ -0,0 +1,80 @@
+/**
+ * file: logic/report.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the term reports on courses and
+ * faculties, for the meetings of the councils.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/reports"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+func termError(term string, err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "The term is invalid"
+	uxe.Detail = fmt.Sprintf("The term you entered (%q) is not a valid academic term, such as 2024-2025-Q1. Correct the term and try again.", term)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// GetReport returns the report of the feedback on the course or
+// faculty during the academic term, the current one if empty. Its
+// statistics are exact if requested, and are otherwise the private
+// statistics last released for the term, left out if none were.
+func GetReport(scope models.StatsScope, code, term string, exact bool) (*reports.Report, error) {
+	if term == "" {
+		term = models.TermOf(time.Now())
+	}
+	since, until, err := models.TermBounds(term)
+	if err != nil {
+		return nil, termError(term, err)
+	}
+
+	q := &models.StatsQuery{Scope: scope, Code: code, Since: &since, Until: &until}
+	if err := validators.ValidateStatsQuery(q); err != nil {
+		return nil, err
+	}
+
+	var s *models.Stats
+	if exact {
+		if s, err = GetStats(q); err != nil {
+			return nil, err
+		}
+	} else if r, err := db.GetLatestStatsRelease(scope, code, term); err == nil {
+		s = r.Stats
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, handleDatabaseError(err)
+	}
+
+	// The earlier feedback is reported on as well.
+	scoped := &models.StatsQuery{Scope: scope, Code: code, Until: &until}
+	feedback, err := db.GetScopedFeedback(scoped)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	changes, err := db.GetStatusChanges(scoped, since, until)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	for _, c := range changes {
+		if err := decodeSnapshot(c); err != nil {
+			return nil, err
+		}
+	}
+
+	q.Code = strings.ToUpper(code)
+	return reports.BuildReport(q, term, feedback, changes, s, reports.ReportLimit), nil
+}
@@ -0,0 +1,64 @@
+/**
+ * file: logic/report_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetReport tests that reports cover the current term by default,
+// with the changes of status made during it.
+func TestGetReport(t *testing.T) {
+	setupTestDatabase(t)
+
+	for i := 0; i < 2; i++ {
+		_, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := UpdateFeedbackUpvotes(2, 1, models.VoteSource{})
+		require.NoError(t, err)
+	}
+	_, err := TransitionFeedbackStatus(1, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err)
+
+	r, err := GetReport(models.StatsScopeCourse, "linfo1101", "", true)
+	require.NoError(t, err, "getting the report should not fail")
+	assert.Equal(t, models.TermOf(time.Now()), r.Term)
+	assert.Equal(t, "LINFO1101", r.Code)
+	require.Len(t, r.Top, 2)
+	assert.Equal(t, uint(2), r.Top[0].ID, "the top feedback should be ranked first")
+	require.Len(t, r.Responses, 1)
+	assert.Equal(t, models.FeedbackStatusAcknowledged, r.Responses[0].Status)
+	require.NotNil(t, r.Stats)
+	assert.Equal(t, int64(2), r.Stats.Feedback)
+
+	r, err = GetReport(models.StatsScopeCourse, "linfo1101", "", false)
+	require.NoError(t, err, "getting the public report should not fail")
+	assert.Nil(t, r.Stats, "statistics should be left out until released")
+	_, err = ReleaseStats(time.Now())
+	require.NoError(t, err)
+	r, err = GetReport(models.StatsScopeCourse, "linfo1101", "", false)
+	require.NoError(t, err)
+	require.NotNil(t, r.Stats)
+	assert.NotNil(t, r.Stats.Privacy, "the released statistics should be reported")
+
+	r, err = GetReport(models.StatsScopeCourse, "linfo1101", "2019-2020-Q2", true)
+	require.NoError(t, err)
+	assert.Empty(t, r.Top, "feedback of other terms should not be reported")
+
+	_, err = GetReport(models.StatsScopeCourse, "linfo1101", "2024-Q1", true)
+	assertStatus(t, http.StatusBadRequest, err)
+	_, err = GetReport(models.StatsScopeCourse, "cooking", "", true)
+	assertStatus(t, http.StatusBadRequest, err)
+}
@@ -0,0 +1,110 @@
+/**
+ * file: logic/representative.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the student representatives,
+ * and their notification settings.
+ */
+
+package logic
+
+import (
+	"errors"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+// sanitizeRepresentative clears the fields maintained by the server
+// and sets the default settings.
+func sanitizeRepresentative(r *models.Representative) {
+	r.LastDigestAt = nil
+	r.TokenHash = nil
+	r.Token = ""
+	if r.Language == "" {
+		r.Language = "en"
+	}
+	if r.DigestFrequency == "" {
+		r.DigestFrequency = models.DigestFrequencyWeekly
+	}
+}
+
+// representativeNotFound returns the error for an unknown
+// representative, or err itself for other errors.
+func representativeNotFound(err error) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return handleDatabaseError(err)
+	}
+
+	uxe := uxerrors.New(err)
+	uxe.Summary = "The representative was not found"
+	uxe.Detail = "The representative you requested does not exist. Check the identifier and try again."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// CreateRepresentative validates and adds the representative, and
+// issues their access token. The token is only returned once.
+func CreateRepresentative(r *models.Representative) (*models.Representative, error) {
+	r.ID = 0
+	sanitizeRepresentative(r)
+	if err := validators.ValidateRepresentative(r); err != nil {
+		return nil, err
+	}
+
+	token, hash, err := newAccessToken()
+	if err != nil {
+		return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+	r.TokenHash = hash
+
+	r, err = db.AddRepresentative(r)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	r.TokenHash = nil
+	r.Token = token
+	return r, nil
+}
+
+func GetRepresentatives() ([]*models.Representative, error) {
+	r, err := db.GetRepresentatives()
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return r, nil
+}
+
+func GetRepresentative(id uint) (*models.Representative, error) {
+	r, err := db.GetRepresentative(id)
+	if err != nil {
+		return nil, representativeNotFound(err)
+	}
+	return r, nil
+}
+
+// UpdateRepresentative replaces the settings of the representative.
+func UpdateRepresentative(id uint, r *models.Representative) (*models.Representative, error) {
+	r.ID = id
+	sanitizeRepresentative(r)
+	if err := validators.ValidateRepresentative(r); err != nil {
+		return nil, err
+	}
+
+	r, err := db.UpdateRepresentative(r)
+	if err != nil {
+		return nil, representativeNotFound(err)
+	}
+	return r, nil
+}
+
+func DeleteRepresentative(id uint) error {
+	if err := db.DeleteRepresentative(id); err != nil {
+		return representativeNotFound(err)
+	}
+	return nil
+}
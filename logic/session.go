@@ -0,0 +1,439 @@
+/**
+ * file: logic/session.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the live voting sessions, run
+ * by representatives in class, and the relay of their
+ * updates to the connected clients.
+ */
+
+package logic
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+const (
+	minRoundDuration time.Duration = 10 * time.Second
+	maxRoundDuration time.Duration = 2 * time.Hour
+)
+
+func sanitizeSession(s *models.Session) {
+	s.ID = 0
+	s.Status = models.SessionStatusIdle
+	s.Round = 0
+	s.ClosesAt = nil
+	s.RepresentativeID = nil
+	s.PresenterTokenHash = nil
+	s.PresenterToken = ""
+	s.Items = nil
+}
+
+// sessionNotFound returns the error for an unknown session, or err
+// itself for other errors.
+func sessionNotFound(err error) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return handleDatabaseError(err)
+	}
+
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Session not found"
+	uxe.Detail = "The session you requested could not be found. Check the ID and try again."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// CreateSession validates and adds the session on behalf of the
+// representative, or of an administrator if the representative is
+// nil, and issues the token of its presenters. The token is only
+// returned once.
+func CreateSession(s *models.Session, r *models.Representative) (*models.Session, error) {
+	sanitizeSession(s)
+	if r != nil {
+		s.RepresentativeID = &r.ID
+	}
+
+	if err := validators.ValidateSession(s); err != nil {
+		return nil, err
+	}
+
+	token, hash, err := newAccessToken()
+	if err != nil {
+		return nil, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+	}
+	s.PresenterTokenHash = hash
+
+	created, err := db.AddSession(s)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	created.PresenterToken = token
+	created.Items = []*models.Feedback{}
+	return created, nil
+}
+
+// AuthenticatePresenter returns an error unless the token is the
+// presenter token of the session, or the access token of the
+// representative who created it.
+func AuthenticatePresenter(id uint, token string) error {
+	s, err := db.GetSession(id)
+	if err != nil {
+		return sessionNotFound(err)
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	if token != "" && subtle.ConstantTimeCompare(hash[:], s.PresenterTokenHash) == 1 {
+		return nil
+	}
+	if token != "" && s.RepresentativeID != nil {
+		r, err := db.GetRepresentativeByToken(hash[:])
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return handleDatabaseError(err)
+		}
+		if err == nil && r.ID == *s.RepresentativeID {
+			return nil
+		}
+	}
+
+	uxe := uxerrors.New(fmt.Errorf("invalid presenter token for session %d", id))
+	uxe.Summary = "You are not allowed to access this resource"
+	uxe.Detail = "This resource is restricted to the presenters of the session. Provide the presenter token given when the session was created and try again."
+	return uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe)
+}
+
+// GetSession returns the session identified by id, along with its
+// items.
+func GetSession(id uint) (*models.Session, error) {
+	s, err := db.GetSession(id)
+	if err != nil {
+		return nil, sessionNotFound(err)
+	}
+
+	if s.Items, err = db.GetSessionItems(id); err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return s, nil
+}
+
+// AttachSessionFeedback attaches the feedback to the session, after
+// the items already attached. Attaching the same feedback twice has
+// no effect. Sessions created by a representative only take the
+// feedback of the courses they follow.
+func AttachSessionFeedback(sessionID, feedbackID uint) (*models.Session, error) {
+	session, err := db.GetSession(sessionID)
+	if err != nil {
+		return nil, sessionNotFound(err)
+	}
+	f, err := db.GetFeedback(feedbackID)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	if session.RepresentativeID != nil {
+		r, err := db.GetRepresentative(*session.RepresentativeID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, handleDatabaseError(err)
+		}
+		if err != nil || !r.Represents(models.StatsScopeCourse, f.Course) {
+			uxe := uxerrors.New(fmt.Errorf("feedback %d on %s not followed by the representative of session %d", f.ID, f.Course, sessionID))
+			uxe.Summary = "You are not allowed to attach this feedback"
+			uxe.Detail = fmt.Sprintf("The feedback is on %s, which the representative running the session does not follow. Choose feedback on the courses they follow and try again.", f.Course)
+			return nil, uxerrors.NewErrors(http.StatusForbidden).Append(uxe)
+		}
+	}
+
+	added, err := db.AddSessionItem(sessionID, feedbackID)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	s, err := GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if added {
+		sessions.broadcast(s)
+	}
+	return s, nil
+}
+
+// OpenSessionRound opens the next round of the session, for the
+// given duration. Votes cast in a round are tallied separately from
+// the previous rounds.
+func OpenSessionRound(id uint, duration time.Duration) (*models.Session, error) {
+	if duration < minRoundDuration || duration > maxRoundDuration {
+		uxe := uxerrors.New(fmt.Errorf("round duration %s out of bounds", duration))
+		uxe.Summary = "The duration of the round is invalid"
+		uxe.Detail = fmt.Sprintf("A round should last at least %s and at most %s. Correct the duration and try again.", minRoundDuration, maxRoundDuration)
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	s, err := db.GetSession(id)
+	if err != nil {
+		return nil, sessionNotFound(err)
+	}
+
+	now := time.Now()
+	if s.Open(now) {
+		uxe := uxerrors.New(fmt.Errorf("round %d still open", s.Round))
+		uxe.Summary = "A round is already open"
+		uxe.Detail = "The current round of the session is still open. Close it, or wait for it to end, and try again."
+		return nil, uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	}
+
+	if _, err := db.OpenSessionRound(id, now.Add(duration)); err != nil {
+		return nil, sessionNotFound(err)
+	}
+
+	s, err = GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions.broadcast(s)
+	round := s.Round
+	time.AfterFunc(duration, func() {
+		// Clients are told about the end of the round, unless another
+		// round was opened in the meantime.
+		if s, err := GetSession(id); err == nil && s.Round == round {
+			sessions.broadcast(s)
+		}
+	})
+
+	return s, nil
+}
+
+// CloseSessionRound closes the current round of the session before
+// its end. Closing a session with no open round has no effect.
+func CloseSessionRound(id uint) (*models.Session, error) {
+	s, err := GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !s.Open(now) {
+		return s, nil
+	}
+
+	if _, err := db.CloseSessionRound(id, now); err != nil {
+		return nil, sessionNotFound(err)
+	}
+
+	s, err = GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions.broadcast(s)
+	return s, nil
+}
+
+// GetSessionTallies returns the votes cast in the current, or last,
+// round of the session, per item.
+func GetSessionTallies(id uint) ([]*models.SessionTally, error) {
+	s, err := db.GetSession(id)
+	if err != nil {
+		return nil, sessionNotFound(err)
+	}
+
+	return getSessionTallies(s)
+}
+
+func getSessionTallies(s *models.Session) ([]*models.SessionTally, error) {
+	t, err := db.GetSessionTallies(s.ID, s.Round)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return t, nil
+}
+
+// CastSessionVote casts the vote of the given kind on an item of the
+// session, while a round is open. The vote follows the same rules
+// as votes cast outside of sessions, and counts towards the score
+// of the feedback as well.
+func CastSessionVote(sessionID, feedbackID uint, kind models.VoteKind, votes int, source models.VoteSource) (*models.Feedback, error) {
+	s, err := GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.Open(time.Now()) {
+		uxe := uxerrors.New(fmt.Errorf("no open round"))
+		uxe.Summary = "Voting is closed"
+		uxe.Detail = "There is no open voting round in this session. Wait for the next round and try again."
+		return nil, uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+	}
+
+	attached := false
+	for _, f := range s.Items {
+		attached = attached || f.ID == feedbackID
+	}
+	if !attached {
+		uxe := uxerrors.New(fmt.Errorf("feedback %d not in session %d", feedbackID, sessionID))
+		uxe.Summary = "The feedback is not part of this session"
+		uxe.Detail = "You can only vote on the feedback of the session during its rounds. Refresh the session and try again."
+		return nil, uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+	}
+
+	v, err := newVote(feedbackID, kind, votes, source)
+	if err != nil {
+		return nil, err
+	}
+	v.SessionID = &s.ID
+	v.SessionRound = s.Round
+
+	f, err := applyVote(db, v)
+	if err != nil {
+		return nil, err
+	}
+
+	publishChanges()
+	sessions.broadcastTallies(s)
+	return f, nil
+}
+
+// The SessionSubscription structure receives the updates of a live
+// session on C. The current state of the session is sent first.
+// Subscribers that do not keep up are dropped, and C is closed.
+// They should subscribe again to get the current state.
+type SessionSubscription struct {
+	C <-chan *models.SessionEvent
+
+	c         chan *models.SessionEvent
+	session   uint
+	presenter bool
+}
+
+// Close stops the subscription and closes C, if it was not closed
+// already.
+func (s *SessionSubscription) Close() {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	sessions.unsubscribe(s)
+}
+
+// The sessionHub structure relays the updates of live sessions to
+// their subscriptions.
+type sessionHub struct {
+	mu            sync.Mutex
+	subscriptions map[uint]map[*SessionSubscription]bool
+
+	// tallies serializes the tallies, so that older tallies are
+	// never sent after newer ones.
+	tallies sync.Mutex
+}
+
+var (
+	sessions = &sessionHub{subscriptions: map[uint]map[*SessionSubscription]bool{}}
+)
+
+// SubscribeSession subscribes to the updates of the session.
+// Presenters also receive the tallies of the current round.
+// The subscription must be closed once done.
+func SubscribeSession(id uint, presenter bool) (*SessionSubscription, error) {
+	s, err := GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*models.SessionEvent{{Type: models.SessionEventSession, Session: s}}
+	if presenter {
+		t, err := getSessionTallies(s)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &models.SessionEvent{Type: models.SessionEventTally, Tallies: t})
+	}
+
+	c := make(chan *models.SessionEvent, subscriptionBuffer)
+	for _, e := range events {
+		c <- e
+	}
+
+	sub := &SessionSubscription{C: c, c: c, session: id, presenter: presenter}
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	if sessions.subscriptions[id] == nil {
+		sessions.subscriptions[id] = map[*SessionSubscription]bool{}
+	}
+	sessions.subscriptions[id][sub] = true
+	return sub, nil
+}
+
+// unsubscribe removes the subscription. The hub must be locked.
+func (h *sessionHub) unsubscribe(s *SessionSubscription) {
+	subs := h.subscriptions[s.session]
+	if !subs[s] {
+		return
+	}
+
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subscriptions, s.session)
+	}
+	close(s.c)
+}
+
+// send sends the event to the subscriptions of the session, or to
+// its presenters only.
+func (h *sessionHub) send(id uint, e *models.SessionEvent, presenters bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscriptions[id] {
+		if presenters && !s.presenter {
+			continue
+		}
+
+		select {
+		case s.c <- e:
+		default:
+			h.unsubscribe(s)
+		}
+	}
+}
+
+// broadcast sends the session to its subscriptions, along with the
+// tallies of its round to the presenters.
+func (h *sessionHub) broadcast(s *models.Session) {
+	h.send(s.ID, &models.SessionEvent{Type: models.SessionEventSession, Session: s}, false)
+	h.broadcastTallies(s)
+}
+
+// broadcastTallies sends the tallies of the round of the session to
+// its presenters. Tallies that cannot be read now are sent with the
+// next update.
+func (h *sessionHub) broadcastTallies(s *models.Session) {
+	h.mu.Lock()
+	listening := len(h.subscriptions[s.ID]) != 0
+	h.mu.Unlock()
+	if !listening {
+		return
+	}
+
+	h.tallies.Lock()
+	defer h.tallies.Unlock()
+
+	t, err := getSessionTallies(s)
+	if err != nil {
+		return
+	}
+
+	h.send(s.ID, &models.SessionEvent{Type: models.SessionEventTally, Tallies: t}, true)
+}
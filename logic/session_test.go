@@ -0,0 +1,179 @@
+/**
+ * file: logic/session_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessionRounds tests that votes are only accepted on the items
+// of a session during its rounds, and tallied per round.
+func TestSessionRounds(t *testing.T) {
+	setupTestDatabase(t)
+
+	f, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	other, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+
+	_, err = CreateSession(&models.Session{}, nil)
+	assertStatus(t, http.StatusBadRequest, err)
+
+	s, err := CreateSession(&models.Session{Title: "LINFO1101 - week 5", Status: models.SessionStatusOpen}, nil)
+	require.NoError(t, err, "creating a session should not fail")
+	assert.Equal(t, models.SessionStatusIdle, s.Status, "new sessions should not be open")
+
+	s, err = AttachSessionFeedback(s.ID, f.ID)
+	require.NoError(t, err, "attaching feedback should not fail")
+	require.Len(t, s.Items, 1)
+	_, err = AttachSessionFeedback(s.ID, 42)
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = CastSessionVote(s.ID, f.ID, models.VoteKindUpvote, 1, models.VoteSource{})
+	assertStatus(t, http.StatusConflict, err)
+
+	_, err = OpenSessionRound(s.ID, time.Second)
+	assertStatus(t, http.StatusBadRequest, err)
+	s, err = OpenSessionRound(s.ID, time.Minute)
+	require.NoError(t, err, "opening a round should not fail")
+	assert.Equal(t, uint(1), s.Round)
+	_, err = OpenSessionRound(s.ID, time.Minute)
+	assertStatus(t, http.StatusConflict, err)
+
+	voted, err := CastSessionVote(s.ID, f.ID, models.VoteKindUpvote, 1, models.VoteSource{Token: "a"})
+	require.NoError(t, err, "voting during a round should not fail")
+	assert.Equal(t, uint64(1), voted.Upvotes, "session votes should count towards the feedback")
+	_, err = CastSessionVote(s.ID, f.ID, models.VoteKindDownvote, 1, models.VoteSource{Token: "b"})
+	require.NoError(t, err, "voting during a round should not fail")
+	_, err = CastSessionVote(s.ID, other.ID, models.VoteKindUpvote, 1, models.VoteSource{})
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = CastSessionVote(s.ID, f.ID, models.VoteKindUpvote, 2, models.VoteSource{})
+	assertStatus(t, http.StatusBadRequest, err)
+	_, err = UpdateFeedbackUpvotes(f.ID, 1, models.VoteSource{})
+	require.NoError(t, err, "voting outside of the session should not fail")
+
+	tallies, err := GetSessionTallies(s.ID)
+	require.NoError(t, err, "getting tallies should not fail")
+	assert.Equal(t, []*models.SessionTally{{FeedbackID: f.ID, Upvotes: 1, Downvotes: 1}}, tallies, "only the votes of the session should be tallied")
+
+	s, err = CloseSessionRound(s.ID)
+	require.NoError(t, err, "closing a round should not fail")
+	assert.False(t, s.Open(time.Now()), "the round should be closed")
+	_, err = CastSessionVote(s.ID, f.ID, models.VoteKindUpvote, 1, models.VoteSource{})
+	assertStatus(t, http.StatusConflict, err)
+
+	_, err = OpenSessionRound(s.ID, time.Minute)
+	require.NoError(t, err, "opening the next round should not fail")
+	tallies, err = GetSessionTallies(s.ID)
+	require.NoError(t, err, "getting tallies should not fail")
+	assert.Empty(t, tallies, "each round should be tallied on its own")
+}
+
+// TestSubscribeSession tests that presenters receive the tallies of
+// the round as votes are cast, and participants only the session.
+func TestSubscribeSession(t *testing.T) {
+	setupTestDatabase(t)
+
+	f, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	s, err := CreateSession(&models.Session{Title: "LINFO1101 - week 5"}, nil)
+	require.NoError(t, err, "creating a session should not fail")
+	_, err = AttachSessionFeedback(s.ID, f.ID)
+	require.NoError(t, err, "attaching feedback should not fail")
+
+	_, err = SubscribeSession(42, false)
+	assertStatus(t, http.StatusNotFound, err)
+
+	presenter, err := SubscribeSession(s.ID, true)
+	require.NoError(t, err, "subscribing should not fail")
+	defer presenter.Close()
+	participant, err := SubscribeSession(s.ID, false)
+	require.NoError(t, err, "subscribing should not fail")
+	defer participant.Close()
+
+	e := <-participant.C
+	assert.Equal(t, models.SessionEventSession, e.Type, "the session should be sent first")
+	assert.Len(t, e.Session.Items, 1)
+	assert.Equal(t, models.SessionEventSession, (<-presenter.C).Type, "the session should be sent first")
+	assert.Equal(t, models.SessionEventTally, (<-presenter.C).Type, "the tallies should be sent to presenters")
+
+	_, err = OpenSessionRound(s.ID, time.Minute)
+	require.NoError(t, err, "opening a round should not fail")
+	e = <-participant.C
+	assert.Equal(t, models.SessionStatusOpen, e.Session.Status, "the opening of the round should be sent")
+	assert.Equal(t, models.SessionEventSession, (<-presenter.C).Type)
+	assert.Equal(t, models.SessionEventTally, (<-presenter.C).Type)
+
+	_, err = CastSessionVote(s.ID, f.ID, models.VoteKindUpvote, 1, models.VoteSource{})
+	require.NoError(t, err, "voting during a round should not fail")
+	e = <-presenter.C
+	assert.Equal(t, models.SessionEventTally, e.Type)
+	assert.Equal(t, []*models.SessionTally{{FeedbackID: f.ID, Upvotes: 1}}, e.Tallies, "the tallies should be updated")
+	assert.Empty(t, participant.C, "participants should not receive tallies")
+}
+
+// TestAuthenticatePresenter tests that presenters are authenticated
+// with the token issued when their session was created.
+func TestAuthenticatePresenter(t *testing.T) {
+	setupTestDatabase(t)
+
+	s, err := CreateSession(&models.Session{Title: "LINFO1101 - week 5", PresenterToken: "chosen"}, nil)
+	require.NoError(t, err, "creating a session should not fail")
+	require.NotEmpty(t, s.PresenterToken, "the presenter token should be returned")
+	assert.NotEqual(t, "chosen", s.PresenterToken, "the presenter token should be issued by the server")
+
+	assert.NoError(t, AuthenticatePresenter(s.ID, s.PresenterToken))
+	assertStatus(t, http.StatusUnauthorized, AuthenticatePresenter(s.ID, ""))
+	assertStatus(t, http.StatusUnauthorized, AuthenticatePresenter(s.ID, "chosen"))
+	assertStatus(t, http.StatusNotFound, AuthenticatePresenter(42, s.PresenterToken))
+
+	other, err := CreateSession(&models.Session{Title: "LINFO1101 - week 6"}, nil)
+	require.NoError(t, err)
+	assertStatus(t, http.StatusUnauthorized, AuthenticatePresenter(other.ID, s.PresenterToken))
+
+	got, err := GetSession(s.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.PresenterToken, "the presenter token should only be returned once")
+}
+
+// TestRepresentativeSession tests that representatives run their
+// sessions with their own token, on the feedback of their courses.
+func TestRepresentativeSession(t *testing.T) {
+	setupTestDatabase(t)
+
+	r, err := CreateRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org", Courses: []string{"LINFO1101"}})
+	require.NoError(t, err)
+	other, err := CreateRepresentative(&models.Representative{Name: "Sam", Email: "sam@example.org", Courses: []string{"LINFO1101"}})
+	require.NoError(t, err)
+
+	s, err := CreateSession(&models.Session{Title: "LINFO1101 - week 5"}, r)
+	require.NoError(t, err, "representatives should create sessions")
+	require.NotNil(t, s.RepresentativeID)
+	assert.Equal(t, r.ID, *s.RepresentativeID)
+
+	assert.NoError(t, AuthenticatePresenter(s.ID, r.Token), "the representative should present their session")
+	assert.NoError(t, AuthenticatePresenter(s.ID, s.PresenterToken))
+	assertStatus(t, http.StatusUnauthorized, AuthenticatePresenter(s.ID, other.Token))
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+	lepl := newTestFeedback()
+	lepl.Course = "LEPL1101"
+	lepl, err = AddFeedback(lepl)
+	require.NoError(t, err)
+
+	_, err = AttachSessionFeedback(s.ID, 1)
+	require.NoError(t, err, "feedback of the courses of the representative should be attached")
+	_, err = AttachSessionFeedback(s.ID, lepl.ID)
+	assertStatus(t, http.StatusForbidden, err)
+}
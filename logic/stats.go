@@ -0,0 +1,127 @@
+/**
+ * file: logic/stats.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the statistics of the feedback
+ * on courses and faculties. Exact statistics are
+ * cached until the next change to feedback.
+ */
+
+package logic
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/validators"
+)
+
+// maxStatsTags is the number of most common tags in statistics.
+const maxStatsTags int = 10
+
+// maxCachedStats bounds the number of cached statistics. The cache
+// is emptied once it is full.
+const maxCachedStats int = 256
+
+// The statsCache structure holds the exact statistics computed since
+// the last change. Statistics computed while a change is made are
+// not cached, as they may predate it.
+type statsCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entries    map[string]*models.Stats
+}
+
+var stats = &statsCache{}
+
+// statsCacheKey returns the key of the statistics of the query in
+// the cache.
+func statsCacheKey(q *models.StatsQuery) string {
+	var since, until int64
+	if q.Since != nil {
+		since = q.Since.UnixNano()
+	}
+	if q.Until != nil {
+		until = q.Until.UnixNano()
+	}
+	return fmt.Sprintf("%s/%s/%d/%d", q.Scope, strings.ToUpper(q.Code), since, until)
+}
+
+// get returns the cached statistics of the query, if any, and the
+// current generation of the cache.
+func (c *statsCache) get(key string) (*models.Stats, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key], c.generation
+}
+
+// put caches the statistics, unless the cache was invalidated since
+// the given generation.
+func (c *statsCache) put(key string, s *models.Stats, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if c.entries == nil || len(c.entries) >= maxCachedStats {
+		c.entries = make(map[string]*models.Stats)
+	}
+	c.entries[key] = s
+}
+
+// invalidate empties the cache.
+func (c *statsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = nil
+}
+
+// completeStats lists the statuses without feedback in the
+// statistics.
+func completeStats(s *models.Stats) {
+	for status := range statusTransitions {
+		if _, ok := s.Statuses[status]; !ok {
+			s.Statuses[status] = 0
+		}
+	}
+}
+
+// topTags returns the maxStatsTags most common tags, most common
+// first.
+func topTags(tags []*models.TagCount) []*models.TagCount {
+	slices.SortStableFunc(tags, func(a, b *models.TagCount) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return tags[:min(len(tags), maxStatsTags)]
+}
+
+// GetStats returns the exact statistics of the feedback selected by
+// the query. The public statistics are released by ReleaseStats.
+func GetStats(q *models.StatsQuery) (*models.Stats, error) {
+	if err := validators.ValidateStatsQuery(q); err != nil {
+		return nil, err
+	}
+
+	key := statsCacheKey(q)
+	s, generation := stats.get(key)
+	if s != nil {
+		return s, nil
+	}
+
+	s, err := db.GetStats(q)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	completeStats(s)
+	s.Tags = topTags(s.Tags)
+
+	stats.put(key, s, generation)
+	return s, nil
+}
@@ -0,0 +1,61 @@
+/**
+ * file: logic/stats_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetStats tests that statistics are cached until feedback
+// changes.
+func TestGetStats(t *testing.T) {
+	setupTestDatabase(t)
+
+	query := &models.StatsQuery{Scope: models.StatsScopeCourse, Code: "linfo1101"}
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+
+	s, err := GetStats(query)
+	require.NoError(t, err, "getting the statistics should not fail")
+	assert.Equal(t, int64(1), s.Feedback)
+	assert.Len(t, s.Statuses, len(statusTransitions), "all statuses should be listed")
+	assert.Nil(t, s.Privacy, "exact statistics should not be private")
+
+	cached, err := GetStats(&models.StatsQuery{Scope: models.StatsScopeCourse, Code: "LINFO1101"})
+	require.NoError(t, err)
+	assert.Same(t, s, cached, "the statistics should be cached")
+
+	_, err = UpdateFeedbackUpvotes(1, 1, models.VoteSource{})
+	require.NoError(t, err)
+	s, err = GetStats(query)
+	require.NoError(t, err)
+	assert.NotSame(t, cached, s, "votes should invalidate the cache")
+	assert.Equal(t, int64(1), s.Upvotes)
+
+	_, err = TransitionFeedbackStatus(1, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err)
+	s, err = GetStats(query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), s.Statuses[models.FeedbackStatusAcknowledged], "changes of status should invalidate the cache")
+	assert.Equal(t, int64(1), s.Responded)
+
+	tagged := newTestFeedback()
+	tagged.Tags = models.Tags{"exam"}
+	_, err = AddFeedback(tagged)
+	require.NoError(t, err)
+	s, err = GetStats(query)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.TagCount{{Tag: "exam", Count: 1}}, s.Tags, "the top tags should be listed")
+
+	_, err = GetStats(&models.StatsQuery{Scope: models.StatsScopeFaculty, Code: "LINFO1101"})
+	assertStatus(t, http.StatusBadRequest, err)
+}
@@ -0,0 +1,250 @@
+/**
+ * file: logic/status.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the workflow of the status of
+ * feedback, as handled by the representatives, and the
+ * requests of the voters for the status to change.
+ */
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+// statusRequestBatch is the number of status requests notified at
+// once.
+const statusRequestBatch int = 100
+
+// statusRequestNotification serializes the notifications of the
+// status requests.
+var statusRequestNotification sync.Mutex
+
+// statusTransitions lists, for each status, the statuses feedback
+// may transition to. Closed feedback may be reopened by
+// acknowledging it again.
+var statusTransitions = map[models.FeedbackStatus][]models.FeedbackStatus{
+	models.FeedbackStatusNew:          {models.FeedbackStatusAcknowledged, models.FeedbackStatusRejected},
+	models.FeedbackStatusAcknowledged: {models.FeedbackStatusInProgress, models.FeedbackStatusResolved, models.FeedbackStatusRejected},
+	models.FeedbackStatusInProgress:   {models.FeedbackStatusResolved, models.FeedbackStatusRejected},
+	models.FeedbackStatusResolved:     {models.FeedbackStatusAcknowledged},
+	models.FeedbackStatusRejected:     {models.FeedbackStatusAcknowledged},
+}
+
+// canTransition returns true if feedback may go from one status to
+// the other.
+func canTransition(from, to models.FeedbackStatus) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionError returns the error for a transition the workflow
+// does not allow.
+func transitionError(from, to models.FeedbackStatus) error {
+	uxe := uxerrors.New(fmt.Errorf("cannot transition from %q to %q", from, to))
+	uxe.Summary = "The status cannot be changed this way"
+	uxe.Detail = fmt.Sprintf("Feedback that is %s cannot become %s. Refresh the feedback and try again.", from, to)
+	return uxerrors.NewErrors(http.StatusConflict).Append(uxe)
+}
+
+// TransitionFeedbackStatus changes the status of the feedback
+// identified by id, if the workflow allows it. The transition only
+// happens if the feedback matches one of the etags, if any are
+// given.
+func TransitionFeedbackStatus(id uint, status models.FeedbackStatus, etags []string) (*models.Feedback, error) {
+	f, err := transitionFeedbackStatus(db, id, status, etags)
+	if err == nil {
+		publishChanges()
+	}
+
+	return f, err
+}
+
+// transitionFeedbackStatus changes the status of the feedback using
+// the given database, which may be bound to a transaction.
+func transitionFeedbackStatus(tx *database.Database, id uint, status models.FeedbackStatus, etags []string) (*models.Feedback, error) {
+	if _, ok := statusTransitions[status]; !ok {
+		uxe := uxerrors.New(fmt.Errorf("unknown status %q", status))
+		uxe.Summary = "The status is unknown"
+		uxe.Detail = fmt.Sprintf("The status %q does not exist. Use one of new, acknowledged, in-progress, resolved or rejected and try again.", status)
+		return nil, uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	matches := ifMatch(etags)
+	precondition := func(f *models.Feedback) error {
+		if err := matches(f); err != nil || f == nil {
+			return err
+		}
+		if !canTransition(f.Status, status) {
+			return transitionError(f.Status, status)
+		}
+		return nil
+	}
+
+	f, err := tx.UpdateFeedbackStatus(id, status, precondition)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return f, nil
+}
+
+// statusPath returns the shortest sequence of statuses feedback goes
+// through to reach one status from the other, ending with it. It
+// returns nil if the status cannot be reached, and an empty path if
+// both are the same.
+func statusPath(from, to models.FeedbackStatus) []models.FeedbackStatus {
+	previous := map[models.FeedbackStatus]models.FeedbackStatus{from: from}
+	queue := []models.FeedbackStatus{from}
+	for len(queue) != 0 && queue[0] != to {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range statusTransitions[current] {
+			if _, seen := previous[next]; !seen {
+				previous[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	if _, ok := previous[to]; !ok {
+		return nil
+	}
+
+	path := []models.FeedbackStatus{}
+	for s := to; s != from; s = previous[s] {
+		path = append([]models.FeedbackStatus{s}, path...)
+	}
+	return path
+}
+
+// statusRequestHash returns the hash identifying the voter with the
+// token among the status requests.
+func statusRequestHash(token string) ([]byte, error) {
+	if len(token) < minVoterTokenLength || len(token) > maxVoterTokenLength {
+		uxe := uxerrors.New(fmt.Errorf("missing or invalid voter token"))
+		uxe.Summary = "A voter token is required"
+		uxe.Detail = fmt.Sprintf("Requesting a change of status requires your voter token, of %d to %d characters, in the X-Voter-Token header. Provide it and try again.", minVoterTokenLength, maxVoterTokenLength)
+		return nil, uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe)
+	}
+
+	return keyedVoterHash(voterHashStatusRequest, token), nil
+}
+
+// RequestFeedbackStatus validates and adds the request of the voter
+// for the status of the feedback identified by id to change. Only
+// statuses the workflow allows from the current one may be
+// requested. It returns the request, and false if the voter already
+// made it. The representatives following the feedback are notified
+// outside of the request.
+func RequestFeedbackStatus(token string, id uint, s *models.StatusRequest) (*models.StatusRequest, bool, error) {
+	hash, err := statusRequestHash(token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.ID, s.FeedbackID, s.NotifiedAt = 0, id, nil
+	s.Reason = strings.TrimSpace(s.Reason)
+	if err := validators.ValidateStatusRequest(s); err != nil {
+		return nil, false, err
+	}
+
+	f, err := db.GetFeedback(id)
+	if err != nil {
+		return nil, false, handleDatabaseError(err)
+	}
+	if !canTransition(f.Status, s.Status) {
+		return nil, false, transitionError(f.Status, s.Status)
+	}
+
+	s.TokenHash = hash
+	s, added, err := db.AddStatusRequest(s)
+	if err != nil {
+		return nil, false, handleDatabaseError(err)
+	}
+	if added {
+		publishChanges()
+	}
+
+	return s, added, nil
+}
+
+// GetStatusRequests returns the pending status requests on the
+// feedback identified by id. Representatives only see the requests
+// on the feedback they follow, r is nil for administrators.
+func GetStatusRequests(id uint, r *models.Representative) ([]*models.StatusRequest, error) {
+	f, err := db.GetFeedback(id)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	if r != nil && !r.Follows(f) {
+		uxe := uxerrors.New(fmt.Errorf("representative %d does not follow feedback %d", r.ID, id))
+		uxe.Summary = "You are not allowed to access this resource"
+		uxe.Detail = "The feedback is on a course you do not represent. Ask a representative of the course, or an administrator."
+		return nil, uxerrors.NewErrors(http.StatusForbidden).Append(uxe)
+	}
+
+	s, err := db.GetStatusRequests(id)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return s, nil
+}
+
+// notifyStatusRequests notifies the representatives following the
+// feedback of the status requests they were not notified of yet.
+// Requests that cannot be notified now are notified on the next
+// call.
+func notifyStatusRequests() error {
+	statusRequestNotification.Lock()
+	defer statusRequestNotification.Unlock()
+
+	for {
+		requests, err := db.GetUnnotifiedStatusRequests(statusRequestBatch)
+		if err != nil {
+			return fmt.Errorf("reading the status requests: %w", err)
+		}
+
+		for _, s := range requests {
+			f, err := db.GetFeedback(s.FeedbackID)
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				// Deleted feedback needs no attention.
+			case err != nil:
+				return fmt.Errorf("reading the feedback of status request %d: %w", s.ID, err)
+			default:
+				detail := string(s.Status)
+				if s.Reason != "" {
+					detail += ": " + s.Reason
+				}
+				if err := notifyInbox(models.NotificationStatusRequest, uint64(s.ID), f, detail); err != nil {
+					return fmt.Errorf("notifying the representatives of status request %d: %w", s.ID, err)
+				}
+			}
+
+			if err := db.SetStatusRequestNotified(s.ID, time.Now()); err != nil {
+				return fmt.Errorf("recording the notification of status request %d: %w", s.ID, err)
+			}
+		}
+
+		if len(requests) < statusRequestBatch {
+			return nil
+		}
+	}
+}
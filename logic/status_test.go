@@ -0,0 +1,92 @@
+/**
+ * file: logic/status_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCanTransition tests the workflow of the status of feedback.
+func TestCanTransition(t *testing.T) {
+	assert.True(t, canTransition(models.FeedbackStatusNew, models.FeedbackStatusAcknowledged))
+	assert.True(t, canTransition(models.FeedbackStatusAcknowledged, models.FeedbackStatusResolved))
+	assert.True(t, canTransition(models.FeedbackStatusResolved, models.FeedbackStatusAcknowledged), "resolved feedback should be reopened")
+
+	assert.False(t, canTransition(models.FeedbackStatusNew, models.FeedbackStatusResolved), "feedback should be acknowledged before being resolved")
+	assert.False(t, canTransition(models.FeedbackStatusRejected, models.FeedbackStatusNew), "feedback should never become new again")
+	assert.False(t, canTransition(models.FeedbackStatusNew, models.FeedbackStatusNew))
+	assert.False(t, canTransition("unknown", models.FeedbackStatusAcknowledged))
+}
+
+// TestRequestFeedbackStatus tests that voters request the statuses
+// the workflow allows, once, and that the representatives following
+// the feedback are notified of the requests until the status changes.
+func TestRequestFeedbackStatus(t *testing.T) {
+	setupTestDatabase(t)
+
+	linfo, err := CreateRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org", Faculties: []string{"LINFO"}})
+	require.NoError(t, err, "creating the representative should not fail")
+	lepl, err := CreateRepresentative(&models.Representative{Name: "Sam", Email: "sam@example.org", Faculties: []string{"LEPL"}})
+	require.NoError(t, err, "creating the representative should not fail")
+
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+	processChanges(t)
+
+	request := func() *models.StatusRequest {
+		return &models.StatusRequest{Status: models.FeedbackStatusAcknowledged, Reason: "Nobody answered yet."}
+	}
+
+	_, _, err = RequestFeedbackStatus("", 1, request())
+	assertStatus(t, http.StatusUnauthorized, err)
+	_, _, err = RequestFeedbackStatus(testVoter, 42, request())
+	assertStatus(t, http.StatusNotFound, err)
+	_, _, err = RequestFeedbackStatus(testVoter, 1, &models.StatusRequest{Status: models.FeedbackStatusResolved})
+	assertStatus(t, http.StatusConflict, err)
+
+	s, added, err := RequestFeedbackStatus(testVoter, 1, request())
+	require.NoError(t, err, "requesting the status should not fail")
+	assert.True(t, added)
+	again, added, err := RequestFeedbackStatus(testVoter, 1, request())
+	require.NoError(t, err, "requesting the status again should not fail")
+	assert.False(t, added, "each voter should request a status once")
+	assert.Equal(t, s.ID, again.ID)
+	_, added, err = RequestFeedbackStatus(testOtherVoter, 1, request())
+	require.NoError(t, err, "requesting the status should not fail")
+	assert.True(t, added)
+
+	processChanges(t)
+	processChanges(t)
+	inbox, err := GetInbox(linfo.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	require.Len(t, inbox.Notifications, 3, "each request should be notified once")
+	assert.Equal(t, models.NotificationStatusRequest, inbox.Notifications[0].Kind)
+	assert.Equal(t, "acknowledged: Nobody answered yet.", inbox.Notifications[0].Detail)
+	inbox, err = GetInbox(lepl.ID, false)
+	require.NoError(t, err, "getting the inbox should not fail")
+	assert.Empty(t, inbox.Notifications, "only the representatives following the feedback should be notified")
+
+	_, err = GetStatusRequests(1, lepl)
+	assertStatus(t, http.StatusForbidden, err)
+	requests, err := GetStatusRequests(1, linfo)
+	require.NoError(t, err, "getting the status requests should not fail")
+	assert.Len(t, requests, 2)
+	requests, err = GetStatusRequests(1, nil)
+	require.NoError(t, err, "getting the status requests should not fail")
+	assert.Len(t, requests, 2, "administrators should see all requests")
+
+	_, err = TransitionFeedbackStatus(1, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err, "changing the status should not fail")
+	requests, err = GetStatusRequests(1, nil)
+	require.NoError(t, err, "getting the status requests should not fail")
+	assert.Empty(t, requests, "the requests should be settled by the change of status")
+}
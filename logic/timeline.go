@@ -0,0 +1,108 @@
+/**
+ * file: logic/timeline.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the analytics of the votes over
+ * time: the timeline of the votes on feedback, and
+ * the detection of feedback heating up on a course.
+ */
+
+package logic
+
+import (
+	"sort"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/validators"
+)
+
+const (
+	// trendingWindow is the recent window whose velocity is
+	// compared to the baseline, the period preceding it.
+	trendingWindow   time.Duration = 24 * time.Hour
+	trendingBaseline time.Duration = 7 * 24 * time.Hour
+
+	// Feedback is heating up once it gets at least trendingMinVotes
+	// net votes in the recent window, trendingRatio times faster than
+	// during the baseline.
+	trendingMinVotes int64   = 5
+	trendingRatio    float64 = 3
+)
+
+// timelineSpans are the default periods of the timelines, ending
+// now, for each interval.
+var timelineSpans = map[models.TimelineInterval]time.Duration{
+	models.TimelineHour: 48 * time.Hour,
+	models.TimelineDay:  30 * 24 * time.Hour,
+	models.TimelineWeek: 26 * 7 * 24 * time.Hour,
+}
+
+// GetTimeline returns the timeline of the votes on the feedback. The
+// interval defaults to a day, the end of the period to now, and its
+// start to a default span before its end.
+func GetTimeline(q *models.TimelineQuery) (*models.Timeline, error) {
+	if q.Interval == "" {
+		q.Interval = models.TimelineDay
+	}
+	if q.Until.IsZero() {
+		q.Until = time.Now()
+	}
+	if q.Since.IsZero() {
+		// Unknown intervals are reported by the validation alone.
+		span, ok := timelineSpans[q.Interval]
+		if !ok {
+			span = timelineSpans[models.TimelineDay]
+		}
+		q.Since = q.Until.Add(-span)
+	}
+	q.Since, q.Until = q.Since.UTC(), q.Until.UTC()
+
+	if err := validators.ValidateTimelineQuery(q); err != nil {
+		return nil, err
+	}
+	if _, err := db.GetFeedback(q.FeedbackID); err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	t, err := db.GetVoteTimeline(q)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return t, nil
+}
+
+// GetTrending returns the feedback on the course heating up at the
+// given time, hottest first. The recent velocity of the net score of
+// feedback is compared to its velocity during the baseline period.
+// The baseline is smoothed by one vote, so that feedback without
+// history must still get trendingMinVotes votes to heat up.
+func GetTrending(course string, now time.Time) ([]*models.Trend, error) {
+	q := &models.StatsQuery{Scope: models.StatsScopeCourse, Code: course}
+	if err := validators.ValidateStatsQuery(q); err != nil {
+		return nil, err
+	}
+
+	recentStart := now.Add(-trendingWindow).UTC()
+	trends, err := db.GetVoteTrends(course, recentStart.Add(-trendingBaseline), recentStart)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	heating := []*models.Trend{}
+	for _, t := range trends {
+		t.Velocity = float64(t.Recent) / trendingWindow.Hours()
+		t.BaselineVelocity = float64(max(t.Baseline, 0)+1) / trendingBaseline.Hours()
+		t.Ratio = t.Velocity / t.BaselineVelocity
+
+		if t.Recent >= trendingMinVotes && t.Ratio >= trendingRatio {
+			heating = append(heating, t)
+		}
+	}
+
+	sort.SliceStable(heating, func(i, j int) bool {
+		return heating[i].Ratio > heating[j].Ratio
+	})
+	return heating, nil
+}
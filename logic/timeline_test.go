@@ -0,0 +1,81 @@
+/**
+ * file: logic/timeline_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetTimeline tests that timelines default to the last 30 days,
+// and are bounded.
+func TestGetTimeline(t *testing.T) {
+	setupTestDatabase(t)
+
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = UpdateFeedbackUpvotes(1, 1, models.VoteSource{})
+		require.NoError(t, err)
+	}
+
+	timeline, err := GetTimeline(&models.TimelineQuery{FeedbackID: 1})
+	require.NoError(t, err, "getting the timeline should not fail")
+	assert.Equal(t, models.TimelineDay, timeline.Interval)
+	require.Len(t, timeline.Buckets, 31, "the timeline should span the last 30 days")
+	assert.Equal(t, int64(3), timeline.Buckets[30].Upvotes)
+
+	_, err = GetTimeline(&models.TimelineQuery{FeedbackID: 42})
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = GetTimeline(&models.TimelineQuery{FeedbackID: 1, Interval: "minute"})
+	assertStatus(t, http.StatusBadRequest, err)
+	assert.Len(t, err.(uxerrors.Errors).Errors, 1, "only the interval should be reported")
+	_, err = GetTimeline(&models.TimelineQuery{FeedbackID: 1, Interval: models.TimelineHour, Since: time.Now().AddDate(-1, 0, 0)})
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+// TestGetTrending tests that feedback heats up when its recent votes
+// come faster than during the baseline.
+func TestGetTrending(t *testing.T) {
+	d := setupTestDatabase(t)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := AddFeedback(newTestFeedback())
+		require.NoError(t, err)
+	}
+	vote := func(id uint, n int, at time.Time) {
+		for i := 0; i < n; i++ {
+			_, err := d.AddVote(&models.Vote{FeedbackID: id, Kind: models.VoteKindUpvote, Delta: 1, CreatedAt: at})
+			require.NoError(t, err)
+		}
+	}
+
+	// The first feedback heats up, the second keeps its pace, and
+	// the third does not get enough votes.
+	vote(1, 2, now.Add(-3*24*time.Hour))
+	vote(1, 10, now.Add(-time.Hour))
+	vote(2, 70, now.Add(-3*24*time.Hour))
+	vote(2, 10, now.Add(-time.Hour))
+	vote(3, 4, now.Add(-time.Hour))
+
+	trends, err := GetTrending("linfo1101", now)
+	require.NoError(t, err, "detecting trends should not fail")
+	require.Len(t, trends, 1, "only the feedback heating up should be returned")
+	assert.Equal(t, uint(1), trends[0].Feedback.ID)
+	assert.Equal(t, int64(10), trends[0].Recent)
+	assert.InDelta(t, 10.0/24/(3.0/168), trends[0].Ratio, 1e-9)
+
+	_, err = GetTrending("cooking", now)
+	assertStatus(t, http.StatusBadRequest, err)
+}
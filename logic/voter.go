@@ -0,0 +1,85 @@
+/**
+ * file: logic/voter.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the keyed hashes of the anonymous
+ * voter tokens. Tokens are never stored: votes, follows
+ * and status requests only keep HMACs of them, keyed with
+ * a server secret kept out of the database and derived
+ * per use, so that none can be linked to the others, nor
+ * to a token, from the database alone.
+ */
+
+package logic
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// MinVoterSecretLength is the minimum length in bytes of the secret
+// the voter tokens are hashed with.
+const MinVoterSecretLength int = 32
+
+// The uses of the voter token hashes, and of the hashes of other
+// credentials. Each use derives its own key from the secret, so that
+// hashes of one use never match another.
+const (
+	voterHashFollow        string = "follow"
+	voterHashVote          string = "vote"
+	voterHashTokenFamily   string = "token-family"
+	voterHashIdempotency   string = "idempotency"
+	voterHashStatusRequest string = "status-request"
+)
+
+// voterSecret is the secret the voter tokens are hashed with. It is
+// random until SetVoterSecret is called, so that tokens are never
+// hashed without a key.
+var voterSecret []byte = func() []byte {
+	secret := make([]byte, MinVoterSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// SetVoterSecret sets the secret the voter tokens are hashed with. It
+// must be kept out of the database, and unchanged across restarts,
+// for the follows and the vote analysis to keep recognizing voters.
+func SetVoterSecret(secret []byte) error {
+	if len(secret) < MinVoterSecretLength {
+		return fmt.Errorf("voter secret of %d bytes, at least %d are required", len(secret), MinVoterSecretLength)
+	}
+
+	voterSecret = secret
+	return nil
+}
+
+// keyedVoterHash returns the HMAC of the voter token for the given
+// use.
+func keyedVoterHash(use string, token string) []byte {
+	key := hmac.New(sha256.New, voterSecret)
+	key.Write([]byte(use))
+
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// voteTokenHashes returns the hashes of the voter token and of its
+// family stored with a vote, empty if the vote carries no token.
+func voteTokenHashes(token string) (hash string, family string) {
+	if token == "" {
+		return "", ""
+	}
+
+	hash = hex.EncodeToString(keyedVoterHash(voterHashVote, token))
+	if prefix := tokenFamily(token, analysisConfig.TokenFamilyLength); prefix != "" {
+		family = hex.EncodeToString(keyedVoterHash(voterHashTokenFamily, prefix))
+	}
+	return hash, family
+}
@@ -0,0 +1,72 @@
+/**
+ * file: logic/voter_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetVoterSecret tests that short secrets are refused, and that
+// the hashes depend on the secret.
+func TestSetVoterSecret(t *testing.T) {
+	previous := voterSecret
+	t.Cleanup(func() { voterSecret = previous })
+
+	assert.Error(t, SetVoterSecret([]byte("short")))
+	require.NoError(t, SetVoterSecret(bytes.Repeat([]byte("a"), MinVoterSecretLength)))
+	hash, err := voterHash(testVoter)
+	require.NoError(t, err)
+
+	require.NoError(t, SetVoterSecret(bytes.Repeat([]byte("b"), MinVoterSecretLength)))
+	other, err := voterHash(testVoter)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "the hash should depend on the secret")
+}
+
+// TestVoteTokenUnlinked tests that votes store neither the voter
+// token nor the hash the follows are keyed on.
+func TestVoteTokenUnlinked(t *testing.T) {
+	setupTestDatabase(t)
+	_, err := db.AddFeedback(newTestFeedback())
+	require.NoError(t, err)
+
+	_, err = UpdateFeedbackUpvotes(1, 1, models.VoteSource{Token: testVoter})
+	require.NoError(t, err)
+	_, err = UpdateFeedbackUpvotes(1, 1, models.VoteSource{Token: testOtherVoter})
+	require.NoError(t, err)
+
+	votes, err := db.GetVotesSince(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, votes, 2)
+
+	hash, err := voterHash(testVoter)
+	require.NoError(t, err)
+	v := votes[0]
+	assert.NotEqual(t, testVoter, v.Token, "the token should not be stored")
+	assert.NotEqual(t, hex.EncodeToString(hash), v.Token, "votes should not be linked to follows")
+	assert.NotEqual(t, votes[1].Token, v.Token, "the voters should be told apart")
+	assert.NotEmpty(t, v.TokenFamily)
+	assert.Equal(t, votes[1].TokenFamily, v.TokenFamily, "tokens of one family should share their family")
+
+	_, err = UpdateFeedbackUpvotes(1, -1, models.VoteSource{Token: testVoter})
+	require.NoError(t, err)
+	votes, err = db.GetVotesSince(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, votes, 3)
+	assert.Equal(t, v.Token, votes[2].Token, "the voter should be recognized")
+
+	_, err = voterHash("short")
+	assertStatus(t, http.StatusUnauthorized, err)
+}
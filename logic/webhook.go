@@ -0,0 +1,316 @@
+/**
+ * file: logic/webhook.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the outgoing webhooks, which push
+ * the changes made to feedback to external tools.
+ * Changes are queued for each webhook from the change
+ * feed, then delivered as signed requests and retried
+ * with an exponential backoff.
+ */
+
+package logic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"git.licolas.net/delegit/delegit/validators"
+	"gorm.io/gorm"
+)
+
+const (
+	// WebhookSignatureHeader carries the signature of a delivery,
+	// as "t=<unix time>,sha256=<hex HMAC>". The HMAC-SHA256 is
+	// computed with the secret of the webhook over the time, a dot
+	// and the body.
+	WebhookSignatureHeader string = "X-Delegit-Signature"
+	WebhookEventHeader     string = "X-Delegit-Event"
+	WebhookDeliveryHeader  string = "X-Delegit-Delivery"
+
+	webhookMaxAttempts  int           = 8
+	webhookBaseBackoff  time.Duration = 30 * time.Second
+	webhookMaxBackoff   time.Duration = 6 * time.Hour
+	webhookTimeout      time.Duration = 10 * time.Second
+	webhookLease        time.Duration = time.Minute
+	webhookBatchSize    int           = 100
+	webhookDeliveryList int           = 50
+	webhookErrorLength  int           = 500
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// webhookNotFound returns the error for an unknown webhook or
+// delivery, or err itself for other errors.
+func webhookNotFound(err error) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return handleDatabaseError(err)
+	}
+
+	uxe := uxerrors.New(err)
+	uxe.Summary = "The webhook was not found"
+	uxe.Detail = "The webhook or delivery you requested does not exist. Check the identifier and try again."
+	return uxerrors.NewErrors(http.StatusNotFound).Append(uxe)
+}
+
+// hideSecret removes the secret of the webhooks, which is never
+// returned to clients.
+func hideSecret(webhooks ...*models.Webhook) {
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+}
+
+// CreateWebhook validates and adds the webhook. Changes made from
+// now on are delivered to it.
+func CreateWebhook(w *models.Webhook) (*models.Webhook, error) {
+	w.ID = 0
+	w.Cursor = 0
+	if err := validators.ValidateWebhook(w); err != nil {
+		return nil, err
+	}
+
+	w, err := db.AddWebhook(w)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	hideSecret(w)
+	return w, nil
+}
+
+func GetWebhooks() ([]*models.Webhook, error) {
+	w, err := db.GetWebhooks()
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	hideSecret(w...)
+	return w, nil
+}
+
+func GetWebhook(id uint) (*models.Webhook, error) {
+	w, err := db.GetWebhook(id)
+	if err != nil {
+		return nil, webhookNotFound(err)
+	}
+
+	hideSecret(w)
+	return w, nil
+}
+
+// DeleteWebhook deletes the webhook. Its pending deliveries are
+// dropped.
+func DeleteWebhook(id uint) error {
+	if err := db.DeleteWebhook(id); err != nil {
+		return webhookNotFound(err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook,
+// with the log of their attempts.
+func GetWebhookDeliveries(id uint) ([]*models.WebhookDelivery, error) {
+	if _, err := db.GetWebhook(id); err != nil {
+		return nil, webhookNotFound(err)
+	}
+
+	d, err := db.GetWebhookDeliveries(id, webhookDeliveryList)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	return d, nil
+}
+
+// RedeliverWebhook queues the delivery of the webhook again, as a
+// fresh delivery. It is attempted on the next run of the deliveries.
+func RedeliverWebhook(id, delivery uint) (*models.WebhookDelivery, error) {
+	d, err := db.RedeliverWebhookDelivery(id, delivery, time.Now().UTC())
+	if err != nil {
+		return nil, webhookNotFound(err)
+	}
+	return d, nil
+}
+
+// ProcessWebhooks queues the changes made since the last run for
+// the webhooks, then attempts the deliveries due at the given time.
+// It returns the number of successful deliveries.
+func ProcessWebhooks(now time.Time) (int, error) {
+	if err := enqueueWebhooks(now); err != nil {
+		return 0, err
+	}
+	return deliverWebhooks(now)
+}
+
+// enqueueWebhooks queues a delivery for each change wanted by each
+// webhook, from the change feed. The deliveries are due at the
+// given time.
+func enqueueWebhooks(now time.Time) error {
+	webhooks, err := db.GetWebhooks()
+	if err != nil {
+		return handleDatabaseError(err)
+	}
+
+	for _, w := range webhooks {
+		for {
+			feed, err := GetChanges(w.Cursor, webhookBatchSize)
+			if err != nil {
+				return err
+			}
+
+			var deliveries []*models.WebhookDelivery
+			for _, c := range feed.Changes {
+				if !w.Wants(c) {
+					continue
+				}
+
+				payload, err := json.Marshal(models.WebhookEvent{Webhook: w.ID, Event: c.Kind, Change: c})
+				if err != nil {
+					return uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err)
+				}
+				deliveries = append(deliveries, &models.WebhookDelivery{
+					WebhookID:     w.ID,
+					Seq:           c.Seq,
+					Event:         c.Kind,
+					Payload:       payload,
+					Status:        models.WebhookDeliveryPending,
+					NextAttemptAt: now.UTC(),
+				})
+			}
+
+			// The changes were queued concurrently, they are not
+			// queued twice.
+			enqueued, err := db.EnqueueWebhookDeliveries(w, deliveries, feed.Cursor)
+			if err != nil {
+				return handleDatabaseError(err)
+			}
+			if !enqueued || !feed.More {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// deliverWebhooks attempts the deliveries due at the given time.
+// Failed deliveries are retried with an exponential backoff, until
+// they run out of attempts.
+func deliverWebhooks(now time.Time) (int, error) {
+	now = now.UTC()
+	due, err := db.GetDueWebhookDeliveries(now, webhookBatchSize)
+	if err != nil {
+		return 0, handleDatabaseError(err)
+	}
+
+	webhooks := make(map[uint]*models.Webhook)
+	delivered := 0
+	for _, d := range due {
+		w, ok := webhooks[d.WebhookID]
+		if !ok {
+			if w, err = db.GetWebhook(d.WebhookID); err != nil {
+				return delivered, handleDatabaseError(err)
+			}
+			webhooks[d.WebhookID] = w
+		}
+
+		claimed, err := db.ClaimWebhookDelivery(d, now, now.Add(webhookLease))
+		if err != nil {
+			return delivered, handleDatabaseError(err)
+		}
+		if !claimed {
+			continue
+		}
+
+		a := attemptWebhookDelivery(w, d, now)
+		d.Attempts++
+		switch {
+		case a.Error == "":
+			d.Status = models.WebhookDeliveryDelivered
+			delivered++
+		case d.Attempts >= webhookMaxAttempts:
+			d.Status = models.WebhookDeliveryFailed
+		default:
+			d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+		}
+
+		if err := db.RecordWebhookAttempt(d, a); err != nil {
+			return delivered, handleDatabaseError(err)
+		}
+	}
+
+	return delivered, nil
+}
+
+// webhookBackoff returns the delay before retrying a delivery after
+// the given number of failed attempts.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+// attemptWebhookDelivery sends the delivery to the webhook, and
+// returns the log of the attempt. Any response other than a 2xx is
+// a failure.
+func attemptWebhookDelivery(w *models.Webhook, d *models.WebhookDelivery, now time.Time) *models.WebhookAttempt {
+	a := &models.WebhookAttempt{CreatedAt: now}
+	start := time.Now()
+	defer func() { a.Duration = time.Since(start) }()
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		a.Error = truncate(err.Error(), webhookErrorLength)
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "delegit-webhooks")
+	req.Header.Set(WebhookEventHeader, string(d.Event))
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(WebhookSignatureHeader, signWebhook(w.Secret, now.Unix(), d.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		a.Error = truncate(err.Error(), webhookErrorLength)
+		return a
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return a
+}
+
+// signWebhook returns the signature of the body sent at the given
+// time, as sent in the WebhookSignatureHeader.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,sha256=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// truncate shortens the string to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
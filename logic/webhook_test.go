@@ -0,0 +1,159 @@
+/**
+ * file: logic/webhook_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package logic
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebhookSecret = "0123456789abcdef"
+
+// webhookReceiver records the deliveries it receives, and answers
+// with the given status.
+type webhookReceiver struct {
+	mu         sync.Mutex
+	status     int
+	deliveries []*http.Request
+	bodies     [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func newWebhookReceiver(t *testing.T, status int) (*webhookReceiver, string) {
+	r := &webhookReceiver{status: status}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+// TestProcessWebhooks tests that the wanted changes are delivered
+// with a valid signature, on the course of the webhook only.
+func TestProcessWebhooks(t *testing.T) {
+	setupTestDatabase(t)
+	receiver, url := newWebhookReceiver(t, http.StatusNoContent)
+
+	_, err := AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+
+	w, err := CreateWebhook(&models.Webhook{
+		URL:    url,
+		Events: []models.ChangeKind{models.ChangeKindCreate, models.ChangeKindVote},
+		Course: "LINFO1101",
+		Secret: testWebhookSecret,
+	})
+	require.NoError(t, err, "creating the webhook should not fail")
+	assert.Empty(t, w.Secret, "the secret should not be returned")
+
+	other := newTestFeedback()
+	other.Course = "LINFO1002"
+	_, err = AddFeedback(other)
+	require.NoError(t, err, "adding feedback should not fail")
+	_, err = UpdateFeedbackUpvotes(1, 1, models.VoteSource{Token: "token"})
+	require.NoError(t, err, "voting should not fail")
+	_, err = TransitionFeedbackStatus(1, models.FeedbackStatusAcknowledged, nil)
+	require.NoError(t, err, "transitioning should not fail")
+
+	now := time.Now()
+	delivered, err := ProcessWebhooks(now)
+	require.NoError(t, err, "processing the webhooks should not fail")
+	assert.Equal(t, 1, delivered, "only the vote should be delivered")
+
+	require.Len(t, receiver.deliveries, 1)
+	req, body := receiver.deliveries[0], receiver.bodies[0]
+	assert.Equal(t, "vote", req.Header.Get(WebhookEventHeader))
+	assert.Equal(t, signWebhook(testWebhookSecret, now.Unix(), body), req.Header.Get(WebhookSignatureHeader), "the delivery should be signed")
+
+	var event models.WebhookEvent
+	require.NoError(t, json.Unmarshal(body, &event), "the payload should be JSON")
+	assert.Equal(t, w.ID, event.Webhook)
+	assert.Equal(t, uint(1), event.Change.FeedbackID)
+	assert.Equal(t, uint64(1), event.Change.Feedback.Upvotes, "the feedback should be delivered as it was after the change")
+
+	delivered, err = ProcessWebhooks(now.Add(time.Hour))
+	require.NoError(t, err, "processing the webhooks should not fail")
+	assert.Zero(t, delivered, "changes should be delivered once")
+
+	deliveries, err := GetWebhookDeliveries(w.ID)
+	require.NoError(t, err, "getting the deliveries should not fail")
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	require.Len(t, deliveries[0].Log, 1, "the attempt should be logged")
+	assert.Equal(t, http.StatusNoContent, deliveries[0].Log[0].StatusCode)
+}
+
+// TestProcessWebhooksRetry tests that failed deliveries are retried
+// with an exponential backoff until they run out of attempts, and
+// can be redelivered manually.
+func TestProcessWebhooksRetry(t *testing.T) {
+	setupTestDatabase(t)
+	receiver, url := newWebhookReceiver(t, http.StatusInternalServerError)
+
+	w, err := CreateWebhook(&models.Webhook{URL: url, Secret: testWebhookSecret})
+	require.NoError(t, err, "creating the webhook should not fail")
+	_, err = AddFeedback(newTestFeedback())
+	require.NoError(t, err, "adding feedback should not fail")
+
+	now := time.Now()
+	for i := 1; i <= webhookMaxAttempts; i++ {
+		_, err = ProcessWebhooks(now)
+		require.NoError(t, err, "processing the webhooks should not fail")
+		require.Len(t, receiver.deliveries, i, "the delivery should be attempted once it is due")
+
+		_, err = ProcessWebhooks(now.Add(webhookBackoff(i) - time.Second))
+		require.NoError(t, err, "processing the webhooks should not fail")
+		require.Len(t, receiver.deliveries, i, "the delivery should not be retried before the backoff")
+
+		now = now.Add(webhookBackoff(i))
+	}
+
+	deliveries, err := GetWebhookDeliveries(w.ID)
+	require.NoError(t, err, "getting the deliveries should not fail")
+	require.Len(t, deliveries, 1)
+	d := deliveries[0]
+	assert.Equal(t, models.WebhookDeliveryFailed, d.Status, "the delivery should fail after the last attempt")
+	assert.Len(t, d.Log, webhookMaxAttempts, "all attempts should be logged")
+	assert.True(t, strings.Contains(d.Log[0].Error, "500"), "the failure should be logged")
+
+	receiver.mu.Lock()
+	receiver.status = http.StatusOK
+	receiver.mu.Unlock()
+	_, err = RedeliverWebhook(w.ID, d.ID)
+	require.NoError(t, err, "redelivering should not fail")
+	delivered, err := ProcessWebhooks(time.Now().Add(time.Second))
+	require.NoError(t, err, "processing the webhooks should not fail")
+	assert.Equal(t, 1, delivered, "the delivery should be redelivered")
+
+	_, err = RedeliverWebhook(w.ID, d.ID+1)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+// TestWebhookBackoff tests that the backoff doubles on each attempt,
+// up to its maximum.
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, webhookBaseBackoff, webhookBackoff(1))
+	assert.Equal(t, 4*webhookBaseBackoff, webhookBackoff(3))
+	assert.Equal(t, webhookMaxBackoff, webhookBackoff(100))
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"git.licolas.net/delegit/delegit/database"
 	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
 	"git.licolas.net/delegit/delegit/routes"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -16,6 +20,17 @@ import (
 const (
 	host string = "0.0.0.0"
 	port uint   = 41990
+
+	databaseKind string = "sqlite"
+	databaseDSN  string = "feedback.db?_busy_timeout=5000&_journal_mode=WAL"
+
+	changeInterval           time.Duration = 5 * time.Second
+	voteAnalysisInterval     time.Duration = 5 * time.Minute
+	idempotencyPurgeInterval time.Duration = time.Hour
+	webhookInterval          time.Duration = 5 * time.Second
+	digestInterval           time.Duration = 15 * time.Minute
+	publicationInterval      time.Duration = time.Minute
+	statsReleaseInterval     time.Duration = time.Hour
 )
 
 var (
@@ -35,18 +50,336 @@ func GetLogger(module string) zerolog.Logger {
 	return globalLogger.With().Str("module", module).Logger()
 }
 
-func main() {
-	logger.Info().Str("host", host).Uint("port", port).Msg("starting server")
-	db, err := database.NewDatabase("sqlite", "feedback.db")
+// processChanges relays the changes to the live subscribers and
+// evaluates the alert rules on them as they are committed, and
+// periodically to retry the changes which could not be processed.
+func processChanges() {
+	ticker := time.NewTicker(changeInterval)
+	for {
+		select {
+		case <-logic.ChangesPublished():
+		case <-ticker.C:
+		}
+
+		if err := logic.ProcessChanges(); err != nil {
+			logger.Error().Err(err).Msg("processing changes failed")
+		}
+	}
+}
+
+// analyzeVotes periodically runs the vote-manipulation detection.
+func analyzeVotes() {
+	for now := range time.Tick(voteAnalysisInterval) {
+		n, err := logic.AnalyzeVotes(now)
+		if err != nil {
+			logger.Error().Err(err).Msg("vote analysis failed")
+			continue
+		}
+		if n > 0 {
+			logger.Warn().Int("votes", n).Msg("quarantined suspicious votes")
+		}
+	}
+}
+
+// expireIdempotencyKeys periodically removes expired idempotency
+// keys.
+func expireIdempotencyKeys() {
+	for now := range time.Tick(idempotencyPurgeInterval) {
+		if _, err := logic.ExpireIdempotencyKeys(now); err != nil {
+			logger.Error().Err(err).Msg("idempotency key expiry failed")
+		}
+	}
+}
+
+// deliverWebhooks periodically delivers the changes to the
+// webhooks.
+func deliverWebhooks() {
+	for now := range time.Tick(webhookInterval) {
+		if _, err := logic.ProcessWebhooks(now); err != nil {
+			logger.Error().Err(err).Msg("webhook delivery failed")
+		}
+	}
+}
+
+// sendDigests periodically sends the digests to the
+// representatives.
+func sendDigests() {
+	for now := range time.Tick(digestInterval) {
+		n, err := logic.SendDigests(now)
+		if err != nil {
+			logger.Error().Err(err).Msg("sending digests failed")
+		}
+		if n > 0 {
+			logger.Info().Int("digests", n).Msg("sent digests")
+		}
+	}
+}
+
+// publishHeldFeedback periodically publishes the held feedback due
+// for publication.
+func publishHeldFeedback() {
+	for now := range time.Tick(publicationInterval) {
+		n, err := logic.PublishHeldFeedback(now)
+		if err != nil {
+			logger.Error().Err(err).Msg("publishing held feedback failed")
+		}
+		if n > 0 {
+			logger.Info().Int("feedback", n).Msg("published held feedback")
+		}
+	}
+}
+
+// releaseStats periodically releases the private statistics due for
+// release.
+func releaseStats() {
+	for now := range time.Tick(statsReleaseInterval) {
+		n, err := logic.ReleaseStats(now)
+		if err != nil {
+			logger.Error().Err(err).Msg("releasing statistics failed")
+		}
+		if n > 0 {
+			logger.Info().Int("statistics", n).Msg("released statistics")
+		}
+	}
+}
+
+// setupPublication configures the publication policy of new feedback
+// from the environment.
+func setupPublication() {
+	p := logic.DefaultPublicationPolicy
+	if env := os.Getenv("DELEGIT_PUBLICATION_K"); env != "" {
+		k, err := strconv.ParseInt(env, 10, 64)
+		if err != nil || k < 1 {
+			logger.Fatal().Str("DELEGIT_PUBLICATION_K", env).Msg("invalid publication policy")
+		}
+		p.K = k
+	}
+	for name, d := range map[string]*time.Duration{"DELEGIT_PUBLICATION_MIN_DELAY": &p.MinDelay, "DELEGIT_PUBLICATION_MAX_DELAY": &p.MaxDelay} {
+		env := os.Getenv(name)
+		if env == "" {
+			continue
+		}
+
+		delay, err := time.ParseDuration(env)
+		if err != nil || delay < 0 {
+			logger.Fatal().Str(name, env).Msg("invalid publication policy")
+		}
+		*d = delay
+	}
+
+	if p.MinDelay > p.MaxDelay {
+		logger.Fatal().Dur("min", p.MinDelay).Dur("max", p.MaxDelay).Msg("the minimum publication delay exceeds the maximum")
+	}
+	logic.SetPublicationPolicy(p)
+}
+
+// setupEmail configures the email notifications from the
+// environment. Emails are disabled unless an SMTP host is set.
+func setupEmail() {
+	host := os.Getenv("DELEGIT_SMTP_HOST")
+	if host == "" {
+		return
+	}
+
+	port := 587
+	if p := os.Getenv("DELEGIT_SMTP_PORT"); p != "" {
+		var err error
+		if port, err = strconv.Atoi(p); err != nil {
+			logger.Fatal().Str("port", p).Msg("invalid SMTP port")
+		}
+	}
+
+	logic.SetNotifyChannel(notify.NewSMTPChannel(notify.SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("DELEGIT_SMTP_USERNAME"),
+		Password: os.Getenv("DELEGIT_SMTP_PASSWORD"),
+		From:     os.Getenv("DELEGIT_SMTP_FROM"),
+	}))
+	logic.RegisterAlertChannel("email", logic.EmailAlertChannel{OnError: func(err error) {
+		logger.Error().Err(err).Msg("sending alert email failed")
+	}})
+	go sendDigests()
+}
+
+// setupPush configures the push messages to the voters from the
+// environment. Push is disabled unless a VAPID key is set.
+func setupPush() {
+	key := os.Getenv("DELEGIT_VAPID_PRIVATE_KEY")
+	if key == "" {
+		return
+	}
+
+	w, err := notify.NewWebPush(key, os.Getenv("DELEGIT_VAPID_SUBJECT"))
 	if err != nil {
-		logger.Fatal().Err(err).Msg("unable to get database")
+		logger.Fatal().Err(err).Msg("invalid Web Push configuration")
 	}
+	if hosts := os.Getenv("DELEGIT_PUSH_HOSTS"); hosts != "" {
+		w.Hosts = strings.Split(hosts, ",")
+	}
+	logic.SetWebPush(w, func(err error) {
+		logger.Error().Err(err).Msg("sending push message failed")
+	})
+}
+
+// setupPrivacy configures the privacy layer of the public statistics
+// from the environment.
+func setupPrivacy() {
+	c := logic.DefaultPrivacyConfig
+	for name, v := range map[string]*float64{"DELEGIT_PRIVACY_EPSILON": &c.Epsilon, "DELEGIT_PRIVACY_BUDGET": &c.Budget} {
+		env := os.Getenv(name)
+		if env == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(env, 64)
+		if err != nil || f <= 0 {
+			logger.Fatal().Str(name, env).Msg("invalid privacy configuration")
+		}
+		*v = f
+	}
+	if env := os.Getenv("DELEGIT_PRIVACY_K"); env != "" {
+		k, err := strconv.ParseInt(env, 10, 64)
+		if err != nil || k < 0 {
+			logger.Fatal().Str("DELEGIT_PRIVACY_K", env).Msg("invalid privacy configuration")
+		}
+		c.K = k
+	}
+	if env := os.Getenv("DELEGIT_PRIVACY_INTERVAL"); env != "" {
+		interval, err := time.ParseDuration(env)
+		if err != nil || interval <= 0 {
+			logger.Fatal().Str("DELEGIT_PRIVACY_INTERVAL", env).Msg("invalid privacy configuration")
+		}
+		c.Interval = interval
+	}
+
+	if c.Epsilon > c.Budget {
+		logger.Fatal().Float64("epsilon", c.Epsilon).Float64("budget", c.Budget).Msg("the privacy loss of statistics exceeds the budget")
+	}
+	logic.SetPrivacyConfig(c)
+}
+
+// setupVoterSecret configures the secret the voter tokens are hashed
+// with from the environment. A random secret is used when unset.
+func setupVoterSecret() {
+	secret := os.Getenv("DELEGIT_VOTER_SECRET")
+	if secret == "" {
+		logger.Warn().Msg("no voter secret set, follows and vote analysis will not recognize voters after a restart")
+		return
+	}
+
+	if err := logic.SetVoterSecret([]byte(secret)); err != nil {
+		logger.Fatal().Err(err).Msg("invalid voter secret")
+	}
+}
+
+// generateVAPIDKey prints a new VAPID private key, for the
+// DELEGIT_VAPID_PRIVATE_KEY variable.
+func generateVAPIDKey() int {
+	key, err := notify.GenerateVAPIDKey()
+	if err != nil {
+		logger.Error().Err(err).Msg("generating the VAPID key failed")
+		return 1
+	}
+
+	fmt.Println(key)
+	return 0
+}
+
+// logAlertChannel is the alert channel writing the alerts to the
+// log.
+type logAlertChannel struct{}
+
+func (logAlertChannel) SendAlert(a *models.Alert) {
+	logger.Info().
+		Str("rule", a.Rule.Name).
+		Uint("feedback", a.FeedbackID).
+		Str("course", a.Feedback.Course).
+		Float64("value", a.Value).
+		Msg("alert fired")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [migrate up|down|status | vapid-key | report [-term TERM] [-format html|pdf] [-o FILE] course|faculty CODE | import [-mapping FILE] [-source NAME] [-dry-run] FILE]\n", os.Args[0])
+}
+
+func serve(db *database.Database) {
+	if err := db.CheckSchemaVersion(); err != nil {
+		logger.Fatal().Err(err).Msg("refusing to serve, run the migrations first")
+	}
+
+	logger.Info().Str("host", host).Uint("port", port).Msg("starting server")
 	logic.Setup(db)
+	logic.RegisterAlertChannel("log", logAlertChannel{})
+	logic.RegisterAlertChannel("inbox", logic.InboxAlertChannel{OnError: func(err error) {
+		logger.Error().Err(err).Msg("adding alert to inboxes failed")
+	}})
+	setupEmail()
+	setupPush()
+	setupPrivacy()
+	setupPublication()
+	setupVoterSecret()
+	if window := os.Getenv("DELEGIT_IDEMPOTENCY_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			logger.Fatal().Str("window", window).Msg("invalid idempotency window")
+		}
+		logic.SetIdempotencyWindow(d)
+	}
+	go processChanges()
+	go analyzeVotes()
+	go expireIdempotencyKeys()
+	go deliverWebhooks()
+	go publishHeldFeedback()
+	go releaseStats()
 
 	r := gin.Default()
+	routes.SetAdminToken(os.Getenv("DELEGIT_ADMIN_TOKEN"))
+	if origins := os.Getenv("DELEGIT_SESSION_ORIGINS"); origins != "" {
+		routes.SetSessionOrigins(strings.Split(origins, ","))
+	}
 	routes.RegisterFeedbackEndpoints(db, r)
+	routes.RegisterModerationEndpoints(r)
+	routes.RegisterBatchEndpoints(r)
+	routes.RegisterChangeEndpoints(r)
+	routes.RegisterSessionEndpoints(r)
+	routes.RegisterWebhookEndpoints(r)
+	routes.RegisterAlertEndpoints(r)
+	routes.RegisterRepresentativeEndpoints(r)
+	routes.RegisterInboxEndpoints(r)
+	routes.RegisterFollowEndpoints(r)
+	routes.RegisterStatsEndpoints(r)
+	routes.RegisterReportEndpoints(r)
+	routes.RegisterImportEndpoints(r)
+	routes.RegisterMeetingEndpoints(r)
 
-	err = http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), r)
+	err := http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), r)
 
 	fmt.Printf("%e\n", err)
 }
+
+func main() {
+	db, err := database.NewDatabase(databaseKind, databaseDSN)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("unable to get database")
+	}
+
+	if len(os.Args) < 2 {
+		serve(db)
+		return
+	}
+
+	switch os.Args[1] {
+	case "migrate":
+		os.Exit(migrate(db, os.Args[2:]))
+	case "vapid-key":
+		os.Exit(generateVAPIDKey())
+	case "report":
+		os.Exit(report(db, os.Args[2:]))
+	case "import":
+		os.Exit(importFeedback(db, os.Args[2:]))
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,79 @@
+/**
+ * file: migrate.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the migrate subcommand, which
+ * applies and rolls back the schema migrations.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"git.licolas.net/delegit/delegit/database"
+)
+
+// migrate runs the migrate subcommand, and returns the exit code.
+//
+//	migrate up      applies all pending migrations
+//	migrate down    rolls back the last migration applied
+//	migrate status  lists all migrations and whether they are applied
+func migrate(db *database.Database, args []string) int {
+	if len(args) != 1 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, m := range applied {
+			logger.Info().Uint("version", m.Version).Str("name", m.Name).Msg("applied migration")
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("unable to apply migrations")
+			return 1
+		}
+		if len(applied) == 0 {
+			logger.Info().Msg("database is up to date")
+		}
+	case "down":
+		m, err := db.MigrateDown()
+		if errors.Is(err, database.ErrNoMigration) {
+			logger.Info().Msg("no migration to roll back")
+			return 0
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("unable to roll back migration")
+			return 1
+		}
+		logger.Info().Uint("version", m.Version).Str("name", m.Name).Msg("rolled back migration")
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			logger.Error().Err(err).Msg("unable to get migration status")
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range status {
+			applied := "no"
+			if s.Applied {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		usage()
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AlertRuleKind is the condition an alert rule fires on.
+type AlertRuleKind string
+
+const (
+	// AlertRuleNetScore fires when the upvotes minus the downvotes of
+	// a feedback reach the threshold. If the rule has a window, only
+	// the votes cast within it are counted.
+	AlertRuleNetScore AlertRuleKind = "net-score"
+
+	// AlertRuleUpvoteRatio fires when the share of upvotes of a
+	// feedback reaches the threshold, once it has at least MinVotes
+	// votes.
+	AlertRuleUpvoteRatio AlertRuleKind = "upvote-ratio"
+
+	// AlertRuleCreated fires when feedback is created.
+	AlertRuleCreated AlertRuleKind = "created"
+)
+
+// The AlertRule structure describes when representatives should be
+// alerted about feedback gaining traction. Rules are evaluated on
+// every vote and new feedback, and fire once per feedback.
+type AlertRule struct {
+	// Each rule is identified uniquely by their ID, attributed by
+	// the database.
+	ID uint `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+
+	Name string `gorm:"<-;size:100;not null" json:"Name" validate:"required,max=100"`
+
+	// Course and Faculty restrict the rule to the feedback on a
+	// course, or on the courses of a faculty, such as LINFO. The rule
+	// applies to all feedback if neither is set.
+	Course  string `gorm:"<-;size:10" json:"Course" validate:"omitempty,iscourse"`
+	Faculty string `gorm:"<-;size:6" json:"Faculty" validate:"omitempty,alpha,min=2,max=6"`
+
+	// Tag restricts the rule to the feedback tagged with it, such as
+	// schedule-conflict.
+	Tag string `gorm:"<-;size:30" json:"Tag" validate:"omitempty,istag"`
+
+	Kind AlertRuleKind `gorm:"<-;size:20;not null" json:"Kind" validate:"required,oneof=net-score upvote-ratio created"`
+
+	// Threshold is the net score the rule fires at, or the upvote
+	// ratio the rule fires above.
+	Threshold float64 `gorm:"<-;not null;default:0" json:"Threshold" validate:"gte=0"`
+
+	// MinVotes is the number of votes a feedback needs before its
+	// upvote ratio is considered.
+	MinVotes uint64 `gorm:"<-;type:bigint;not null;default:0" json:"MinVotes" validate:"-"`
+
+	// Window restricts the net score to the votes cast within it,
+	// such as "48h". All votes are counted if it is empty.
+	Window string `gorm:"<-;column:vote_window;size:20" json:"Window" validate:"max=20"`
+
+	// Channels are the names of the notification channels the
+	// alerts are routed to.
+	Channels []string `gorm:"<-;serializer:json" json:"Channels" validate:"required,min=1,dive,required,max=50"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt" validate:"-"`
+}
+
+// Matches returns true if the rule applies to the feedback.
+func (r *AlertRule) Matches(f *Feedback) bool {
+	if r.Tag != "" && !f.Tags.Has(r.Tag) {
+		return false
+	}
+
+	switch {
+	case r.Course != "":
+		return strings.EqualFold(r.Course, f.Course)
+	case r.Faculty != "":
+		return strings.EqualFold(r.Faculty, f.Faculty())
+	default:
+		return true
+	}
+}
+
+// The Alert structure records that a rule fired on a feedback.
+type Alert struct {
+	ID         uint `gorm:"<-:create;primaryKey" json:"ID"`
+	RuleID     uint `gorm:"<-:create;not null;uniqueIndex:idx_alerts_rule_feedback" json:"RuleID"`
+	FeedbackID uint `gorm:"<-:create;not null;uniqueIndex:idx_alerts_rule_feedback" json:"FeedbackID"`
+
+	// Value is the net score or upvote ratio that fired the rule.
+	Value float64 `gorm:"<-:create;not null" json:"Value"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt"`
+
+	// Rule and Feedback are set when the alert is routed.
+	Rule     *AlertRule `gorm:"-" json:"Rule,omitempty"`
+	Feedback *Feedback  `gorm:"-" json:"Feedback,omitempty"`
+}
@@ -0,0 +1,74 @@
+package models
+
+// BatchOperationKind identifies the operation run by an item of a
+// batch.
+type BatchOperationKind string
+
+const (
+	BatchOperationCreate   BatchOperationKind = "create"
+	BatchOperationUpvote   BatchOperationKind = "upvote"
+	BatchOperationDownvote BatchOperationKind = "downvote"
+	BatchOperationStatus   BatchOperationKind = "status"
+)
+
+// The BatchOperation structure is one item of a batch. Only the
+// fields relevant to the kind of operation are used.
+type BatchOperation struct {
+	// Op is the kind of operation.
+	Op BatchOperationKind `json:"Op"`
+
+	// ID is the feedback the operation applies to, for all
+	// operations but create.
+	ID uint `json:"ID"`
+
+	// Feedback is the feedback to create.
+	Feedback *Feedback `json:"Feedback"`
+
+	// Votes is the vote cast, 1 or -1, for vote operations.
+	Votes int `json:"Votes"`
+
+	// Status is the status to transition the feedback to.
+	Status FeedbackStatus `json:"Status"`
+
+	// IfMatch is the optional entity tag the feedback must match
+	// for a status transition.
+	IfMatch string `json:"IfMatch"`
+}
+
+// The Batch structure is a list of operations submitted at once.
+type Batch struct {
+	// Atomic runs the batch all-or-nothing: if any operation
+	// fails, none is applied. Otherwise, every operation is applied
+	// on its own, whether the others fail or not.
+	Atomic bool `json:"Atomic"`
+
+	Operations []BatchOperation `json:"Operations"`
+}
+
+// The BatchResult structure is the outcome of one operation of a
+// batch.
+type BatchResult struct {
+	// Index is the position of the operation in the batch.
+	Index int
+
+	// Status is the HTTP status the operation would have gotten
+	// on its own endpoint.
+	Status int
+
+	// Feedback is the feedback resulting from the operation, if it
+	// was applied.
+	Feedback *Feedback
+
+	// Error is the reason the operation failed, if it did.
+	Error error
+}
+
+// The BatchOutcome structure is the outcome of a batch.
+type BatchOutcome struct {
+	// Committed is true if the operations that succeeded were
+	// applied. Atomic batches with any failed operation are not
+	// committed.
+	Committed bool
+
+	Results []*BatchResult
+}
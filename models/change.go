@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// ChangeKind is the kind of change made to a feedback.
+type ChangeKind string
+
+const (
+	ChangeKindCreate ChangeKind = "create"
+	ChangeKindUpdate ChangeKind = "update"
+	ChangeKindVote   ChangeKind = "vote"
+	ChangeKindStatus ChangeKind = "status"
+	ChangeKindDelete ChangeKind = "delete"
+	ChangeKindPurge  ChangeKind = "purge"
+)
+
+// The Change structure records a change made to a feedback, so that
+// clients can synchronize incrementally. Changes are recorded in the
+// same transaction as the change itself.
+type Change struct {
+	// Seq identifies the change, and is the cursor of the feed. It
+	// is set by the database, and only ever increases. Changes made
+	// by concurrent transactions are fed in the order of their
+	// transactions rather than of their Seq.
+	Seq uint64 `gorm:"<-:create;primaryKey;autoIncrement" json:"Seq"`
+
+	// FeedbackID is the ID of the changed feedback.
+	FeedbackID uint `gorm:"<-:create;not null;index" json:"FeedbackID"`
+
+	// Kind is the kind of the change.
+	Kind ChangeKind `gorm:"<-:create;size:20;not null" json:"Kind"`
+
+	// Version is the version of the feedback after the change.
+	Version uint64 `gorm:"<-:create;type:bigint;not null" json:"Version"`
+
+	// Snapshot is the feedback after the change, as exposed to
+	// clients. It is empty for tombstones, and cleared on earlier
+	// changes once the feedback is deleted.
+	Snapshot []byte `gorm:"<-" json:"-"`
+
+	// Feedback is the decoded Snapshot, if any.
+	Feedback *Feedback `gorm:"-" json:"Feedback,omitempty"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt"`
+}
+
+// Tombstone returns true if the change removed the feedback.
+func (c *Change) Tombstone() bool {
+	return c.Kind == ChangeKindDelete || c.Kind == ChangeKindPurge
+}
+
+// The ChangeFeed structure is a page of changes, in the order they
+// were made.
+type ChangeFeed struct {
+	// Changes are the changes made after the requested cursor.
+	Changes []*Change `json:"Changes"`
+
+	// Cursor is the cursor to request the next page with. It is
+	// the requested cursor if there are no new changes.
+	Cursor uint64 `json:"Cursor"`
+
+	// More is true if more changes are available after Cursor.
+	More bool `json:"More"`
+}
@@ -1,5 +1,26 @@
 package models
 
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FeedbackStatus is the stage of the handling of a feedback by the
+// student representatives.
+type FeedbackStatus string
+
+const (
+	FeedbackStatusNew          FeedbackStatus = "new"
+	FeedbackStatusAcknowledged FeedbackStatus = "acknowledged"
+	FeedbackStatusInProgress   FeedbackStatus = "in-progress"
+	FeedbackStatusResolved     FeedbackStatus = "resolved"
+	FeedbackStatusRejected     FeedbackStatus = "rejected"
+)
+
 // The Feedback structure represents a feedback, comment, or note
 // left by users on the page.
 type Feedback struct {
@@ -22,17 +43,153 @@ type Feedback struct {
 	// NOTE: The upper bound may change in the future.
 	Feedback string `gorm:"<-;not null" json:"Feedback" validate:"required,min=25,max=2000,alphanumunicodetext"`
 
+	// Tags are the topics the feedback is about, chosen by its
+	// author. There are at most 5 of them.
+	Tags Tags `gorm:"<-;type:text;not null;default:'[]'" json:"Tags" validate:"max=5,dive,istag"`
+
 	// Upvotes are votes cast by people to indicate them being in
 	// agreement, and supporting the feedback given.
-	// It must be initialized to the default value (0) when creating
-	// an entry, and be at most 2000.
-	// NOTE: The upper bound may change in the future.
-	Upvotes uint `gorm:"<-;default:0;size:11;scale:0;precision:4" json:"Upvotes" validate:"omitempty,min=0,max=2000"`
+	// It is an aggregate maintained by the server: it is initialized
+	// to the default value (0) when creating an entry, and is only
+	// changed by casting votes. It is therefore not validated.
+	Upvotes uint64 `gorm:"<-;type:bigint;not null;default:0" json:"Upvotes" validate:"-"`
 
 	// Downvotes are votes cast by people to indicate them being in
 	// disagreement, and opposing the feedback given.
-	// It must be initialized to the default value (0) when creating
-	// an entry, and be at most 2000.
-	// NOTE: The upper bound may change in the future.
-	Downvotes uint `gorm:"<-;default:0;size:11;scale:0;precision:4" json:"Downvotes" validate:"omitempty,min=0,max=2000"`
+	// It is an aggregate maintained by the server: it is initialized
+	// to the default value (0) when creating an entry, and is only
+	// changed by casting votes. It is therefore not validated.
+	Downvotes uint64 `gorm:"<-;type:bigint;not null;default:0" json:"Downvotes" validate:"-"`
+
+	// Status is the stage of the handling of the feedback. New
+	// feedback starts as FeedbackStatusNew, and only representatives
+	// may change it.
+	Status FeedbackStatus `gorm:"<-;size:20;not null;default:new;index" json:"Status" validate:"-"`
+
+	// Version is incremented on every change to the feedback,
+	// including votes. It is maintained by the server and backs the
+	// entity tag used for optimistic concurrency.
+	Version uint64 `gorm:"<-;type:bigint;not null;default:1" json:"Version" validate:"-"`
+
+	// CreatedAt and UpdatedAt are maintained by the server. UpdatedAt
+	// changes along with the Version.
+	CreatedAt time.Time `gorm:"<-:create" json:"-" validate:"-"`
+	UpdatedAt time.Time `gorm:"<-;index" json:"-" validate:"-"`
+
+	// DeletedAt is set when the feedback is deleted. Deleted
+	// feedback is kept as a tombstone, hidden from all queries,
+	// until it is purged.
+	DeletedAt gorm.DeletedAt `gorm:"<-;index" json:"-" validate:"-"`
+
+	// DeleteReason is the reason given when the feedback was
+	// deleted.
+	DeleteReason string `gorm:"<-;size:500" json:"-" validate:"-"`
+
+	// Response is the public response of the representatives to the
+	// feedback, recorded along with the outcome of a meeting. It is
+	// maintained by the server.
+	Response    string     `gorm:"<-;not null;default:''" json:"Response,omitempty" validate:"-"`
+	RespondedAt *time.Time `gorm:"<-" json:"RespondedAt,omitempty" validate:"-"`
+
+	// Held is true if the feedback was accepted, but is held until
+	// its publication so that it cannot be attributed to its
+	// author. Held feedback has no ID yet.
+	Held bool `gorm:"-" json:"Held,omitempty" validate:"-"`
+}
+
+// The HeldFeedback structure is feedback awaiting its publication.
+// Feedback on a course is published in batches, once enough of it is
+// held or once the publication time of one of them has passed.
+type HeldFeedback struct {
+	ID       uint   `gorm:"<-:create;primaryKey"`
+	Course   string `gorm:"<-:create;size:10;not null;index"`
+	Feedback string `gorm:"<-:create;not null"`
+	Tags     Tags   `gorm:"<-:create;type:text;not null;default:'[]'"`
+
+	// PublishAt is the randomized time after which the feedback is
+	// published, even if too little feedback is held on the course.
+	PublishAt time.Time `gorm:"<-:create;not null;index"`
+	CreatedAt time.Time `gorm:"<-:create"`
+}
+
+// ETag returns the strong entity tag of the feedback, as used in
+// HTTP conditional requests. It changes with the Version.
+func (f *Feedback) ETag() string {
+	return fmt.Sprintf("\"%d-%d\"", f.ID, f.Version)
+}
+
+// Score returns the net score of the feedback, its upvotes minus
+// its downvotes.
+func (f *Feedback) Score() int64 {
+	return int64(f.Upvotes) - int64(f.Downvotes)
+}
+
+// Faculty returns the faculty or programme code of the course of
+// the feedback, such as LINFO for LINFO1101: the course code without
+// its four digits.
+func (f *Feedback) Faculty() string {
+	return Faculty(f.Course)
+}
+
+// Faculty returns the faculty or programme code of the course.
+func Faculty(course string) string {
+	if len(course) < 4 {
+		return strings.ToUpper(course)
+	}
+	return strings.ToUpper(course[:len(course)-4])
+}
+
+// The FeedbackFilter structure selects feedback from the list, as
+// listed or exported. Empty fields select all feedback.
+type FeedbackFilter struct {
+	Course  string         `validate:"omitempty,iscourse"`
+	Faculty string         `validate:"omitempty,alpha,min=2,max=6"`
+	Status  FeedbackStatus `validate:"omitempty,oneof=new acknowledged in-progress resolved rejected"`
+	Tag     string         `validate:"omitempty,istag"`
+
+	// Since and Until bound the creation time of the feedback,
+	// Since included and Until excluded, if set.
+	Since *time.Time `validate:"-"`
+	Until *time.Time `validate:"-"`
+}
+
+// The FeedbackListVersion structure summarizes the state of all
+// feedback, deleted or not, so that clients can cheaply check
+// whether the list of feedback changed.
+type FeedbackListVersion struct {
+	// Count is the number of feedback, including tombstones.
+	Count int64
+
+	// MaxID is the highest feedback ID.
+	MaxID uint
+
+	// Versions is the sum of the versions of all feedback. It
+	// increases on every change to any feedback.
+	Versions uint64
+
+	// ModifiedAt is the last time any feedback was created,
+	// changed or deleted.
+	ModifiedAt time.Time
+}
+
+// ETag returns the strong entity tag of the list of feedback.
+func (v *FeedbackListVersion) ETag() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%d\x00%d\x00%d", v.Count, v.MaxID, v.Versions, v.ModifiedAt.UnixNano())
+	return fmt.Sprintf("\"%x\"", h.Sum(nil)[:16])
+}
+
+// The FeedbackDeletion structure reports the outcome of a deletion
+// request.
+type FeedbackDeletion struct {
+	// ID is the ID of the feedback the deletion was requested on.
+	ID uint `json:"ID"`
+
+	// Deleted is true if the feedback was deleted by the request.
+	// It is false if there was nothing to delete.
+	Deleted bool `json:"Deleted"`
+
+	// Purged is true if the feedback was removed permanently,
+	// rather than kept as a tombstone.
+	Purged bool `json:"Purged"`
 }
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// The Follow structure is the subscription of an anonymous voter to
+// feedback, a course, a faculty or a tag. Voters are only known by the hash
+// of their voter token, which is never returned to clients.
+type Follow struct {
+	ID        uint   `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+	TokenHash []byte `gorm:"<-:create;not null;uniqueIndex:idx_follows_target" json:"-" validate:"-"`
+
+	// Exactly one of FeedbackID, Course, Faculty and Tag is set.
+	FeedbackID uint   `gorm:"<-:create;not null;default:0;uniqueIndex:idx_follows_target;index" json:"FeedbackID,omitempty" validate:"-"`
+	Course     string `gorm:"<-:create;size:10;not null;default:'';uniqueIndex:idx_follows_target;index" json:"Course,omitempty" validate:"omitempty,iscourse"`
+	Faculty    string `gorm:"<-:create;size:6;not null;default:'';uniqueIndex:idx_follows_target;index" json:"Faculty,omitempty" validate:"omitempty,alpha,min=2,max=6"`
+	Tag        string `gorm:"<-:create;size:30;not null;default:'';uniqueIndex:idx_follows_target;index" json:"Tag,omitempty" validate:"omitempty,istag"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt" validate:"-"`
+}
+
+// The FollowNotification structure is an entry in the inbox of an
+// anonymous voter, about an event on feedback they follow. Voters
+// only receive new feedback and changes of status.
+type FollowNotification struct {
+	ID        uint   `gorm:"<-:create;primaryKey" json:"ID"`
+	TokenHash []byte `gorm:"<-:create;not null;uniqueIndex:idx_follow_notifications_event" json:"-"`
+
+	Kind NotificationKind `gorm:"<-:create;size:20;not null;uniqueIndex:idx_follow_notifications_event" json:"Kind"`
+
+	// Ref is the sequence number of the change. Each change is
+	// notified once to a voter, whatever the number of their
+	// follows covering it.
+	Ref uint64 `gorm:"<-:create;type:bigint;not null;uniqueIndex:idx_follow_notifications_event" json:"-"`
+
+	FeedbackID uint   `gorm:"<-:create;not null" json:"FeedbackID"`
+	Course     string `gorm:"<-:create;size:10;not null" json:"Course"`
+
+	// Detail is the new status of the feedback, if any.
+	Detail string `gorm:"<-:create;size:200" json:"Detail"`
+
+	CreatedAt time.Time `gorm:"<-:create;index" json:"CreatedAt"`
+}
+
+// The PushKeys structure holds the keys the browser encrypts its
+// push messages with, as base64url strings.
+type PushKeys struct {
+	P256dh string `gorm:"<-;column:p256dh;size:100;not null" json:"p256dh" validate:"required,max=100"`
+	Auth   string `gorm:"<-;column:auth;size:50;not null" json:"auth" validate:"required,max=50"`
+}
+
+// The PushSubscription structure is the Web Push subscription of
+// the browser of an anonymous voter. Its JSON form is the one of the
+// PushSubscription browser API, so that clients can send it as is.
+type PushSubscription struct {
+	ID        uint   `gorm:"<-:create;primaryKey" json:"-" validate:"-"`
+	TokenHash []byte `gorm:"<-;not null;index" json:"-" validate:"-"`
+
+	// Endpoint is the URL of the push service, unique to the
+	// browser.
+	Endpoint string   `gorm:"<-;size:500;not null;uniqueIndex" json:"endpoint" validate:"required,max=500,url,startswith=https://"`
+	Keys     PushKeys `gorm:"embedded" json:"keys"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"-" validate:"-"`
+}
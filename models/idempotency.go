@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// The IdempotencyKey structure records a request made with an
+// Idempotency-Key header, along with the response it got, so that
+// retries of the request are answered with the same response
+// instead of being processed again.
+type IdempotencyKey struct {
+	// Key is the idempotency key chosen by the client.
+	Key string `gorm:"<-:create;primaryKey;size:255"`
+
+	// Scope is the endpoint the key was used on, as the method and
+	// path of the request. The same key may be used on different
+	// endpoints.
+	Scope string `gorm:"<-:create;primaryKey;size:255"`
+
+	// Caller is the keyed hash of the credentials the request was
+	// made with, so that a key used by one caller is never
+	// replayed to another.
+	Caller string `gorm:"<-:create;primaryKey;size:64"`
+
+	// RequestHash is the hash of the body of the request. Retries
+	// must send the same body.
+	RequestHash string `gorm:"<-:create;size:64;not null"`
+
+	// Status is the status code of the response, 0 while the
+	// request is being processed.
+	Status int `gorm:"<-;not null;default:0"`
+
+	// ContentType, ETag, LastModified and Body are the stored
+	// response.
+	ContentType  string `gorm:"<-;size:255"`
+	ETag         string `gorm:"<-;column:etag;size:255"`
+	LastModified string `gorm:"<-;size:255"`
+	Body         []byte `gorm:"<-"`
+
+	// CreatedAt is when the key was first used, and ExpiresAt when
+	// the key can be used again for a different request.
+	CreatedAt time.Time `gorm:"<-:create"`
+	ExpiresAt time.Time `gorm:"<-:create;index"`
+}
+
+// Pending returns true if the request made with the key is still
+// being processed.
+func (k *IdempotencyKey) Pending() bool {
+	return k.Status == 0
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// The ImportMapping structure maps the columns of an imported CSV
+// file to the fields of feedback. Columns are named by their header,
+// case insensitively.
+type ImportMapping struct {
+	// Course is the column of the course codes. Rows without course
+	// are on the DefaultCourse, for forms about a single course.
+	Course        string `json:"Course" validate:"required_without=DefaultCourse"`
+	DefaultCourse string `json:"DefaultCourse" validate:"omitempty,iscourse"`
+
+	Feedback string `json:"Feedback" validate:"required"`
+
+	// CreatedAt is the column of the submission times, if any. Times
+	// are parsed with the TimeFormat layout in the TimeZone, or with
+	// the usual formats in UTC by default.
+	CreatedAt  string `json:"CreatedAt"`
+	TimeFormat string `json:"TimeFormat"`
+	TimeZone   string `json:"TimeZone"`
+
+	// Delimiter separates the fields, a comma by default.
+	Delimiter string `json:"Delimiter" validate:"omitempty,len=1"`
+}
+
+// DefaultImportMapping maps the columns of a Google Forms export,
+// whose questions are named Course and Feedback.
+var DefaultImportMapping = ImportMapping{
+	Course:    "Course",
+	Feedback:  "Feedback",
+	CreatedAt: "Timestamp",
+}
+
+// ImportRowStatus is the outcome of the import of a row.
+type ImportRowStatus string
+
+const (
+	// ImportRowValid rows would be imported, but the import is a dry
+	// run.
+	ImportRowValid     ImportRowStatus = "valid"
+	ImportRowImported  ImportRowStatus = "imported"
+	ImportRowDuplicate ImportRowStatus = "duplicate"
+	ImportRowInvalid   ImportRowStatus = "invalid"
+)
+
+// The ImportedRow structure records a row imported as feedback, so
+// that importing it again does nothing.
+type ImportedRow struct {
+	// Hash is the SHA-256 of the fields of the row.
+	Hash       string `gorm:"<-:create;primaryKey;size:64"`
+	Source     string `gorm:"<-:create;size:200;not null"`
+	FeedbackID uint   `gorm:"<-:create;not null;index"`
+	CreatedAt  time.Time
+}
+
+// The ImportResult structure is the outcome of the import of a row.
+type ImportResult struct {
+	// Row is the line of the row in the file, the headers being on
+	// the first one.
+	Row    int
+	Hash   string
+	Status ImportRowStatus
+
+	// Feedback is the feedback read from the row, with its ID if it
+	// was imported.
+	Feedback *Feedback
+
+	// Error is the reason the row is invalid, if it is.
+	Error error
+}
+
+// The ImportReport structure is the outcome of an import.
+type ImportReport struct {
+	Source string
+	DryRun bool
+
+	// Valid counts the rows a dry run would import, which are not
+	// Imported.
+	Rows, Valid, Imported, Duplicates, Invalid int
+
+	Results []*ImportResult
+}
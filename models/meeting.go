@@ -0,0 +1,82 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// The Meeting structure is a meeting of a council on a course or
+// faculty, such as a faculty council. Representatives prepare its
+// agenda by attaching feedback to raise, and record the outcome of
+// each once the meeting is held.
+type Meeting struct {
+	// Each meeting is identified uniquely by their ID, attributed
+	// by the database.
+	ID uint `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+
+	// Body is the council meeting, such as the faculty council. It
+	// is required and at most 200 long.
+	Body string `gorm:"<-;size:200;not null" json:"Body" validate:"required,max=200"`
+
+	// Scope and Code are the course or faculty the meeting is about.
+	Scope StatsScope `gorm:"<-;size:10;not null" json:"Scope" validate:"required,oneof=course faculty"`
+	Code  string     `gorm:"<-;size:10;not null" json:"Code" validate:"required"`
+
+	StartsAt time.Time `gorm:"<-;not null;index" json:"StartsAt" validate:"required"`
+	EndsAt   time.Time `gorm:"<-;not null" json:"EndsAt" validate:"required,gtfield=StartsAt"`
+	Location string    `gorm:"<-;size:200;not null;default:''" json:"Location" validate:"max=200"`
+
+	// RepresentativeID is the representative who created the
+	// meeting. It is maintained by the server.
+	RepresentativeID *uint `gorm:"<-:create" json:"RepresentativeID" validate:"-"`
+
+	// HeldAt is when the first outcome of the meeting was recorded.
+	// It is maintained by the server.
+	HeldAt *time.Time `gorm:"<-" json:"HeldAt,omitempty" validate:"-"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"-" validate:"-"`
+	UpdatedAt time.Time `gorm:"<-" json:"UpdatedAt" validate:"-"`
+
+	// Items are the agenda of the meeting, in order.
+	Items []*MeetingItem `gorm:"-" json:"Items" validate:"-"`
+}
+
+// Covers returns true if the feedback is on the course or faculty of
+// the meeting.
+func (m *Meeting) Covers(f *Feedback) bool {
+	if m.Scope == StatsScopeFaculty {
+		return f.Faculty() == strings.ToUpper(m.Code)
+	}
+	return strings.EqualFold(f.Course, m.Code)
+}
+
+// The MeetingItem structure attaches a feedback to the agenda of a
+// meeting.
+type MeetingItem struct {
+	MeetingID  uint `gorm:"<-:create;primaryKey;autoIncrement:false" json:"-" validate:"-"`
+	FeedbackID uint `gorm:"<-:create;primaryKey;autoIncrement:false" json:"FeedbackID" validate:"-"`
+
+	// Position orders the items of the agenda.
+	Position int `gorm:"<-:create;not null" json:"Position" validate:"-"`
+
+	// Notes are the notes of the representatives on the item. They
+	// are not public, and at most 2000 long.
+	Notes string `gorm:"<-;not null;default:''" json:"Notes" validate:"max=2000"`
+
+	// Outcome and Response are the status and public response
+	// decided during the meeting, once recorded.
+	Outcome   FeedbackStatus `gorm:"<-;size:20;not null;default:''" json:"Outcome,omitempty" validate:"-"`
+	Response  string         `gorm:"<-;not null;default:''" json:"Response,omitempty" validate:"-"`
+	DecidedAt *time.Time     `gorm:"<-" json:"DecidedAt,omitempty" validate:"-"`
+
+	Feedback *Feedback `gorm:"-" json:"Feedback" validate:"-"`
+}
+
+// The MeetingOutcome structure is the outcome of the discussion of
+// an item of a meeting: the new status of the feedback, and the
+// public response to it.
+type MeetingOutcome struct {
+	FeedbackID uint           `json:"FeedbackID" validate:"required"`
+	Status     FeedbackStatus `json:"Status" validate:"required,oneof=acknowledged in-progress resolved rejected"`
+	Response   string         `json:"Response" validate:"required,max=2000"`
+}
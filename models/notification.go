@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// NotificationKind is the event a notification is about.
+type NotificationKind string
+
+const (
+	// NotificationFeedback is new feedback, awaiting a response.
+	NotificationFeedback NotificationKind = "feedback"
+
+	// NotificationStatus is a change of the status of feedback.
+	NotificationStatus NotificationKind = "status"
+
+	// NotificationAlert is an alert rule firing on feedback.
+	NotificationAlert NotificationKind = "alert"
+
+	// NotificationStatusRequest is a voter requesting a change of
+	// the status of feedback.
+	NotificationStatusRequest NotificationKind = "status-request"
+
+	// NotificationModeration is the review of votes on feedback
+	// assigned to the representative.
+	NotificationModeration NotificationKind = "moderation"
+)
+
+// NotificationKinds lists all kinds of notifications.
+var NotificationKinds = []NotificationKind{
+	NotificationFeedback,
+	NotificationStatus,
+	NotificationAlert,
+	NotificationStatusRequest,
+	NotificationModeration,
+}
+
+// The Notification structure is an entry in the inbox of a
+// representative, about an event on feedback they follow.
+type Notification struct {
+	ID               uint `gorm:"<-:create;primaryKey" json:"ID"`
+	RepresentativeID uint `gorm:"<-:create;not null;uniqueIndex:idx_notifications_event" json:"-"`
+
+	Kind NotificationKind `gorm:"<-:create;size:20;not null;uniqueIndex:idx_notifications_event" json:"Kind"`
+
+	// Ref identifies the event within its kind: the sequence number
+	// of the change, the ID of the alert or of the status request,
+	// or the ID of the latest vote awaiting review. Each event is
+	// notified once.
+	Ref uint64 `gorm:"<-:create;type:bigint;not null;uniqueIndex:idx_notifications_event" json:"-"`
+
+	FeedbackID uint   `gorm:"<-:create;not null" json:"FeedbackID"`
+	Course     string `gorm:"<-:create;size:10;not null" json:"Course"`
+
+	// Detail describes the event: the new or requested status, the
+	// name of the alert rule, or the number of votes awaiting review.
+	Detail string `gorm:"<-:create;size:200" json:"Detail"`
+
+	// ReadAt is the time the notification was read, nil if it is
+	// unread.
+	ReadAt *time.Time `gorm:"<-" json:"ReadAt"`
+
+	CreatedAt time.Time `gorm:"<-:create;index" json:"CreatedAt"`
+}
+
+// The Inbox structure lists the latest notifications of a
+// representative.
+type Inbox struct {
+	Notifications []*Notification `json:"Notifications"`
+
+	// Unread is the number of unread notifications in the inbox.
+	Unread int64 `json:"Unread"`
+}
+
+// The RepresentativePreferences structure holds the settings a
+// representative can change themselves.
+type RepresentativePreferences struct {
+	Language        string             `json:"Language" validate:"required,oneof=en fr"`
+	DigestFrequency DigestFrequency    `json:"DigestFrequency" validate:"required,oneof=off daily weekly"`
+	InboxEvents     []NotificationKind `json:"InboxEvents" validate:"dive,oneof=feedback status alert status-request moderation"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// The PrivacyBudget structure is the privacy loss spent on the
+// public statistics of a course or faculty during a term.
+type PrivacyBudget struct {
+	ID    uint       `gorm:"<-:create;primaryKey"`
+	Scope StatsScope `gorm:"<-:create;size:10;not null;uniqueIndex:idx_privacy_budgets_target"`
+	Code  string     `gorm:"<-:create;size:10;not null;uniqueIndex:idx_privacy_budgets_target"`
+	Term  string     `gorm:"<-:create;size:20;not null;uniqueIndex:idx_privacy_budgets_target"`
+	Spent float64    `gorm:"not null;default:0"`
+
+	CreatedAt time.Time `gorm:"<-:create"`
+	UpdatedAt time.Time
+}
+
+// The StatsRelease structure is a release of the private statistics
+// of a course or faculty for a term. Releases are made on a schedule,
+// and are the only statistics served to the public.
+type StatsRelease struct {
+	ID    uint       `gorm:"<-:create;primaryKey"`
+	Scope StatsScope `gorm:"<-:create;size:10;not null;index:idx_stats_releases_target"`
+	Code  string     `gorm:"<-:create;size:10;not null;index:idx_stats_releases_target"`
+	Term  string     `gorm:"<-:create;size:20;not null;index:idx_stats_releases_target"`
+	Stats *Stats     `gorm:"<-:create;serializer:json;type:text;not null"`
+
+	CreatedAt time.Time `gorm:"<-:create;index:idx_stats_releases_target"`
+}
+
+// The StatsPrivacy structure describes the noise added to public
+// statistics.
+type StatsPrivacy struct {
+	// Epsilon is the privacy loss of the statistics, spent from the
+	// budget of the course or faculty for the term.
+	Epsilon         float64 `json:"Epsilon"`
+	Term            string  `json:"Term"`
+	RemainingBudget float64 `json:"RemainingBudget"`
+
+	// Suppressed is true if the statistics were withheld, as they
+	// cover too little feedback.
+	Suppressed bool `json:"Suppressed"`
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DigestFrequency is how often a representative receives the digest
+// of the feedback on their courses.
+type DigestFrequency string
+
+const (
+	DigestFrequencyOff    DigestFrequency = "off"
+	DigestFrequencyDaily  DigestFrequency = "daily"
+	DigestFrequencyWeekly DigestFrequency = "weekly"
+)
+
+// Period returns the time between two digests, or 0 if digests are
+// off.
+func (d DigestFrequency) Period() time.Duration {
+	switch d {
+	case DigestFrequencyDaily:
+		return 24 * time.Hour
+	case DigestFrequencyWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// The Representative structure is a student representative, who is
+// notified about the feedback on the courses they follow.
+type Representative struct {
+	// Each representative is identified uniquely by their ID,
+	// attributed by the database.
+	ID uint `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+
+	Name  string `gorm:"<-;size:100;not null" json:"Name" validate:"required,max=100"`
+	Email string `gorm:"<-;size:254;not null;uniqueIndex" json:"Email" validate:"required,email,max=254"`
+
+	// Language is the language of the notifications, either en or
+	// fr.
+	Language string `gorm:"<-;size:5;not null;default:en" json:"Language" validate:"required,oneof=en fr"`
+
+	// Courses and Faculties are the courses followed by the
+	// representative, by code or by faculty, such as LINFO. A
+	// representative following neither follows no course.
+	Courses   []string `gorm:"<-;serializer:json" json:"Courses" validate:"dive,iscourse"`
+	Faculties []string `gorm:"<-;serializer:json" json:"Faculties" validate:"dive,alpha,min=2,max=6"`
+
+	// AllCourses is set by the administrators for the
+	// representatives following all courses and faculties, whatever
+	// their Courses and Faculties.
+	AllCourses bool `gorm:"<-;not null;default:false" json:"AllCourses" validate:"-"`
+
+	DigestFrequency DigestFrequency `gorm:"<-;size:10;not null;default:weekly" json:"DigestFrequency" validate:"required,oneof=off daily weekly"`
+
+	// InboxEvents are the kinds of notifications landing in the
+	// inbox of the representative. All kinds do if it is nil.
+	InboxEvents []NotificationKind `gorm:"<-;serializer:json" json:"InboxEvents" validate:"dive,oneof=feedback status alert status-request moderation"`
+
+	// TokenHash is the SHA-256 hash of the access token of the
+	// representative. Token is only set when the token is issued,
+	// and is never stored.
+	TokenHash []byte `gorm:"<-;uniqueIndex" json:"-" validate:"-"`
+	Token     string `gorm:"-" json:"Token,omitempty" validate:"-"`
+
+	// LastDigestAt is the last time a digest was sent to the
+	// representative. It is maintained by the server.
+	LastDigestAt *time.Time `gorm:"<-" json:"LastDigestAt" validate:"-"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt" validate:"-"`
+	UpdatedAt time.Time `gorm:"<-" json:"UpdatedAt" validate:"-"`
+}
+
+// Follows returns true if the feedback is on a course followed by
+// the representative.
+func (r *Representative) Follows(f *Feedback) bool {
+	if r.AllCourses {
+		return true
+	}
+
+	for _, c := range r.Courses {
+		if strings.EqualFold(c, f.Course) {
+			return true
+		}
+	}
+	for _, fac := range r.Faculties {
+		if strings.EqualFold(fac, f.Faculty()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Represents returns true if the course or faculty is followed by
+// the representative. A faculty is only represented as a whole, by
+// representatives following it or all courses.
+func (r *Representative) Represents(scope StatsScope, code string) bool {
+	if r.AllCourses {
+		return true
+	}
+
+	faculty := code
+	if scope == StatsScopeCourse {
+		for _, c := range r.Courses {
+			if strings.EqualFold(c, code) {
+				return true
+			}
+		}
+		faculty = Faculty(code)
+	}
+	for _, fac := range r.Faculties {
+		if strings.EqualFold(fac, faculty) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants returns true if notifications of the given kind should
+// land in the inbox of the representative.
+func (r *Representative) Wants(kind NotificationKind) bool {
+	if r.InboxEvents == nil {
+		return true
+	}
+	for _, k := range r.InboxEvents {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestDue returns true if a digest should be sent to the
+// representative at the given time.
+func (r *Representative) DigestDue(now time.Time) bool {
+	period := r.DigestFrequency.Period()
+	if period == 0 {
+		return false
+	}
+	return r.LastDigestAt == nil || !now.Before(r.LastDigestAt.Add(period))
+}
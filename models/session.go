@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// SessionStatus is whether a live session is accepting votes.
+type SessionStatus string
+
+const (
+	SessionStatusIdle SessionStatus = "idle"
+	SessionStatusOpen SessionStatus = "open"
+)
+
+// The Session structure represents a live voting session, run by a
+// representative in class. Participants vote on the feedback
+// attached to the session, during time-boxed rounds.
+type Session struct {
+	// Each session is identified uniquely by their ID, attributed
+	// by the database.
+	ID uint `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+
+	// Title is shown to participants. It is required and at most
+	// 200 long.
+	Title string `gorm:"<-;size:200;not null" json:"Title" validate:"required,max=200"`
+
+	// Status is whether a round is open. It is maintained by the
+	// server.
+	Status SessionStatus `gorm:"<-;size:10;not null;default:idle" json:"Status" validate:"-"`
+
+	// Round is the number of the current, or last, round. Votes are
+	// tallied per round. It is maintained by the server.
+	Round uint `gorm:"<-;not null;default:0" json:"Round" validate:"-"`
+
+	// ClosesAt is when the current round closes, or closed.
+	ClosesAt *time.Time `gorm:"<-" json:"ClosesAt,omitempty" validate:"-"`
+
+	// RepresentativeID is the representative who created the
+	// session, if any. They run its rounds with their own token, on
+	// the feedback of the courses they follow. It is maintained by
+	// the server.
+	RepresentativeID *uint `gorm:"<-:create" json:"RepresentativeID" validate:"-"`
+
+	// PresenterTokenHash is the SHA-256 hash of the token the
+	// presenters of the session see its tallies with. PresenterToken
+	// is only set when the session is created, and is never stored.
+	PresenterTokenHash []byte `gorm:"<-:create" json:"-" validate:"-"`
+	PresenterToken     string `gorm:"-" json:"PresenterToken,omitempty" validate:"-"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"-" validate:"-"`
+	UpdatedAt time.Time `gorm:"<-" json:"-" validate:"-"`
+
+	// Items are the feedback attached to the session, in order.
+	Items []*Feedback `gorm:"-" json:"Items" validate:"-"`
+}
+
+// Open returns true if the session accepts votes at the given time.
+func (s *Session) Open(now time.Time) bool {
+	return s.Status == SessionStatusOpen && s.ClosesAt != nil && now.Before(*s.ClosesAt)
+}
+
+// The SessionItem structure attaches a feedback to a session.
+type SessionItem struct {
+	SessionID  uint `gorm:"<-:create;primaryKey;autoIncrement:false"`
+	FeedbackID uint `gorm:"<-:create;primaryKey;autoIncrement:false"`
+
+	// Position orders the items of the session.
+	Position int `gorm:"<-:create;not null"`
+}
+
+// The SessionTally structure is the outcome of a round of a session,
+// on one of its items. Quarantined votes are not counted.
+type SessionTally struct {
+	FeedbackID uint  `json:"FeedbackID"`
+	Upvotes    int64 `json:"Upvotes"`
+	Downvotes  int64 `json:"Downvotes"`
+}
+
+// SessionEventType is the kind of update sent to session clients.
+type SessionEventType string
+
+const (
+	// SessionEventSession carries the session and its items. It is
+	// sent when connecting, and whenever a round opens or closes.
+	SessionEventSession SessionEventType = "session"
+
+	// SessionEventTally carries the tallies of the current round.
+	// It is only sent to presenters.
+	SessionEventTally SessionEventType = "tally"
+)
+
+// The SessionEvent structure is an update of a live session.
+type SessionEvent struct {
+	Type    SessionEventType `json:"Type"`
+	Session *Session         `json:"Session,omitempty"`
+	Tallies []*SessionTally  `json:"Tallies,omitempty"`
+}
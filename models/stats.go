@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// StatsScope is what statistics are computed over.
+type StatsScope string
+
+const (
+	StatsScopeCourse  StatsScope = "course"
+	StatsScopeFaculty StatsScope = "faculty"
+)
+
+// ScoreBucketBounds are the lower bounds of the buckets of the score
+// distribution, after a first bucket of negative scores. Each bucket
+// holds the scores up to the next bound, excluded.
+var ScoreBucketBounds = []int64{0, 1, 5, 10, 25, 50}
+
+// The StatsQuery structure selects the feedback statistics are
+// computed over: the feedback on a course or faculty, optionally
+// created within a period such as a term. The statistics of a query
+// are exact, and restricted to administrators.
+type StatsQuery struct {
+	Scope StatsScope `validate:"required,oneof=course faculty"`
+	Code  string     `validate:"required"`
+
+	// Since and Until bound the creation time of the feedback,
+	// Since included and Until excluded, if set.
+	Since *time.Time `validate:"-"`
+	Until *time.Time `validate:"-"`
+}
+
+// The ScoreBucket structure counts the feedback with a net score
+// between Min and Max, included. Min is nil for the bucket of
+// negative scores, and Max for the bucket of the highest scores.
+type ScoreBucket struct {
+	Min      *int64 `json:"Min"`
+	Max      *int64 `json:"Max"`
+	Feedback int64  `json:"Feedback"`
+}
+
+// The Stats structure holds the statistics of the feedback on a
+// course or faculty. Deleted feedback is not counted.
+type Stats struct {
+	Scope StatsScope `json:"Scope"`
+	Code  string     `json:"Code"`
+	Since *time.Time `json:"Since"`
+	Until *time.Time `json:"Until"`
+
+	Feedback  int64 `json:"Feedback"`
+	Upvotes   int64 `json:"Upvotes"`
+	Downvotes int64 `json:"Downvotes"`
+
+	// UpvoteRatio is the share of upvotes among all votes, nil if
+	// there are no votes.
+	UpvoteRatio *float64 `json:"UpvoteRatio"`
+
+	Scores   []*ScoreBucket           `json:"Scores"`
+	Statuses map[FeedbackStatus]int64 `json:"Statuses"`
+
+	// Tags are the most common tags of the feedback, most common
+	// first.
+	Tags []*TagCount `json:"Tags"`
+
+	// Responded is the number of feedback whose status was changed
+	// at least once. MedianResponseSeconds is the median time from
+	// their creation to their first change of status, nil if none
+	// was responded to.
+	Responded             int64    `json:"Responded"`
+	MedianResponseSeconds *float64 `json:"MedianResponseSeconds"`
+
+	// Privacy describes the noise added to the statistics, nil if
+	// they are exact.
+	Privacy *StatsPrivacy `json:"Privacy"`
+
+	// ComputedAt is the time the statistics were computed. Exact
+	// statistics are cached until the next change to feedback,
+	// private ones are released on a schedule.
+	ComputedAt time.Time `json:"ComputedAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// The StatusRequest structure is the request of an anonymous voter
+// for the status of feedback to change, such as resolved feedback to
+// be reopened. Requests are pending until the status of the feedback
+// changes. Voters are only known by the keyed hash of their voter
+// token, which is never returned to clients.
+type StatusRequest struct {
+	ID        uint   `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+	TokenHash []byte `gorm:"<-:create;not null;uniqueIndex:idx_status_requests_voter" json:"-" validate:"-"`
+
+	FeedbackID uint `gorm:"<-:create;not null;uniqueIndex:idx_status_requests_voter;index" json:"FeedbackID" validate:"-"`
+
+	// Status is the status requested for the feedback. Each voter
+	// requests a given status at most once per feedback.
+	Status FeedbackStatus `gorm:"<-:create;size:20;not null;uniqueIndex:idx_status_requests_voter" json:"Status" validate:"required,oneof=new acknowledged in-progress resolved rejected"`
+
+	// Reason explains the request to the representatives.
+	Reason string `gorm:"<-:create;size:500" json:"Reason" validate:"max=500"`
+
+	// NotifiedAt is the time the representatives were notified of
+	// the request, nil until then. It is maintained by the server.
+	NotifiedAt *time.Time `gorm:"<-;index" json:"-" validate:"-"`
+
+	CreatedAt time.Time `gorm:"<-:create;index" json:"CreatedAt" validate:"-"`
+}
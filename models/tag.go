@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Tags are the topics a feedback is tagged with by its author, such
+// as schedule-conflict. Tags are lowercase words separated by
+// hyphens. They are stored as a JSON array.
+type Tags []string
+
+// Has returns true if the tags contain the given tag, case
+// insensitively.
+func (t Tags) Has(tag string) bool {
+	return slices.Contains(t, strings.ToLower(tag))
+}
+
+// Normalize returns the tags in lowercase, without surrounding
+// spaces, empty tags or duplicates, in their original order.
+func (t Tags) Normalize() Tags {
+	n := Tags{}
+	for _, tag := range t {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !n.Has(tag) {
+			n = append(n, tag)
+		}
+	}
+	return n
+}
+
+// MarshalJSON encodes the tags as a JSON array, empty if there are
+// none.
+func (t Tags) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(t))
+}
+
+// Value stores the tags as a JSON array, empty if there are none.
+func (t Tags) Value() (driver.Value, error) {
+	b, err := t.MarshalJSON()
+	return string(b), err
+}
+
+// Scan reads the tags from a JSON array.
+func (t *Tags) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into tags", value)
+	}
+
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// MatchesTag returns true if the feedback of the change is tagged
+// with the given tag, or if no tag is given. Tombstones carry no
+// feedback, and match all tags.
+func MatchesTag(tag string, c *Change) bool {
+	if tag == "" || c.Feedback == nil {
+		return true
+	}
+	return c.Feedback.Tags.Has(tag)
+}
+
+// The TagCount structure is the number of feedback tagged with a
+// tag.
+type TagCount struct {
+	Tag   string `json:"Tag"`
+	Count int64  `json:"Count"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TermOf returns the academic term holding t, such as 2024-2025-Q1.
+// The first term runs from September to January, and the second one
+// from February to August.
+func TermOf(t time.Time) string {
+	t = t.UTC()
+	year, term := t.Year(), 2
+	switch {
+	case t.Month() >= time.September:
+		term = 1
+	case t.Month() == time.January:
+		year, term = year-1, 1
+	default:
+		year--
+	}
+	return fmt.Sprintf("%d-%d-Q%d", year, year+1, term)
+}
+
+// TermBounds returns the start, included, and the end, excluded, of
+// the academic term, as returned by TermOf.
+func TermBounds(term string) (time.Time, time.Time, error) {
+	var first, second, q int
+	if _, err := fmt.Sscanf(term, "%4d-%4d-Q%1d", &first, &second, &q); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if second != first+1 || (q != 1 && q != 2) || fmt.Sprintf("%d-%d-Q%d", first, second, q) != term {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid term %q", term)
+	}
+
+	start := time.Date(first, time.September, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(second, time.February, 1, 0, 0, 0, 0, time.UTC)
+	if q == 2 {
+		start, end = end, time.Date(second, time.September, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return start, end, nil
+}
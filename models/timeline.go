@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// TimelineInterval is the width of the buckets of a timeline.
+type TimelineInterval string
+
+const (
+	TimelineHour TimelineInterval = "hour"
+	TimelineDay  TimelineInterval = "day"
+	TimelineWeek TimelineInterval = "week"
+)
+
+// TimelineOrigin is the start of the first bucket of all timelines,
+// a Monday at midnight UTC, so that weekly buckets start on Mondays.
+var TimelineOrigin = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// Duration returns the width of the buckets, or 0 for unknown
+// intervals.
+func (i TimelineInterval) Duration() time.Duration {
+	switch i {
+	case TimelineHour:
+		return time.Hour
+	case TimelineDay:
+		return 24 * time.Hour
+	case TimelineWeek:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// Truncate returns the start of the bucket holding t.
+func (i TimelineInterval) Truncate(t time.Time) time.Time {
+	d := i.Duration()
+	return TimelineOrigin.Add(t.Sub(TimelineOrigin) / d * d)
+}
+
+// The TimelineQuery structure selects the votes of a timeline, cast
+// from Since, included, to Until, excluded.
+type TimelineQuery struct {
+	FeedbackID uint             `validate:"required"`
+	Interval   TimelineInterval `validate:"required,oneof=hour day week"`
+	Since      time.Time        `validate:"required"`
+	Until      time.Time        `validate:"required,gtfield=Since"`
+}
+
+// The TimelineBucket structure counts the votes cast on feedback
+// within a bucket of time. Retracted votes are subtracted, and votes
+// awaiting review are not counted.
+type TimelineBucket struct {
+	Start     time.Time `json:"Start"`
+	Upvotes   int64     `json:"Upvotes"`
+	Downvotes int64     `json:"Downvotes"`
+}
+
+// The Timeline structure is the history of the votes on feedback,
+// in consecutive buckets, oldest first.
+type Timeline struct {
+	FeedbackID uint              `json:"FeedbackID"`
+	Interval   TimelineInterval  `json:"Interval"`
+	Buckets    []*TimelineBucket `json:"Buckets"`
+}
+
+// The Trend structure compares the recent net score velocity of
+// feedback against its baseline, in votes per hour.
+type Trend struct {
+	Feedback *Feedback `json:"Feedback"`
+
+	// Recent and Baseline are the net votes cast within the recent
+	// window, and within the baseline period preceding it.
+	Recent   int64 `json:"Recent"`
+	Baseline int64 `json:"Baseline"`
+
+	Velocity         float64 `json:"Velocity"`
+	BaselineVelocity float64 `json:"BaselineVelocity"`
+
+	// Ratio is the recent velocity over the baseline velocity. The
+	// feedback is heating up if it reaches the trending threshold.
+	Ratio float64 `json:"Ratio"`
+}
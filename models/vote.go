@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// VoteKind identifies which appreciation counter of a feedback
+// a vote applies to.
+type VoteKind string
+
+const (
+	VoteKindUpvote   VoteKind = "upvote"
+	VoteKindDownvote VoteKind = "downvote"
+)
+
+// The Vote structure records a single vote event cast on a
+// feedback. Votes are kept alongside the appreciation counters
+// of the feedback, so that suspicious voting patterns can be
+// detected and the offending votes quarantined.
+type Vote struct {
+	// Each vote is identified uniquely by their ID, attributed
+	// by the database.
+	ID uint `gorm:"<-:create;primaryKey" json:"ID"`
+
+	// FeedbackID is the ID of the feedback the vote was cast on.
+	FeedbackID uint `gorm:"<-:create;not null;index" json:"FeedbackID"`
+
+	// Kind is the counter the vote applies to.
+	Kind VoteKind `gorm:"<-:create;size:10;not null" json:"Kind"`
+
+	// Delta is the value the vote added to the counter, either
+	// 1 for a vote, or -1 for a retracted vote.
+	Delta int `gorm:"<-:create;not null" json:"Delta"`
+
+	// Token is the keyed hash of the anonymous voter token sent
+	// by the client, if any. The token itself is never stored,
+	// and the hash is never returned to clients.
+	Token string `gorm:"<-:create;size:64;index" json:"-"`
+
+	// TokenFamily is the keyed hash of the prefix shared by the
+	// tokens of the family of the voter token, if any. It is
+	// never returned to clients.
+	TokenFamily string `gorm:"<-:create;size:64;index" json:"-"`
+
+	// Address is the network address the vote originated from.
+	// It is never returned to clients.
+	Address string `gorm:"<-:create;size:45;index" json:"-"`
+
+	// CreatedAt is the time the vote was cast.
+	CreatedAt time.Time `gorm:"<-:create;not null;index" json:"CreatedAt"`
+
+	// Flag is the reason the vote was flagged by the analysis,
+	// empty if the vote was never flagged.
+	Flag string `gorm:"<-;size:20" json:"Flag"`
+
+	// Quarantined votes are excluded from the public score of
+	// the feedback until a moderator reviews them.
+	Quarantined bool `gorm:"<-;not null;default:false" json:"Quarantined"`
+
+	// Reviewed is set once a moderator took a decision on the
+	// vote. Reviewed votes are not analyzed again.
+	Reviewed bool `gorm:"<-;not null;default:false" json:"Reviewed"`
+
+	// SessionID and SessionRound identify the round of the live
+	// session the vote was cast in, if any.
+	SessionID    *uint `gorm:"<-:create" json:"SessionID,omitempty"`
+	SessionRound uint  `gorm:"<-:create;not null" json:"SessionRound,omitempty"`
+}
+
+// VoteSource describes where a vote originated from. It is
+// filled by the routes and recorded with every vote.
+type VoteSource struct {
+	Token   string
+	Address string
+}
+
+// FlaggedFeedback is a report entry listing a feedback and
+// the votes on it awaiting moderator review.
+type FlaggedFeedback struct {
+	Feedback *Feedback `json:"Feedback"`
+	Votes    []*Vote   `json:"Votes"`
+
+	// AssigneeID is the ID of the representative assigned to the
+	// review, nil if none follows the feedback.
+	AssigneeID *uint `json:"AssigneeID"`
+}
+
+// The ModerationAssignment structure assigns the review of the votes
+// quarantined on a feedback to a representative following it. It is
+// removed once all the votes are reviewed.
+type ModerationAssignment struct {
+	FeedbackID       uint `gorm:"<-:create;primaryKey;autoIncrement:false" json:"FeedbackID"`
+	RepresentativeID uint `gorm:"<-:create;not null;index" json:"RepresentativeID"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// WebhookDeliveryStatus is the stage of the delivery of an event to
+// a webhook.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// The Webhook structure is a subscription of an external tool to
+// the changes made to feedback. Changes are delivered as signed
+// HTTP POST requests.
+type Webhook struct {
+	// Each webhook is identified uniquely by their ID, attributed
+	// by the database.
+	ID uint `gorm:"<-:create;primaryKey" json:"ID" validate:"omitempty,min=1"`
+
+	// URL is where the events are delivered. It must be an HTTP or
+	// HTTPS URL.
+	URL string `gorm:"<-;size:2000;not null" json:"URL" validate:"required,max=2000,http_url"`
+
+	// Events are the kinds of changes delivered. All changes are
+	// delivered if it is empty.
+	Events []ChangeKind `gorm:"<-;serializer:json" json:"Events" validate:"dive,oneof=create update vote status delete purge"`
+
+	// Course restricts the delivered changes to a single course, if
+	// set. Tombstones are always delivered, as they carry no course.
+	Course string `gorm:"<-;size:10" json:"Course" validate:"omitempty,iscourse"`
+
+	// Secret is the key signing the deliveries. It is never returned
+	// to clients.
+	Secret string `gorm:"<-;size:255;not null" json:"Secret,omitempty" validate:"required,min=16,max=255"`
+
+	// Cursor is the sequence number of the last change enqueued for
+	// delivery. It is maintained by the server.
+	Cursor uint64 `gorm:"<-;type:bigint;not null;default:0" json:"-" validate:"-"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt" validate:"-"`
+}
+
+// Wants returns true if the change should be delivered to the
+// webhook.
+func (w *Webhook) Wants(c *Change) bool {
+	wanted := len(w.Events) == 0
+	for _, k := range w.Events {
+		wanted = wanted || k == c.Kind
+	}
+
+	return wanted && MatchesCourse(w.Course, c)
+}
+
+// MatchesCourse returns true if the change was made on the given
+// course, or if no course is given. Tombstones carry no feedback,
+// and match all courses.
+func MatchesCourse(course string, c *Change) bool {
+	if course == "" || c.Feedback == nil {
+		return true
+	}
+	return strings.EqualFold(course, c.Feedback.Course)
+}
+
+// The WebhookDelivery structure is an event queued for delivery to
+// a webhook.
+type WebhookDelivery struct {
+	ID        uint `gorm:"<-:create;primaryKey" json:"ID"`
+	WebhookID uint `gorm:"<-:create;not null;index" json:"WebhookID"`
+
+	// Seq and Event identify the delivered change.
+	Seq   uint64     `gorm:"<-:create;type:bigint;not null" json:"Seq"`
+	Event ChangeKind `gorm:"<-:create;size:20;not null" json:"Event"`
+
+	// Payload is the body of the delivery, built when the change was
+	// enqueued.
+	Payload []byte `gorm:"<-:create;not null" json:"-"`
+
+	Status WebhookDeliveryStatus `gorm:"<-;size:10;not null;default:pending" json:"Status"`
+
+	// Attempts is the number of delivery attempts made so far, and
+	// NextAttemptAt when the next one is due.
+	Attempts      int       `gorm:"<-;not null;default:0" json:"Attempts"`
+	NextAttemptAt time.Time `gorm:"<-;not null;index" json:"NextAttemptAt"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt"`
+
+	// Log lists the attempts made, in order.
+	Log []*WebhookAttempt `gorm:"foreignKey:DeliveryID" json:"Log"`
+}
+
+// The WebhookAttempt structure logs an attempt at delivering an
+// event.
+type WebhookAttempt struct {
+	ID         uint `gorm:"<-:create;primaryKey" json:"-"`
+	DeliveryID uint `gorm:"<-:create;not null;index" json:"-"`
+
+	// StatusCode is the status of the response, 0 if no response was
+	// received, in which case Error describes the failure.
+	StatusCode int    `gorm:"<-:create;not null" json:"StatusCode"`
+	Error      string `gorm:"<-:create;size:500" json:"Error,omitempty"`
+
+	// Duration is how long the attempt took.
+	Duration time.Duration `gorm:"<-:create;not null" json:"Duration"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"CreatedAt"`
+}
+
+// The WebhookEvent structure is the payload of a delivery.
+type WebhookEvent struct {
+	// Webhook is the ID of the webhook the event is delivered to.
+	Webhook uint `json:"Webhook"`
+
+	Event  ChangeKind `json:"Event"`
+	Change *Change    `json:"Change"`
+}
@@ -0,0 +1,88 @@
+/**
+ * file: notify/digest.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file builds the digests, summarizing the new
+ * and top feedback on the courses followed by a
+ * representative.
+ */
+
+package notify
+
+import (
+	"sort"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// DigestLimit is the number of feedback listed in each section of a
+// digest.
+const DigestLimit int = 10
+
+// The Digest structure summarizes the feedback on the courses
+// followed by a representative over a period.
+type Digest struct {
+	Representative *models.Representative
+	Since, Until   time.Time
+
+	// New is the feedback created during the period, latest first.
+	New []*models.Feedback
+
+	// Top is the highest ranked feedback still open.
+	Top []*models.Feedback
+}
+
+// Rank sorts the feedback by net score, then by upvotes, highest
+// first. Ties are broken by age, oldest first.
+func Rank(feedback []*models.Feedback) {
+	sort.SliceStable(feedback, func(i, j int) bool {
+		a, b := feedback[i], feedback[j]
+		if a.Score() != b.Score() {
+			return a.Score() > b.Score()
+		}
+		if a.Upvotes != b.Upvotes {
+			return a.Upvotes > b.Upvotes
+		}
+		return a.ID < b.ID
+	})
+}
+
+// BuildDigest builds the digest of the feedback followed by the
+// representative, created between since and until. At most limit
+// feedback are listed in each section.
+func BuildDigest(r *models.Representative, feedback []*models.Feedback, since, until time.Time, limit int) *Digest {
+	d := &Digest{Representative: r, Since: since, Until: until}
+
+	var open []*models.Feedback
+	for _, f := range feedback {
+		if !r.Follows(f) {
+			continue
+		}
+
+		if !f.CreatedAt.Before(since) && f.CreatedAt.Before(until) {
+			d.New = append(d.New, f)
+		}
+		if f.Status != models.FeedbackStatusResolved && f.Status != models.FeedbackStatusRejected {
+			open = append(open, f)
+		}
+	}
+
+	sort.SliceStable(d.New, func(i, j int) bool { return d.New[i].CreatedAt.After(d.New[j].CreatedAt) })
+	Rank(open)
+	d.New = d.New[:min(len(d.New), limit)]
+	d.Top = open[:min(len(open), limit)]
+	return d
+}
+
+// Empty returns true if the digest lists no feedback.
+func (d *Digest) Empty() bool {
+	return len(d.New) == 0 && len(d.Top) == 0
+}
+
+// Message returns the message sending the digest to the
+// representative, in their language.
+func (d *Digest) Message() (*Message, error) {
+	return render("digest", d.Representative.Language, []string{d.Representative.Email}, d)
+}
@@ -0,0 +1,87 @@
+/**
+ * file: notify/digest_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func feedbackIDs(feedback []*models.Feedback) []uint {
+	ids := []uint{}
+	for _, f := range feedback {
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+// TestBuildDigest tests that digests list the new feedback and the
+// top open feedback on the followed courses.
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)
+	since := now.Add(-7 * 24 * time.Hour)
+	r := &models.Representative{Name: "Alex", Email: "alex@example.org", Language: "en", Faculties: []string{"LINFO"}}
+
+	feedback := []*models.Feedback{
+		{ID: 1, Course: "LINFO1101", Upvotes: 11, Downvotes: 2, CreatedAt: since.Add(-time.Hour)},
+		{ID: 2, Course: "LINFO1002", Upvotes: 3, CreatedAt: since.Add(time.Hour)},
+		{ID: 3, Course: "LINFO1101", Upvotes: 12, Downvotes: 4, CreatedAt: now.Add(-time.Hour)},
+		{ID: 4, Course: "LEPL1102", Upvotes: 50, CreatedAt: now.Add(-time.Hour)},
+		{ID: 5, Course: "LINFO1101", Upvotes: 40, Status: models.FeedbackStatusResolved, CreatedAt: since.Add(-time.Hour)},
+		{ID: 6, Course: "LINFO1101", Upvotes: 3, CreatedAt: since.Add(-time.Hour)},
+	}
+
+	d := BuildDigest(r, feedback, since, now, 3)
+	assert.Equal(t, []uint{3, 2}, feedbackIDs(d.New), "new feedback should be listed latest first")
+	assert.Equal(t, []uint{1, 3, 2}, feedbackIDs(d.Top), "open feedback should be ranked and limited")
+	assert.False(t, d.Empty())
+
+	r.Faculties = []string{"LMECA"}
+	assert.True(t, BuildDigest(r, feedback, since, now, 3).Empty(), "only followed courses should be listed")
+}
+
+// TestDigestMessage tests that digests are rendered in the language
+// of the representative, falling back to English.
+func TestDigestMessage(t *testing.T) {
+	now := time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)
+	r := &models.Representative{Name: "Alex", Email: "alex@example.org", Language: "fr", Courses: []string{"LINFO1101"}, DigestFrequency: models.DigestFrequencyDaily}
+	f := &models.Feedback{ID: 1, Course: "LINFO1101", Feedback: "Les séances <d'exercices> sont trop courtes.", Upvotes: 4, Downvotes: 1, CreatedAt: now.Add(-time.Hour)}
+	d := BuildDigest(r, []*models.Feedback{f}, now.Add(-24*time.Hour), now, DigestLimit)
+
+	m, err := d.Message()
+	require.NoError(t, err, "rendering the digest should not fail")
+	assert.Equal(t, []string{"alex@example.org"}, m.To)
+	assert.Equal(t, "Votre résumé delegit, du 2026-10-11 au 2026-10-12", m.Subject)
+	assert.Contains(t, m.Text, "Bonjour Alex")
+	assert.Contains(t, m.Text, "Score +3 (4 pour, 1 contre)")
+	assert.Contains(t, m.Text, "chaque jour")
+	assert.Contains(t, m.HTML, "Les séances &lt;d&#39;exercices&gt; sont trop courtes.", "the HTML body should be escaped")
+
+	r.Language = "nl"
+	m, err = d.Message()
+	require.NoError(t, err, "rendering the digest should not fail")
+	assert.Equal(t, "Your delegit digest, 2026-10-11 to 2026-10-12", m.Subject, "unknown languages should fall back to English")
+}
+
+// TestAlertMessage tests that alerts are rendered with their rule
+// and feedback.
+func TestAlertMessage(t *testing.T) {
+	r := &models.Representative{Name: "Alex", Email: "alex@example.org", Language: "en"}
+	a := &models.Alert{
+		Rule:     &models.AlertRule{Name: "Hot on LINFO"},
+		Feedback: &models.Feedback{Course: "LINFO1101", Feedback: "The exercise sessions are far too short.", Upvotes: 31},
+	}
+
+	m, err := AlertMessage(r, a)
+	require.NoError(t, err, "rendering the alert should not fail")
+	assert.Equal(t, "[LINFO1101] Alert: Hot on LINFO", m.Subject)
+	assert.Contains(t, m.Text, "Score +31")
+}
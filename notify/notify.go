@@ -0,0 +1,28 @@
+/**
+ * file: notify/notify.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The notify package sends notifications to the
+ * student representatives, through channels such as
+ * email, and to the students following feedback,
+ * through Web Push.
+ */
+
+package notify
+
+import "context"
+
+// The Message structure is a notification, with a plain text and an
+// HTML rendition of its body.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// A Channel delivers messages to their recipients.
+type Channel interface {
+	Send(ctx context.Context, m *Message) error
+}
@@ -0,0 +1,283 @@
+/**
+ * file: notify/pushtest/pushtest.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The pushtest package provides a local stand-in for
+ * a Web Push service and the browsers subscribed to
+ * it, for testing. It checks the VAPID signature of
+ * the messages and decrypts them as a browser would.
+ */
+
+package pushtest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// The Message structure is a message received and decrypted by the
+// push service.
+type Message struct {
+	Endpoint string
+	Payload  []byte
+
+	// VAPIDKey is the public key the message was signed with, and
+	// Subject the contact of the sender.
+	VAPIDKey string
+	Subject  string
+}
+
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+	gone bool
+}
+
+// The Server structure is a push service over TLS. Messages it
+// accepts are sent on Messages.
+type Server struct {
+	*httptest.Server
+	Messages chan *Message
+
+	mu       sync.Mutex
+	browsers map[string]*browser
+}
+
+// NewServer starts a push service. It should be closed when done.
+func NewServer() *Server {
+	s := &Server{
+		Messages: make(chan *Message, 16),
+		browsers: make(map[string]*browser),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.push))
+	return s
+}
+
+// Host returns the host and port of the push service, to be allowed
+// on the sender.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "https://")
+}
+
+// Subscribe returns the push subscription of a new browser.
+func (s *Server) Subscribe() *models.PushSubscription {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint := fmt.Sprintf("%s/push/%d", s.URL, len(s.browsers)+1)
+	s.browsers[endpoint] = &browser{key: key, auth: auth}
+
+	return &models.PushSubscription{
+		Endpoint: endpoint,
+		Keys: models.PushKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(auth),
+		},
+	}
+}
+
+// Revoke revokes the subscription. Further messages to it are
+// answered with 410 Gone.
+func (s *Server) Revoke(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.browsers[endpoint].gone = true
+}
+
+// Receive returns the next message accepted by the push service, or
+// nil if none is accepted within the timeout.
+func (s *Server) Receive(timeout time.Duration) *Message {
+	select {
+	case m := <-s.Messages:
+		return m
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+func (s *Server) push(w http.ResponseWriter, r *http.Request) {
+	endpoint := s.URL + r.URL.Path
+	s.mu.Lock()
+	b, ok := s.browsers[endpoint]
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		http.Error(w, "unknown subscription", http.StatusNotFound)
+		return
+	case b.gone:
+		http.Error(w, "subscription revoked", http.StatusGone)
+		return
+	case r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "":
+		http.Error(w, "missing headers", http.StatusBadRequest)
+		return
+	}
+
+	key, subject, err := s.verify(r.Header.Get("Authorization"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		body, err = decrypt(b, body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Messages <- &Message{Endpoint: endpoint, Payload: body, VAPIDKey: key, Subject: subject}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// verify checks the VAPID authorization of a message, and returns
+// the public key and subject of the sender.
+func (s *Server) verify(authorization string) (string, string, error) {
+	params, ok := strings.CutPrefix(authorization, "vapid ")
+	if !ok {
+		return "", "", errors.New("missing VAPID authorization")
+	}
+
+	var token, key string
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch k {
+		case "t":
+			token = v
+		case "k":
+			key = v
+		}
+	}
+
+	enc := base64.RawURLEncoding
+	pub, err := enc.DecodeString(key)
+	if err != nil || len(pub) != 65 {
+		return "", "", errors.New("invalid VAPID key")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", errors.New("invalid VAPID token")
+	}
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return "", "", errors.New("invalid VAPID signature")
+	}
+
+	verifier := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pub[1:33]),
+		Y:     new(big.Int).SetBytes(pub[33:]),
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(verifier, hash[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return "", "", errors.New("bad VAPID signature")
+	}
+
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	c, err := enc.DecodeString(parts[1])
+	if err == nil {
+		err = json.Unmarshal(c, &claims)
+	}
+	switch {
+	case err != nil:
+		return "", "", errors.New("invalid VAPID claims")
+	case claims.Aud != s.URL:
+		return "", "", fmt.Errorf("VAPID token for %q", claims.Aud)
+	case time.Unix(claims.Exp, 0).Before(time.Now()) || time.Unix(claims.Exp, 0).After(time.Now().Add(24*time.Hour)):
+		return "", "", errors.New("VAPID token expired or too long-lived")
+	case claims.Sub == "":
+		return "", "", errors.New("missing VAPID subject")
+	}
+
+	return key, claims.Sub, nil
+}
+
+func hkdf(salt, secret, info []byte, n int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(append(append([]byte(nil), info...), 1))
+	return expand.Sum(nil)[:n]
+}
+
+// decrypt decrypts an aes128gcm message for the browser, and returns
+// its payload.
+func decrypt(b *browser, body []byte) ([]byte, error) {
+	if len(body) < 21 || len(body) < 21+int(body[20]) {
+		return nil, errors.New("truncated header")
+	}
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	serverKey, record := body[21:21+idlen], body[21+idlen:]
+	if len(record) > int(rs) {
+		return nil, errors.New("more than one record")
+	}
+
+	server, err := ecdh.P256().NewPublicKey(serverKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := b.key.ECDH(server)
+	if err != nil {
+		return nil, err
+	}
+
+	info := append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...)
+	ikm := hkdf(b.auth, secret, append(info, serverKey...), 32)
+	block, err := aes.NewCipher(hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), record, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last record ends with a 2 delimiter, followed by padding.
+	end := len(plaintext) - 1
+	for end >= 0 && plaintext[end] == 0 {
+		end--
+	}
+	if end < 0 || plaintext[end] != 2 {
+		return nil, errors.New("missing last record delimiter")
+	}
+	return plaintext[:end], nil
+}
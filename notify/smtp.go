@@ -0,0 +1,186 @@
+/**
+ * file: notify/smtp.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the email channel, delivering
+ * messages through an SMTP relay.
+ */
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const smtpTimeout time.Duration = 30 * time.Second
+
+var (
+	ErrNoStartTLS error = errors.New("smtp server does not support STARTTLS")
+)
+
+// The SMTPConfig structure configures the SMTP channel.
+type SMTPConfig struct {
+	Host string
+	Port int
+
+	// Username and Password authenticate to the server with PLAIN
+	// authentication, if a username is set.
+	Username string
+	Password string
+
+	// From is the address messages are sent from.
+	From string
+
+	// TLSConfig configures STARTTLS. The server name defaults to the
+	// host.
+	TLSConfig *tls.Config
+
+	// Insecure allows sending messages to servers that do not
+	// support STARTTLS. It should only be used with local relays.
+	Insecure bool
+}
+
+// The SMTPChannel structure delivers messages through an SMTP relay,
+// upgrading the connection with STARTTLS.
+type SMTPChannel struct {
+	config SMTPConfig
+}
+
+func NewSMTPChannel(config SMTPConfig) *SMTPChannel {
+	return &SMTPChannel{config: config}
+}
+
+// Send delivers the message to all its recipients, in a single
+// transaction.
+func (c *SMTPChannel) Send(ctx context.Context, m *Message) error {
+	body, err := c.compose(m, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := &tls.Config{}
+		if c.config.TLSConfig != nil {
+			config = c.config.TLSConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = c.config.Host
+		}
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	} else if !c.config.Insecure {
+		return ErrNoStartTLS
+	}
+
+	if c.config.Username != "" {
+		auth := smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(c.config.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// compose returns the MIME message, with the text and HTML bodies
+// as alternatives.
+func (c *SMTPChannel) compose(m *Message, now time.Time) ([]byte, error) {
+	for _, addr := range append([]string{c.config.From}, m.To...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid address %q", addr)
+		}
+	}
+
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	header := []string{
+		"From: " + c.config.From,
+		"To: " + strings.Join(m.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + parts.Boundary(),
+	}
+	body := strings.Join(header, "\r\n") + "\r\n\r\n"
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(body), buf.Bytes()...), nil
+}
@@ -0,0 +1,263 @@
+/**
+ * file: notify/smtp_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The sinkMessage structure is a message received by the sink.
+type sinkMessage struct {
+	From string
+	To   []string
+	Data string
+
+	// TLS is true if the message was sent after STARTTLS, and Auth
+	// holds the PLAIN credentials used, if any.
+	TLS  bool
+	Auth string
+}
+
+// smtpSink is an in-process SMTP server, recording the messages it
+// receives. It supports STARTTLS, unless tls is nil, and PLAIN
+// authentication once the connection is secure.
+type smtpSink struct {
+	listener net.Listener
+	tls      *tls.Config
+
+	mu       sync.Mutex
+	messages []*sinkMessage
+}
+
+// newSMTPSink starts a sink, and returns it along with the TLS
+// configuration trusting its certificate.
+func newSMTPSink(t *testing.T, starttls bool) (*smtpSink, *tls.Config) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "the sink should listen")
+	t.Cleanup(func() { listener.Close() })
+
+	sink := &smtpSink{listener: listener}
+	client := &tls.Config{RootCAs: x509.NewCertPool()}
+	if starttls {
+		cert, parsed := newTestCertificate(t)
+		sink.tls = &tls.Config{Certificates: []tls.Certificate{cert}}
+		client.RootCAs.AddCert(parsed)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+
+	return sink, client
+}
+
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "the key should be generated")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "the certificate should be created")
+	parsed, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "the certificate should be parsed")
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, parsed
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 sink ESMTP")
+
+	m := new(sinkMessage)
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			ext := []string{"sink"}
+			if s.tls != nil && !m.TLS {
+				ext = append(ext, "STARTTLS")
+			}
+			if m.TLS {
+				ext = append(ext, "AUTH PLAIN")
+			}
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+				text.PrintfLine("250%s%s", sep, e)
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			secure := tls.Server(conn, s.tls)
+			if err := secure.Handshake(); err != nil {
+				return
+			}
+			conn, text, m.TLS = secure, textproto.NewConn(secure), true
+		case "AUTH":
+			_, initial, _ := strings.Cut(arg, " ")
+			creds, _ := base64.StdEncoding.DecodeString(initial)
+			m.Auth = string(creds)
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			m.From = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			m.To = append(m.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			m.Data = string(data)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, m)
+			s.mu.Unlock()
+			m = &sinkMessage{TLS: m.TLS}
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *smtpSink) received() []*sinkMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*sinkMessage(nil), s.messages...)
+}
+
+func (s *smtpSink) config(tls *tls.Config) SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return SMTPConfig{
+		Host:      addr.IP.String(),
+		Port:      addr.Port,
+		Username:  "reps",
+		Password:  "hunter2",
+		From:      "delegit@example.org",
+		TLSConfig: tls,
+	}
+}
+
+// TestSMTPChannelSend tests that messages are sent over STARTTLS,
+// authenticated, with both bodies as alternatives.
+func TestSMTPChannelSend(t *testing.T) {
+	sink, tlsConfig := newSMTPSink(t, true)
+	channel := NewSMTPChannel(sink.config(tlsConfig))
+
+	m := &Message{
+		To:      []string{"rep@example.org", "council@example.org"},
+		Subject: "Résumé de la semaine",
+		Text:    "Bonjour,\nvoici les avis.",
+		HTML:    "<p>Bonjour,</p><p>voici les avis.</p>",
+	}
+	require.NoError(t, channel.Send(context.Background(), m), "sending should not fail")
+
+	received := sink.received()
+	require.Len(t, received, 1, "the message should be sent once")
+	r := received[0]
+	assert.True(t, r.TLS, "the message should be sent over TLS")
+	assert.Equal(t, "\x00reps\x00hunter2", r.Auth, "the channel should authenticate")
+	assert.Equal(t, "delegit@example.org", r.From)
+	assert.Equal(t, m.To, r.To)
+
+	msg, err := mail.ReadMessage(strings.NewReader(r.Data))
+	require.NoError(t, err, "the message should be valid")
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err, "the subject should be valid")
+	assert.Equal(t, m.Subject, subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err, "the content type should be valid")
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		part, err := parts.NextPart()
+		require.NoError(t, err, "the message should have both bodies")
+		assert.Equal(t, want.contentType, part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(part)
+		require.NoError(t, err, "the body should be readable")
+		assert.Equal(t, want.body, strings.ReplaceAll(string(body), "\r\n", "\n"))
+	}
+}
+
+// TestSMTPChannelRequiresTLS tests that messages are not sent in the
+// clear, unless explicitly allowed.
+func TestSMTPChannelRequiresTLS(t *testing.T) {
+	sink, tlsConfig := newSMTPSink(t, false)
+	config := sink.config(tlsConfig)
+	config.Username = ""
+	m := &Message{To: []string{"rep@example.org"}, Subject: "Digest", Text: "text", HTML: "<p>html</p>"}
+
+	err := NewSMTPChannel(config).Send(context.Background(), m)
+	assert.ErrorIs(t, err, ErrNoStartTLS, "the channel should require STARTTLS")
+	assert.Empty(t, sink.received(), "no message should be sent")
+
+	config.Insecure = true
+	require.NoError(t, NewSMTPChannel(config).Send(context.Background(), m), "insecure channels should send")
+	assert.Len(t, sink.received(), 1)
+}
+
+// TestSMTPChannelHeaderInjection tests that addresses cannot inject
+// headers.
+func TestSMTPChannelHeaderInjection(t *testing.T) {
+	channel := NewSMTPChannel(SMTPConfig{From: "delegit@example.org"})
+	_, err := channel.compose(&Message{To: []string{"rep@example.org\r\nBcc: x@example.org"}}, time.Now())
+	assert.Error(t, err, "addresses with line breaks should be refused")
+}
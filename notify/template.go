@@ -0,0 +1,107 @@
+/**
+ * file: notify/template.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file renders the messages from their localized
+ * templates. Each language has a directory of
+ * templates, with a text and an HTML version of each
+ * message. The text version defines the subject.
+ */
+
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// DefaultLanguage is the language used when a message is not
+// available in the requested language.
+const DefaultLanguage string = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	templateFuncs = map[string]any{
+		"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+		"score": func(f *models.Feedback) string { return fmt.Sprintf("%+d", f.Score()) },
+	}
+
+	textTemplates = make(map[string]*texttemplate.Template)
+	htmlTemplates = make(map[string]*htmltemplate.Template)
+)
+
+func init() {
+	languages, err := templateFS.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, l := range languages {
+		files, err := templateFS.ReadDir("templates/" + l.Name())
+		if err != nil {
+			panic(err)
+		}
+
+		// Each message is parsed on its own, as all text versions
+		// define their subject.
+		for _, f := range files {
+			path := "templates/" + l.Name() + "/" + f.Name()
+			name, ext, _ := strings.Cut(f.Name(), ".")
+			key := l.Name() + "/" + name
+
+			switch ext {
+			case "txt":
+				textTemplates[key] = texttemplate.Must(texttemplate.New(f.Name()).Funcs(templateFuncs).ParseFS(templateFS, path))
+			case "html":
+				htmlTemplates[key] = htmltemplate.Must(htmltemplate.New(f.Name()).Funcs(templateFuncs).ParseFS(templateFS, path))
+			}
+		}
+	}
+}
+
+// render renders the message with the given name in the language,
+// or in the default language if it is not available.
+func render(name, language string, to []string, data any) (*Message, error) {
+	key := language + "/" + name
+	if _, ok := textTemplates[key]; !ok {
+		key = DefaultLanguage + "/" + name
+	}
+
+	var subject, text, html bytes.Buffer
+	t := textTemplates[key]
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := t.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := htmlTemplates[key].Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// AlertMessage returns the message notifying the representative of
+// the alert. The rule and feedback of the alert must be set.
+func AlertMessage(r *models.Representative, a *models.Alert) (*Message, error) {
+	return render("alert", r.Language, []string{r.Email}, struct {
+		Representative *models.Representative
+		Alert          *models.Alert
+	}{r, a})
+}
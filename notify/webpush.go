@@ -0,0 +1,331 @@
+/**
+ * file: notify/webpush.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file sends Web Push messages to the browsers
+ * of the students following feedback. Messages are
+ * encrypted for the browser (RFC 8291), and the
+ * server identifies itself to the push services with
+ * VAPID (RFC 8292).
+ */
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+const (
+	pushTTL        time.Duration = 24 * time.Hour
+	vapidLifetime  time.Duration = 12 * time.Hour
+	pushRecordSize uint32        = 4096
+	pushSaltSize   int           = 16
+)
+
+// ErrPushGone is returned when the push service reports that the
+// subscription expired or was revoked. It should be deleted.
+var ErrPushGone = errors.New("the push subscription is gone")
+
+// ErrPushHost is returned when the endpoint of a subscription is not
+// on a known push service. Messages are never sent to other hosts, so
+// that the server cannot be made to post to arbitrary URLs.
+var ErrPushHost = errors.New("the push endpoint is not on a known push service")
+
+// DefaultPushHosts are the hosts of the push services of the major
+// browsers. Hosts starting with a dot match their subdomains.
+var DefaultPushHosts = []string{
+	"fcm.googleapis.com",
+	"updates.push.services.mozilla.com",
+	"web.push.apple.com",
+	".notify.windows.com",
+}
+
+// pushClient sends the messages unless another client is set. Push
+// services answer directly, so redirects are not followed.
+var pushClient = &http.Client{
+	Timeout: 30 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// The WebPush structure sends Web Push messages, signed with its
+// VAPID key.
+type WebPush struct {
+	key     *ecdsa.PrivateKey
+	subject string
+
+	// Client is the HTTP client the messages are sent with. A
+	// client not following redirects is used if it is nil.
+	Client *http.Client
+
+	// Hosts are the hosts of the push services messages may be sent
+	// to, as in DefaultPushHosts, which are used if it is nil. Hosts
+	// with a port only match that port, others only match 443.
+	Hosts []string
+}
+
+// NewWebPush returns a Web Push sender with the given VAPID private
+// key, a base64url P-256 scalar, and subject, a mailto: or https:
+// URL the push services can reach the operator at.
+func NewWebPush(privateKey, subject string) (*WebPush, error) {
+	b, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	k, err := ecdh.P256().NewPrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	if subject == "" {
+		return nil, errors.New("the VAPID subject is required")
+	}
+
+	pub := k.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(b),
+	}
+	return &WebPush{key: key, subject: subject}, nil
+}
+
+// GenerateVAPIDKey returns a new VAPID private key, for NewWebPush.
+func GenerateVAPIDKey() (string, error) {
+	k, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(k.Bytes()), nil
+}
+
+// PublicKey returns the VAPID public key, which browsers need to
+// subscribe, as a base64url uncompressed point.
+func (w *WebPush) PublicKey() string {
+	pub := make([]byte, 65)
+	pub[0] = 4
+	w.key.X.FillBytes(pub[1:33])
+	w.key.Y.FillBytes(pub[33:])
+	return base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// Allows returns true if the endpoint is an HTTPS URL on one of the
+// hosts of the push services.
+func (w *WebPush) Allows(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Hostname() == "" {
+		return false
+	}
+
+	hosts := w.Hosts
+	if hosts == nil {
+		hosts = DefaultPushHosts
+	}
+
+	hostname, port := strings.ToLower(u.Hostname()), u.Port()
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if _, _, err := net.SplitHostPort(h); err == nil {
+			if strings.ToLower(u.Host) == h {
+				return true
+			}
+			continue
+		}
+		if port != "" && port != "443" {
+			continue
+		}
+		if hostname == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(hostname, h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send encrypts the payload for the browser, and sends it to its
+// push service. It returns ErrPushGone if the subscription is no
+// longer valid, and ErrPushHost if its endpoint is not on a known
+// push service.
+func (w *WebPush) Send(ctx context.Context, s *models.PushSubscription, payload []byte) error {
+	if !w.Allows(s.Endpoint) {
+		return ErrPushHost
+	}
+
+	body, err := encryptPush(s, payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return err
+	}
+	token, err := w.vapidToken(endpoint.Scheme+"://"+endpoint.Host, time.Now().Add(vapidLifetime))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, w.PublicKey()))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL.Seconds())))
+
+	client := w.Client
+	if client == nil {
+		client = pushClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrPushGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("the push service answered %s", resp.Status)
+	}
+	return nil
+}
+
+// vapidToken returns the JWT identifying the server to the push
+// service at the given origin, until exp.
+func (w *WebPush) vapidToken(audience string, exp time.Time) (string, error) {
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": exp.Unix(),
+		"sub": w.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// hkdf derives n bytes of key material, with a single round of
+// HKDF-SHA-256.
+func hkdf(salt, secret, info []byte, n int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:n]
+}
+
+// pushKeys derives the content encryption key and nonce of a message
+// from the shared secret with the browser.
+func pushKeys(secret, auth, salt, browserKey, serverKey []byte) (cipher.AEAD, []byte, error) {
+	info := append([]byte("WebPush: info\x00"), browserKey...)
+	info = append(info, serverKey...)
+	ikm := hkdf(auth, secret, info, 32)
+
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gcm, nonce, nil
+}
+
+// encryptPush encrypts the payload for the browser, in a single
+// aes128gcm record.
+func encryptPush(s *models.PushSubscription, payload []byte) ([]byte, error) {
+	browserKey, err := base64.RawURLEncoding.DecodeString(s.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	browser, err := ecdh.P256().NewPublicKey(browserKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	auth, err := base64.RawURLEncoding.DecodeString(s.Keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+
+	server, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := server.ECDH(browser)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, pushSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	serverKey := server.PublicKey().Bytes()
+	gcm, nonce, err := pushKeys(secret, auth, salt, browserKey, serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header holds the salt, the record size, and the public
+	// key of the server. The payload is followed by the delimiter
+	// of the last record.
+	header := make([]byte, 0, pushSaltSize+5+len(serverKey))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(serverKey)))
+	header = append(header, serverKey...)
+
+	plaintext := append(append([]byte(nil), payload...), 2)
+	if len(plaintext)+gcm.Overhead() > int(pushRecordSize) {
+		return nil, errors.New("the push payload is too large")
+	}
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
@@ -0,0 +1,105 @@
+/**
+ * file: notify/webpush_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/notify/pushtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewWebPush tests that VAPID keys and subjects are checked.
+func TestNewWebPush(t *testing.T) {
+	key, err := GenerateVAPIDKey()
+	require.NoError(t, err, "generating a VAPID key should not fail")
+
+	w, err := NewWebPush(key, "mailto:admin@example.org")
+	require.NoError(t, err, "a generated key should be valid")
+	assert.Len(t, w.PublicKey(), 87, "the public key should be an uncompressed point")
+
+	_, err = NewWebPush(key, "")
+	assert.Error(t, err, "the subject should be required")
+	_, err = NewWebPush("not a key", "mailto:admin@example.org")
+	assert.Error(t, err, "the key should be base64url")
+	_, err = NewWebPush(strings.Repeat("A", 43), "mailto:admin@example.org")
+	assert.Error(t, err, "the key should be a valid scalar")
+}
+
+// TestWebPushAllows tests that only the endpoints on the hosts of the
+// push services are allowed.
+func TestWebPushAllows(t *testing.T) {
+	key, err := GenerateVAPIDKey()
+	require.NoError(t, err)
+	w, err := NewWebPush(key, "mailto:admin@example.org")
+	require.NoError(t, err)
+
+	assert.True(t, w.Allows("https://fcm.googleapis.com/fcm/send/abc"))
+	assert.True(t, w.Allows("https://updates.push.services.mozilla.com:443/wpush/v2/abc"))
+	assert.True(t, w.Allows("https://wns2-par02p.notify.windows.com/w/?token=abc"))
+
+	for _, endpoint := range []string{
+		"http://fcm.googleapis.com/fcm/send/abc",
+		"https://fcm.googleapis.com:8443/fcm/send/abc",
+		"https://user@fcm.googleapis.com/fcm/send/abc",
+		"https://fcm.googleapis.com.example.org/abc",
+		"https://notify.windows.com/abc",
+		"https://127.0.0.1/abc",
+		"https://169.254.169.254/latest/meta-data/",
+		"https://localhost/abc",
+	} {
+		assert.False(t, w.Allows(endpoint), "%s should not be allowed", endpoint)
+	}
+
+	w.Hosts = []string{"push.example.org", "127.0.0.1:8443"}
+	assert.True(t, w.Allows("https://push.example.org/abc"))
+	assert.True(t, w.Allows("https://127.0.0.1:8443/abc"))
+	assert.False(t, w.Allows("https://127.0.0.1/abc"), "hosts with a port should only match it")
+	assert.False(t, w.Allows("https://fcm.googleapis.com/fcm/send/abc"), "the default hosts should be replaced")
+}
+
+// TestWebPushSend tests that messages are signed, and decrypted by
+// the browser, and that revoked subscriptions are reported.
+func TestWebPushSend(t *testing.T) {
+	service := pushtest.NewServer()
+	defer service.Close()
+
+	key, err := GenerateVAPIDKey()
+	require.NoError(t, err)
+	w, err := NewWebPush(key, "mailto:admin@example.org")
+	require.NoError(t, err)
+	w.Client = service.Client()
+
+	s := service.Subscribe()
+	payload := []byte(`{"Kind":"status","FeedbackID":1}`)
+	assert.ErrorIs(t, w.Send(context.Background(), s, payload), ErrPushHost, "unknown push services should be refused")
+	assert.Nil(t, service.Receive(100*time.Millisecond), "nothing should be sent to unknown push services")
+
+	w.Hosts = []string{service.Host()}
+	require.NoError(t, w.Send(context.Background(), s, payload), "sending the message should not fail")
+
+	m := service.Receive(time.Second)
+	require.NotNil(t, m, "the push service should accept the message")
+	assert.Equal(t, s.Endpoint, m.Endpoint)
+	assert.Equal(t, payload, m.Payload, "the browser should decrypt the payload")
+	assert.Equal(t, w.PublicKey(), m.VAPIDKey)
+	assert.Equal(t, "mailto:admin@example.org", m.Subject)
+
+	service.Revoke(s.Endpoint)
+	assert.ErrorIs(t, w.Send(context.Background(), s, payload), ErrPushGone)
+
+	s = service.Subscribe()
+	s.Keys.P256dh = "AAAA"
+	assert.Error(t, w.Send(context.Background(), s, payload), "invalid browser keys should be rejected")
+
+	_, err = encryptPush(service.Subscribe(), make([]byte, 4096))
+	assert.Error(t, err, "payloads larger than a record should be rejected")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+)
+
+// report runs the report subcommand, and returns the exit code. The
+// report is written to the standard output unless a file is given.
+// Its statistics are exact.
+//
+//	report [-term 2024-2025-Q1] [-format html|pdf] [-o file] course|faculty CODE
+func report(db *database.Database, args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	term := flags.String("term", "", "academic term, the current one by default")
+	format := flags.String("format", "html", "format of the report, html or pdf")
+	output := flags.String("o", "", "file to write the report to")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 || (*format != "html" && *format != "pdf") {
+		usage()
+		return 2
+	}
+
+	scope := models.StatsScope(flags.Arg(0))
+	if scope != models.StatsScopeCourse && scope != models.StatsScopeFaculty {
+		usage()
+		return 2
+	}
+
+	if err := db.CheckSchemaVersion(); err != nil {
+		logger.Error().Err(err).Msg("run the migrations first")
+		return 1
+	}
+	logic.Setup(db)
+
+	r, err := logic.GetReport(scope, flags.Arg(1), *term, true)
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to build the report")
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			logger.Error().Err(err).Msg("unable to create the report file")
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "pdf" {
+		err = r.WritePDF(w)
+	} else {
+		err = r.WriteHTML(w)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to write the report")
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,94 @@
+/**
+ * file: reports/fonts.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the metrics of the standard PDF
+ * fonts the reports are written with, so that no font
+ * needs to be embedded, and the encoding of the text.
+ */
+
+package reports
+
+// font is one of the standard PDF fonts.
+type font int
+
+const (
+	fontRegular font = iota
+	fontBold
+)
+
+// fontNames are the base names of the fonts.
+var fontNames = [...]string{
+	fontRegular: "Helvetica",
+	fontBold:    "Helvetica-Bold",
+}
+
+// fontWidths are the widths of the printable ASCII characters of the
+// fonts, from the space on, in thousandths of the font size.
+var fontWidths = [...][95]int{
+	fontRegular: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	fontBold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+// winAnsiExtras are the characters of the Windows-1252 encoding used
+// by the fonts outside of ASCII and Latin-1.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‹': 0x8b, 'Œ': 0x8c,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96,
+	'—': 0x97, '™': 0x99, '›': 0x9b, 'œ': 0x9c, 'Ÿ': 0x9f,
+}
+
+// latinBase are the ASCII letters the Latin-1 letters are measured
+// as, from À on. Accents do not change the width of letters.
+const latinBase = "AAAAAAACEEEEIIIIDNOOOOOxOUUUUYPsaaaaaaaceeeeiiiidnooooo/ouuuuypy"
+
+// encode returns the text in the encoding of the fonts. Characters
+// the fonts do not have are replaced by question marks.
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n':
+			b = append(b, ' ')
+		case r >= ' ' && r <= '~', r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case winAnsiExtras[r] != 0:
+			b = append(b, winAnsiExtras[r])
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// width returns the width of the encoded text in the font, in
+// thousandths of the font size.
+func (f font) width(text []byte) int {
+	w := 0
+	for _, c := range text {
+		switch {
+		case c >= ' ' && c <= '~':
+			w += fontWidths[f][c-' ']
+		case c >= 0xc0:
+			w += fontWidths[f][latinBase[c-0xc0]-' ']
+		default:
+			w += 556
+		}
+	}
+	return w
+}
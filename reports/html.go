@@ -0,0 +1,33 @@
+/**
+ * file: reports/html.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file renders the reports as self-contained
+ * HTML pages, which can be printed as they are.
+ */
+
+package reports
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var reportTemplate = template.Must(template.New("report.html").Funcs(map[string]any{
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+	"score": func(f *models.Feedback) string { return fmt.Sprintf("%+d", f.Score()) },
+}).ParseFS(templateFS, "templates/report.html"))
+
+// WriteHTML writes the report as an HTML page, with its style inlined.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
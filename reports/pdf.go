@@ -0,0 +1,276 @@
+/**
+ * file: reports/pdf.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file renders the reports as PDF documents. The
+ * documents are written directly, with the standard
+ * fonts, so that no external tool is needed.
+ */
+
+package reports
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The size and margins of the A4 pages, in points.
+const (
+	pageWidth  float64 = 595.28
+	pageHeight float64 = 841.89
+	pageMargin float64 = 56
+)
+
+// The pdfDocument structure lays text out on pages, from top to
+// bottom, and writes them as a PDF document.
+type pdfDocument struct {
+	title   string
+	created time.Time
+
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+
+	// y is the baseline of the next line on the page.
+	y float64
+}
+
+// newPDFDocument returns a document without pages.
+func newPDFDocument(title string, created time.Time) *pdfDocument {
+	return &pdfDocument{title: title, created: created}
+}
+
+// newPage starts a new page.
+func (d *pdfDocument) newPage() {
+	d.page = new(bytes.Buffer)
+	d.pages = append(d.pages, d.page)
+	d.y = pageHeight - pageMargin
+}
+
+// reserve starts a new page unless the given height fits on the
+// current one.
+func (d *pdfDocument) reserve(height float64) {
+	if d.page == nil || d.y-height < pageMargin {
+		d.newPage()
+	}
+}
+
+// skip leaves the given vertical space.
+func (d *pdfDocument) skip(height float64) {
+	d.y -= height
+}
+
+// pdfString returns the encoded text as a PDF string literal.
+func pdfString(text []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range text {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// show writes the encoded text on a single line at the given
+// position, in the given gray level, 0 being black.
+func (d *pdfDocument) show(f font, size, x, y, gray float64, text []byte) {
+	fmt.Fprintf(d.page, "BT %.2f g /F%d %.1f Tf %.2f %.2f Td %s Tj ET\n", gray, f+1, size, x, y, pdfString(text))
+}
+
+// wrap splits the text into the lines fitting in the width, in
+// points. Words longer than a line are split.
+func wrap(f font, size, width float64, text string) [][]byte {
+	limit := int(width * 1000 / size)
+	space := f.width([]byte{' '})
+
+	var lines [][]byte
+	var line []byte
+	for _, word := range strings.Fields(text) {
+		w := encode(word)
+		for f.width(w) > limit {
+			n := len(w) - 1
+			for n > 1 && f.width(w[:n]) > limit {
+				n--
+			}
+			if len(line) != 0 {
+				lines, line = append(lines, line), nil
+			}
+			lines, w = append(lines, w[:n]), w[n:]
+		}
+
+		if len(line) != 0 && f.width(line)+space+f.width(w) > limit {
+			lines, line = append(lines, line), nil
+		}
+		if len(line) != 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+	}
+	if len(line) != 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// paragraph writes the text, wrapped within the margins and the
+// indent, starting a new page when needed.
+func (d *pdfDocument) paragraph(f font, size, indent, gray float64, text string) {
+	leading := size * 1.3
+	for _, line := range wrap(f, size, pageWidth-2*pageMargin-indent, text) {
+		d.reserve(leading)
+		d.y -= leading
+		d.show(f, size, pageMargin+indent, d.y, gray, line)
+	}
+}
+
+// columns writes a line made of the texts, each starting at the
+// given offset from the margin.
+func (d *pdfDocument) columns(f font, size float64, offsets []float64, texts ...string) {
+	leading := size * 1.3
+	d.reserve(leading)
+	d.y -= leading
+	for i, text := range texts {
+		d.show(f, size, pageMargin+offsets[i], d.y, 0, encode(text))
+	}
+}
+
+// heading writes a section heading, underlined, keeping room for
+// some of the section on the same page.
+func (d *pdfDocument) heading(text string) {
+	d.reserve(80)
+	d.skip(14)
+	d.paragraph(fontBold, 13, 0, 0, text)
+	d.skip(4)
+	fmt.Fprintf(d.page, "0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S\n", pageMargin, d.y, pageWidth-pageMargin, d.y)
+	d.skip(4)
+}
+
+// footers writes the title and the page number at the bottom of
+// every page.
+func (d *pdfDocument) footers() {
+	title := encode(d.title)
+	for i, page := range d.pages {
+		d.page = page
+		number := encode(fmt.Sprintf("Page %d of %d", i+1, len(d.pages)))
+		d.show(fontRegular, 8, pageMargin, pageMargin/2, 0.4, title)
+		d.show(fontRegular, 8, pageWidth-pageMargin-float64(fontRegular.width(number))*8/1000, pageMargin/2, 0.4, number)
+	}
+}
+
+// WriteTo writes the document, and returns the number of bytes
+// written.
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	if d.page == nil {
+		d.newPage()
+	}
+	d.footers()
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...any) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&out, format, args...)
+		out.WriteString("\nendobj\n")
+	}
+
+	// The catalog, the page tree, the fonts and the information
+	// dictionary come first, followed by each page and its content.
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+	for _, name := range fontNames {
+		object("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name)
+	}
+	object("<< /Title %s /Producer (Deleg'it) /CreationDate (D:%s) >>", pdfString(encode(d.title)), d.created.UTC().Format("20060102150405Z"))
+
+	for i, page := range d.pages {
+		var content bytes.Buffer
+		z := zlib.NewWriter(&content)
+		if _, err := page.WriteTo(z); err != nil {
+			return 0, err
+		}
+		if err := z.Close(); err != nil {
+			return 0, err
+		}
+
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, firstPage+2*i+1)
+		object("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+// WritePDF writes the report as a PDF document.
+func (r *Report) WritePDF(w io.Writer) error {
+	d := newPDFDocument(r.Title(), r.GeneratedAt)
+	date := func(t time.Time) string { return t.Format("2006-01-02") }
+
+	d.paragraph(fontBold, 18, 0, 0, r.Title())
+	d.paragraph(fontRegular, 9, 0, 0.4, fmt.Sprintf("%s, from %s to %s. Generated on %s.", r.Code, date(r.Since), date(r.LastDay()), date(r.GeneratedAt)))
+
+	d.heading("Top feedback of the term")
+	if len(r.Top) == 0 {
+		d.paragraph(fontRegular, 11, 0, 0, "No feedback was left this term.")
+	}
+	for i, f := range r.Top {
+		d.skip(4)
+		d.paragraph(fontBold, 11, 0, 0, fmt.Sprintf("%d. #%d %s", i+1, f.ID, f.Course))
+		d.paragraph(fontRegular, 11, 14, 0, f.Feedback)
+		d.paragraph(fontRegular, 9, 14, 0.4, fmt.Sprintf("Score %+d (%d up, %d down), %s", f.Score(), f.Upvotes, f.Downvotes, f.Status))
+	}
+
+	d.heading("Earlier feedback")
+	if len(r.Earlier) == 0 {
+		d.paragraph(fontRegular, 11, 0, 0, "No earlier feedback is open.")
+	}
+	for _, f := range r.Earlier {
+		d.skip(4)
+		d.paragraph(fontBold, 11, 0, 0, fmt.Sprintf("#%d %s", f.ID, f.Course))
+		d.paragraph(fontRegular, 11, 14, 0, f.Feedback)
+		d.paragraph(fontRegular, 9, 14, 0.4, fmt.Sprintf("Left on %s, score %+d, %s", date(f.CreatedAt), f.Score(), f.Status))
+	}
+
+	d.heading("Responses of the representatives")
+	if len(r.Responses) == 0 {
+		d.paragraph(fontRegular, 11, 0, 0, "The status of no feedback was changed this term.")
+	}
+	for _, resp := range r.Responses {
+		d.columns(fontRegular, 11, []float64{0, 90, 220}, date(resp.At), fmt.Sprintf("#%d %s", resp.Feedback.ID, resp.Feedback.Course), string(resp.Status))
+		if resp.Text != "" {
+			d.paragraph(fontRegular, 9, 90, 0.4, resp.Text)
+		}
+	}
+
+	d.heading("Statistics")
+	for _, l := range r.StatLines() {
+		d.columns(fontRegular, 11, []float64{0, 220}, l.Label, l.Value)
+	}
+	if note := r.StatsNote(); note != "" {
+		d.skip(4)
+		d.paragraph(fontRegular, 9, 0, 0.4, note)
+	}
+
+	_, err := d.WriteTo(w)
+	return err
+}
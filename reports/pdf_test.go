@@ -0,0 +1,79 @@
+/**
+ * file: reports/pdf_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package reports
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrap tests that text is wrapped within the width, splitting
+// words longer than a line.
+func TestWrap(t *testing.T) {
+	lines := wrap(fontRegular, 10, 100, "The exercise sessions are far too short, and way toooooooooooooooooooooo long.")
+	require.NotEmpty(t, lines)
+	for _, l := range lines {
+		assert.LessOrEqual(t, float64(fontRegular.width(l))*10/1000, 100.0, "%q should fit in the width", l)
+	}
+	assert.Equal(t, "The exercise sessions", string(lines[0]))
+
+	assert.Equal(t, []byte("\xc7a co\xfbte 5 \x80 ?"), encode("Ça coûte 5 € 😀"), "text should be encoded in WinAnsi")
+}
+
+// TestWritePDF tests that reports are written as well-formed PDF
+// documents, spanning several pages if needed.
+func TestWritePDF(t *testing.T) {
+	r := newTestReport(t)
+	for i := 0; i < 4; i++ {
+		r.Top = append(r.Top, r.Top...)
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, r.WritePDF(&b), "writing the report should not fail")
+	pdf := b.Bytes()
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+
+	// The cross-reference table should point to each object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")), "startxref should point to the table")
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		offset, err := strconv.Atoi(string(e[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "entry %d should point to its object", i+1)
+	}
+
+	pages := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(pdf)
+	require.NotNil(t, pages)
+	assert.NotEqual(t, "1", string(pages[1]), "long reports should span several pages")
+
+	// The content of the first page should show the title.
+	stream := regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindSubmatch(pdf)
+	require.NotNil(t, stream)
+	z, err := zlib.NewReader(bytes.NewReader(stream[1]))
+	require.NoError(t, err)
+	content, err := io.ReadAll(z)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "(Feedback report of LINFO1101, 2024-2025-Q1) Tj"), "the title should be shown")
+	assert.Contains(t, string(content), "Page 1 of "+string(pages[1]))
+	assert.Contains(t, string(content), `\(`, "parentheses should be escaped")
+}
@@ -0,0 +1,203 @@
+/**
+ * file: reports/report.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file builds the term reports on a course or
+ * faculty, for the meetings of the councils: the top
+ * feedback of the term, the status of the earlier
+ * feedback, the responses of the representatives,
+ * and the statistics.
+ */
+
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/notify"
+)
+
+// ReportLimit is the number of feedback listed in the sections of
+// the top and earlier feedback of a report.
+const ReportLimit int = 10
+
+// The Report structure is the report of the feedback on a course or
+// faculty during an academic term.
+type Report struct {
+	Scope        models.StatsScope
+	Code         string
+	Term         string
+	Since, Until time.Time
+
+	// Top is the most consensual feedback created during the term.
+	Top []*models.Feedback
+
+	// Earlier is the feedback created before the term which is
+	// still open, or whose status changed during the term.
+	Earlier []*models.Feedback
+
+	// Responses are the changes of status made during the term, in
+	// order.
+	Responses []*Response
+
+	// Stats are the statistics of the feedback created during the
+	// term, nil if they are not available.
+	Stats *models.Stats
+
+	GeneratedAt time.Time
+}
+
+// The Response structure is a change of the status of feedback by
+// the representatives.
+type Response struct {
+	Feedback *models.Feedback
+	Status   models.FeedbackStatus
+	At       time.Time
+
+	// Text is the public response given along with the change, if
+	// any.
+	Text string
+}
+
+// open returns true if the representatives are still handling the
+// feedback.
+func open(f *models.Feedback) bool {
+	return f.Status != models.FeedbackStatusResolved && f.Status != models.FeedbackStatusRejected
+}
+
+// BuildReport builds the report of the feedback selected by the query
+// during the term, between its Since and Until. The feedback may have
+// been created before the term, and the changes of status must be
+// decoded. At most limit feedback are listed in the sections of the
+// top and earlier feedback.
+func BuildReport(q *models.StatsQuery, term string, feedback []*models.Feedback, changes []*models.Change, stats *models.Stats, limit int) *Report {
+	r := &Report{Scope: q.Scope, Code: q.Code, Term: term, Since: *q.Since, Until: *q.Until, Stats: stats, GeneratedAt: time.Now()}
+
+	byID := make(map[uint]*models.Feedback, len(feedback))
+	for _, f := range feedback {
+		byID[f.ID] = f
+	}
+
+	changed := make(map[uint]bool)
+	responded := make(map[uint]*time.Time)
+	for _, c := range changes {
+		f, ok := byID[c.FeedbackID]
+		if !ok || c.Feedback == nil {
+			continue
+		}
+
+		changed[f.ID] = true
+		resp := &Response{Feedback: f, Status: c.Feedback.Status, At: c.CreatedAt}
+		// Responses are kept by the later changes, and only listed
+		// along with the change they were given with.
+		if at := c.Feedback.RespondedAt; at != nil && !at.Before(r.Since) && (responded[f.ID] == nil || !at.Equal(*responded[f.ID])) {
+			resp.Text = c.Feedback.Response
+			responded[f.ID] = at
+		}
+		r.Responses = append(r.Responses, resp)
+	}
+
+	for _, f := range feedback {
+		switch {
+		case !f.CreatedAt.Before(r.Since) && f.CreatedAt.Before(r.Until):
+			r.Top = append(r.Top, f)
+		case f.CreatedAt.Before(r.Since) && (open(f) || changed[f.ID]):
+			r.Earlier = append(r.Earlier, f)
+		}
+	}
+
+	notify.Rank(r.Top)
+	notify.Rank(r.Earlier)
+	r.Top = r.Top[:min(len(r.Top), limit)]
+	r.Earlier = r.Earlier[:min(len(r.Earlier), limit)]
+	return r
+}
+
+// Title returns the title of the report.
+func (r *Report) Title() string {
+	return "Feedback report of " + r.Code + ", " + r.Term
+}
+
+// LastDay returns the last day of the term covered by the report.
+func (r *Report) LastDay() time.Time {
+	return r.Until.AddDate(0, 0, -1)
+}
+
+// Filename returns the name of the file of the report, without its
+// extension.
+func (r *Report) Filename() string {
+	return r.Code + "-" + r.Term
+}
+
+// The StatLine structure is a line of the statistics of a report.
+type StatLine struct {
+	Label string
+	Value string
+}
+
+// StatLines returns the lines of the statistics of the report, nil
+// if they are not available or suppressed.
+func (r *Report) StatLines() []StatLine {
+	s := r.Stats
+	if s == nil || (s.Privacy != nil && s.Privacy.Suppressed) {
+		return nil
+	}
+
+	lines := []StatLine{
+		{"Feedback", fmt.Sprint(s.Feedback)},
+		{"Upvotes", fmt.Sprint(s.Upvotes)},
+		{"Downvotes", fmt.Sprint(s.Downvotes)},
+	}
+	if s.UpvoteRatio != nil {
+		lines = append(lines, StatLine{"Upvote ratio", fmt.Sprintf("%.0f%%", *s.UpvoteRatio*100)})
+	}
+	lines = append(lines, StatLine{"Responded to", fmt.Sprint(s.Responded)})
+	if s.MedianResponseSeconds != nil {
+		d := time.Duration(*s.MedianResponseSeconds * float64(time.Second))
+		lines = append(lines, StatLine{"Median response time", d.Round(time.Minute).String()})
+	}
+
+	statuses := make([]string, 0, len(s.Statuses))
+	for status := range s.Statuses {
+		statuses = append(statuses, string(status))
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		lines = append(lines, StatLine{"Status " + status, fmt.Sprint(s.Statuses[models.FeedbackStatus(status)])})
+	}
+
+	for _, b := range s.Scores {
+		var label string
+		switch {
+		case b.Min == nil:
+			label = fmt.Sprintf("Score %d or less", *b.Max)
+		case b.Max == nil:
+			label = fmt.Sprintf("Score %d or more", *b.Min)
+		case *b.Min == *b.Max:
+			label = fmt.Sprintf("Score %d", *b.Min)
+		default:
+			label = fmt.Sprintf("Score %d to %d", *b.Min, *b.Max)
+		}
+		lines = append(lines, StatLine{label, fmt.Sprint(b.Feedback)})
+	}
+	return lines
+}
+
+// StatsNote returns the note on the statistics of the report, if
+// any: why they are missing, or how they were made private.
+func (r *Report) StatsNote() string {
+	switch {
+	case r.Stats == nil:
+		return "The statistics cannot be published anymore this term."
+	case r.Stats.Privacy == nil:
+		return ""
+	case r.Stats.Privacy.Suppressed:
+		return "The statistics are withheld, as they cover too little feedback to protect the privacy of the students."
+	default:
+		return fmt.Sprintf("The statistics are made private with random noise (epsilon %g), and the median response time is withheld.", r.Stats.Privacy.Epsilon)
+	}
+}
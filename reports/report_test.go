@@ -0,0 +1,81 @@
+/**
+ * file: reports/report_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ */
+
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func feedbackIDs(feedback []*models.Feedback) []uint {
+	ids := []uint{}
+	for _, f := range feedback {
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+// newTestReport returns the report of LINFO1101 during the first term
+// of 2024-2025.
+func newTestReport(t *testing.T) *Report {
+	since, until, err := models.TermBounds("2024-2025-Q1")
+	require.NoError(t, err)
+	during, before := since.AddDate(0, 1, 0), since.AddDate(0, -1, 0)
+
+	feedback := []*models.Feedback{
+		{ID: 1, Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us.", Upvotes: 12, Downvotes: 2, CreatedAt: during},
+		{ID: 2, Course: "LINFO1101", Feedback: "The slides are published <too late> before lectures.", Upvotes: 20, CreatedAt: during},
+		{ID: 3, Course: "LINFO1101", Feedback: "The project statement is ambiguous in many places.", Status: models.FeedbackStatusAcknowledged, CreatedAt: before},
+		{ID: 4, Course: "LINFO1101", Feedback: "The exam was much longer than announced in class.", Status: models.FeedbackStatusResolved, CreatedAt: before},
+		{ID: 5, Course: "LINFO1101", Feedback: "The room is too small for everyone to have a seat.", Status: models.FeedbackStatusResolved, CreatedAt: before},
+	}
+	changes := []*models.Change{
+		{FeedbackID: 4, Kind: models.ChangeKindStatus, Feedback: &models.Feedback{ID: 4, Status: models.FeedbackStatusResolved, Response: "The exam was shortened.", RespondedAt: &during}, CreatedAt: during},
+	}
+	ratio := 0.9375
+	stats := &models.Stats{Feedback: 2, Upvotes: 32, Downvotes: 2, UpvoteRatio: &ratio, Statuses: map[models.FeedbackStatus]int64{models.FeedbackStatusNew: 2}}
+
+	q := &models.StatsQuery{Scope: models.StatsScopeCourse, Code: "LINFO1101", Since: &since, Until: &until}
+	return BuildReport(q, "2024-2025-Q1", feedback, changes, stats, ReportLimit)
+}
+
+// TestBuildReport tests that reports rank the feedback of the term,
+// and list the earlier feedback still open or changed.
+func TestBuildReport(t *testing.T) {
+	r := newTestReport(t)
+
+	assert.Equal(t, []uint{2, 1}, feedbackIDs(r.Top), "the feedback of the term should be ranked")
+	assert.Equal(t, []uint{3, 4}, feedbackIDs(r.Earlier), "earlier feedback should be listed if open or changed")
+	require.Len(t, r.Responses, 1)
+	assert.Equal(t, models.FeedbackStatusResolved, r.Responses[0].Status)
+	assert.Equal(t, "The exam was shortened.", r.Responses[0].Text)
+	assert.Equal(t, "LINFO1101-2024-2025-Q1", r.Filename())
+	assert.Equal(t, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), r.LastDay())
+
+	assert.Contains(t, r.StatLines(), StatLine{"Upvote ratio", "94%"})
+	assert.Empty(t, r.StatsNote(), "exact statistics should have no note")
+	r.Stats.Privacy = &models.StatsPrivacy{Epsilon: 1, Suppressed: true}
+	assert.Nil(t, r.StatLines(), "suppressed statistics should not be listed")
+	assert.NotEmpty(t, r.StatsNote())
+}
+
+// TestWriteHTML tests that reports are written as escaped HTML.
+func TestWriteHTML(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, newTestReport(t).WriteHTML(&b), "writing the report should not fail")
+
+	html := b.String()
+	assert.Contains(t, html, "<title>Feedback report of LINFO1101, 2024-2025-Q1</title>")
+	assert.Contains(t, html, "&lt;too late&gt;", "feedback should be escaped")
+	assert.Contains(t, html, "from 2024-09-01 to 2025-01-31")
+	assert.NotContains(t, html, "<link", "reports should be self-contained")
+}
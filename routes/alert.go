@@ -0,0 +1,98 @@
+/**
+ * file: router/alert.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the alert endpoints. They are reserved to
+ * administrators.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func alertBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your alert rule"
+	uxe.Detail = "The alert rule you gave could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func getAlerts(ctx *gin.Context) {
+	a, err := logic.GetAlerts()
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, a)
+}
+
+func getAlertChannels(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, logic.AlertChannels())
+}
+
+func getAlertRules(ctx *gin.Context) {
+	r, err := logic.GetAlertRules()
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, r)
+}
+
+func postAlertRule(ctx *gin.Context) {
+	var rule models.AlertRule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		handleError(ctx, alertBindError(err))
+		return
+	}
+
+	r, err := logic.CreateAlertRule(&rule)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, r)
+}
+
+func deleteAlertRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, alertBindError(err))
+		return
+	}
+
+	if err := logic.DeleteAlertRule(uint(id)); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func optionsAlert(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+}
+
+func RegisterAlertEndpoints(router *gin.Engine) {
+	group := router.Group("/alerts")
+	group.Use(CommonHeaders, optionsAlert)
+	group.GET("/", RequireAdmin, getAlerts)
+	group.GET("/channels", RequireAdmin, getAlertChannels)
+	group.GET("/rules", RequireAdmin, getAlertRules)
+	group.POST("/rules", RequireAdmin, postAlertRule)
+	group.DELETE("/rules/:id", RequireAdmin, deleteAlertRule)
+	group.OPTIONS("/*any", Terminate)
+}
@@ -0,0 +1,84 @@
+/**
+ * file: router/batch.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the batch endpoints.
+ */
+
+package routes
+
+import (
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+// The batchResult structure is the outcome of one operation of a
+// batch, as returned to clients.
+type batchResult struct {
+	Index    int              `json:"Index"`
+	Status   int              `json:"Status"`
+	Feedback *models.Feedback `json:"Feedback,omitempty"`
+	Errors   []map[string]any `json:"Errors,omitempty"`
+}
+
+// The batchOutcome structure is the outcome of a batch, as returned
+// to clients.
+type batchOutcome struct {
+	Committed bool          `json:"Committed"`
+	Results   []batchResult `json:"Results"`
+}
+
+func batchBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your batch"
+	uxe.Detail = "The batch you gave could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func postBatch(ctx *gin.Context) {
+	var batch models.Batch
+	if err := ctx.ShouldBindJSON(&batch); err != nil {
+		handleError(ctx, batchBindError(err))
+		return
+	}
+
+	outcome, err := logic.RunBatch(&batch, voteSource(ctx), isAdmin(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	response := batchOutcome{Committed: outcome.Committed, Results: []batchResult{}}
+	for _, r := range outcome.Results {
+		result := batchResult{Index: r.Index, Status: r.Status, Feedback: r.Feedback}
+		switch v := r.Error.(type) {
+		case nil:
+		case uxerrors.Errors:
+			result.Errors = v.ToMap(false)["Errors"]
+		default:
+			result.Errors = uxerrors.NewErrors(r.Status).AppendNew(v).ToMap(false)["Errors"]
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	// The batch itself was processed, the status of each operation is
+	// reported in its result.
+	ctx.JSON(http.StatusOK, response)
+}
+
+func optionsBatch(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+}
+
+func RegisterBatchEndpoints(router *gin.Engine) {
+	batch := router.Group("/batch")
+	batch.Use(CommonHeaders, optionsBatch)
+	batch.POST("/", Idempotent, postBatch)
+	batch.OPTIONS("/", Terminate)
+}
@@ -0,0 +1,59 @@
+/**
+ * file: router/change.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the change feed endpoints.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func changesBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your request"
+	uxe.Detail = "The since and limit parameters should be positive integers. Use the cursor returned with the previous changes, or 0 to start over, and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func getChanges(ctx *gin.Context) {
+	since, err := strconv.ParseUint(ctx.DefaultQuery("since", "0"), 10, 64)
+	if err != nil {
+		handleError(ctx, changesBindError(err))
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(logic.DefaultChangeLimit)))
+	if err != nil {
+		handleError(ctx, changesBindError(err))
+		return
+	}
+
+	feed, err := logic.GetChanges(since, limit)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, feed)
+}
+
+func optionsChanges(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+}
+
+func RegisterChangeEndpoints(router *gin.Engine) {
+	changes := router.Group("/changes")
+	changes.Use(CommonHeaders, optionsChanges)
+	changes.GET("/", getChanges)
+	changes.OPTIONS("/", Terminate)
+}
@@ -0,0 +1,97 @@
+/**
+ * file: router/export.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the route exporting feedback
+ * for spreadsheets, streamed as it is read.
+ */
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.licolas.net/delegit/delegit/export"
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func exportFormatError(format export.Format) error {
+	uxe := uxerrors.New(fmt.Errorf("unknown export format %q", format))
+	uxe.Summary = "The export format is unknown"
+	uxe.Detail = fmt.Sprintf("Feedback is exported as csv, xlsx or ndjson, but %q was requested. Correct the format and try again.", format)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// The exportResponse structure writes the export to the response.
+// The response is started by the first write, so that errors
+// occurring before can still be reported.
+type exportResponse struct {
+	ctx      *gin.Context
+	format   export.Format
+	language string
+	started  bool
+}
+
+func (r *exportResponse) start() {
+	if r.started {
+		return
+	}
+	r.started = true
+
+	filename := fmt.Sprintf("feedback-%s.%s", time.Now().UTC().Format("20060102"), r.format)
+	r.ctx.Header("Content-Type", r.format.ContentType())
+	r.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	r.ctx.Header("Content-Language", r.language)
+	r.ctx.Header("Vary", "Accept-Language")
+	r.ctx.Status(http.StatusOK)
+}
+
+func (r *exportResponse) Write(p []byte) (int, error) {
+	r.start()
+	return r.ctx.Writer.Write(p)
+}
+
+// exportFeedback exports the feedback selected by the same filters as
+// the list, in the requested format. The headers are in the language
+// of the lang parameter, or of the Accept-Language header. Errors
+// occurring once the export is under way cut it short.
+func exportFeedback(ctx *gin.Context) {
+	format := export.Format(ctx.DefaultQuery("format", string(export.FormatCSV)))
+	if !format.Valid() {
+		handleError(ctx, exportFormatError(format))
+		return
+	}
+
+	filter, err := feedbackFilter(ctx)
+	if err != nil {
+		handleError(ctx, statsBindError(err))
+		return
+	}
+
+	r := &exportResponse{
+		ctx:      ctx,
+		format:   format,
+		language: export.Language(ctx.Query("lang"), ctx.GetHeader("Accept-Language")),
+	}
+	w, err := export.NewWriter(format, r, r.language)
+	if err == nil {
+		err = logic.ExportFeedback(filter, w)
+	}
+	if err != nil {
+		if !r.started {
+			handleError(ctx, err)
+			return
+		}
+		ctx.Error(err)
+		return
+	}
+
+	// Empty exports may not have written anything.
+	r.start()
+	ctx.Writer.WriteHeaderNow()
+}
@@ -56,10 +56,46 @@ func feedbackBindError(err error) error {
 	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
 }
 
+// feedbackFilter returns the filter given in the course, faculty,
+// status, since and until parameters of the request.
+func feedbackFilter(ctx *gin.Context) (*models.FeedbackFilter, error) {
+	since, until, err := statsPeriod(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FeedbackFilter{
+		Course:  ctx.Query("course"),
+		Faculty: ctx.Query("faculty"),
+		Status:  models.FeedbackStatus(ctx.Query("status")),
+		Tag:     ctx.Query("tag"),
+		Since:   since,
+		Until:   until,
+	}, nil
+}
+
 func getAllFeedback(ctx *gin.Context) {
-	feedback, err := logic.GetAllFeedback()
+	filter, err := feedbackFilter(ctx)
+	if err != nil {
+		handleError(ctx, statsBindError(err))
+		return
+	}
+
+	// The version is fetched before the list, so that a change in
+	// between makes clients fetch the list again rather than miss it.
+	version, err := logic.GetFeedbackListVersion()
 	if err != nil {
 		handleError(ctx, err)
+		return
+	}
+	if notModified(ctx, version.ETag(), version.ModifiedAt) {
+		return
+	}
+
+	feedback, err := logic.GetFilteredFeedback(filter)
+	if err != nil {
+		handleError(ctx, err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, feedback)
@@ -77,6 +113,10 @@ func postFeedback(ctx *gin.Context) {
 		handleError(ctx, err)
 		return
 	}
+	if f.Held {
+		ctx.JSON(http.StatusAccepted, f)
+		return
+	}
 	ctx.JSON(http.StatusOK, f)
 }
 
@@ -95,46 +135,230 @@ func getFeedback(ctx *gin.Context) {
 		handleError(ctx, err)
 		return
 	}
+	if notModified(ctx, feedback.ETag(), feedback.UpdatedAt) {
+		return
+	}
 	ctx.JSON(http.StatusOK, feedback)
 }
 
+func getFeedbackTimeline(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	since, until, err := statsPeriod(ctx)
+	if err != nil {
+		handleError(ctx, statsBindError(err))
+		return
+	}
+
+	q := &models.TimelineQuery{FeedbackID: uint(id), Interval: models.TimelineInterval(ctx.Query("interval"))}
+	if since != nil {
+		q.Since = *since
+	}
+	if until != nil {
+		q.Until = *until
+	}
+
+	timeline, err := logic.GetTimeline(q)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, timeline)
+}
+
 func putFeedback(ctx *gin.Context) {
+	_id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	id := uint(_id)
+
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
 	var feedback *models.Feedback
 	if err := ctx.ShouldBind(&feedback); err != nil {
 		handleError(ctx, feedbackBindError(err))
 		return
 	}
 
-	feedback, err := logic.UpdateFeedback(feedback)
+	feedback, err = logic.UpdateFeedback(id, feedback, ifMatchHeader(ctx))
 	if err != nil {
 		handleError(ctx, err)
 		return
 	}
+	ctx.Header("ETag", feedback.ETag())
 	ctx.JSON(http.StatusOK, feedback)
 }
 
-func deleteFeedback(ctx *gin.Context) {
-	var feedback *models.Feedback
-	if err := ctx.ShouldBind(&feedback); err != nil {
+func patchFeedback(ctx *gin.Context) {
+	_id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	id := uint(_id)
+
+	if err != nil {
 		handleError(ctx, feedbackBindError(err))
 		return
 	}
 
-	feedback, err := logic.DeleteFeedback(feedback)
+	patch, err := ctx.GetRawData()
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	feedback, err := logic.PatchFeedback(id, logic.PatchKind(ctx.ContentType()), patch, ifMatchHeader(ctx))
 	if err != nil {
 		handleError(ctx, err)
 		return
 	}
+	ctx.Header("ETag", feedback.ETag())
+	ctx.JSON(http.StatusOK, feedback)
+}
+
+// The feedbackStatus structure is the body of a status transition
+// request.
+type feedbackStatus struct {
+	Status models.FeedbackStatus `json:"Status" binding:"required"`
+}
+
+func putFeedbackStatus(ctx *gin.Context) {
+	_id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	id := uint(_id)
+
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	var body feedbackStatus
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
 
+	feedback, err := logic.TransitionFeedbackStatus(id, body.Status, ifMatchHeader(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Header("ETag", feedback.ETag())
 	ctx.JSON(http.StatusOK, feedback)
 }
 
+// postStatusRequest records the request of a voter for the status of
+// the feedback to change, for the representatives to consider.
+func postStatusRequest(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	var s models.StatusRequest
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		handleError(ctx, followBindError(err))
+		return
+	}
+
+	request, added, err := logic.RequestFeedbackStatus(voterToken(ctx), uint(id), &s)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	if added {
+		ctx.JSON(http.StatusCreated, request)
+	} else {
+		ctx.JSON(http.StatusOK, request)
+	}
+}
+
+func getStatusRequests(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	s, err := logic.GetStatusRequests(uint(id), requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, s)
+}
+
+// The feedbackDeletion structure is the optional body of a
+// deletion request.
+type feedbackDeletion struct {
+	Reason string `json:"Reason"`
+}
+
+// deleteFeedback hides the feedback from everyone, leaving a
+// tombstone. It is restricted to the administrators, who moderate
+// the feedback.
+func deleteFeedback(ctx *gin.Context) {
+	_id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	id := uint(_id)
+
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	var body feedbackDeletion
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			handleError(ctx, feedbackBindError(err))
+			return
+		}
+	}
+
+	deletion, err := logic.DeleteFeedback(id, body.Reason, ifMatchHeader(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deletion)
+}
+
+func purgeFeedback(ctx *gin.Context) {
+	_id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	id := uint(_id)
+
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	deletion, err := logic.PurgeFeedback(id, ifMatchHeader(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deletion)
+}
+
 func optionsFeedbackList(ctx *gin.Context) {
 	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 }
 
 func optionsFeedbackEntry(ctx *gin.Context) {
-	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, PUT, PATCH, DELETE, OPTIONS")
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	ctx.Writer.Header().Set("Accept-Patch", fmt.Sprintf("%s, %s", logic.PatchKindMerge, logic.PatchKindJSON))
+}
+
+// voteSource returns the origin of the vote cast in the request.
+func voteSource(ctx *gin.Context) models.VoteSource {
+	return models.VoteSource{
+		Token:   ctx.GetHeader("X-Voter-Token"),
+		Address: ctx.ClientIP(),
+	}
 }
 
 func updateFeedbackUpvotes(ctx *gin.Context) {
@@ -152,11 +376,12 @@ func updateFeedbackUpvotes(ctx *gin.Context) {
 		return
 	}
 
-	feedback, err := logic.UpdateFeedbackUpvotes(id, votes)
+	feedback, err := logic.UpdateFeedbackUpvotes(id, votes, voteSource(ctx))
 	if err != nil {
 		handleError(ctx, err)
 		return
 	}
+	ctx.Header("ETag", feedback.ETag())
 
 	ctx.JSON(http.StatusCreated, feedback)
 }
@@ -176,11 +401,12 @@ func updateFeedbackDownvotes(ctx *gin.Context) {
 		return
 	}
 
-	feedback, err := logic.UpdateFeedbackDownvotes(id, votes)
+	feedback, err := logic.UpdateFeedbackDownvotes(id, votes, voteSource(ctx))
 	if err != nil {
 		handleError(ctx, err)
 		return
 	}
+	ctx.Header("ETag", feedback.ETag())
 
 	ctx.JSON(http.StatusCreated, feedback)
 }
@@ -191,15 +417,23 @@ func RegisterFeedbackEndpoints(database *database.Database, router *gin.Engine)
 	list := router.Group("/feedback")
 	list.Use(CommonHeaders, optionsFeedbackList)
 	list.GET("/", getAllFeedback)
-	list.POST("/", postFeedback)
+	list.POST("/", Idempotent, postFeedback)
+	list.GET("/stream", streamFeedback)
+	list.GET("/export", exportFeedback)
 	list.OPTIONS("/", Terminate)
 
 	entry := router.Group("/feedback/:id")
 	entry.Use(optionsFeedbackEntry)
 	entry.GET("/", getFeedback)
-	entry.PATCH("/upvote", updateFeedbackUpvotes)
-	entry.PATCH("/downvote", updateFeedbackDownvotes)
-	entry.PUT("/", putFeedback)
-	entry.DELETE("/", deleteFeedback)
+	entry.GET("/timeline", getFeedbackTimeline)
+	entry.PATCH("/upvote", Idempotent, updateFeedbackUpvotes)
+	entry.PATCH("/downvote", Idempotent, updateFeedbackDownvotes)
+	entry.PUT("/", RequireIfMatch, putFeedback)
+	entry.PATCH("/", RequireIfMatch, patchFeedback)
+	entry.PUT("/status", RequireAdmin, RequireIfMatch, putFeedbackStatus)
+	entry.GET("/status-requests", RequireRepresentativeOrAdmin, getStatusRequests)
+	entry.POST("/status-requests", Idempotent, postStatusRequest)
+	entry.DELETE("/", RequireAdmin, RequireIfMatch, deleteFeedback)
+	entry.DELETE("/purge", RequireAdmin, RequireIfMatch, purgeFeedback)
 	entry.OPTIONS("/", Terminate)
 }
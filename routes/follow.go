@@ -0,0 +1,152 @@
+/**
+ * file: router/follow.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the follows of the anonymous voters. Voters
+ * are identified by their voter token, in the
+ * X-Voter-Token header.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func followBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your request"
+	uxe.Detail = "The request you made could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// voterToken returns the voter token of the request.
+func voterToken(ctx *gin.Context) string {
+	return ctx.GetHeader("X-Voter-Token")
+}
+
+func getFollows(ctx *gin.Context) {
+	f, err := logic.GetFollows(voterToken(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, f)
+}
+
+func postFollow(ctx *gin.Context) {
+	var f models.Follow
+	if err := ctx.ShouldBindJSON(&f); err != nil {
+		handleError(ctx, followBindError(err))
+		return
+	}
+
+	follow, added, err := logic.CreateFollow(voterToken(ctx), &f)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	if added {
+		ctx.JSON(http.StatusCreated, follow)
+	} else {
+		ctx.JSON(http.StatusOK, follow)
+	}
+}
+
+func deleteFollow(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, followBindError(err))
+		return
+	}
+
+	if err := logic.DeleteFollow(voterToken(ctx), uint(id)); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func getFollowNotifications(ctx *gin.Context) {
+	since, err := strconv.ParseUint(ctx.DefaultQuery("since", "0"), 10, 32)
+	if err != nil {
+		handleError(ctx, followBindError(err))
+		return
+	}
+
+	n, err := logic.GetFollowNotifications(voterToken(ctx), uint(since))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, n)
+}
+
+func getPushKey(ctx *gin.Context) {
+	key, err := logic.GetPushKey()
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"PublicKey": key})
+}
+
+func postPushSubscription(ctx *gin.Context) {
+	var s models.PushSubscription
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		handleError(ctx, followBindError(err))
+		return
+	}
+
+	if err := logic.SubscribePush(voterToken(ctx), &s); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func deletePushSubscription(ctx *gin.Context) {
+	var s models.PushSubscription
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		handleError(ctx, followBindError(err))
+		return
+	}
+
+	if err := logic.UnsubscribePush(voterToken(ctx), s.Endpoint); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func optionsFollows(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+}
+
+func RegisterFollowEndpoints(router *gin.Engine) {
+	group := router.Group("/follows")
+	group.Use(CommonHeaders, optionsFollows)
+	group.OPTIONS("/*any", Terminate)
+	group.GET("/", getFollows)
+	group.POST("/", postFollow)
+	group.DELETE("/:id", deleteFollow)
+	group.GET("/notifications", getFollowNotifications)
+	group.GET("/push/key", getPushKey)
+	group.POST("/push", postPushSubscription)
+	group.DELETE("/push", deletePushSubscription)
+}
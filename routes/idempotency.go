@@ -0,0 +1,112 @@
+/**
+ * file: router/idempotency.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the middleware replaying responses
+ * to requests retried with an idempotency key.
+ */
+
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"github.com/gin-gonic/gin"
+)
+
+// The recordingWriter structure keeps a copy of the response body
+// while writing it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyCaller returns the credentials identifying the caller of
+// the request: its bearer token and voter token, or its address for
+// anonymous requests.
+func idempotencyCaller(ctx *gin.Context) string {
+	credentials := ctx.GetHeader("Authorization") + "\n" + ctx.GetHeader("X-Voter-Token")
+	if credentials == "\n" {
+		return "address " + ctx.ClientIP()
+	}
+	return credentials
+}
+
+// Idempotent is a middleware making requests with an
+// Idempotency-Key header safe to retry. The response to the first
+// request is stored, and replayed on retries by the same caller with
+// the same key and body. Server errors are not stored, so that the
+// request can be retried.
+func Idempotent(ctx *gin.Context) {
+	key := ctx.GetHeader("Idempotency-Key")
+	if key == "" {
+		ctx.Next()
+		return
+	}
+
+	body, err := ctx.GetRawData()
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	scope := ctx.Request.Method + " " + ctx.Request.URL.Path
+	claim, replay, err := logic.BeginIdempotentRequest(key, scope, idempotencyCaller(ctx), body)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	if replay {
+		ctx.Header("Idempotent-Replayed", "true")
+		if claim.ETag != "" {
+			ctx.Header("ETag", claim.ETag)
+		}
+		if claim.LastModified != "" {
+			ctx.Header("Last-Modified", claim.LastModified)
+		}
+		ctx.Data(claim.Status, claim.ContentType, claim.Body)
+		ctx.Abort()
+		return
+	}
+
+	// The key is released if the handler panics, rather than being
+	// left pending until it expires.
+	completed := false
+	defer func() {
+		if !completed {
+			logic.ReleaseIdempotentRequest(claim)
+		}
+	}()
+
+	w := &recordingWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = w
+	ctx.Next()
+	ctx.Writer = w.ResponseWriter
+	completed = true
+
+	status := w.Status()
+	if status >= http.StatusInternalServerError {
+		err = logic.ReleaseIdempotentRequest(claim)
+	} else {
+		err = logic.CompleteIdempotentRequest(claim, status, w.Header(), w.body.Bytes())
+	}
+	if err != nil {
+		ctx.Error(err)
+	}
+}
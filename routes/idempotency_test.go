@@ -0,0 +1,129 @@
+/**
+ * file: router/idempotency_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the idempotency middleware.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/logic"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupIdempotentRouter returns a router serving POST /items with
+// the idempotency middleware, and the number of times the handler
+// ran. The handler waits on release, if set.
+func setupIdempotentRouter(t *testing.T, release chan struct{}) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	d, err := database.NewDatabase("sqlite", t.TempDir()+"/test.db")
+	require.NoError(t, err, "could not create database")
+	_, err = d.MigrateUp()
+	require.NoError(t, err, "could not migrate database")
+	logic.Setup(d)
+
+	calls := 0
+	r := gin.New()
+	r.POST("/items", Idempotent, func(ctx *gin.Context) {
+		calls++
+		if release != nil {
+			<-release
+		}
+		ctx.Header("ETag", `"1"`)
+		ctx.Header("Last-Modified", "Sun, 18 Oct 2026 12:00:00 GMT")
+		ctx.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+	return r, &calls
+}
+
+// idempotentRequest sends the body to POST /items with the key, as
+// the caller with the given bearer token.
+func idempotentRequest(r *gin.Engine, key, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestIdempotentReplay tests that retries are answered with the
+// stored response and validators, without running the handler.
+func TestIdempotentReplay(t *testing.T) {
+	r, calls := setupIdempotentRouter(t, nil)
+
+	first := idempotentRequest(r, "key", "token", `{"a":1}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	retry := idempotentRequest(r, "key", "token", `{"a":1}`)
+	assert.Equal(t, 1, *calls, "retries should not run the handler")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	assert.Equal(t, `"1"`, retry.Header().Get("ETag"))
+	assert.Equal(t, "Sun, 18 Oct 2026 12:00:00 GMT", retry.Header().Get("Last-Modified"))
+
+	other := idempotentRequest(r, "key", "other", `{"a":1}`)
+	assert.Equal(t, 2, *calls, "the key of another caller should not be replayed")
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+
+	anonymous := idempotentRequest(r, "key", "", `{"a":1}`)
+	assert.Equal(t, 3, *calls, "the key of another caller should not be replayed")
+	assert.Empty(t, anonymous.Header().Get("Idempotent-Replayed"))
+}
+
+// TestIdempotentMismatch tests that keys reused with another body
+// are refused.
+func TestIdempotentMismatch(t *testing.T) {
+	r, calls := setupIdempotentRouter(t, nil)
+
+	require.Equal(t, http.StatusCreated, idempotentRequest(r, "key", "token", `{"a":1}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, idempotentRequest(r, "key", "token", `{"a":2}`).Code)
+	assert.Equal(t, 1, *calls, "refused requests should not run the handler")
+}
+
+// TestIdempotentInFlight tests that retries are refused while the
+// first request is processed.
+func TestIdempotentInFlight(t *testing.T) {
+	release := make(chan struct{})
+	r, calls := setupIdempotentRouter(t, release)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- idempotentRequest(r, "key", "token", `{"a":1}`) }()
+	require.Eventually(t, func() bool {
+		return idempotentRequest(r, "key", "token", `{"a":1}`).Code == http.StatusConflict
+	}, time.Second, 10*time.Millisecond, "retries should be refused while processed")
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, 1, *calls)
+}
+
+// TestIdempotentExpiry tests that expired keys are processed again.
+func TestIdempotentExpiry(t *testing.T) {
+	r, calls := setupIdempotentRouter(t, nil)
+	logic.SetIdempotencyWindow(-time.Minute)
+	t.Cleanup(func() { logic.SetIdempotencyWindow(logic.DefaultIdempotencyWindow) })
+
+	require.Equal(t, http.StatusCreated, idempotentRequest(r, "key", "token", `{"a":1}`).Code)
+	retry := idempotentRequest(r, "key", "token", `{"a":2}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, *calls, "expired keys should be processed again")
+}
@@ -0,0 +1,124 @@
+/**
+ * file: router/import.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the imports of feedback from CSV files.
+ */
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize is the maximum size of the imported files.
+const maxImportSize int64 = 32 << 20
+
+// The importResult structure is the outcome of the import of a row,
+// as returned to clients.
+type importResult struct {
+	Row      int                    `json:"Row"`
+	Hash     string                 `json:"Hash"`
+	Status   models.ImportRowStatus `json:"Status"`
+	Feedback *models.Feedback       `json:"Feedback,omitempty"`
+	Errors   []map[string]any       `json:"Errors,omitempty"`
+}
+
+// The importReport structure is the outcome of an import, as
+// returned to clients.
+type importReport struct {
+	Source     string         `json:"Source"`
+	DryRun     bool           `json:"DryRun"`
+	Rows       int            `json:"Rows"`
+	Valid      int            `json:"Valid"`
+	Imported   int            `json:"Imported"`
+	Duplicates int            `json:"Duplicates"`
+	Invalid    int            `json:"Invalid"`
+	Results    []importResult `json:"Results"`
+}
+
+func importBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your import"
+	uxe.Detail = fmt.Sprintf("The import should be a multipart form, with the CSV file of at most %d MiB in the file field, and optionally the JSON mapping of its columns in the mapping field. Check your input and try again.", maxImportSize>>20)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// postImport imports the feedback of the file field. The columns are
+// mapped as in Google Forms exports, unless the mapping field gives
+// another mapping. The source defaults to the name of the file. With
+// the dry_run parameter, the rows are only reported on.
+func postImport(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize+1<<20)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		handleError(ctx, importBindError(err))
+		return
+	}
+
+	mapping := models.DefaultImportMapping
+	if m := ctx.PostForm("mapping"); m != "" {
+		if err := json.Unmarshal([]byte(m), &mapping); err != nil {
+			handleError(ctx, importBindError(err))
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		handleError(ctx, importBindError(err))
+		return
+	}
+	defer file.Close()
+
+	source := ctx.DefaultPostForm("source", header.Filename)
+	report, err := logic.ImportFeedback(file, source, &mapping, ctx.Query("dry_run") == "true")
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	response := importReport{
+		Source:     report.Source,
+		DryRun:     report.DryRun,
+		Rows:       report.Rows,
+		Valid:      report.Valid,
+		Imported:   report.Imported,
+		Duplicates: report.Duplicates,
+		Invalid:    report.Invalid,
+		Results:    []importResult{},
+	}
+	for _, r := range report.Results {
+		result := importResult{Row: r.Row, Hash: r.Hash, Status: r.Status, Feedback: r.Feedback}
+		switch v := r.Error.(type) {
+		case nil:
+		case uxerrors.Errors:
+			result.Errors = v.ToMap(false)["Errors"]
+		default:
+			result.Errors = uxerrors.NewErrors(http.StatusBadRequest).AppendNew(v).ToMap(false)["Errors"]
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func optionsImports(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+}
+
+func RegisterImportEndpoints(router *gin.Engine) {
+	imports := router.Group("/imports")
+	imports.Use(CommonHeaders, optionsImports)
+	imports.POST("/", RequireAdmin, postImport)
+	imports.OPTIONS("/", Terminate)
+}
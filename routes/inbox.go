@@ -0,0 +1,104 @@
+/**
+ * file: router/inbox.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the inbox of the representatives. They are
+ * reserved to the representative themselves.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func inboxBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your request"
+	uxe.Detail = "The request you made could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func getMe(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, currentRepresentative(ctx))
+}
+
+func putPreferences(ctx *gin.Context) {
+	var p models.RepresentativePreferences
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		handleError(ctx, inboxBindError(err))
+		return
+	}
+
+	r, err := logic.UpdatePreferences(currentRepresentative(ctx).ID, &p)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, r)
+}
+
+func getInbox(ctx *gin.Context) {
+	unread, err := strconv.ParseBool(ctx.DefaultQuery("unread", "false"))
+	if err != nil {
+		handleError(ctx, inboxBindError(err))
+		return
+	}
+
+	inbox, err := logic.GetInbox(currentRepresentative(ctx).ID, unread)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, inbox)
+}
+
+func postNotificationRead(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, inboxBindError(err))
+		return
+	}
+
+	if err := logic.MarkNotificationRead(currentRepresentative(ctx).ID, uint(id)); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func postNotificationsRead(ctx *gin.Context) {
+	n, err := logic.MarkAllNotificationsRead(currentRepresentative(ctx).ID)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Marked": n})
+}
+
+func optionsInbox(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+}
+
+func RegisterInboxEndpoints(router *gin.Engine) {
+	group := router.Group("/me")
+	group.Use(CommonHeaders, optionsInbox)
+	group.OPTIONS("/*any", Terminate)
+	group.GET("/", RequireRepresentative, getMe)
+	group.PUT("/preferences", RequireRepresentative, putPreferences)
+	group.GET("/notifications", RequireRepresentative, getInbox)
+	group.POST("/notifications/read", RequireRepresentative, postNotificationsRead)
+	group.POST("/notifications/:id/read", RequireRepresentative, postNotificationRead)
+}
@@ -0,0 +1,274 @@
+/**
+ * file: router/meeting.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to the
+ * council meetings, their agenda and outcomes,
+ * and the exports of their agenda.
+ */
+
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/agenda"
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+// The meetingNotes structure is the body of a request attaching
+// feedback to an agenda.
+type meetingNotes struct {
+	Notes string `json:"Notes"`
+}
+
+func meetingBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your meeting"
+	uxe.Detail = "The meeting you gave could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func agendaFormatError(format, formats string) error {
+	uxe := uxerrors.New(fmt.Errorf("unknown agenda format %q", format))
+	uxe.Summary = "The agenda format is unknown"
+	uxe.Detail = fmt.Sprintf("Agendas are available as %s, but %q was requested. Correct the format and try again.", formats, format)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// meetingID returns the ID of the meeting in the path. It handles
+// the error and returns false if the ID is invalid.
+func meetingID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, meetingBindError(err))
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// meetingItem returns the IDs of the meeting and of the feedback in
+// the path. It handles the error and returns false if either is
+// invalid.
+func meetingItem(ctx *gin.Context) (uint, uint, bool) {
+	id, ok := meetingID(ctx)
+	if !ok {
+		return 0, 0, false
+	}
+
+	feedbackID, err := strconv.ParseUint(ctx.Param("feedback"), 10, 32)
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return 0, 0, false
+	}
+
+	return id, uint(feedbackID), true
+}
+
+// writeICS responds with the meetings as an iCalendar object.
+func writeICS(ctx *gin.Context, filename string, meetings ...*models.Meeting) {
+	var b bytes.Buffer
+	if err := agenda.WriteICS(&b, meetings...); err != nil {
+		handleError(ctx, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err))
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".ics"))
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", b.Bytes())
+}
+
+// getMeetings lists the meetings the caller may manage, optionally
+// starting within a period, as JSON or, with ?format=ics, as a calendar.
+func getMeetings(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "ics" {
+		handleError(ctx, agendaFormatError(format, "json or ics"))
+		return
+	}
+
+	since, until, err := statsPeriod(ctx)
+	if err != nil {
+		handleError(ctx, meetingBindError(err))
+		return
+	}
+
+	m, err := logic.GetMeetings(since, until, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	if format == "ics" {
+		writeICS(ctx, "meetings", m...)
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
+
+func postMeeting(ctx *gin.Context) {
+	var meeting models.Meeting
+	if err := ctx.ShouldBindJSON(&meeting); err != nil {
+		handleError(ctx, meetingBindError(err))
+		return
+	}
+
+	m, err := logic.CreateMeeting(&meeting, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, m)
+}
+
+func getMeeting(ctx *gin.Context) {
+	id, ok := meetingID(ctx)
+	if !ok {
+		return
+	}
+
+	m, err := logic.GetMeeting(id, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, m)
+}
+
+func deleteMeeting(ctx *gin.Context) {
+	id, ok := meetingID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := logic.DeleteMeeting(id, requestRepresentative(ctx)); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// getAgenda exports the agenda of the meeting as Markdown or, with
+// ?format=ics, as a calendar event.
+func getAgenda(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "md")
+	if format != "md" && format != "ics" {
+		handleError(ctx, agendaFormatError(format, "md or ics"))
+		return
+	}
+
+	id, ok := meetingID(ctx)
+	if !ok {
+		return
+	}
+
+	m, err := logic.GetMeeting(id, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	if format == "ics" {
+		writeICS(ctx, agenda.Filename(m), m)
+		return
+	}
+
+	var b bytes.Buffer
+	if err := agenda.WriteMarkdown(&b, m); err != nil {
+		handleError(ctx, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err))
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", agenda.Filename(m)+".md"))
+	ctx.Data(http.StatusOK, "text/markdown; charset=utf-8", b.Bytes())
+}
+
+func putMeetingItem(ctx *gin.Context) {
+	id, feedbackID, ok := meetingItem(ctx)
+	if !ok {
+		return
+	}
+
+	var notes meetingNotes
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&notes); err != nil {
+			handleError(ctx, meetingBindError(err))
+			return
+		}
+	}
+
+	m, err := logic.SetMeetingItem(id, feedbackID, notes.Notes, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, m)
+}
+
+func deleteMeetingItem(ctx *gin.Context) {
+	id, feedbackID, ok := meetingItem(ctx)
+	if !ok {
+		return
+	}
+
+	m, err := logic.RemoveMeetingItem(id, feedbackID, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, m)
+}
+
+func postMeetingOutcomes(ctx *gin.Context) {
+	id, ok := meetingID(ctx)
+	if !ok {
+		return
+	}
+
+	var outcomes []*models.MeetingOutcome
+	if err := ctx.ShouldBindJSON(&outcomes); err != nil {
+		handleError(ctx, meetingBindError(err))
+		return
+	}
+
+	m, err := logic.RecordMeetingOutcomes(id, outcomes, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, m)
+}
+
+func optionsMeeting(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+}
+
+func RegisterMeetingEndpoints(router *gin.Engine) {
+	list := router.Group("/meetings")
+	list.Use(CommonHeaders, optionsMeeting)
+	list.OPTIONS("/", Terminate)
+	list.GET("/", RequireRepresentativeOrAdmin, getMeetings)
+	list.POST("/", RequireRepresentativeOrAdmin, postMeeting)
+
+	entry := router.Group("/meetings/:id")
+	entry.Use(CommonHeaders, optionsMeeting)
+	entry.OPTIONS("/*any", Terminate)
+	entry.GET("/", RequireRepresentativeOrAdmin, getMeeting)
+	entry.DELETE("/", RequireRepresentativeOrAdmin, deleteMeeting)
+	entry.GET("/agenda", RequireRepresentativeOrAdmin, getAgenda)
+	entry.PUT("/items/:feedback", RequireRepresentativeOrAdmin, putMeetingItem)
+	entry.DELETE("/items/:feedback", RequireRepresentativeOrAdmin, deleteMeetingItem)
+	entry.POST("/outcomes", RequireRepresentativeOrAdmin, postMeetingOutcomes)
+}
@@ -0,0 +1,78 @@
+/**
+ * file: router/meeting_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the meeting endpoints.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMeetingsRestricted tests that meetings are restricted to the
+// representatives of their scope and to the administrators.
+func TestMeetingsRestricted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d, err := database.NewDatabase("sqlite", t.TempDir()+"/test.db")
+	require.NoError(t, err, "could not create database")
+	_, err = d.MigrateUp()
+	require.NoError(t, err, "could not migrate database")
+	logic.Setup(d)
+
+	SetAdminToken("admin")
+	t.Cleanup(func() { SetAdminToken("") })
+
+	linfo, err := logic.CreateRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org", Faculties: []string{"LINFO"}})
+	require.NoError(t, err)
+	lepl, err := logic.CreateRepresentative(&models.Representative{Name: "Sam", Email: "sam@example.org", Faculties: []string{"LEPL"}})
+	require.NoError(t, err)
+	unscoped, err := logic.CreateRepresentative(&models.Representative{Name: "Kim", Email: "kim@example.org"})
+	require.NoError(t, err)
+
+	r := gin.New()
+	RegisterMeetingEndpoints(r)
+
+	request := func(method, path, token, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	start := time.Now().Add(24 * time.Hour).UTC()
+	meeting := `{"Body":"LINFO faculty council","Scope":"faculty","Code":"LINFO","StartsAt":"` + start.Format(time.RFC3339) + `","EndsAt":"` + start.Add(time.Hour).Format(time.RFC3339) + `"}`
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/meetings/", "", meeting), "anonymous clients should not create meetings")
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/meetings/", lepl.Token, meeting), "representatives should not create meetings outside their scope")
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/meetings/", unscoped.Token, meeting), "representatives following nothing should not create meetings")
+	require.Equal(t, http.StatusCreated, request(http.MethodPost, "/meetings/", linfo.Token, meeting))
+
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/meetings/1/", lepl.Token, ""), "representatives should not read meetings outside their scope")
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/meetings/1/", unscoped.Token, ""), "representatives following nothing should not read meetings")
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/meetings/1/agenda", unscoped.Token, ""), "representatives following nothing should not export agendas")
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/meetings/1/outcomes", lepl.Token, `[{"FeedbackID":1,"Status":"resolved","Response":"Done."}]`))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodDelete, "/meetings/1/", lepl.Token, ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/meetings/1/", linfo.Token, ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/meetings/1/agenda", "admin", ""), "administrators should manage all meetings")
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/meetings/1/", "admin", ""))
+}
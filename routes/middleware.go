@@ -9,16 +9,37 @@
 package routes
 
 import (
+	"crypto/subtle"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
 	"github.com/gin-gonic/gin"
 )
 
+const representativeKey string = "representative"
+
+var (
+	adminToken string
+)
+
+// SetAdminToken sets the bearer token granting access to the
+// administration and moderation endpoints. An empty token
+// disables these endpoints altogether.
+func SetAdminToken(token string) {
+	adminToken = token
+}
+
 // CommonHeaders is a common middleware inserting common headers
 // that should be included in every response from the server.
 func CommonHeaders(ctx *gin.Context) {
 	ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-	ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, If-Modified-Since, Idempotency-Key, Last-Event-ID, X-Voter-Token")
+	ctx.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Idempotent-Replayed")
 	ctx.Writer.Header().Set("Access-Control-Max-Age", "300")
 	ctx.Writer.Header().Set("X-Content-Type-Options", "nosniff")
 	ctx.Next()
@@ -27,3 +48,136 @@ func CommonHeaders(ctx *gin.Context) {
 func Terminate(ctx *gin.Context) {
 	ctx.AbortWithStatus(http.StatusNoContent)
 }
+
+// isAdmin returns true if the request bears the administration
+// token.
+func isAdmin(ctx *gin.Context) bool {
+	token, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	return found && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// RequireAdmin is a middleware restricting access to requests
+// bearing the administration token.
+func RequireAdmin(ctx *gin.Context) {
+	if isAdmin(ctx) {
+		ctx.Next()
+		return
+	}
+
+	uxe := uxerrors.New(fmt.Errorf("missing or invalid administration token"))
+	uxe.Summary = "You are not allowed to access this resource"
+	uxe.Detail = "This resource is restricted to administrators. Provide a valid administration token and try again."
+	handleError(ctx, uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe))
+}
+
+// RequireRepresentative is a middleware restricting access to
+// requests bearing the access token of a representative. The
+// representative is stored in the context.
+func RequireRepresentative(ctx *gin.Context) {
+	token, _ := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	r, err := logic.AuthenticateRepresentative(token)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Set(representativeKey, r)
+	ctx.Next()
+}
+
+// currentRepresentative returns the representative authenticated by
+// RequireRepresentative.
+func currentRepresentative(ctx *gin.Context) *models.Representative {
+	return ctx.MustGet(representativeKey).(*models.Representative)
+}
+
+// RequireRepresentativeOrAdmin is a middleware restricting access to
+// requests bearing the administration token, or the access token of a
+// representative, who is then stored in the context.
+func RequireRepresentativeOrAdmin(ctx *gin.Context) {
+	if isAdmin(ctx) {
+		ctx.Next()
+		return
+	}
+
+	RequireRepresentative(ctx)
+}
+
+// requestRepresentative returns the representative authenticated by
+// RequireRepresentativeOrAdmin, or nil for administrators.
+func requestRepresentative(ctx *gin.Context) *models.Representative {
+	r, ok := ctx.Get(representativeKey)
+	if !ok {
+		return nil
+	}
+	return r.(*models.Representative)
+}
+
+// entityTags returns the entity tags listed in the given header of
+// the request, if any.
+func entityTags(ctx *gin.Context, header string) []string {
+	etags := []string{}
+	for _, v := range ctx.Request.Header.Values(header) {
+		for _, etag := range strings.Split(v, ",") {
+			if etag = strings.TrimSpace(etag); etag != "" {
+				etags = append(etags, etag)
+			}
+		}
+	}
+
+	return etags
+}
+
+// ifMatchHeader returns the entity tags listed in the If-Match
+// header of the request, if any.
+func ifMatchHeader(ctx *gin.Context) []string {
+	return entityTags(ctx, "If-Match")
+}
+
+// RequireIfMatch is a middleware rejecting requests without an
+// If-Match header, so that clients cannot overwrite changes they
+// have not seen.
+func RequireIfMatch(ctx *gin.Context) {
+	if len(ifMatchHeader(ctx)) != 0 {
+		ctx.Next()
+		return
+	}
+
+	uxe := uxerrors.New(fmt.Errorf("missing If-Match header"))
+	uxe.Summary = "The version of the feedback is required"
+	uxe.Detail = "Changing feedback requires the ETag of the version you based your request on, in the If-Match header. Fetch the feedback and try again."
+	handleError(ctx, uxerrors.NewErrors(http.StatusPreconditionRequired).Append(uxe))
+}
+
+// notModified sets the validators of the response, and answers the
+// request with 304 Not Modified if the client already has the
+// current representation. If-None-Match takes precedence over
+// If-Modified-Since.
+// It returns true if the request was answered.
+func notModified(ctx *gin.Context, etag string, modified time.Time) bool {
+	ctx.Header("ETag", etag)
+	if !modified.IsZero() {
+		ctx.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if etags := entityTags(ctx, "If-None-Match"); len(etags) != 0 {
+		for _, e := range etags {
+			if e == "*" || strings.TrimPrefix(e, "W/") == etag {
+				ctx.AbortWithStatus(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(ctx.GetHeader("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+	if !modified.Truncate(time.Second).After(since) {
+		ctx.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+/**
+ * file: router/moderation.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the moderation endpoints.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"github.com/gin-gonic/gin"
+)
+
+// The voteReview structure is the body of a moderator decision
+// on a quarantined vote.
+type voteReview struct {
+	Approve bool `json:"Approve"`
+}
+
+func getFlaggedFeedback(ctx *gin.Context) {
+	report, err := logic.GetFlaggedFeedback(requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+func reviewVote(ctx *gin.Context) {
+	_id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	id := uint(_id)
+
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	var review voteReview
+	if err := ctx.ShouldBindJSON(&review); err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	vote, err := logic.ReviewVote(id, review.Approve, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, vote)
+}
+
+func optionsModeration(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, PATCH, OPTIONS")
+}
+
+func RegisterModerationEndpoints(router *gin.Engine) {
+	moderation := router.Group("/moderation")
+	moderation.Use(CommonHeaders, optionsModeration)
+	moderation.OPTIONS("/*any", Terminate)
+
+	restricted := moderation.Group("/", RequireRepresentativeOrAdmin)
+	restricted.GET("/report", getFlaggedFeedback)
+	restricted.PATCH("/votes/:id", reviewVote)
+}
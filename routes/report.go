@@ -0,0 +1,79 @@
+/**
+ * file: router/report.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the term reports on courses and faculties,
+ * as HTML pages or PDF documents.
+ */
+
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func reportFormatError(format string) error {
+	uxe := uxerrors.New(fmt.Errorf("unknown report format %q", format))
+	uxe.Summary = "The report format is unknown"
+	uxe.Detail = fmt.Sprintf("Reports are available as html or pdf, but %q was requested. Correct the format and try again.", format)
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+func getReport(scope models.StatsScope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		format := ctx.DefaultQuery("format", "html")
+		if format != "html" && format != "pdf" {
+			handleError(ctx, reportFormatError(format))
+			return
+		}
+
+		exact := ctx.Query("exact") == "true"
+		if exact && !isAdmin(ctx) {
+			RequireAdmin(ctx)
+			return
+		}
+
+		r, err := logic.GetReport(scope, ctx.Param("code"), ctx.Query("term"), exact)
+		if err != nil {
+			handleError(ctx, err)
+			return
+		}
+
+		var b bytes.Buffer
+		contentType := "text/html; charset=utf-8"
+		if format == "pdf" {
+			contentType = "application/pdf"
+			ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", r.Filename()+".pdf"))
+			err = r.WritePDF(&b)
+		} else {
+			err = r.WriteHTML(&b)
+		}
+		if err != nil {
+			handleError(ctx, uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(err))
+			return
+		}
+
+		ctx.Data(http.StatusOK, contentType, b.Bytes())
+	}
+}
+
+func optionsReports(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+}
+
+func RegisterReportEndpoints(router *gin.Engine) {
+	group := router.Group("/reports")
+	group.Use(CommonHeaders, optionsReports)
+	group.OPTIONS("/*any", Terminate)
+	group.GET("/courses/:code", getReport(models.StatsScopeCourse))
+	group.GET("/faculties/:code", getReport(models.StatsScopeFaculty))
+}
@@ -0,0 +1,151 @@
+/**
+ * file: router/representative.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the representative endpoints. They are
+ * reserved to administrators.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func representativeBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your representative"
+	uxe.Detail = "The representative you gave could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// representativeID returns the ID of the representative in the
+// path. It handles the error and returns false if the ID is invalid.
+func representativeID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, representativeBindError(err))
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+func getRepresentatives(ctx *gin.Context) {
+	r, err := logic.GetRepresentatives()
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, r)
+}
+
+func postRepresentative(ctx *gin.Context) {
+	var representative models.Representative
+	if err := ctx.ShouldBindJSON(&representative); err != nil {
+		handleError(ctx, representativeBindError(err))
+		return
+	}
+
+	r, err := logic.CreateRepresentative(&representative)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, r)
+}
+
+func getRepresentative(ctx *gin.Context) {
+	id, ok := representativeID(ctx)
+	if !ok {
+		return
+	}
+
+	r, err := logic.GetRepresentative(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, r)
+}
+
+func putRepresentative(ctx *gin.Context) {
+	id, ok := representativeID(ctx)
+	if !ok {
+		return
+	}
+
+	var representative models.Representative
+	if err := ctx.ShouldBindJSON(&representative); err != nil {
+		handleError(ctx, representativeBindError(err))
+		return
+	}
+
+	r, err := logic.UpdateRepresentative(id, &representative)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, r)
+}
+
+func deleteRepresentative(ctx *gin.Context) {
+	id, ok := representativeID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := logic.DeleteRepresentative(id); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func postRepresentativeToken(ctx *gin.Context) {
+	id, ok := representativeID(ctx)
+	if !ok {
+		return
+	}
+
+	r, err := logic.RotateRepresentativeToken(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, r)
+}
+
+func optionsRepresentative(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+}
+
+func RegisterRepresentativeEndpoints(router *gin.Engine) {
+	list := router.Group("/representatives")
+	list.Use(CommonHeaders, optionsRepresentative)
+	list.OPTIONS("/", Terminate)
+	list.GET("/", RequireAdmin, getRepresentatives)
+	list.POST("/", RequireAdmin, postRepresentative)
+
+	entry := router.Group("/representatives/:id")
+	entry.Use(CommonHeaders, optionsRepresentative)
+	entry.OPTIONS("/*any", Terminate)
+	entry.GET("/", RequireAdmin, getRepresentative)
+	entry.PUT("/", RequireAdmin, putRepresentative)
+	entry.DELETE("/", RequireAdmin, deleteRepresentative)
+	entry.POST("/token", RequireAdmin, postRepresentativeToken)
+}
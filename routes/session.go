@@ -0,0 +1,421 @@
+/**
+ * file: router/session.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the live session endpoints.
+ */
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	sessionOrigins []string
+)
+
+// SetSessionOrigins sets the origins of the web pages allowed to
+// connect to the live sessions, such as https://delegit.example.org.
+// Pages served by the API host itself are always allowed.
+func SetSessionOrigins(origins []string) {
+	sessionOrigins = make([]string, len(origins))
+	for i, origin := range origins {
+		sessionOrigins[i] = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+	}
+}
+
+// checkSessionOrigin refuses the WebSockets opened by web pages of
+// origins that are not allowed, so that other sites cannot vote from
+// the browsers of their visitors. Clients other than browsers send
+// no origin.
+func checkSessionOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	for _, allowed := range sessionOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+// presenterAuthTimeout is how long presenters connecting to a live
+// session without an Authorization header have to send their token.
+const presenterAuthTimeout time.Duration = 10 * time.Second
+
+// authenticatePresenter returns an error unless the request is made
+// by an administrator, or bears the presenter token of the session
+// or the access token of the representative running it, as a bearer
+// token. Tokens are never taken from the URL, which is logged.
+func authenticatePresenter(ctx *gin.Context, id uint) error {
+	if isAdmin(ctx) {
+		return nil
+	}
+
+	token, _ := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	return logic.AuthenticatePresenter(id, token)
+}
+
+// RequirePresenter is a middleware restricting access to the
+// administrators and the presenters of the session, who run its
+// rounds.
+func RequirePresenter(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := authenticatePresenter(ctx, id); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Next()
+}
+
+// The sessionRound structure is the body of a request opening a
+// round.
+type sessionRound struct {
+	// Duration is the duration of the round, such as "5m".
+	Duration string `json:"Duration"`
+}
+
+// The sessionMessage structure is a message exchanged with the
+// clients of a live session. Clients send votes, and receive the
+// updates of the session, along with the outcome of their votes.
+type sessionMessage struct {
+	Type string `json:"Type"`
+
+	// FeedbackID, Kind and Votes describe a vote. Token is the
+	// anonymous voter token of votes, or the token of presenter
+	// messages, as browsers cannot set headers on WebSockets.
+	FeedbackID uint            `json:"FeedbackID,omitempty"`
+	Kind       models.VoteKind `json:"Kind,omitempty"`
+	Votes      int             `json:"Votes,omitempty"`
+	Token      string          `json:"Token,omitempty"`
+
+	Feedback *models.Feedback `json:"Feedback,omitempty"`
+	Errors   []map[string]any `json:"Errors,omitempty"`
+}
+
+func sessionBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your session"
+	uxe.Detail = "The session you gave could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// sessionID returns the ID of the session in the path. It handles
+// the error and returns false if the ID is invalid.
+func sessionID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		handleError(ctx, sessionBindError(err))
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+func postSession(ctx *gin.Context) {
+	var session models.Session
+	if err := ctx.ShouldBindJSON(&session); err != nil {
+		handleError(ctx, sessionBindError(err))
+		return
+	}
+
+	s, err := logic.CreateSession(&session, requestRepresentative(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, s)
+}
+
+func getSession(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	s, err := logic.GetSession(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, s)
+}
+
+func putSessionItem(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	feedbackID, err := strconv.ParseUint(ctx.Param("feedback"), 10, 32)
+	if err != nil {
+		handleError(ctx, feedbackBindError(err))
+		return
+	}
+
+	s, err := logic.AttachSessionFeedback(id, uint(feedbackID))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, s)
+}
+
+func postSessionRound(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	var round sessionRound
+	if err := ctx.ShouldBindJSON(&round); err != nil {
+		handleError(ctx, sessionBindError(err))
+		return
+	}
+
+	duration, err := time.ParseDuration(round.Duration)
+	if err != nil {
+		handleError(ctx, sessionBindError(err))
+		return
+	}
+
+	s, err := logic.OpenSessionRound(id, duration)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, s)
+}
+
+func deleteSessionRound(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	s, err := logic.CloseSessionRound(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, s)
+}
+
+func getSessionTally(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	t, err := logic.GetSessionTallies(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, t)
+}
+
+// liveSession connects a client to the live session over a
+// WebSocket. Participants receive the session and its items, and
+// cast votes. Presenters, connecting with ?role=presenter, also
+// receive the tallies of the current round. They authenticate with
+// an Authorization header or, as browsers cannot set headers on
+// WebSockets, with a first presenter message carrying their token.
+func liveSession(ctx *gin.Context) {
+	id, ok := sessionID(ctx)
+	if !ok {
+		return
+	}
+
+	presenter := ctx.Query("role") == "presenter"
+	authenticated := false
+	if presenter && ctx.GetHeader("Authorization") != "" {
+		if err := authenticatePresenter(ctx, id); err != nil {
+			handleError(ctx, err)
+			return
+		}
+		authenticated = true
+	}
+
+	// The subscription is made before upgrading the connection, so
+	// that unknown sessions are reported as such.
+	sub, err := logic.SubscribeSession(id, authenticated)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	defer func() { sub.Close() }()
+
+	source := voteSource(ctx)
+	server := websocket.Server{
+		Handshake: checkSessionOrigin,
+		Handler: func(ws *websocket.Conn) {
+			if presenter && !authenticated {
+				if sub, err = presenterSubscription(ws, id, sub); err != nil {
+					ws.Close()
+					return
+				}
+			}
+
+			var mu sync.Mutex
+			send := func(m any) error {
+				mu.Lock()
+				defer mu.Unlock()
+				return websocket.JSON.Send(ws, m)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					var m sessionMessage
+					if err := websocket.JSON.Receive(ws, &m); err != nil {
+						return
+					}
+					if err := send(sessionVote(id, m, source)); err != nil {
+						return
+					}
+				}
+			}()
+
+			for {
+				select {
+				case <-done:
+					return
+				case e, ok := <-sub.C:
+					// Clients that lag behind are disconnected, and get the
+					// current state when reconnecting.
+					if !ok || send(e) != nil {
+						ws.Close()
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// presenterSubscription authenticates the presenter with the first
+// message sent on the WebSocket, and returns the subscription of
+// the presenter replacing the given one. The error is sent to the
+// client if the presenter cannot be authenticated.
+func presenterSubscription(ws *websocket.Conn, id uint, sub *logic.SessionSubscription) (*logic.SessionSubscription, error) {
+	ws.SetReadDeadline(time.Now().Add(presenterAuthTimeout))
+	defer ws.SetReadDeadline(time.Time{})
+
+	var m sessionMessage
+	if err := websocket.JSON.Receive(ws, &m); err != nil {
+		return sub, err
+	}
+
+	var err error
+	if m.Type != "presenter" {
+		uxe := uxerrors.New(fmt.Errorf("expected a presenter message, got %q", m.Type))
+		uxe.Summary = "The presenter token is required"
+		uxe.Detail = "Presenters send their token in a first presenter message, or in the Authorization header. Authenticate and try again."
+		err = uxerrors.NewErrors(http.StatusUnauthorized).Append(uxe)
+	} else {
+		err = logic.AuthenticatePresenter(id, m.Token)
+	}
+
+	var presenter *logic.SessionSubscription
+	if err == nil {
+		presenter, err = logic.SubscribeSession(id, true)
+	}
+	if err != nil {
+		websocket.JSON.Send(ws, sessionError(m.FeedbackID, err))
+		return sub, err
+	}
+
+	sub.Close()
+	return presenter, nil
+}
+
+// sessionError returns the message reporting the error to a client
+// of a live session.
+func sessionError(feedbackID uint, err error) sessionMessage {
+	switch v := err.(type) {
+	case uxerrors.Errors:
+		return sessionMessage{Type: "error", FeedbackID: feedbackID, Errors: v.ToMap(false)["Errors"]}
+	default:
+		return sessionMessage{Type: "error", FeedbackID: feedbackID, Errors: uxerrors.NewErrors(http.StatusInternalServerError).AppendNew(v).ToMap(false)["Errors"]}
+	}
+}
+
+// sessionVote casts the vote sent by a client of a live session, and
+// returns the outcome to send back.
+func sessionVote(id uint, m sessionMessage, source models.VoteSource) sessionMessage {
+	if m.Token != "" {
+		source.Token = m.Token
+	}
+
+	var f *models.Feedback
+	var err error
+	if m.Type == "vote" {
+		f, err = logic.CastSessionVote(id, m.FeedbackID, m.Kind, m.Votes, source)
+	} else {
+		uxe := uxerrors.New(fmt.Errorf("unknown message type %q", m.Type))
+		uxe.Summary = "The message is unknown"
+		uxe.Detail = "Only vote messages can be sent to live sessions. Check your message and try again."
+		err = uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+	}
+
+	if err != nil {
+		return sessionError(m.FeedbackID, err)
+	}
+	return sessionMessage{Type: "vote", FeedbackID: f.ID, Feedback: f}
+}
+
+func optionsSession(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+}
+
+func RegisterSessionEndpoints(router *gin.Engine) {
+	list := router.Group("/sessions")
+	list.Use(CommonHeaders, optionsSession)
+	list.POST("/", RequireRepresentativeOrAdmin, postSession)
+	list.OPTIONS("/", Terminate)
+
+	entry := router.Group("/sessions/:id")
+	entry.Use(CommonHeaders, optionsSession)
+	entry.GET("/", getSession)
+	entry.GET("/tally", RequirePresenter, getSessionTally)
+	entry.GET("/live", liveSession)
+	entry.PUT("/items/:feedback", RequirePresenter, putSessionItem)
+	entry.POST("/round", RequirePresenter, postSessionRound)
+	entry.DELETE("/round", RequirePresenter, deleteSessionRound)
+	entry.OPTIONS("/*any", Terminate)
+}
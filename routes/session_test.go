@@ -0,0 +1,163 @@
+/**
+ * file: router/session_test.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file provides unit test cases for
+ * the live session endpoints.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/database"
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// setupSessionServer returns a server of the session endpoints, and
+// a session with its presenter token.
+func setupSessionServer(t *testing.T) (*httptest.Server, *models.Session) {
+	gin.SetMode(gin.TestMode)
+	d, err := database.NewDatabase("sqlite", t.TempDir()+"/test.db")
+	require.NoError(t, err, "could not create database")
+	_, err = d.MigrateUp()
+	require.NoError(t, err, "could not migrate database")
+	logic.Setup(d)
+
+	SetAdminToken("admin")
+	SetSessionOrigins([]string{"https://delegit.example.org/"})
+	t.Cleanup(func() {
+		SetAdminToken("")
+		SetSessionOrigins(nil)
+	})
+
+	s, err := logic.CreateSession(&models.Session{Title: "LINFO1101 - week 5"}, nil)
+	require.NoError(t, err, "could not create session")
+
+	r := gin.New()
+	RegisterSessionEndpoints(r)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server, s
+}
+
+// TestSessionTallyRestricted tests that tallies are restricted to the
+// administrators and the presenters of the session.
+func TestSessionTallyRestricted(t *testing.T) {
+	server, s := setupSessionServer(t)
+
+	tally := func(token string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/sessions/1/tally", nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, tally(""), "anonymous clients should not see the tallies")
+	assert.Equal(t, http.StatusUnauthorized, tally("guess"))
+	assert.Equal(t, http.StatusOK, tally(s.PresenterToken), "presenters should see the tallies")
+	assert.Equal(t, http.StatusOK, tally("admin"), "administrators should see the tallies")
+
+	res, err := http.Get(server.URL + "/sessions/1/tally?token=" + s.PresenterToken)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "tokens should not be taken from the URL")
+}
+
+// TestSessionRunByRepresentative tests that representatives create
+// sessions and run their rounds, and that others cannot.
+func TestSessionRunByRepresentative(t *testing.T) {
+	server, s := setupSessionServer(t)
+
+	r, err := logic.CreateRepresentative(&models.Representative{Name: "Alex", Email: "alex@example.org", Courses: []string{"LINFO1101"}})
+	require.NoError(t, err)
+
+	request := func(method, path, token, body string) int {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/sessions/", "", `{"Title":"LINFO1101 - week 6"}`))
+	assert.Equal(t, http.StatusCreated, request(http.MethodPost, "/sessions/", r.Token, `{"Title":"LINFO1101 - week 6"}`), "representatives should create sessions")
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/sessions/2/round", r.Token, `{"Duration":"5m"}`), "representatives should run their sessions")
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/sessions/1/round", r.Token, `{"Duration":"5m"}`), "representatives should not run the sessions of others")
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/sessions/1/round", s.PresenterToken, `{"Duration":"5m"}`), "presenters should run their session")
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/sessions/1/round", "admin", ""))
+}
+
+// TestLiveSessionRestricted tests that live sessions refuse the pages
+// of other origins, and presenters without their token.
+func TestLiveSessionRestricted(t *testing.T) {
+	server, s := setupSessionServer(t)
+	live := "ws" + strings.TrimPrefix(server.URL, "http") + "/sessions/1/live"
+
+	dial := func(url, origin string) error {
+		ws, err := websocket.Dial(url, "", origin)
+		if err == nil {
+			ws.Close()
+		}
+		return err
+	}
+
+	assert.NoError(t, dial(live, "https://delegit.example.org"), "allowed origins should connect")
+	assert.NoError(t, dial(live, server.URL), "the API origin should connect")
+	assert.Error(t, dial(live, "https://evil.example.com"), "other origins should be refused")
+
+	// present connects as a presenter with the first message, and
+	// returns the first message received.
+	present := func(token string) sessionMessage {
+		ws, err := websocket.Dial(live+"?role=presenter", "", server.URL)
+		require.NoError(t, err)
+		defer ws.Close()
+
+		require.NoError(t, websocket.JSON.Send(ws, sessionMessage{Type: "presenter", Token: token}))
+		var m sessionMessage
+		require.NoError(t, websocket.JSON.Receive(ws, &m))
+		return m
+	}
+
+	assert.Equal(t, "error", present("guess").Type, "presenters should need their token")
+	var e models.SessionEvent
+	ws, err := websocket.Dial(live+"?role=presenter", "", server.URL)
+	require.NoError(t, err)
+	require.NoError(t, websocket.JSON.Send(ws, sessionMessage{Type: "presenter", Token: s.PresenterToken}))
+	require.NoError(t, websocket.JSON.Receive(ws, &e))
+	require.NoError(t, websocket.JSON.Receive(ws, &e))
+	ws.Close()
+	assert.Equal(t, models.SessionEventTally, e.Type, "presenters should receive the tallies once authenticated")
+
+	config, err := websocket.NewConfig(live+"?role=presenter", server.URL)
+	require.NoError(t, err)
+	config.Header.Set("Authorization", "Bearer guess")
+	_, err = websocket.DialConfig(config)
+	assert.Error(t, err, "presenters with an invalid header should be refused")
+	config.Header.Set("Authorization", "Bearer "+s.PresenterToken)
+	ws, err = websocket.DialConfig(config)
+	require.NoError(t, err, "presenters should connect with their token in the header")
+	ws.Close()
+}
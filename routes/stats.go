@@ -0,0 +1,102 @@
+/**
+ * file: router/stats.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the statistics of the feedback on courses
+ * and faculties, and to the feedback heating
+ * up on courses.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func statsBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your request"
+	uxe.Detail = "The period should be given as RFC 3339 times, such as 2024-09-16T00:00:00Z, in the since and until parameters. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// statsPeriod returns the period given in the since and until
+// parameters of the request, if any.
+func statsPeriod(ctx *gin.Context) (since, until *time.Time, err error) {
+	for param, t := range map[string]**time.Time{"since": &since, "until": &until} {
+		v := ctx.Query(param)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		*t = &parsed
+	}
+
+	return since, until, nil
+}
+
+// getStats returns the private statistics last released for the
+// term given in the term parameter, the current one by default.
+// Administrators get the exact statistics of any period instead,
+// with the exact, since and until parameters.
+func getStats(scope models.StatsScope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		since, until, err := statsPeriod(ctx)
+		if err != nil {
+			handleError(ctx, statsBindError(err))
+			return
+		}
+
+		var s *models.Stats
+		if ctx.Query("exact") == "true" || since != nil || until != nil {
+			if !isAdmin(ctx) {
+				RequireAdmin(ctx)
+				return
+			}
+			s, err = logic.GetStats(&models.StatsQuery{Scope: scope, Code: ctx.Param("code"), Since: since, Until: until})
+		} else {
+			s, err = logic.GetReleasedStats(scope, ctx.Param("code"), ctx.Query("term"))
+		}
+		if err != nil {
+			handleError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, s)
+	}
+}
+
+func getTrending(ctx *gin.Context) {
+	trends, err := logic.GetTrending(ctx.Param("code"), time.Now())
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, trends)
+}
+
+func optionsStats(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+}
+
+func RegisterStatsEndpoints(router *gin.Engine) {
+	group := router.Group("/stats")
+	group.Use(CommonHeaders, optionsStats)
+	group.OPTIONS("/*any", Terminate)
+	group.GET("/courses/:code", getStats(models.StatsScopeCourse))
+	group.GET("/courses/:code/trending", getTrending)
+	group.GET("/faculties/:code", getStats(models.StatsScopeFaculty))
+}
@@ -0,0 +1,123 @@
+/**
+ * file: router/stream.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains the live stream of changes to
+ * feedback, sent as Server-Sent Events.
+ */
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	heartbeatInterval time.Duration = 15 * time.Second
+)
+
+func lastEventIDError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your request"
+	uxe.Detail = "The Last-Event-ID header should be the ID of the last event you received. Reconnect without it to only receive new events."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// sendChange sends the change as an event named after its kind,
+// identified by its sequence number.
+func sendChange(ctx *gin.Context, c *models.Change) {
+	ctx.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(c.Seq, 10),
+		Event: string(c.Kind),
+		Data:  c,
+	})
+	ctx.Writer.Flush()
+}
+
+// streamFeedback streams the changes to feedback, optionally on a
+// single course or tag, until the client disconnects. Clients reconnecting
+// with a Last-Event-ID header first receive the changes they missed.
+// Clients that do not keep up are disconnected, and should reconnect
+// the same way.
+func streamFeedback(ctx *gin.Context) {
+	course, tag := ctx.Query("course"), ctx.Query("tag")
+
+	var last uint64
+	resume := ctx.GetHeader("Last-Event-ID")
+	if resume != "" {
+		var err error
+		if last, err = strconv.ParseUint(resume, 10, 64); err != nil {
+			handleError(ctx, lastEventIDError(err))
+			return
+		}
+	}
+
+	// The subscription is made before catching up, so that no change
+	// is missed in between.
+	sub, err := logic.SubscribeChanges(course, tag)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	defer sub.Close()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	// Changes are not fed in the order of their sequence numbers, so
+	// the changes sent while catching up are remembered, to skip them
+	// once relayed by the subscription.
+	sent := map[uint64]bool{}
+	for more := resume != ""; more; {
+		feed, err := logic.GetChanges(last, logic.DefaultChangeLimit)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		for _, c := range feed.Changes {
+			sent[c.Seq] = true
+			if sub.Matches(c) {
+				sendChange(ctx, c)
+			}
+		}
+		last, more = feed.Cursor, feed.More
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(ctx.Writer, ": heartbeat\n\n")
+			ctx.Writer.Flush()
+		case c, ok := <-sub.C:
+			if !ok {
+				// The subscriber lagged behind, and should reconnect to
+				// catch up from the change feed.
+				return
+			}
+			if sent[c.Seq] {
+				delete(sent, c.Seq)
+				continue
+			}
+			sendChange(ctx, c)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/**
+ * file: router/webhook.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * This file contains all routes leading to
+ * the webhook endpoints. They are reserved to
+ * administrators.
+ */
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.licolas.net/delegit/delegit/logic"
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/gin-gonic/gin"
+)
+
+func webhookBindError(err error) error {
+	uxe := uxerrors.New(err)
+	uxe.Summary = "Could not parse your webhook"
+	uxe.Detail = "The webhook you gave could not be parsed. This usually means that you did not respect the specification. Check your input and try again."
+	return uxerrors.NewErrors(http.StatusBadRequest).Append(uxe)
+}
+
+// webhookParam returns the ID in the given path parameter. It
+// handles the error and returns false if the ID is invalid.
+func webhookParam(ctx *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param(name), 10, 32)
+	if err != nil {
+		handleError(ctx, webhookBindError(err))
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+func postWebhook(ctx *gin.Context) {
+	var webhook models.Webhook
+	if err := ctx.ShouldBindJSON(&webhook); err != nil {
+		handleError(ctx, webhookBindError(err))
+		return
+	}
+
+	w, err := logic.CreateWebhook(&webhook)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, w)
+}
+
+func getWebhooks(ctx *gin.Context) {
+	w, err := logic.GetWebhooks()
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, w)
+}
+
+func getWebhook(ctx *gin.Context) {
+	id, ok := webhookParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	w, err := logic.GetWebhook(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, w)
+}
+
+func deleteWebhook(ctx *gin.Context) {
+	id, ok := webhookParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := logic.DeleteWebhook(id); err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func getWebhookDeliveries(ctx *gin.Context) {
+	id, ok := webhookParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	d, err := logic.GetWebhookDeliveries(id)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, d)
+}
+
+func postWebhookRedelivery(ctx *gin.Context) {
+	id, ok := webhookParam(ctx, "id")
+	if !ok {
+		return
+	}
+	delivery, ok := webhookParam(ctx, "delivery")
+	if !ok {
+		return
+	}
+
+	d, err := logic.RedeliverWebhook(id, delivery)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, d)
+}
+
+func optionsWebhook(ctx *gin.Context) {
+	ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+}
+
+func RegisterWebhookEndpoints(router *gin.Engine) {
+	list := router.Group("/webhooks")
+	list.Use(CommonHeaders, optionsWebhook)
+	list.OPTIONS("/", Terminate)
+	list.GET("/", RequireAdmin, getWebhooks)
+	list.POST("/", RequireAdmin, postWebhook)
+
+	entry := router.Group("/webhooks/:id")
+	entry.Use(CommonHeaders, optionsWebhook)
+	entry.GET("/", RequireAdmin, getWebhook)
+	entry.DELETE("/", RequireAdmin, deleteWebhook)
+	entry.GET("/deliveries", RequireAdmin, getWebhookDeliveries)
+	entry.POST("/deliveries/:delivery/redeliver", RequireAdmin, postWebhookRedelivery)
+	entry.OPTIONS("/*any", Terminate)
+}
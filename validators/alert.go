@@ -0,0 +1,64 @@
+/**
+ * file: validators/alert.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The alert validator validates the alert rule
+ * form.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateAlertRule validates the alert rule structure. It returns an
+// UXErrors containing all the errors that occurred during validation
+// or nil if no errors occurred.
+func ValidateAlertRule(r *models.AlertRule) error {
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	v.RegisterValidation("istag", IsTag, false)
+	err := v.Struct(r)
+	if err == nil {
+		return nil
+	}
+
+	vErr := err.(validator.ValidationErrors)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range vErr {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "min", "ge", "gt":
+			minError(&xerr, ve)
+		case "max", "le", "lt":
+			maxError(&xerr, ve)
+		case "oneof":
+			xerr.Summary = "The rule kind is unknown"
+			xerr.Detail = fmt.Sprintf("The rule kind %q is unknown. It must be one of %s. Check the kind and try again.", ve.Value(), ve.Param())
+		case "alpha":
+			xerr.Summary = "The faculty does not look like a valid faculty"
+			xerr.Detail = fmt.Sprintf("The faculty you entered (%q) does not look like a valid faculty code, such as LINFO. Check the code and try again.", ve.Value())
+		case "iscourse":
+			xerr.Summary = "The course does not look like a valid course"
+			xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+		case "istag":
+			tagError(&xerr, ve)
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
@@ -0,0 +1,50 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateAlertRule tests that rules need a name, a known kind,
+// valid scopes and at least one channel.
+func TestValidateAlertRule(t *testing.T) {
+	valid := func() *models.AlertRule {
+		return &models.AlertRule{
+			Name:      "Hot on LINFO",
+			Faculty:   "LINFO",
+			Kind:      models.AlertRuleNetScore,
+			Threshold: 30,
+			Window:    "48h",
+			Channels:  []string{"log"},
+		}
+	}
+	assert.NoError(t, ValidateAlertRule(valid()), "the rule should be valid")
+
+	invalid := map[string]func(r *models.AlertRule){
+		"no name":       func(r *models.AlertRule) { r.Name = "" },
+		"unknown kind":  func(r *models.AlertRule) { r.Kind = "exploded" },
+		"bad course":    func(r *models.AlertRule) { r.Course = "cooking" },
+		"bad faculty":   func(r *models.AlertRule) { r.Faculty = "L1NFO" },
+		"bad tag":       func(r *models.AlertRule) { r.Tag = "Schedule Conflict" },
+		"no channels":   func(r *models.AlertRule) { r.Channels = []string{} },
+		"empty channel": func(r *models.AlertRule) { r.Channels = []string{""} },
+	}
+	for name, mutate := range invalid {
+		r := valid()
+		mutate(r)
+
+		err := ValidateAlertRule(r)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		require.Len(t, errs.Errors, 1, "%s should report a single error", name)
+		assert.NotEmpty(t, errs.Errors[0].Summary, "%s should be explained", name)
+	}
+}
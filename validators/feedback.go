@@ -103,6 +103,7 @@ func ValidateFeedback(f *models.Feedback) error {
 	v := validator.New()
 	v.RegisterValidation("iscourse", IsCourse, false)
 	v.RegisterValidation("alphanumunicodetext", IsAsciiNumUnicodeText, false)
+	v.RegisterValidation("istag", IsTag, false)
 	err := v.Struct(f)
 
 	if err == nil {
@@ -127,6 +128,8 @@ func ValidateFeedback(f *models.Feedback) error {
 		case "iscourse":
 			xerr.Summary = "The course does not look like a valid course"
 			xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+		case "istag":
+			tagError(&xerr, ve)
 		default:
 			genericError(&xerr, ve)
 		}
@@ -136,3 +139,49 @@ func ValidateFeedback(f *models.Feedback) error {
 
 	return errs
 }
+
+// ValidateFeedbackFilter validates the feedback filter structure. It
+// returns an UXErrors containing all the errors that occurred during
+// validation or nil if no errors occurred.
+func ValidateFeedbackFilter(f *models.FeedbackFilter) error {
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	v.RegisterValidation("istag", IsTag, false)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+
+	if err := v.Struct(f); err != nil {
+		for _, ve := range err.(validator.ValidationErrors) {
+			xerr := uxerrors.New(err)
+
+			switch ve.Tag() {
+			case "iscourse":
+				xerr.Summary = "The course does not look like a valid course"
+				xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+			case "istag":
+				tagError(&xerr, ve)
+			case "alpha", "min", "max":
+				xerr.Summary = "The faculty does not look like a valid faculty"
+				xerr.Detail = fmt.Sprintf("The faculty you entered (%q) does not look like a valid faculty code, such as LINFO. Check the code and try again.", ve.Value())
+			case "oneof":
+				xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+				xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+			default:
+				genericError(&xerr, ve)
+			}
+
+			errs.Errors = append(errs.Errors, xerr)
+		}
+	}
+
+	if f.Since != nil && f.Until != nil && !f.Until.After(*f.Since) {
+		xerr := uxerrors.New(fmt.Errorf("until %s is not after since %s", f.Until, f.Since))
+		xerr.Summary = "The period is empty"
+		xerr.Detail = "The end of the period (until) should be after its start (since). Correct the period and try again."
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	if len(errs.Errors) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -2,6 +2,7 @@ package validators
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -50,8 +51,8 @@ func generateFeedback(fkr faker.Faker, mutator func(*models.Feedback, faker.Fake
 		ID:        fkr.UInt(),
 		Course:    generateCourse(fkr),
 		Feedback:  fkr.Lorem().Paragraph(3),
-		Upvotes:   fkr.UIntBetween(0, 2000),
-		Downvotes: fkr.UIntBetween(0, 2000),
+		Upvotes:   fkr.UInt64Between(0, 1<<40),
+		Downvotes: fkr.UInt64Between(0, 1<<40),
 	}
 	mutator(newFeedback, fkr)
 
@@ -250,42 +251,73 @@ func TestValidateFeedbackTooLongFeedback(t *testing.T) {
 	}
 }
 
-func TestValidateFeedbackTooBigUpvotes(t *testing.T) {
+// TestValidateFeedbackTags tests that feedback has at most 5 tags,
+// of lowercase words separated by hyphens.
+func TestValidateFeedbackTags(t *testing.T) {
+	f := &models.Feedback{Course: "LINFO1101", Feedback: "The exercise sessions are far too short for us."}
+
+	f.Tags = models.Tags{"schedule-conflict", "exam", "q4"}
+	assert.NoError(t, ValidateFeedback(f), "the tags should be valid")
+
+	for _, tag := range []string{"Exam", "schedule conflict", "-exam", "exam--session", "x", strings.Repeat("a", 31)} {
+		f.Tags = models.Tags{tag}
+		err := ValidateFeedback(f)
+		require.IsType(t, uxerrors.Errors{}, err, "the tag %q should not be valid", tag)
+		assert.Equal(t, "The tag does not look like a valid tag", err.(uxerrors.Errors).Errors[0].Summary)
+	}
+
+	f.Tags = models.Tags{"a1", "a2", "a3", "a4", "a5", "a6"}
+	err := ValidateFeedback(f)
+	require.IsType(t, uxerrors.Errors{}, err, "more than 5 tags should not be valid")
+	assert.Equal(t, "The Tags field has too many items", err.(uxerrors.Errors).Errors[0].Summary)
+}
+
+// TestValidateFeedbackLargeVotes tests that vote counters, which
+// are aggregates maintained by the server, are not bounded by
+// validation.
+func TestValidateFeedbackLargeVotes(t *testing.T) {
 	seed := time.Now().UnixMilli()
 	t.Logf("Current seed: %d\n", seed)
 
 	fkr := faker.NewWithSeed(rand.NewSource(seed))
 	mutator := func(f *models.Feedback, fkr faker.Faker) {
-		f.Upvotes = 2001
+		f.Upvotes = fkr.UInt64Between(2001, math.MaxInt64)
+		f.Downvotes = fkr.UInt64Between(2001, math.MaxInt64)
 	}
 	for i := 0; i < 10; i++ {
 		feedback := generateFeedback(fkr, mutator)
 		err := ValidateFeedback(feedback)
-		assert.Error(t, err, "the feedback generated contains an invalid upvotes and should not pass validation")
-		require.IsType(t, uxerrors.Errors{}, err, "the error returned should be of type uxerrors.Errors")
-		xerr := err.(uxerrors.Errors)
-		assert.Len(t, xerr.Errors, 1, "the error returned should contain only one error")
-		assert.Equal(t, "The Upvotes field is too high", xerr.Errors[0].Summary, "the error returned should indicate that the upvotes field is too high")
-		assert.Equal(t, "The Upvotes field is too high. It should be at most 2000, but was 2001. Decrease the value and try again.", xerr.Errors[0].Detail, "the error returned should indicate that the upvotes field is too high")
+		assert.NoError(t, err, "large vote counters should pass validation")
 	}
 }
 
-func TestValidateFeedbackTooBigDownvotes(t *testing.T) {
-	seed := time.Now().UnixMilli()
-	t.Logf("Current seed: %d\n", seed)
+// TestValidateFeedbackFilter tests that feedback is filtered on a
+// valid course, faculty and status, over a non-empty period.
+func TestValidateFeedbackFilter(t *testing.T) {
+	since := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 4, 0)
 
-	fkr := faker.NewWithSeed(rand.NewSource(seed))
-	mutator := func(f *models.Feedback, fkr faker.Faker) {
-		f.Downvotes = 2001
+	valid := []*models.FeedbackFilter{
+		{},
+		{Course: "linfo1101", Status: models.FeedbackStatusInProgress},
+		{Faculty: "LEPL", Since: &since, Until: &until},
 	}
-	for i := 0; i < 10; i++ {
-		feedback := generateFeedback(fkr, mutator)
-		err := ValidateFeedback(feedback)
-		assert.Error(t, err, "the feedback generated contains an invalid downvotes and should not pass validation")
-		require.IsType(t, uxerrors.Errors{}, err, "the error returned should be of type uxerrors.Errors")
-		xerr := err.(uxerrors.Errors)
-		assert.Len(t, xerr.Errors, 1, "the error returned should contain only one error")
-		assert.Equal(t, "The Downvotes field is too high", xerr.Errors[0].Summary, "the error returned should indicate that the downvotes field is too high")
-		assert.Equal(t, "The Downvotes field is too high. It should be at most 2000, but was 2001. Decrease the value and try again.", xerr.Errors[0].Detail, "the error returned should indicate that the downvotes field is too high")
+	for _, f := range valid {
+		assert.NoError(t, ValidateFeedbackFilter(f), "%+v should be valid", f)
+	}
+
+	invalid := map[string]*models.FeedbackFilter{
+		"bad course":     {Course: "LINFO"},
+		"bad faculty":    {Faculty: "LINFO1101"},
+		"unknown status": {Status: "closed"},
+		"empty period":   {Since: &until, Until: &since},
+	}
+	for name, f := range invalid {
+		err := ValidateFeedbackFilter(f)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
 	}
 }
@@ -0,0 +1,121 @@
+/**
+ * file: validators/follow.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The follow validator validates the follows of the
+ * anonymous voters and their push subscriptions.
+ */
+
+package validators
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateFollow validates the follow structure. It returns an
+// UXErrors containing all the errors that occurred during validation
+// or nil if no errors occurred.
+func ValidateFollow(f *models.Follow) error {
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+
+	targets := 0
+	for _, set := range []bool{f.FeedbackID != 0, f.Course != "", f.Faculty != "", f.Tag != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		xerr := uxerrors.New(fmt.Errorf("follow has %d targets", targets))
+		xerr.Summary = "The follow should have a single target"
+		xerr.Detail = "A follow is on either a feedback, a course, a faculty or a tag. Set exactly one of the FeedbackID, Course, Faculty and Tag fields and try again."
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	v.RegisterValidation("istag", IsTag, false)
+	if err := v.Struct(f); err != nil {
+		for _, ve := range err.(validator.ValidationErrors) {
+			xerr := uxerrors.New(err)
+
+			switch ve.Tag() {
+			case "alpha", "min", "max":
+				xerr.Summary = "The faculty does not look like a valid faculty"
+				xerr.Detail = fmt.Sprintf("The faculty you entered (%q) does not look like a valid faculty code, such as LINFO. Check the code and try again.", ve.Value())
+			case "iscourse":
+				xerr.Summary = "The course does not look like a valid course"
+				xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+			case "istag":
+				tagError(&xerr, ve)
+			default:
+				genericError(&xerr, ve)
+			}
+
+			errs.Errors = append(errs.Errors, xerr)
+		}
+	}
+
+	if len(errs.Errors) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidatePushSubscription validates the push subscription
+// structure. It returns an UXErrors containing all the errors that
+// occurred during validation or nil if no errors occurred.
+func ValidatePushSubscription(s *models.PushSubscription) error {
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+
+	if err := validator.New().Struct(s); err != nil {
+		for _, ve := range err.(validator.ValidationErrors) {
+			xerr := uxerrors.New(err)
+
+			switch ve.Tag() {
+			case "required":
+				requiredMissingError(&xerr, ve)
+			case "max":
+				maxError(&xerr, ve)
+			case "url", "startswith":
+				xerr.Summary = "The push endpoint is not a valid HTTPS URL"
+				xerr.Detail = fmt.Sprintf("The push endpoint (%q) is not a valid HTTPS URL. Send the subscription as given by the browser and try again.", ve.Value())
+			default:
+				genericError(&xerr, ve)
+			}
+
+			errs.Errors = append(errs.Errors, xerr)
+		}
+		return errs
+	}
+
+	keys := []struct {
+		name, value string
+		size        int
+	}{
+		{"p256dh", s.Keys.P256dh, 65},
+		{"auth", s.Keys.Auth, 16},
+	}
+	for _, k := range keys {
+		b, err := base64.RawURLEncoding.DecodeString(k.value)
+		if err == nil && len(b) == k.size {
+			continue
+		}
+
+		xerr := uxerrors.New(fmt.Errorf("invalid %s key", k.name))
+		xerr.Summary = fmt.Sprintf("The %s key is invalid", k.name)
+		xerr.Detail = fmt.Sprintf("The %s key should be %d bytes, encoded in base64url. Send the subscription as given by the browser and try again.", k.name, k.size)
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	if len(errs.Errors) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,77 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateFollow tests that follows have a single, valid,
+// target.
+func TestValidateFollow(t *testing.T) {
+	valid := []*models.Follow{
+		{FeedbackID: 1},
+		{Course: "LINFO1101"},
+		{Faculty: "LEPL"},
+		{Tag: "schedule-conflict"},
+	}
+	for _, f := range valid {
+		assert.NoError(t, ValidateFollow(f), "%+v should be valid", f)
+	}
+
+	invalid := map[string]*models.Follow{
+		"no target":      {},
+		"two targets":    {FeedbackID: 1, Course: "LINFO1101"},
+		"bad course":     {Course: "cooking"},
+		"bad faculty":    {Faculty: "L3PL"},
+		"bad tag":        {Tag: "Schedule Conflict"},
+		"tag and course": {Course: "LINFO1101", Tag: "exam"},
+	}
+	for name, f := range invalid {
+		err := ValidateFollow(f)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
+
+// TestValidatePushSubscription tests that push subscriptions need an
+// HTTPS endpoint, and keys of the right size.
+func TestValidatePushSubscription(t *testing.T) {
+	valid := func() *models.PushSubscription {
+		return &models.PushSubscription{
+			Endpoint: "https://push.example.org/send/abc",
+			Keys: models.PushKeys{
+				P256dh: "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+				Auth:   "BTBZMqHH6r4Tts7J_aSIgg",
+			},
+		}
+	}
+	assert.NoError(t, ValidatePushSubscription(valid()), "the subscription should be valid")
+
+	invalid := map[string]func(s *models.PushSubscription){
+		"no endpoint":   func(s *models.PushSubscription) { s.Endpoint = "" },
+		"http endpoint": func(s *models.PushSubscription) { s.Endpoint = "http://push.example.org/send/abc" },
+		"short p256dh":  func(s *models.PushSubscription) { s.Keys.P256dh = "BCVxsr7N" },
+		"bad auth":      func(s *models.PushSubscription) { s.Keys.Auth = "not base64!" },
+	}
+	for name, mutate := range invalid {
+		s := valid()
+		mutate(s)
+
+		err := ValidatePushSubscription(s)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
@@ -0,0 +1,67 @@
+/**
+ * file: validators/import.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The import validator validates the mappings of the
+ * columns of imported files.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateImportMapping validates the import mapping structure. It
+// returns an UXErrors containing all the errors that occurred during
+// validation or nil if no errors occurred.
+func ValidateImportMapping(m *models.ImportMapping) error {
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+
+	if err := v.Struct(m); err != nil {
+		for _, ve := range err.(validator.ValidationErrors) {
+			xerr := uxerrors.New(err)
+
+			switch ve.Tag() {
+			case "required":
+				requiredMissingError(&xerr, ve)
+			case "required_without":
+				xerr.Summary = "The course is not mapped"
+				xerr.Detail = "The mapping should give either the column of the courses (Course), or the course of all rows (DefaultCourse). Complete the mapping and try again."
+			case "iscourse":
+				xerr.Summary = "The course does not look like a valid course"
+				xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+			case "len":
+				xerr.Summary = "The delimiter is invalid"
+				xerr.Detail = fmt.Sprintf("The delimiter should be a single character, such as a comma or a semicolon, but was %q. Correct the delimiter and try again.", ve.Value())
+			default:
+				genericError(&xerr, ve)
+			}
+
+			errs.Errors = append(errs.Errors, xerr)
+		}
+	}
+
+	if m.TimeZone != "" {
+		if _, err := time.LoadLocation(m.TimeZone); err != nil {
+			xerr := uxerrors.New(err)
+			xerr.Summary = "The time zone is unknown"
+			xerr.Detail = fmt.Sprintf("The time zone you entered (%q) is unknown. Use a time zone such as Europe/Brussels and try again.", m.TimeZone)
+			errs.Errors = append(errs.Errors, xerr)
+		}
+	}
+
+	if len(errs.Errors) == 0 {
+		return nil
+	}
+	return errs
+}
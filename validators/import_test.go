@@ -0,0 +1,41 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateImportMapping tests that mappings give the columns of
+// the course and feedback, in a known time zone.
+func TestValidateImportMapping(t *testing.T) {
+	valid := []*models.ImportMapping{
+		&models.DefaultImportMapping,
+		{DefaultCourse: "linfo1101", Feedback: "Comments", Delimiter: ";"},
+		{Course: "Course", Feedback: "Comments", CreatedAt: "When", TimeFormat: "02/01/2006 15:04", TimeZone: "UTC"},
+	}
+	for _, m := range valid {
+		assert.NoError(t, ValidateImportMapping(m), "%+v should be valid", m)
+	}
+
+	invalid := map[string]*models.ImportMapping{
+		"no course":     {Feedback: "Comments"},
+		"no feedback":   {Course: "Course"},
+		"bad course":    {DefaultCourse: "LINFO", Feedback: "Comments"},
+		"bad delimiter": {Course: "Course", Feedback: "Comments", Delimiter: "||"},
+		"bad time zone": {Course: "Course", Feedback: "Comments", TimeZone: "Europe/Louvain-la-Neuve"},
+	}
+	for name, m := range invalid {
+		err := ValidateImportMapping(m)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
@@ -0,0 +1,82 @@
+/**
+ * file: validators/meeting.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The meeting validator validates the council meeting
+ * form, the notes on its agenda, and the outcomes
+ * recorded once it is held.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// meetingErrors converts the validation errors of a meeting form to
+// UXErrors.
+func meetingErrors(err error) uxerrors.Errors {
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range err.(validator.ValidationErrors) {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "min", "ge", "gt":
+			minError(&xerr, ve)
+		case "max", "le", "lt":
+			maxError(&xerr, ve)
+		case "oneof":
+			xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+			xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+		case "gtfield":
+			xerr.Summary = "The meeting ends before it starts"
+			xerr.Detail = "The end of the meeting (EndsAt) should be after its start (StartsAt). Correct the times and try again."
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
+
+// ValidateMeeting validates the meeting structure, along with the
+// course or faculty it is about. It returns an UXErrors containing
+// all the errors that occurred during validation or nil if no errors
+// occurred.
+func ValidateMeeting(m *models.Meeting) error {
+	if err := validator.New().Struct(m); err != nil {
+		return meetingErrors(err)
+	}
+
+	return ValidateStatsQuery(&models.StatsQuery{Scope: m.Scope, Code: m.Code})
+}
+
+// ValidateMeetingItem validates the notes on an item of the agenda
+// of a meeting.
+func ValidateMeetingItem(item *models.MeetingItem) error {
+	if err := validator.New().Struct(item); err != nil {
+		return meetingErrors(err)
+	}
+
+	return nil
+}
+
+// ValidateMeetingOutcome validates the outcome of an item of a
+// meeting.
+func ValidateMeetingOutcome(o *models.MeetingOutcome) error {
+	if err := validator.New().Struct(o); err != nil {
+		return meetingErrors(err)
+	}
+
+	return nil
+}
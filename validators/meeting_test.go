@@ -0,0 +1,63 @@
+package validators
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateMeeting tests that meetings need a body, a course or
+// faculty, and to end after they start.
+func TestValidateMeeting(t *testing.T) {
+	start := time.Date(2025, 3, 12, 14, 0, 0, 0, time.UTC)
+	valid := func() *models.Meeting {
+		return &models.Meeting{
+			Body:     "LINFO faculty council",
+			Scope:    models.StatsScopeFaculty,
+			Code:     "LINFO",
+			StartsAt: start,
+			EndsAt:   start.Add(2 * time.Hour),
+		}
+	}
+	assert.NoError(t, ValidateMeeting(valid()), "the meeting should be valid")
+
+	cases := map[string]func(*models.Meeting){
+		"missing body":   func(m *models.Meeting) { m.Body = "" },
+		"long body":      func(m *models.Meeting) { m.Body = strings.Repeat("a", 201) },
+		"unknown scope":  func(m *models.Meeting) { m.Scope = "campus" },
+		"invalid course": func(m *models.Meeting) { m.Scope, m.Code = models.StatsScopeCourse, "cooking" },
+		"ends early":     func(m *models.Meeting) { m.EndsAt = m.StartsAt },
+	}
+	for name, change := range cases {
+		m := valid()
+		change(m)
+
+		err := ValidateMeeting(m)
+		require.Error(t, err, "the meeting with a %s should not be valid", name)
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "only the %s should be reported", name)
+	}
+}
+
+// TestValidateMeetingOutcome tests that outcomes need a response and
+// a status reachable by the representatives.
+func TestValidateMeetingOutcome(t *testing.T) {
+	o := &models.MeetingOutcome{FeedbackID: 1, Status: models.FeedbackStatusResolved, Response: "The slides are now online."}
+	assert.NoError(t, ValidateMeetingOutcome(o), "the outcome should be valid")
+
+	o.Status = models.FeedbackStatusNew
+	o.Response = ""
+	err := ValidateMeetingOutcome(o)
+	require.Error(t, err, "new outcomes without a response should not be valid")
+	assert.Len(t, err.(uxerrors.Errors).Errors, 2, "the status and the response should be reported")
+
+	assert.Error(t, ValidateMeetingItem(&models.MeetingItem{Notes: strings.Repeat("a", 2001)}), "long notes should not be valid")
+}
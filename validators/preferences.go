@@ -0,0 +1,49 @@
+/**
+ * file: validators/preferences.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The preferences validator validates the
+ * notification preferences of representatives.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidatePreferences validates the preferences structure. It returns
+// an UXErrors containing all the errors that occurred during
+// validation or nil if no errors occurred.
+func ValidatePreferences(p *models.RepresentativePreferences) error {
+	err := validator.New().Struct(p)
+	if err == nil {
+		return nil
+	}
+
+	vErr := err.(validator.ValidationErrors)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range vErr {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "oneof":
+			xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+			xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
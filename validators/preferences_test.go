@@ -0,0 +1,46 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidatePreferences tests that preferences need a known
+// language, digest frequency and inbox events.
+func TestValidatePreferences(t *testing.T) {
+	valid := func() *models.RepresentativePreferences {
+		return &models.RepresentativePreferences{
+			Language:        "en",
+			DigestFrequency: models.DigestFrequencyDaily,
+			InboxEvents:     []models.NotificationKind{models.NotificationFeedback, models.NotificationAlert, models.NotificationStatusRequest},
+		}
+	}
+	assert.NoError(t, ValidatePreferences(valid()), "the preferences should be valid")
+
+	p := valid()
+	p.InboxEvents = nil
+	assert.NoError(t, ValidatePreferences(p), "all inbox events should be allowed by default")
+
+	invalid := map[string]func(p *models.RepresentativePreferences){
+		"bad language":  func(p *models.RepresentativePreferences) { p.Language = "nl" },
+		"bad frequency": func(p *models.RepresentativePreferences) { p.DigestFrequency = "hourly" },
+		"bad event":     func(p *models.RepresentativePreferences) { p.InboxEvents = append(p.InboxEvents, "vote") },
+	}
+	for name, mutate := range invalid {
+		p := valid()
+		mutate(p)
+
+		err := ValidatePreferences(p)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
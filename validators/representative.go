@@ -0,0 +1,64 @@
+/**
+ * file: validators/representative.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The representative validator validates the
+ * representative form.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateRepresentative validates the representative structure. It returns an
+// UXErrors containing all the errors that occurred during validation
+// or nil if no errors occurred.
+func ValidateRepresentative(r *models.Representative) error {
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	err := v.Struct(r)
+	if err == nil {
+		return nil
+	}
+
+	vErr := err.(validator.ValidationErrors)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range vErr {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "min", "ge", "gt":
+			minError(&xerr, ve)
+		case "max", "le", "lt":
+			maxError(&xerr, ve)
+		case "email":
+			xerr.Summary = "The email address is invalid"
+			xerr.Detail = fmt.Sprintf("The email address you entered (%q) is invalid. Check the address and try again.", ve.Value())
+		case "oneof":
+			xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+			xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+		case "alpha":
+			xerr.Summary = "The faculty does not look like a valid faculty"
+			xerr.Detail = fmt.Sprintf("The faculty you entered (%q) does not look like a valid faculty code, such as LINFO. Check the code and try again.", ve.Value())
+		case "iscourse":
+			xerr.Summary = "The course does not look like a valid course"
+			xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
@@ -0,0 +1,50 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateRepresentative tests that representatives need a name,
+// an email address, a known language and digest frequency, and
+// valid courses.
+func TestValidateRepresentative(t *testing.T) {
+	valid := func() *models.Representative {
+		return &models.Representative{
+			Name:            "Alex",
+			Email:           "alex@example.org",
+			Language:        "fr",
+			Courses:         []string{"LINFO1101"},
+			Faculties:       []string{"LEPL"},
+			DigestFrequency: models.DigestFrequencyWeekly,
+		}
+	}
+	assert.NoError(t, ValidateRepresentative(valid()), "the representative should be valid")
+
+	invalid := map[string]func(r *models.Representative){
+		"no name":       func(r *models.Representative) { r.Name = "" },
+		"bad email":     func(r *models.Representative) { r.Email = "alex" },
+		"bad language":  func(r *models.Representative) { r.Language = "nl" },
+		"bad course":    func(r *models.Representative) { r.Courses = append(r.Courses, "cooking") },
+		"bad faculty":   func(r *models.Representative) { r.Faculties = []string{"L3PL"} },
+		"bad frequency": func(r *models.Representative) { r.DigestFrequency = "hourly" },
+		"no frequency":  func(r *models.Representative) { r.DigestFrequency = "" },
+	}
+	for name, mutate := range invalid {
+		r := valid()
+		mutate(r)
+
+		err := ValidateRepresentative(r)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
@@ -0,0 +1,49 @@
+/**
+ * file: validators/session.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The session validator validates the live session
+ * form.
+ */
+
+package validators
+
+import (
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateSession validates the session structure. It returns an
+// UXErrors containing all the errors that occurred during validation
+// or nil if no errors occurred.
+func ValidateSession(s *models.Session) error {
+	err := validator.New().Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	vErr := err.(validator.ValidationErrors)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range vErr {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "min", "ge", "gt":
+			minError(&xerr, ve)
+		case "max", "le", "lt":
+			maxError(&xerr, ve)
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
@@ -0,0 +1,28 @@
+package validators
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateSession tests that sessions need a title of at most
+// 200 characters.
+func TestValidateSession(t *testing.T) {
+	assert.NoError(t, ValidateSession(&models.Session{Title: "LINFO1101 - week 5"}), "a titled session should be valid")
+
+	for _, title := range []string{"", strings.Repeat("a", 201)} {
+		err := ValidateSession(&models.Session{Title: title})
+		require.Error(t, err, "the title %q should not be valid", title)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "only the title should be reported")
+	}
+}
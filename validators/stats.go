@@ -0,0 +1,76 @@
+/**
+ * file: validators/stats.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The stats validator validates the queries of
+ * feedback statistics.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateStatsQuery validates the statistics query structure. It
+// returns an UXErrors containing all the errors that occurred during
+// validation or nil if no errors occurred.
+func ValidateStatsQuery(q *models.StatsQuery) error {
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+
+	if err := v.Struct(q); err != nil {
+		for _, ve := range err.(validator.ValidationErrors) {
+			xerr := uxerrors.New(err)
+
+			switch ve.Tag() {
+			case "required":
+				requiredMissingError(&xerr, ve)
+			case "oneof":
+				xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+				xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+			default:
+				genericError(&xerr, ve)
+			}
+
+			errs.Errors = append(errs.Errors, xerr)
+		}
+		return errs
+	}
+
+	switch q.Scope {
+	case models.StatsScopeCourse:
+		if err := v.Var(q.Code, "iscourse"); err != nil {
+			xerr := uxerrors.New(err)
+			xerr.Summary = "The course does not look like a valid course"
+			xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", q.Code)
+			errs.Errors = append(errs.Errors, xerr)
+		}
+	case models.StatsScopeFaculty:
+		if err := v.Var(q.Code, "alpha,min=2,max=6"); err != nil {
+			xerr := uxerrors.New(err)
+			xerr.Summary = "The faculty does not look like a valid faculty"
+			xerr.Detail = fmt.Sprintf("The faculty you entered (%q) does not look like a valid faculty code, such as LINFO. Check the code and try again.", q.Code)
+			errs.Errors = append(errs.Errors, xerr)
+		}
+	}
+
+	if q.Since != nil && q.Until != nil && !q.Until.After(*q.Since) {
+		xerr := uxerrors.New(fmt.Errorf("until %s is not after since %s", q.Until, q.Since))
+		xerr.Summary = "The period is empty"
+		xerr.Detail = "The end of the period (until) should be after its start (since). Correct the period and try again."
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	if len(errs.Errors) == 0 {
+		return nil
+	}
+	return errs
+}
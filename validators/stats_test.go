@@ -0,0 +1,44 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateStatsQuery tests that statistics are queried on a
+// valid course or faculty, over a non-empty period.
+func TestValidateStatsQuery(t *testing.T) {
+	since := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 4, 0)
+
+	valid := []*models.StatsQuery{
+		{Scope: models.StatsScopeCourse, Code: "LINFO1101"},
+		{Scope: models.StatsScopeFaculty, Code: "linfo", Since: &since},
+		{Scope: models.StatsScopeFaculty, Code: "LEPL", Since: &since, Until: &until},
+	}
+	for _, q := range valid {
+		assert.NoError(t, ValidateStatsQuery(q), "%+v should be valid", q)
+	}
+
+	invalid := map[string]*models.StatsQuery{
+		"unknown scope": {Scope: "tag", Code: "exam"},
+		"bad course":    {Scope: models.StatsScopeCourse, Code: "LINFO"},
+		"bad faculty":   {Scope: models.StatsScopeFaculty, Code: "LINFO1101"},
+		"empty period":  {Scope: models.StatsScopeCourse, Code: "LINFO1101", Since: &until, Until: &since},
+	}
+	for name, q := range invalid {
+		err := ValidateStatsQuery(q)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
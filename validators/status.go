@@ -0,0 +1,51 @@
+/**
+ * file: validators/status.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The status validator validates the status change
+ * requests of the anonymous voters.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateStatusRequest validates the status request structure. It
+// returns an UXErrors containing all the errors that occurred during
+// validation or nil if no errors occurred.
+func ValidateStatusRequest(s *models.StatusRequest) error {
+	err := validator.New().Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	vErr := err.(validator.ValidationErrors)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range vErr {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "oneof":
+			xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+			xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+		case "max":
+			maxError(&xerr, ve)
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
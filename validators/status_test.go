@@ -0,0 +1,34 @@
+package validators
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateStatusRequest tests that status requests need a known
+// status, and a reason of reasonable length.
+func TestValidateStatusRequest(t *testing.T) {
+	assert.NoError(t, ValidateStatusRequest(&models.StatusRequest{Status: models.FeedbackStatusAcknowledged}), "the reason should be optional")
+	assert.NoError(t, ValidateStatusRequest(&models.StatusRequest{Status: models.FeedbackStatusResolved, Reason: "The slides are online now."}))
+
+	invalid := map[string]*models.StatusRequest{
+		"no status":       {Reason: "Please look at this."},
+		"bad status":      {Status: "closed"},
+		"too long reason": {Status: models.FeedbackStatusResolved, Reason: strings.Repeat("a", 501)},
+	}
+	for name, s := range invalid {
+		err := ValidateStatusRequest(s)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
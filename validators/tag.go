@@ -0,0 +1,42 @@
+/**
+ * file: validators/tag.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The tag validator validates the tags of the
+ * feedback form.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"regexp"
+
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	minTagLength int = 2
+	maxTagLength int = 30
+)
+
+var (
+	tagPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// IsTag validates that a field is a tag: lowercase letters and
+// digits, in words separated by single hyphens, such as
+// schedule-conflict.
+func IsTag(fl validator.FieldLevel) bool {
+	tag := fl.Field().String()
+	return len(tag) >= minTagLength && len(tag) <= maxTagLength && tagPattern.MatchString(tag)
+}
+
+// tagError sets the summary and detail fields of the error to
+// indicate that a tag is invalid.
+func tagError(xerr *uxerrors.Error, err validator.FieldError) {
+	xerr.Summary = "The tag does not look like a valid tag"
+	xerr.Detail = fmt.Sprintf("The tag you entered (%q) is not a valid tag. Tags are %d to %d lowercase letters or digits, in words separated by hyphens, such as schedule-conflict. Correct the tag and try again.", err.Value(), minTagLength, maxTagLength)
+}
@@ -0,0 +1,61 @@
+/**
+ * file: validators/timeline.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The timeline validator validates the queries of
+ * vote timelines.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxTimelineBuckets is the maximum number of buckets of a timeline.
+const maxTimelineBuckets int64 = 1000
+
+// ValidateTimelineQuery validates the timeline query structure. It
+// returns an UXErrors containing all the errors that occurred during
+// validation or nil if no errors occurred.
+func ValidateTimelineQuery(q *models.TimelineQuery) error {
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+
+	if err := validator.New().Struct(q); err != nil {
+		for _, ve := range err.(validator.ValidationErrors) {
+			xerr := uxerrors.New(err)
+
+			switch ve.Tag() {
+			case "required":
+				requiredMissingError(&xerr, ve)
+			case "oneof":
+				xerr.Summary = fmt.Sprintf("The %s field has an unknown value", ve.Field())
+				xerr.Detail = fmt.Sprintf("The %s field should be one of %s, but was %q. Correct the field and try again.", ve.Field(), ve.Param(), ve.Value())
+			case "gtfield":
+				xerr.Summary = "The period is empty"
+				xerr.Detail = "The end of the period (until) should be after its start (since). Correct the period and try again."
+			default:
+				genericError(&xerr, ve)
+			}
+
+			errs.Errors = append(errs.Errors, xerr)
+		}
+		return errs
+	}
+
+	if buckets := int64(q.Until.Sub(q.Since) / q.Interval.Duration()); buckets > maxTimelineBuckets {
+		xerr := uxerrors.New(fmt.Errorf("timeline of %d buckets", buckets))
+		xerr.Summary = "The period is too long"
+		xerr.Detail = fmt.Sprintf("A timeline has at most %d buckets, but the period spans %d %ss. Shorten the period or use a longer interval and try again.", maxTimelineBuckets, buckets, q.Interval)
+		errs.Errors = append(errs.Errors, xerr)
+		return errs
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateTimelineQuery tests that timelines are queried with a
+// known interval, over a non-empty and bounded period.
+func TestValidateTimelineQuery(t *testing.T) {
+	since := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+
+	valid := []*models.TimelineQuery{
+		{FeedbackID: 1, Interval: models.TimelineHour, Since: since, Until: since.Add(time.Hour)},
+		{FeedbackID: 1, Interval: models.TimelineDay, Since: since, Until: since.AddDate(0, 4, 0)},
+		{FeedbackID: 1, Interval: models.TimelineWeek, Since: since, Until: since.AddDate(10, 0, 0)},
+	}
+	for _, q := range valid {
+		assert.NoError(t, ValidateTimelineQuery(q), "%+v should be valid", q)
+	}
+
+	invalid := map[string]*models.TimelineQuery{
+		"no feedback":      {Interval: models.TimelineDay, Since: since, Until: since.AddDate(0, 0, 1)},
+		"unknown interval": {FeedbackID: 1, Interval: "minute", Since: since, Until: since.Add(time.Hour)},
+		"empty period":     {FeedbackID: 1, Interval: models.TimelineDay, Since: since, Until: since},
+		"too long":         {FeedbackID: 1, Interval: models.TimelineHour, Since: since, Until: since.AddDate(0, 4, 0)},
+	}
+	for name, q := range invalid {
+		err := ValidateTimelineQuery(q)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}
@@ -46,6 +46,11 @@ func minError(xerr *uxerrors.Error, err validator.FieldError) {
 	case reflect.TypeFor[int](), reflect.TypeFor[uint]():
 		summary = "is too small"
 		detail = fmt.Sprintf("It should be at least %s, but was %d. Increase the value and try again.", err.Param(), err.Value())
+	default:
+		if err.Kind() == reflect.Slice {
+			summary = "has too few items"
+			detail = fmt.Sprintf("It should have at least %s items, but had %d. Add items and try again.", err.Param(), reflect.ValueOf(err.Value()).Len())
+		}
 	}
 
 	xerr.Summary = fmt.Sprintf("The %s field %s", err.Field(), summary)
@@ -65,6 +70,11 @@ func maxError(xerr *uxerrors.Error, err validator.FieldError) {
 	case reflect.TypeFor[int](), reflect.TypeFor[uint]():
 		summary = "is too high"
 		detail = fmt.Sprintf("It should be at most %s, but was %d. Decrease the value and try again.", err.Param(), err.Value())
+	default:
+		if err.Kind() == reflect.Slice {
+			summary = "has too many items"
+			detail = fmt.Sprintf("It should have at most %s items, but had %d. Remove items and try again.", err.Param(), reflect.ValueOf(err.Value()).Len())
+		}
 	}
 
 	xerr.Summary = fmt.Sprintf("The %s field %s", err.Field(), summary)
@@ -0,0 +1,61 @@
+/**
+ * file: validators/webhook.go
+ * author: theo technicguy
+ * license: apache-2.0
+ *
+ * The webhook validator validates the webhook
+ * subscription form.
+ */
+
+package validators
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidateWebhook validates the webhook structure. It returns an
+// UXErrors containing all the errors that occurred during validation
+// or nil if no errors occurred.
+func ValidateWebhook(w *models.Webhook) error {
+	v := validator.New()
+	v.RegisterValidation("iscourse", IsCourse, false)
+	err := v.Struct(w)
+	if err == nil {
+		return nil
+	}
+
+	vErr := err.(validator.ValidationErrors)
+	errs := uxerrors.Errors{Status: http.StatusBadRequest}
+	for _, ve := range vErr {
+		xerr := uxerrors.New(err)
+
+		switch ve.Tag() {
+		case "required":
+			requiredMissingError(&xerr, ve)
+		case "min", "ge", "gt":
+			minError(&xerr, ve)
+		case "max", "le", "lt":
+			maxError(&xerr, ve)
+		case "http_url":
+			xerr.Summary = "The URL is not a valid HTTP URL"
+			xerr.Detail = fmt.Sprintf("The URL you entered (%q) is not a valid HTTP or HTTPS URL. Check the URL and try again.", ve.Value())
+		case "oneof":
+			xerr.Summary = "The event type is unknown"
+			xerr.Detail = fmt.Sprintf("The event type %q is unknown. It must be one of %s. Check the event types and try again.", ve.Value(), ve.Param())
+		case "iscourse":
+			xerr.Summary = "The course does not look like a valid course"
+			xerr.Detail = fmt.Sprintf("The course you entered (%q) does not look like a valid course code. Check the code and try again.", ve.Value())
+		default:
+			genericError(&xerr, ve)
+		}
+
+		errs.Errors = append(errs.Errors, xerr)
+	}
+
+	return errs
+}
@@ -0,0 +1,45 @@
+package validators
+
+import (
+	"net/http"
+	"testing"
+
+	"git.licolas.net/delegit/delegit/models"
+	"git.licolas.net/delegit/delegit/uxerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateWebhook tests that webhooks need an HTTP URL, known
+// event types, a valid course and a long enough secret.
+func TestValidateWebhook(t *testing.T) {
+	valid := func() *models.Webhook {
+		return &models.Webhook{
+			URL:    "https://hooks.example.org/delegit",
+			Events: []models.ChangeKind{models.ChangeKindCreate, models.ChangeKindVote},
+			Course: "LINFO1101",
+			Secret: "0123456789abcdef",
+		}
+	}
+	assert.NoError(t, ValidateWebhook(valid()), "the webhook should be valid")
+
+	invalid := map[string]func(w *models.Webhook){
+		"no url":        func(w *models.Webhook) { w.URL = "" },
+		"ftp url":       func(w *models.Webhook) { w.URL = "ftp://example.org/" },
+		"unknown event": func(w *models.Webhook) { w.Events = append(w.Events, "exploded") },
+		"bad course":    func(w *models.Webhook) { w.Course = "cooking" },
+		"short secret":  func(w *models.Webhook) { w.Secret = "hunter2" },
+	}
+	for name, mutate := range invalid {
+		w := valid()
+		mutate(w)
+
+		err := ValidateWebhook(w)
+		require.Error(t, err, "%s should not be valid", name)
+
+		errs, ok := err.(uxerrors.Errors)
+		require.True(t, ok, "the error should be UXErrors")
+		assert.Equal(t, http.StatusBadRequest, errs.Status)
+		assert.Len(t, errs.Errors, 1, "%s should report a single error", name)
+	}
+}